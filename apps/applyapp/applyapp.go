@@ -1,468 +1,290 @@
 package applyapp
 
 import (
+	"context"
 	"fmt"
 	"os"
-	"strings"
 
-	"github.com/charmbracelet/huh/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/log"
 	"github.com/demingongo/ecx/aws"
+	"github.com/demingongo/ecx/bubbles/progressmodel"
 	"github.com/demingongo/ecx/globals"
+	"github.com/demingongo/ecx/project"
 	"github.com/spf13/viper"
-	"gopkg.in/yaml.v2"
+	"golang.org/x/term"
 )
 
-type LogGroup struct {
-	Group     string `yaml:"group"`
-	Retention int    `yaml:"retention"`
-}
+type applier struct {
+	logger *log.Logger
+	client aws.Client
+	config *project.Config
+	state  *project.State
+	refs   *project.Refs
 
-type FlowRule struct {
-	Listener    string `yaml:"listener"`
-	Priority    int    `yaml:"priority"`
-	TargetGroup string `yaml:"targetGroup"`
-	Value       string `yaml:"value"`
-}
+	// templated resource files
+	renderer *project.Renderer
 
-type Flow struct {
-	Name                          string     `yaml:"name"`
-	Service                       string     `yaml:"service"`
-	TargetGroup                   string     `yaml:"targetGroup"`
-	HealthCheckGracePeriodSeconds int        `yaml:"healthCheckGracePeriodSeconds"`
-	Rules                         []FlowRule `yaml:"rules"`
-}
+	// resources created by the run
+	journal *journal
 
-type LoadBalancer struct {
-	Key   string `yaml:"key"`
-	Value string `yaml:"value"`
+	// done on ctrl+c
+	ctx context.Context
 }
 
-type Rule struct {
-	Priority    int    `yaml:"priority"`
-	TargetGroup string `yaml:"targetGroup"`
-	Value       string `yaml:"value"`
+func (a *applier) targetGroupRef(key string) aws.TargetGroup {
+	v, _ := a.refs.Get(project.KindTargetGroup, key)
+	targetGroup, _ := v.(aws.TargetGroup)
+	return targetGroup
 }
 
-type Listener struct {
-	Key          string `yaml:"key"`
-	Value        string `yaml:"value"`
-	LoadBalancer string `yaml:"loadBalancer"`
-	TargetGroup  string `yaml:"targetGroup"`
-	Rules        []Rule `yaml:"rules"`
+func (a *applier) listenerRef(key string) aws.Listener {
+	v, _ := a.refs.Get(project.KindListener, key)
+	listener, _ := v.(aws.Listener)
+	return listener
 }
 
-type TargetGroup struct {
-	Key   string `yaml:"key"`
-	Value string `yaml:"value"`
+func (a *applier) flowRef(id string) project.FlowRef {
+	v, _ := a.refs.Get(project.KindFlow, id)
+	flow, _ := v.(project.FlowRef)
+	return flow
 }
 
-type Config struct {
-	Api             string         `yaml:"api"`
-	ApiVersion      string         `yaml:"apiVersion"`
-	LogGroups       []LogGroup     `yaml:"logGroups"`
-	TaskDefinitions []string       `yaml:"taskDefinitions"`
-	Flows           []Flow         `yaml:"flows"`
-	LoadBalancers   []LoadBalancer `yaml:"loadBalancers"`
-	Listeners       []Listener     `yaml:"listeners"`
-	TargetGroups    []TargetGroup  `yaml:"targetGroups"`
+// ref resolves {{ ref "key" }} in templates
+// and the "ref:" values of the resource files.
+func (a *applier) ref(key string) (string, error) {
+	return a.refs.Resolve("ref:" + key)
 }
 
-func (c *Config) loadConfig() *Config {
+var kindLabels = map[string]string{
+	project.KindTargetGroup:    "target group",
+	project.KindLoadBalancer:   "load balancer",
+	project.KindListener:       "listener",
+	project.KindLogGroup:       "log group",
+	project.KindTaskDefinition: "task definition",
+	project.KindFlow:           "flow",
+}
 
-	yamlFile, err := os.ReadFile("ecx.yaml")
-	if err != nil {
-		globals.Logger.Fatalf("%v", err)
-	}
-	err = yaml.Unmarshal(yamlFile, c)
-	if err != nil {
-		globals.Logger.Fatalf("Unmarshal: %v", err)
+// apply applies the resource of the node
+// and returns its status.
+func (a *applier) apply(n *project.Node) (string, error) {
+	switch n.Kind {
+	case project.KindTargetGroup:
+		return a.applyTargetGroup(a.config.TargetGroups[n.Index])
+	case project.KindLoadBalancer:
+		return a.applyLoadBalancer(a.config.LoadBalancers[n.Index])
+	case project.KindListener:
+		return a.applyListener(a.config.Listeners[n.Index])
+	case project.KindLogGroup:
+		return a.applyLogGroup(a.config.LogGroups[n.Index])
+	case project.KindTaskDefinition:
+		return a.applyTaskDefinition(a.config.TaskDefinitions[n.Index])
+	case project.KindFlow:
+		return a.applyFlow(a.config.Flows[n.Index])
 	}
-
-	return c
+	return "", fmt.Errorf("unknown resource kind \"%s\"", n.Kind)
 }
 
-type ConfigRefs struct {
-	LoadBalancers map[string]aws.LoadBalancer
-	Listeners     map[string]aws.Listener
-	TargetGroups  map[string]aws.TargetGroup
-}
+func Run(client aws.Client) {
+	logger := globals.Logger
 
-var (
-	config Config
+	logger.Debugf("ecx apply %s", viper.GetString("project"))
 
-	validApi        = "ecx"
-	validApiVersion = "0.1"
-)
+	config, err := project.Open(viper.GetString("project"))
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
 
-func createConfigRefs() ConfigRefs {
-	var configRefs ConfigRefs
-	configRefs.LoadBalancers = make(map[string]aws.LoadBalancer)
-	configRefs.Listeners = make(map[string]aws.Listener)
-	configRefs.TargetGroups = make(map[string]aws.TargetGroup)
-	return configRefs
-}
+	logger.Debug(*config)
+	if config.Env != "" {
+		logger.Debugf("env %s", config.Env)
+	}
+	for _, name := range config.VarNames() {
+		logger.Debugf("var.%s = %s", name, config.Vars[name])
+	}
 
-func Run() {
-	logger := globals.Logger
+	if err := config.Check(); err != nil {
+		logger.Fatal(err)
+	}
 
-	if viper.GetString("project") != "" {
-		err := os.Chdir(viper.GetString("project"))
-		if err != nil {
-			logger.Fatalf("project: %v", err)
-		}
+	// resource graph
+	// (cycles and dangling refs are reported before any aws call)
+	graph, err := project.BuildGraph(config)
+	if err != nil {
+		logger.Fatalf("%s:\n%v", project.FileName, err)
+	}
+	nodes, err := graph.Sort()
+	if err != nil {
+		logger.Fatalf("%s: %v", project.FileName, err)
+	}
+	for _, n := range nodes {
+		logger.Debugf("%s => %v", n.Id(), n.Deps)
 	}
 
-	logger.Debugf("ecx apply %s", viper.GetString("project"))
+	// --target, --only, --exclude
+	// (the dependencies of the selection are looked up, not applied)
+	selector := project.SelectorFromFlags()
+	selected, err := graph.Select(selector)
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+	dependencies := graph.Dependencies(selected)
+	if !selector.IsEmpty() {
+		var subset []*project.Node
+		for _, n := range nodes {
+			if selected[n.Id()] || dependencies[n.Id()] {
+				subset = append(subset, n)
+			}
+		}
+		nodes = subset
+		if len(nodes) == 0 {
+			logger.Fatalf("no resource selected")
+		}
+	}
 
-	config.loadConfig()
+	// no other apply or destroy until the end of the run
+	unlock, err := project.LockProject(client, config, "apply")
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+	exit := func(code int) {
+		if err := unlock(); err != nil {
+			logger.Errorf("%v", err)
+		}
+		os.Exit(code)
+	}
+	fatalf := func(format string, args ...any) {
+		logger.Errorf(format, args...)
+		exit(1)
+	}
 
-	logger.Debug(config)
+	// resources created by previous runs
+	state, err := project.LoadState(config.Env)
+	if err != nil {
+		fatalf("%v", err)
+	}
 
-	if config.Api != validApi {
-		logger.Fatalf("Value for \"%s\" is not valid. Expected \"%s\".", "api", validApi)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	a := &applier{
+		ctx:     ctx,
+		logger:  logger,
+		client:  client,
+		config:  config,
+		state:   state,
+		refs:    project.NewRefs(),
+		journal: &journal{},
 	}
-	if config.ApiVersion != "0.1" {
-		logger.Fatalf("Value for \"%s\" is not valid. Expected \"%s\".", "apiVersion", validApiVersion)
+	a.renderer = project.NewRenderer(config, a.ref)
+
+	// hooks: preApply of the project
+	if err := a.runHooks(config.Hooks, project.HookPreApply, a.hookEnv(), os.Stdout); err != nil {
+		a.projectFailure(err)
+		fatalf("%v", err)
 	}
 
-	// references
-	refs := createConfigRefs()
-
-	// @TODO targetGroups
-	if len(config.TargetGroups) > 0 {
-		logger.Debugf("TargetGroups: %v", config.TargetGroups)
-		for _, targetGroup := range config.TargetGroups {
-			if targetGroup.Value != "" {
-				var (
-					err  error
-					resp aws.TargetGroup
-				)
-				// get name from file
-				content := viper.New()
-				content.SetConfigFile(targetGroup.Value)
-				err = content.ReadInConfig()
-				if err != nil {
-					logger.Fatalf("checking target group %s: %v", targetGroup.Key, err)
-				}
-				name := content.GetString("Name")
-				if name != "" {
-					found := false
-					_ = spinner.New().Type(spinner.Pulse).
-						Title(fmt.Sprintf(" DescribeTargetGroupsWithNames %s", name)).
-						Action(func() {
-							// do not handle error below as aws cli
-							// returns error if one name is not found
-							results, _ := aws.DescribeTargetGroupsWithNames([]string{name})
-							if len(results) > 0 {
-								if targetGroup.Key != "" {
-									refs.TargetGroups[targetGroup.Key] = results[0]
-								}
-								found = true
-							}
-						}).
-						Run()
-					if found {
-						logger.Infof("target group named \"%s\" already exists", name)
-						continue
-					}
-				}
-
-				_ = spinner.New().Type(spinner.MiniDot).
-					Title(fmt.Sprintf(" target group: %s", targetGroup.Key)).
-					Action(func() {
-						// create target group
-						resp, err = aws.CreateTargetGroup(targetGroup.Value)
-					}).
-					Run()
-				if err != nil {
-					logger.Fatalf("CreateTargetGroup: %v", err)
-				}
-				if targetGroup.Key != "" && resp.TargetGroupArn != "" {
-					refs.TargetGroups[targetGroup.Key] = resp
-				}
-				fmt.Printf("target group: %s\n", targetGroup.Key)
-			}
-		}
+	rows := make([]progressmodel.Row, 0, len(nodes))
+	for _, n := range nodes {
+		rows = append(rows, progressmodel.Row{
+			Id:    n.Id(),
+			Title: fmt.Sprintf("%s: %s", kindLabels[n.Kind], n.Key),
+		})
 	}
 
-	// @TODO loadBalancers
-	if len(config.LoadBalancers) > 0 {
-		logger.Debugf("LoadBalancers: %v", config.LoadBalancers)
-		for _, loadBalancer := range config.LoadBalancers {
-			if loadBalancer.Value != "" {
-				var (
-					err  error
-					resp aws.LoadBalancer
-				)
-				// get name from file
-				content := viper.New()
-				content.SetConfigFile(loadBalancer.Value)
-				err = content.ReadInConfig()
-				if err != nil {
-					logger.Fatalf("checking load balancer %s: %v", loadBalancer.Key, err)
-				}
-				name := content.GetString("Name")
-				if name != "" {
-					found := false
-					_ = spinner.New().Type(spinner.Pulse).
-						Title(fmt.Sprintf(" DescribeLoadBalancersWithNames %s", name)).
-						Action(func() {
-							// do not handle error below as aws cli
-							// returns error if one name is not found
-							results, _ := aws.DescribeLoadBalancersWithNames([]string{name})
-							if len(results) > 0 {
-								if loadBalancer.Key != "" {
-									refs.LoadBalancers[loadBalancer.Key] = results[0]
-								}
-								found = true
-							}
-						}).
-						Run()
-					if found {
-						logger.Infof("load balancer named \"%s\" already exists", name)
-						continue
-					}
-				}
-
-				_ = spinner.New().Type(spinner.MiniDot).
-					Title(fmt.Sprintf(" load balancer: %s", loadBalancer.Key)).
-					Action(func() {
-						// create load balancer
-						resp, err = aws.CreateLoadBalancer(loadBalancer.Value)
-					}).
-					Run()
-				if err != nil {
-					logger.Fatalf("CreateLoadBalancer: %v", err)
-				}
-				if loadBalancer.Key != "" && resp.LoadBalancerArn != "" {
-					refs.LoadBalancers[loadBalancer.Key] = resp
-				}
-				fmt.Printf("load balancer: %s\n", loadBalancer.Key)
+	program := tea.NewProgram(progressmodel.NewModel(" ecx apply ", rows))
+
+	var results []nodeResult
+	scheduled := make(chan struct{})
+	go func() {
+		results, err = schedule(ctx, nodes, viper.GetInt("parallelism"), func(n *project.Node) (string, error) {
+			if dependencies[n.Id()] {
+				return a.lookupNode(n)
+			}
+			return a.applyNode(n)
+		}, program.Send)
+		close(scheduled)
+	}()
+
+	if _, uiErr := program.Run(); uiErr != nil {
+		// no progress view (e.g. no tty)
+		<-scheduled
+		for _, r := range results {
+			if r.status != "" {
+				fmt.Printf("%s: %s (%s)\n", kindLabels[r.node.Kind], r.node.Key, r.status)
+			} else if r.err == nil {
+				fmt.Printf("%s: %s\n", kindLabels[r.node.Kind], r.node.Key)
 			}
 		}
 	}
+	// interrupted (ctrl+c): wait for the running resources
+	cancel()
+	<-scheduled
 
-	// @TODO listeners
-	if len(config.Listeners) > 0 {
-		logger.Debugf("Listeners: %v", config.Listeners)
-		for _, listener := range config.Listeners {
-			if listener.Value != "" {
-				var (
-					err     error
-					resp    aws.Listener
-					loading *spinner.Spinner
-				)
-				loading = spinner.New().Type(spinner.MiniDot).
-					Title(fmt.Sprintf(" listener: %s", listener.Key)).
-					Action(func() {
-						var (
-							lbArn string
-							tgArn string
-						)
-						if listener.LoadBalancer != "" {
-							if strings.HasPrefix(listener.LoadBalancer, "ref:") {
-								key := listener.LoadBalancer[4:]
-								lbArn = refs.LoadBalancers[key].LoadBalancerArn
-								if lbArn == "" {
-									logger.Fatalf("ecx - could not find load balancer reference \"%s\"", key)
-								}
-							} else {
-								lbArn = listener.LoadBalancer
-							}
-						}
-						if listener.TargetGroup != "" {
-							if strings.HasPrefix(listener.TargetGroup, "ref:") {
-								key := listener.TargetGroup[4:]
-								tgArn = refs.TargetGroups[key].TargetGroupArn
-								if tgArn == "" {
-									logger.Fatalf("ecx - could not find target group reference \"%s\"", key)
-								}
-							} else {
-								tgArn = listener.TargetGroup
-							}
-						}
-						// create listener
-						resp, err = aws.CreateListener(listener.Value, lbArn, tgArn)
-						if err != nil {
-							return
-						}
-						// create rules
-						for _, rule := range listener.Rules {
-							var ruleDestination string
-							if strings.HasPrefix(rule.TargetGroup, "ref:") {
-								key := rule.TargetGroup[4:]
-								ruleDestination = refs.TargetGroups[key].TargetGroupArn
-								if ruleDestination == "" {
-									err = fmt.Errorf("ecx - could not find target group reference \"%s\"", key)
-									break
-								}
-							} else {
-								ruleDestination = rule.TargetGroup
-							}
-							loading.Title(fmt.Sprintf(" listener: %s - rule: %s", listener.Key, rule.Value))
-							// create rule
-							_, err = aws.CreateRule2(rule.Value, ruleDestination, rule.Priority, resp.ListenerArn)
-							if err != nil {
-								break
-							}
-						}
-					})
-				_ = loading.Run()
-				if err != nil {
-					logger.Fatalf("CreateListener: %v", err)
-				}
-				if listener.Key != "" && resp.ListenerArn != "" {
-					refs.Listeners[listener.Key] = resp
-				}
-				fmt.Printf("listener: %s\n", listener.Key)
-			}
-		}
+	if dir := a.renderer.Dir(); dir != "" && viper.GetBool("keep-rendered") {
+		logger.Infof("rendered files kept in %s", dir)
+	}
+	if cleanErr := a.renderer.Close(); cleanErr != nil {
+		logger.Warnf("%v", cleanErr)
 	}
 
-	// logGroups
-	if len(config.LogGroups) > 0 {
-		var err error
-		for _, logGroup := range config.LogGroups {
-			_ = spinner.New().Type(spinner.MiniDot).
-				Title(fmt.Sprintf(" log group: %s", logGroup.Group)).
-				Action(func() {
-					// create log group
-					aws.CreateLogGroup(logGroup.Group)
-					if logGroup.Retention > 0 {
-						// put retention policy in number of days
-						_, err = aws.PutRetentionPolicy(logGroup.Group, logGroup.Retention)
-					}
-				}).
-				Run()
-			if err != nil {
-				logger.Fatalf("CreateLogGroup: %v", err)
-			}
-			fmt.Printf("log group: %s\n", logGroup.Group)
+	// outputs (ecx output)
+	// (not evaluated when some resources were not applied)
+	var outputs map[string]string
+	if err == nil && len(config.Outputs) > 0 && !selector.IsEmpty() {
+		logger.Info("outputs are not updated by a targeted apply")
+	} else if err == nil && len(config.Outputs) > 0 {
+		outputs, err = project.EvalOutputs(config.Outputs, a.refs)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		state.Outputs = outputs
+		if err = state.Save(); err != nil {
+			fatalf("%v", err)
+		}
+		fmt.Println("Outputs:")
+		for _, name := range project.OutputNames(outputs) {
+			fmt.Printf("  %s = %s\n", name, outputs[name])
 		}
 	}
 
-	// taskDefinitions
-	if len(config.TaskDefinitions) > 0 {
-		var err error
-		for _, taskDefinitionFile := range config.TaskDefinitions {
-			_ = spinner.New().Type(spinner.MiniDot).
-				Title(fmt.Sprintf(" task definition: %s", taskDefinitionFile)).
-				Action(func() {
-					// create new revision for task definition
-					_, err = aws.RegisterTaskDefinition(fmt.Sprintf("file://%s", taskDefinitionFile))
-				}).
-				Run()
-			if err != nil {
-				logger.Fatalf("RegisterTaskDefinition: %v", err)
-			}
-			fmt.Printf("task definition: %s\n", taskDefinitionFile)
+	// hooks: postApply of the project
+	// (with the outputs as ECX_OUTPUT_<name>)
+	if err == nil && len(config.Hooks.PostApply) > 0 {
+		env := a.hookEnv()
+		for _, name := range project.OutputNames(outputs) {
+			env = append(env, project.EnvName("ECX_OUTPUT", name)+"="+outputs[name])
 		}
+		err = a.runHooks(config.Hooks, project.HookPostApply, env, os.Stdout)
 	}
 
-	// flows
-	if len(config.Flows) > 0 {
-		var err error
-		for _, flow := range config.Flows {
-			_ = spinner.New().Type(spinner.MiniDot).
-				Title(fmt.Sprintf(" flow: %v", flow)).
-				Action(func() {
-					// @TODO create target group, rules and/or service
-
-					var (
-						targetGroup   aws.TargetGroup
-						containerName string
-						containerPort int
-					)
-
-					// create target group
-					if flow.TargetGroup != "" {
-						if strings.HasPrefix(flow.TargetGroup, "ref:") {
-							key := flow.TargetGroup[4:]
-							targetGroup = refs.TargetGroups[key]
-							if targetGroup.TargetGroupArn == "" {
-								err = fmt.Errorf("ecx - could not find target group reference \"%s\"", key)
-								return
-							}
-						} else {
-							targetGroup, err = aws.CreateTargetGroup(flow.TargetGroup)
-						}
-					}
-					if err != nil {
-						return
-					}
-
-					// create rules
-					if targetGroup.TargetGroupArn != "" && len(flow.Rules) > 0 {
-						for _, rule := range flow.Rules {
-							var listenerArn string = rule.Listener
-							if strings.HasPrefix(listenerArn, "ref:") {
-								key := listenerArn[4:]
-								listenerArn = refs.Listeners[key].ListenerArn
-								if listenerArn == "" {
-									err = fmt.Errorf("ecx - could not find listener reference \"%s\"", key)
-									break
-								}
-							}
-							_, err = aws.CreateRule2(rule.Value, targetGroup.TargetGroupArn, rule.Priority, listenerArn)
-							if err != nil {
-								break
-							}
-						}
-					}
-					if err != nil {
-						return
-					}
-
-					// create service
-					if flow.Service != "" {
-						// get port mapping named "http"
-						// or the first port mapping
-						if targetGroup.TargetGroupArn != "" {
-							var containers []aws.ContainerPortMapping
-							serviceConf := viper.New()
-							serviceConf.SetConfigFile(flow.Service)
-							err = serviceConf.ReadInConfig()
-							if err != nil {
-								return
-							}
-							serviceName := serviceConf.GetString("serviceName")
-							taskDefinition := serviceConf.GetString("taskDefinition")
-
-							logger.Debugf("serviceName %s", serviceName)
-							logger.Debugf("taskDefinition %s", taskDefinition)
-
-							containers, err = aws.ListPortMapping(taskDefinition)
-							if err != nil {
-								return
-							}
-
-							for i, container := range containers {
-								if container.PortMapping.Name == "http" {
-									containerName = container.Name
-									containerPort = container.PortMapping.ContainerPort
-									break
-								}
-								if i == 0 {
-									containerName = container.Name
-									containerPort = container.PortMapping.ContainerPort
-								}
-							}
-						}
-
-						_, err = aws.CreateService(flow.Service, aws.ServiceLoadBalancer{
-							TargetGroupArn: targetGroup.TargetGroupArn,
-							ContainerName:  containerName,
-							ContainerPort:  containerPort,
-						}, flow.HealthCheckGracePeriodSeconds)
-					}
-				}).
-				Run()
-			if err != nil {
-				logger.Fatalf("flow: %v", err)
-			}
-			fmt.Printf("flow: %v\n", flow)
+	created := a.journal.created()
+	if err != nil {
+		a.projectFailure(err)
+		if len(created) == 0 {
+			fatalf("%v", err)
+		}
+		logger.Errorf("%v", err)
+		rollBack := viper.GetBool("rollback-on-failure")
+		if !rollBack && term.IsTerminal(int(os.Stdin.Fd())) {
+			rollBack = runFormRollback(created)
 		}
+		if !rollBack {
+			fmt.Print(report(created, nil, nil))
+			exit(1)
+		}
+		rolledBack, leftInPlace := rollback(client, state, created, func(r project.StateResource) {
+			fmt.Printf("rolling back %s: %s\n", r.Kind, r.Key)
+		})
+		fmt.Print(report(created, rolledBack, leftInPlace))
+		exit(1)
 	}
 
+	if len(created) > 0 {
+		fmt.Print(report(created, nil, nil))
+	}
+	if err := unlock(); err != nil {
+		logger.Errorf("%v", err)
+	}
 	fmt.Println("Done")
 }
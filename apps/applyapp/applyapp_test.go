@@ -0,0 +1,217 @@
+package applyapp
+
+import (
+	"flag"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/demingongo/ecx/aws"
+	"github.com/demingongo/ecx/globals"
+	"github.com/demingongo/ecx/project"
+	"github.com/spf13/viper"
+)
+
+var update = flag.Bool("update", false, "record the cassettes of testdata again (with a fake account)")
+
+// cassette returns the client of a test: with -update, a Recorder
+// of fake saving into dir, the Replayer of dir otherwise.
+// The calls are checked against the cassette at the end of the test.
+func cassette(t *testing.T, dir string, fake aws.Client) aws.Client {
+	t.Helper()
+	if *update {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatal(err)
+		}
+		recorder, err := aws.NewRecorder(dir, fake)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return recorder
+	}
+	replayer, err := aws.NewReplayer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := replayer.Check(); err != nil {
+			t.Errorf("%s:\n%v", dir, err)
+		}
+	})
+	return replayer
+}
+
+// operations returns the operations of the cassette in dir, in order.
+func operations(t *testing.T, dir string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result []string
+	for _, file := range files {
+		_, operation, _ := strings.Cut(strings.TrimSuffix(filepath.Base(file), ".json"), "-")
+		result = append(result, operation)
+	}
+	return result
+}
+
+// copyProject copies a project into a temporary directory
+// (apply writes ecx.state.json next to ecx.yaml).
+func copyProject(t *testing.T, src string) string {
+	t.Helper()
+	dir := t.TempDir()
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dir, strings.TrimPrefix(path, src))
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, content, 0644)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// setup copies testdata/project for a run of apply
+// and returns the absolute path of testdata.
+func setup(t *testing.T) string {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// absolute: the project is opened from another directory
+	testdata, err := filepath.Abs("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := copyProject(t, filepath.Join(testdata, "project"))
+	t.Cleanup(func() {
+		viper.Reset()
+		_ = os.Chdir(wd)
+	})
+	viper.Set("project", dir)
+	// one resource at a time: the calls are made in the order of the cassette
+	viper.Set("parallelism", 1)
+	viper.Set("wait-timeout", time.Minute)
+	globals.LoadGlobals()
+	return testdata
+}
+
+func TestRun(t *testing.T) {
+	testdata := setup(t)
+
+	// the account of both applies
+	fake := aws.NewFake()
+
+	t.Run("create", func(t *testing.T) {
+		Run(cassette(t, filepath.Join(testdata, "create"), fake))
+
+		want := []string{
+			"DescribeTargetGroupsWithNames", "CreateTargetGroup",
+			"DescribeLoadBalancersWithNames", "CreateLoadBalancer",
+			"CreateLogGroup", "PutRetentionPolicy", "DescribeLogGroups",
+			"DescribeListeners", "CreateListener",
+			"RegisterTaskDefinition",
+			"DescribeRules", "CreateRule2",
+			"DescribeTaskDefinition", "DescribeServices", "CreateService", "DescribeServiceStability",
+		}
+		if got := operations(t, filepath.Join(testdata, "create")); !slices.Equal(got, want) {
+			t.Errorf("operations:\n got %v\nwant %v", got, want)
+		}
+		checkState(t, wantState)
+	})
+
+	// nothing changed: the resources of the state are only described
+	t.Run("unchanged", func(t *testing.T) {
+		Run(cassette(t, filepath.Join(testdata, "unchanged"), fake))
+
+		want := []string{"PutRetentionPolicy", "DescribeLogGroups", "DescribeTaskDefinition"}
+		if got := operations(t, filepath.Join(testdata, "unchanged")); !slices.Equal(got, want) {
+			t.Errorf("operations:\n got %v\nwant %v", got, want)
+		}
+		checkState(t, wantState)
+	})
+}
+
+// A log group that already exists is adopted: it is
+// not reported as created and destroy leaves it in place.
+func TestRunExistingLogGroup(t *testing.T) {
+	testdata := setup(t)
+	fake, err := aws.NewFakeFromFixture(aws.Fixture{
+		LogGroups: []map[string]any{{"logGroupName": "/ecs/app"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Run(cassette(t, filepath.Join(testdata, "existing-log-group"), fake))
+
+	state, err := project.LoadState("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, ok := state.Get(project.KindLogGroup, "/ecs/app"); !ok || !r.Adopted {
+		t.Errorf("log group %+v: not adopted", r)
+	}
+	for _, r := range state.Destroyable() {
+		if r.Kind == project.KindLogGroup {
+			t.Errorf("log group %s: destroyable", r.Key)
+		}
+	}
+}
+
+// wantState is ecx.state.json after the apply of testdata/project.
+var wantState = []project.StateResource{
+	{Kind: project.KindTargetGroup, Key: "tg-app", Arn: "arn:aws:elasticloadbalancing:us-west-2:123456789012:targetgroup/app-tg/4bcc3fb0875767a6"},
+	{Kind: project.KindLoadBalancer, Key: "alb", Arn: "arn:aws:elasticloadbalancing:us-west-2:123456789012:loadbalancer/app/app-alb/0dd5be4602463aa6"},
+	{Kind: project.KindLogGroup, Key: "/ecs/app"},
+	{Kind: project.KindListener, Key: "http-alb", Arn: "arn:aws:elasticloadbalancing:us-west-2:123456789012:listener/app/app-alb/0dd5be4602463aa6/31b9829a1024cd70"},
+	{Kind: project.KindTaskDefinition, Key: "td-web", Arn: "arn:aws:ecs:us-west-2:123456789012:task-definition/web:1"},
+	{Kind: project.KindRule, Key: "app-flow/rules/0", Arn: "arn:aws:elasticloadbalancing:us-west-2:123456789012:listener-rule/app/app-alb/0dd5be4602463aa6/31b9829a1024cd70/f55ac34710b31f7d"},
+	{Kind: project.KindService, Key: "app-flow", Arn: "arn:aws:ecs:us-west-2:123456789012:service/app-cluster/app"},
+}
+
+// wantOutputs are the outputs of testdata/project.
+var wantOutputs = map[string]string{
+	"dns":     "app-alb-819145830.us-west-2.elb.amazonaws.com",
+	"service": "app",
+	"td":      "arn:aws:ecs:us-west-2:123456789012:task-definition/web:1",
+}
+
+// checkState compares the kinds, keys and arns of ecx.state.json
+// (in the current directory) and its outputs to the expected ones.
+func checkState(t *testing.T, want []project.StateResource) {
+	t.Helper()
+	state, err := project.LoadState("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Resources) != len(want) {
+		t.Errorf("%d resources, want %d", len(state.Resources), len(want))
+	}
+	for _, w := range want {
+		if r, ok := state.Get(w.Kind, w.Key); !ok {
+			t.Errorf("%s %s: not in the state", w.Kind, w.Key)
+		} else if r.Arn != w.Arn {
+			t.Errorf("%s %s: arn %q, want %q", w.Kind, w.Key, r.Arn, w.Arn)
+		}
+	}
+	if !maps.Equal(state.Outputs, wantOutputs) {
+		t.Errorf("outputs:\n got %v\nwant %v", state.Outputs, wantOutputs)
+	}
+}
@@ -0,0 +1,45 @@
+package applyapp
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/huh"
+	formmmodel "github.com/demingongo/ecx/bubbles/formmodel"
+	"github.com/demingongo/ecx/globals"
+	"github.com/demingongo/ecx/project"
+)
+
+func generateFormRollback(created []project.StateResource) *huh.Form {
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewConfirm().
+				Key("confirm").
+				Title(fmt.Sprintf("Apply failed. Roll back the %d resource(s) created by this run?", len(created))).
+				Negative("Leave them").
+				Affirmative("Roll back").
+				Inline(true),
+		),
+	).
+		WithTheme(globals.Theme).
+		WithWidth(globals.FormWidth)
+
+	return form
+}
+
+// runFormRollback asks whether the resources created
+// by the run should be rolled back.
+func runFormRollback(created []project.StateResource) bool {
+	form := generateFormRollback(created)
+	fModel := formmmodel.NewModel(formmmodel.ModelConfig{
+		Form:         form,
+		InfoBubble:   report(created, nil, nil),
+		VerticalMode: true,
+	}).Width(globals.Width)
+
+	if _, err := tea.NewProgram(&fModel).Run(); err != nil {
+		return false
+	}
+
+	return form.State == huh.StateCompleted && form.GetBool("confirm")
+}
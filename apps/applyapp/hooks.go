@@ -0,0 +1,146 @@
+package applyapp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/demingongo/ecx/project"
+	"github.com/spf13/viper"
+)
+
+// runHooks runs the commands of a hook one after the other
+// and stops at the first one that fails. The output of the
+// commands is written to out (else to the debug logs).
+func (a *applier) runHooks(hooks project.Hooks, hook string, env []string, out io.Writer) error {
+	for _, command := range hooks.Commands(hook) {
+		var buf bytes.Buffer
+		cmd := exec.Command("sh", "-c", command)
+		cmd.Env = append(os.Environ(), env...)
+		cmd.Env = append(cmd.Env, "ECX_HOOK="+hook)
+		if out != nil {
+			cmd.Stdout = out
+			cmd.Stderr = out
+		} else {
+			cmd.Stdout = &buf
+			cmd.Stderr = &buf
+		}
+		err := cmd.Run()
+		if err != nil {
+			output := strings.TrimSpace(buf.String())
+			if output != "" {
+				return fmt.Errorf("%s hook \"%s\": %v\n%s", hook, command, err, output)
+			}
+			return fmt.Errorf("%s hook \"%s\": %v", hook, command, err)
+		}
+		if output := strings.TrimSpace(buf.String()); output != "" {
+			a.logger.Debugf("%s hook \"%s\":\n%s", hook, command, output)
+		}
+	}
+	return nil
+}
+
+// hookEnv returns the environment variables given to every hook:
+// ECX_ENV, ECX_DUMMY, ECX_VAR_<name> and ECX_REF_<key>
+// (the arns of the resources applied so far).
+func (a *applier) hookEnv() []string {
+	env := []string{
+		"ECX_ENV=" + a.config.Env,
+		fmt.Sprintf("ECX_DUMMY=%t", viper.GetBool("dummy")),
+	}
+	for _, name := range a.config.VarNames() {
+		env = append(env, project.EnvName("ECX_VAR", name)+"="+a.config.Vars[name])
+	}
+
+	refs := a.refs.Arns()
+	keys := make([]string, 0, len(refs))
+	for key := range refs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if refs[key] != "" {
+			env = append(env, project.EnvName("ECX_REF", key)+"="+refs[key])
+		}
+	}
+	return env
+}
+
+// nodeHooks returns the hooks of a flow or a listener.
+func (a *applier) nodeHooks(n *project.Node) project.Hooks {
+	switch n.Kind {
+	case project.KindListener:
+		return a.config.Listeners[n.Index].Hooks
+	case project.KindFlow:
+		return a.config.Flows[n.Index].Hooks
+	}
+	return project.Hooks{}
+}
+
+// nodeEnv returns the environment variables of the hooks of a node:
+// ECX_KIND, ECX_KEY, ECX_ARN and, for a flow, ECX_SERVICE_ARN,
+// ECX_SERVICE_NAME, ECX_CLUSTER and ECX_TARGET_GROUP_ARN.
+func (a *applier) nodeEnv(n *project.Node) []string {
+	env := append(a.hookEnv(), "ECX_KIND="+n.Kind, "ECX_KEY="+n.Key)
+	switch n.Kind {
+	case project.KindListener:
+		if key := a.config.Listeners[n.Index].Key; key != "" {
+			env = append(env, "ECX_ARN="+a.listenerRef(key).ListenerArn)
+		}
+	case project.KindFlow:
+		ref := a.flowRef(a.config.Flows[n.Index].Id())
+		arn := ref.ServiceArn
+		if arn == "" {
+			arn = ref.TargetGroupArn
+		}
+		env = append(env,
+			"ECX_ARN="+arn,
+			"ECX_SERVICE_ARN="+ref.ServiceArn,
+			"ECX_SERVICE_NAME="+ref.ServiceName,
+			"ECX_CLUSTER="+ref.Cluster,
+			"ECX_TARGET_GROUP_ARN="+ref.TargetGroupArn,
+		)
+	}
+	return env
+}
+
+// applyNode applies a node between its preApply and postApply hooks.
+// The onFailure hooks are run when the node or one of its hooks failed.
+func (a *applier) applyNode(n *project.Node) (string, error) {
+	hooks := a.nodeHooks(n)
+	if hooks.IsEmpty() {
+		return a.apply(n)
+	}
+	var status string
+	err := a.runHooks(hooks, project.HookPreApply, a.nodeEnv(n), nil)
+	if err == nil {
+		status, err = a.apply(n)
+	}
+	if err == nil {
+		err = a.runHooks(hooks, project.HookPostApply, a.nodeEnv(n), nil)
+	}
+	if err != nil && len(hooks.OnFailure) > 0 {
+		env := append(a.nodeEnv(n), "ECX_ERROR="+err.Error())
+		if hookErr := a.runHooks(hooks, project.HookOnFailure, env, nil); hookErr != nil {
+			err = errors.Join(err, hookErr)
+		}
+	}
+	return status, err
+}
+
+// projectFailure runs the onFailure hooks of the project
+// (ECX_ERROR is the error of the apply).
+func (a *applier) projectFailure(err error) {
+	if len(a.config.Hooks.OnFailure) == 0 {
+		return
+	}
+	env := append(a.hookEnv(), "ECX_ERROR="+err.Error())
+	if hookErr := a.runHooks(a.config.Hooks, project.HookOnFailure, env, os.Stdout); hookErr != nil {
+		a.logger.Errorf("%v", hookErr)
+	}
+}
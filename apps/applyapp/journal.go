@@ -0,0 +1,107 @@
+package applyapp
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/demingongo/ecx/aws"
+	"github.com/demingongo/ecx/project"
+)
+
+// journal is the list of the resources created by the run
+// (in creation order) so they can be rolled back on failure.
+type journal struct {
+	mu      sync.Mutex
+	entries []project.StateResource
+}
+
+func (j *journal) add(r project.StateResource) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.entries = append(j.entries, r)
+}
+
+// created returns the resources created by the run.
+func (j *journal) created() []project.StateResource {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]project.StateResource{}, j.entries...)
+}
+
+// created records a resource created by the run
+// in the state and in the journal.
+func (a *applier) created(r project.StateResource) {
+	a.state.Put(r)
+	a.journal.add(r)
+}
+
+// rollbackEntry is a resource of the journal after rollback.
+type rollbackEntry struct {
+	resource project.StateResource
+	err      error // why it was left in place
+}
+
+// rollback deletes the resources created by the run in reverse order
+// (dependents were created after their dependencies).
+// Task definition revisions are not deleted.
+func rollback(client aws.Client, state *project.State, created []project.StateResource, progress func(project.StateResource)) (rolledBack []project.StateResource, leftInPlace []rollbackEntry) {
+	for i := len(created) - 1; i >= 0; i-- {
+		r := created[i]
+		if r.Kind == project.KindTaskDefinition {
+			leftInPlace = append(leftInPlace, rollbackEntry{resource: r, err: fmt.Errorf("revisions are not deleted")})
+			continue
+		}
+		if progress != nil {
+			progress(r)
+		}
+		if err := project.DeleteResource(client, r); err != nil {
+			leftInPlace = append(leftInPlace, rollbackEntry{resource: r, err: err})
+			continue
+		}
+		state.Remove(r.Kind, r.Key)
+		if err := state.Save(); err != nil {
+			leftInPlace = append(leftInPlace, rollbackEntry{resource: r, err: err})
+			continue
+		}
+		rolledBack = append(rolledBack, r)
+	}
+	return rolledBack, leftInPlace
+}
+
+var (
+	reportTitleStyle = lipgloss.NewStyle().Bold(true)
+	createdStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("2"))
+	rolledBackStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("3"))
+	leftStyle        = lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+	reasonStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+)
+
+// report lists what the run created, rolled back and left in place.
+func report(created []project.StateResource, rolledBack []project.StateResource, leftInPlace []rollbackEntry) string {
+	var b strings.Builder
+	line := func(style lipgloss.Style, prefix string, r project.StateResource) string {
+		return style.Render(fmt.Sprintf("  %s %s: %s", prefix, r.Kind, r.Key))
+	}
+	fmt.Fprintf(&b, "%s\n", reportTitleStyle.Render(fmt.Sprintf("Created (%d):", len(created))))
+	for _, r := range created {
+		b.WriteString(line(createdStyle, "+", r) + "\n")
+	}
+	if rolledBack == nil && leftInPlace == nil {
+		return b.String()
+	}
+	fmt.Fprintf(&b, "%s\n", reportTitleStyle.Render(fmt.Sprintf("Rolled back (%d):", len(rolledBack))))
+	for _, r := range rolledBack {
+		b.WriteString(line(rolledBackStyle, "-", r) + "\n")
+	}
+	fmt.Fprintf(&b, "%s\n", reportTitleStyle.Render(fmt.Sprintf("Left in place (%d):", len(leftInPlace))))
+	for _, e := range leftInPlace {
+		b.WriteString(line(leftStyle, "!", e.resource))
+		if e.err != nil {
+			b.WriteString(reasonStyle.Render(fmt.Sprintf(" (%v)", e.err)))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,203 @@
+package applyapp
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/demingongo/ecx/aws"
+	"github.com/demingongo/ecx/project"
+)
+
+// status of the dependencies that are not selected
+// (--target, --only, --exclude)
+const statusLookedUp = "looked up"
+
+// lookupNode resolves the refs of a resource that is not selected
+// without creating or modifying it.
+func (a *applier) lookupNode(n *project.Node) (string, error) {
+	switch n.Kind {
+	case project.KindTargetGroup:
+		return a.lookupTargetGroup(a.config.TargetGroups[n.Index])
+	case project.KindLoadBalancer:
+		return a.lookupLoadBalancer(a.config.LoadBalancers[n.Index])
+	case project.KindListener:
+		return a.lookupListener(a.config.Listeners[n.Index])
+	case project.KindLogGroup:
+		return a.lookupLogGroup(a.config.LogGroups[n.Index])
+	case project.KindTaskDefinition:
+		return a.lookupTaskDefinition(a.config.TaskDefinitions[n.Index])
+	case project.KindFlow:
+		return a.lookupFlow(a.config.Flows[n.Index])
+	}
+	return "", fmt.Errorf("unknown resource kind \"%s\"", n.Kind)
+}
+
+// resourceName returns the "Name" of a resource file.
+func resourceName(filepath string) (string, error) {
+	content, err := project.ReadResourceFile(filepath)
+	if err != nil {
+		return "", err
+	}
+	return content.GetString("Name"), nil
+}
+
+func (a *applier) lookupTargetGroup(targetGroup project.TargetGroup) (string, error) {
+	if targetGroup.Key == "" || targetGroup.Value == "" {
+		return statusLookedUp, nil
+	}
+	filepath, err := a.renderer.Render(targetGroup.Value)
+	if err != nil {
+		return "", err
+	}
+	if r, ok := a.state.Get(project.KindTargetGroup, targetGroup.Id()); ok && r.Arn != "" {
+		a.refs.Set(project.KindTargetGroup, targetGroup.Key, aws.TargetGroup{
+			TargetGroupArn:  r.Arn,
+			TargetGroupName: r.Attributes["name"],
+		}, filepath)
+		return statusLookedUp, nil
+	}
+	name, err := resourceName(filepath)
+	if err != nil {
+		return "", err
+	}
+	if name != "" {
+		results, err := a.client.DescribeTargetGroupsWithNames([]string{name})
+		if err != nil && !errors.Is(err, aws.ErrNotFound) {
+			return "", err
+		}
+		if len(results) > 0 {
+			a.refs.Set(project.KindTargetGroup, targetGroup.Key, results[0], filepath)
+			return statusLookedUp, nil
+		}
+	}
+	return "", fmt.Errorf("target group \"%s\" does not exist (it is not selected)", targetGroup.Id())
+}
+
+func (a *applier) lookupLoadBalancer(loadBalancer project.LoadBalancer) (string, error) {
+	if loadBalancer.Key == "" || loadBalancer.Value == "" {
+		return statusLookedUp, nil
+	}
+	filepath, err := a.renderer.Render(loadBalancer.Value)
+	if err != nil {
+		return "", err
+	}
+	name := ""
+	if r, ok := a.state.Get(project.KindLoadBalancer, loadBalancer.Id()); ok && r.Arn != "" {
+		name = r.Attributes["name"]
+		if r.Attributes["dnsName"] != "" || name == "" {
+			a.refs.Set(project.KindLoadBalancer, loadBalancer.Key, aws.LoadBalancer{
+				LoadBalancerArn:  r.Arn,
+				LoadBalancerName: name,
+				Type:             r.Attributes["type"],
+				DNSName:          r.Attributes["dnsName"],
+				VpcId:            r.Attributes["vpcId"],
+				Scheme:           r.Attributes["scheme"],
+			}, filepath)
+			return statusLookedUp, nil
+		}
+	}
+	if name == "" {
+		if name, err = resourceName(filepath); err != nil {
+			return "", err
+		}
+	}
+	if name != "" {
+		results, err := a.client.DescribeLoadBalancersWithNames([]string{name})
+		if err != nil && !errors.Is(err, aws.ErrNotFound) {
+			return "", err
+		}
+		if len(results) > 0 {
+			a.refs.Set(project.KindLoadBalancer, loadBalancer.Key, results[0], filepath)
+			return statusLookedUp, nil
+		}
+	}
+	return "", fmt.Errorf("load balancer \"%s\" does not exist (it is not selected)", loadBalancer.Id())
+}
+
+func (a *applier) lookupListener(listener project.Listener) (string, error) {
+	if listener.Key == "" || listener.Value == "" {
+		return statusLookedUp, nil
+	}
+	filepath, err := a.renderer.Render(listener.Value)
+	if err != nil {
+		return "", err
+	}
+	if r, ok := a.state.Get(project.KindListener, listener.Id()); ok && r.Arn != "" {
+		a.refs.Set(project.KindListener, listener.Key, aws.Listener{ListenerArn: r.Arn}, filepath)
+		return statusLookedUp, nil
+	}
+	lbArn, err := a.loadBalancerArn(listener.LoadBalancer)
+	if err != nil {
+		return "", err
+	}
+	existing, err := project.FindListener(a.client, lbArn, filepath)
+	if err != nil {
+		return "", err
+	}
+	if existing.ListenerArn == "" {
+		return "", fmt.Errorf("listener \"%s\" does not exist (it is not selected)", listener.Id())
+	}
+	a.refs.Set(project.KindListener, listener.Key, aws.Listener{ListenerArn: existing.ListenerArn}, filepath)
+	return statusLookedUp, nil
+}
+
+func (a *applier) lookupLogGroup(logGroup project.LogGroup) (string, error) {
+	if err := a.setLogGroupRef(logGroup); err != nil {
+		return "", err
+	}
+	return statusLookedUp, nil
+}
+
+func (a *applier) lookupTaskDefinition(taskDefinition project.TaskDefinition) (string, error) {
+	filepath, err := a.renderer.Render(taskDefinition.Value)
+	if err != nil {
+		return "", err
+	}
+	if r, ok := a.state.Get(project.KindTaskDefinition, taskDefinition.Id()); ok && r.Arn != "" {
+		a.setTaskDefinitionRef(taskDefinition, r.Arn, filepath)
+		return statusLookedUp, nil
+	}
+	// latest revision of the family
+	var content struct {
+		Family string `json:"family"`
+	}
+	if err = project.ReadJSON(filepath, &content); err != nil {
+		return "", err
+	}
+	td, err := a.client.DescribeTaskDefinition(content.Family)
+	if err != nil && !errors.Is(err, aws.ErrNotFound) {
+		return "", err
+	}
+	if err != nil || td.TaskDefinitionArn == "" {
+		return "", fmt.Errorf("task definition \"%s\" does not exist (it is not selected)", taskDefinition.Id())
+	}
+	a.setTaskDefinitionRef(taskDefinition, td.TaskDefinitionArn, filepath)
+	return statusLookedUp, nil
+}
+
+func (a *applier) lookupFlow(flow project.Flow) (string, error) {
+	var ref project.FlowRef
+	if _, ok := project.IsRef(flow.TargetGroup); ok {
+		arn, err := a.targetGroupArn(flow.TargetGroup)
+		if err != nil {
+			return "", err
+		}
+		ref.TargetGroupArn = arn
+	} else if r, ok := a.state.Get(project.KindTargetGroup, flow.Id()+"/targetGroup"); ok {
+		ref.TargetGroupArn = r.Arn
+	}
+	if r, ok := a.state.Get(project.KindService, flow.Id()); ok {
+		ref.ServiceArn = r.Arn
+		ref.ServiceName = r.Attributes["name"]
+		ref.Cluster = r.Attributes["cluster"]
+	}
+	serviceFile := ""
+	if flow.Service != "" {
+		var err error
+		if serviceFile, err = a.renderer.Render(flow.Service); err != nil {
+			return "", err
+		}
+	}
+	a.refs.Set(project.KindFlow, flow.Id(), ref, serviceFile)
+	return statusLookedUp, nil
+}
@@ -0,0 +1,612 @@
+package applyapp
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/demingongo/ecx/aws"
+	"github.com/demingongo/ecx/project"
+)
+
+// statuses returned by the apply functions
+// (empty when the resource was created)
+const (
+	statusExists   = "already exists"
+	statusUpToDate = "up to date"
+	statusUpdated  = "updated"
+	statusSkipped  = "skipped"
+	statusAdopted  = "updated existing"
+)
+
+func (a *applier) targetGroupArn(value string) (string, error) {
+	return a.refs.Resolve(value, project.KindTargetGroup)
+}
+
+func (a *applier) loadBalancerArn(value string) (string, error) {
+	return a.refs.Resolve(value, project.KindLoadBalancer)
+}
+
+func (a *applier) listenerArn(value string) (string, error) {
+	return a.refs.Resolve(value, project.KindListener)
+}
+
+// applyRule creates the rule or, if it was already created
+// by a previous run, modifies it when it changed.
+// A rule of the listener with the same priority (or conditions)
+// is modified in place.
+func (a *applier) applyRule(id string, value string, targetGroupArn string, priority int, listenerArn string) error {
+	filepath, err := a.renderer.Render(value)
+	if err != nil {
+		return err
+	}
+	hash, err := project.Hash(filepath, targetGroupArn, fmt.Sprint(priority), listenerArn)
+	if err != nil {
+		return err
+	}
+	if r, ok := a.state.Get(project.KindRule, id); ok && r.Arn != "" {
+		if r.Hash == hash {
+			return nil
+		}
+		if _, err = a.client.ModifyRule(r.Arn, filepath, targetGroupArn); err != nil {
+			return err
+		}
+		if priority > 0 {
+			if _, err = a.client.SetRulePriority(r.Arn, priority); err != nil {
+				return err
+			}
+		}
+		r.File = value
+		r.Hash = hash
+		a.state.Put(r)
+		return a.state.Save()
+	}
+
+	existing, err := project.FindRule(a.client, listenerArn, filepath, priority)
+	if err != nil {
+		return err
+	}
+	if existing.RuleArn != "" {
+		if _, err = a.client.ModifyRule(existing.RuleArn, filepath, targetGroupArn); err != nil {
+			return err
+		}
+		// found by conditions
+		rulePriority, err := project.RulePriority(filepath, priority)
+		if err != nil {
+			return err
+		}
+		if rulePriority > 0 && existing.Priority != fmt.Sprint(rulePriority) {
+			if _, err = a.client.SetRulePriority(existing.RuleArn, rulePriority); err != nil {
+				return err
+			}
+		}
+		a.state.Put(project.StateResource{
+			Kind:    project.KindRule,
+			Key:     id,
+			File:    value,
+			Arn:     existing.RuleArn,
+			Hash:    hash,
+			Adopted: true,
+		})
+		return a.state.Save()
+	}
+
+	rule, err := a.client.CreateRule2(filepath, targetGroupArn, priority, listenerArn)
+	if err != nil {
+		return err
+	}
+	a.created(project.StateResource{
+		Kind: project.KindRule,
+		Key:  id,
+		File: value,
+		Arn:  rule.RuleArn,
+		Hash: hash,
+	})
+	return a.state.Save()
+}
+
+// applyTargetGroupFile creates the target group or, if it was already
+// created by a previous run, modifies it when it changed.
+func (a *applier) applyTargetGroupFile(id string, value string, lookup bool) (aws.TargetGroup, string, error) {
+	var resp aws.TargetGroup
+	filepath, err := a.renderer.Render(value)
+	if err != nil {
+		return resp, "", err
+	}
+	hash, err := project.Hash(filepath)
+	if err != nil {
+		return resp, "", err
+	}
+	// created by a previous run
+	if r, ok := a.state.Get(project.KindTargetGroup, id); ok && r.Arn != "" {
+		resp = aws.TargetGroup{
+			TargetGroupArn:  r.Arn,
+			TargetGroupName: r.Attributes["name"],
+		}
+		if r.Hash == hash {
+			return resp, statusUpToDate, nil
+		}
+		if _, err = a.client.ModifyTargetGroup(r.Arn, filepath); err != nil {
+			return resp, "", err
+		}
+		r.File = value
+		r.Hash = hash
+		a.state.Put(r)
+		return resp, statusUpdated, a.state.Save()
+	}
+	if lookup {
+		// get name from file
+		content, err := project.ReadResourceFile(filepath)
+		if err != nil {
+			return resp, "", err
+		}
+		if name := content.GetString("Name"); name != "" {
+			// aws returns ErrNotFound if one name is not found
+			results, err := a.client.DescribeTargetGroupsWithNames([]string{name})
+			if err != nil && !errors.Is(err, aws.ErrNotFound) {
+				return resp, "", err
+			}
+			if len(results) > 0 {
+				return results[0], statusExists, nil
+			}
+		}
+	}
+
+	// create target group
+	resp, err = a.client.CreateTargetGroup(filepath)
+	if err != nil {
+		return resp, "", err
+	}
+	a.created(project.StateResource{
+		Kind:       project.KindTargetGroup,
+		Key:        id,
+		File:       value,
+		Arn:        resp.TargetGroupArn,
+		Hash:       hash,
+		Attributes: map[string]string{"name": resp.TargetGroupName},
+	})
+	return resp, "", a.state.Save()
+}
+
+func (a *applier) applyTargetGroup(targetGroup project.TargetGroup) (string, error) {
+	if targetGroup.Value == "" {
+		return statusSkipped, nil
+	}
+	resp, status, err := a.applyTargetGroupFile(targetGroup.Id(), targetGroup.Value, true)
+	if err != nil {
+		return status, err
+	}
+	if targetGroup.Key != "" && resp.TargetGroupArn != "" {
+		filepath, _ := a.renderer.Render(targetGroup.Value)
+		a.refs.Set(project.KindTargetGroup, targetGroup.Key, resp, filepath)
+	}
+	return status, nil
+}
+
+func (a *applier) applyLoadBalancer(loadBalancer project.LoadBalancer) (string, error) {
+	if loadBalancer.Value == "" {
+		return statusSkipped, nil
+	}
+	filepath, err := a.renderer.Render(loadBalancer.Value)
+	if err != nil {
+		return "", err
+	}
+	hash, err := project.Hash(filepath)
+	if err != nil {
+		return "", err
+	}
+	// created by a previous run
+	if r, ok := a.state.Get(project.KindLoadBalancer, loadBalancer.Id()); ok && r.Arn != "" {
+		if loadBalancer.Key != "" {
+			resp := aws.LoadBalancer{
+				LoadBalancerArn:  r.Arn,
+				LoadBalancerName: r.Attributes["name"],
+				Type:             r.Attributes["type"],
+				DNSName:          r.Attributes["dnsName"],
+				VpcId:            r.Attributes["vpcId"],
+				Scheme:           r.Attributes["scheme"],
+			}
+			if resp.DNSName == "" && resp.LoadBalancerName != "" {
+				// created before the attributes were recorded
+				results, err := a.client.DescribeLoadBalancersWithNames([]string{resp.LoadBalancerName})
+				if err != nil && !errors.Is(err, aws.ErrNotFound) {
+					return "", err
+				}
+				if len(results) > 0 {
+					resp = results[0]
+				}
+			}
+			a.refs.Set(project.KindLoadBalancer, loadBalancer.Key, resp, filepath)
+		}
+		if r.Hash != hash {
+			// subnets, security groups, ... are not modified
+			return "changed but load balancers are not updated in place", nil
+		}
+		return statusUpToDate, nil
+	}
+	// get name from file
+	content, err := project.ReadResourceFile(filepath)
+	if err != nil {
+		return "", err
+	}
+	if name := content.GetString("Name"); name != "" {
+		// aws returns ErrNotFound if one name is not found
+		results, err := a.client.DescribeLoadBalancersWithNames([]string{name})
+		if err != nil && !errors.Is(err, aws.ErrNotFound) {
+			return "", err
+		}
+		if len(results) > 0 {
+			if loadBalancer.Key != "" {
+				a.refs.Set(project.KindLoadBalancer, loadBalancer.Key, results[0], filepath)
+			}
+			return statusExists, nil
+		}
+	}
+
+	// create load balancer
+	resp, err := a.client.CreateLoadBalancer(filepath)
+	if err != nil {
+		return "", err
+	}
+	if loadBalancer.Key != "" && resp.LoadBalancerArn != "" {
+		a.refs.Set(project.KindLoadBalancer, loadBalancer.Key, resp, filepath)
+	}
+	a.created(project.StateResource{
+		Kind: project.KindLoadBalancer,
+		Key:  loadBalancer.Id(),
+		File: loadBalancer.Value,
+		Arn:  resp.LoadBalancerArn,
+		Hash: hash,
+		Attributes: map[string]string{
+			"name":    resp.LoadBalancerName,
+			"type":    resp.Type,
+			"dnsName": resp.DNSName,
+			"vpcId":   resp.VpcId,
+			"scheme":  resp.Scheme,
+		},
+	})
+	return "", a.state.Save()
+}
+
+func (a *applier) applyListener(listener project.Listener) (string, error) {
+	if listener.Value == "" {
+		return statusSkipped, nil
+	}
+	var (
+		resp   aws.Listener
+		status string
+	)
+	lbArn, err := a.loadBalancerArn(listener.LoadBalancer)
+	if err != nil {
+		return "", err
+	}
+	tgArn, err := a.targetGroupArn(listener.TargetGroup)
+	if err != nil {
+		return "", err
+	}
+	filepath, err := a.renderer.Render(listener.Value)
+	if err != nil {
+		return "", err
+	}
+	hash, err := project.ListenerHash(filepath, lbArn, tgArn)
+	if err != nil {
+		return "", err
+	}
+	if r, ok := a.state.Get(project.KindListener, listener.Id()); ok && r.Arn != "" {
+		// created by a previous run
+		resp = aws.Listener{ListenerArn: r.Arn}
+		status = statusUpToDate
+		if r.Hash != hash {
+			if _, err = a.client.ModifyListener(r.Arn, filepath, tgArn); err != nil {
+				return "", err
+			}
+			r.File = listener.Value
+			r.Hash = hash
+			a.state.Put(r)
+			if err = a.state.Save(); err != nil {
+				return "", err
+			}
+			status = statusUpdated
+		}
+	} else {
+		existing, err := project.FindListener(a.client, lbArn, filepath)
+		if err != nil {
+			return "", err
+		}
+		if existing.ListenerArn != "" {
+			// the load balancer already has a listener on that port
+			if _, err = a.client.ModifyListener(existing.ListenerArn, filepath, tgArn); err != nil {
+				return "", err
+			}
+			resp = aws.Listener{ListenerArn: existing.ListenerArn}
+			status = statusAdopted
+		} else {
+			// create listener
+			resp, err = a.client.CreateListener(filepath, lbArn, tgArn)
+			if err != nil {
+				return "", err
+			}
+		}
+		r := project.StateResource{
+			Kind:    project.KindListener,
+			Key:     listener.Id(),
+			File:    listener.Value,
+			Arn:     resp.ListenerArn,
+			Hash:    hash,
+			Adopted: existing.ListenerArn != "",
+		}
+		if r.Adopted {
+			a.state.Put(r)
+		} else {
+			a.created(r)
+		}
+		if err = a.state.Save(); err != nil {
+			return "", err
+		}
+	}
+	if listener.Key != "" && resp.ListenerArn != "" {
+		a.refs.Set(project.KindListener, listener.Key, resp, filepath)
+	}
+
+	// create rules
+	for i, rule := range listener.Rules {
+		ruleDestination, err := a.targetGroupArn(rule.TargetGroup)
+		if err != nil {
+			return status, err
+		}
+		err = a.applyRule(project.RuleId(listener.Id(), i), rule.Value, ruleDestination, rule.Priority, resp.ListenerArn)
+		if err != nil {
+			return status, fmt.Errorf("rule %s: %v", rule.Value, err)
+		}
+	}
+	return status, nil
+}
+
+func (a *applier) applyLogGroup(logGroup project.LogGroup) (string, error) {
+	var (
+		err    error
+		status = statusExists
+	)
+	if _, ok := a.state.Get(project.KindLogGroup, logGroup.Group); !ok {
+		// create log group
+		// (it may already exist)
+		r := project.StateResource{
+			Kind: project.KindLogGroup,
+			Key:  logGroup.Group,
+		}
+		if _, err = a.client.CreateLogGroup(logGroup.Group); errors.Is(err, aws.ErrAlreadyExists) {
+			// not created by the project: never deleted by destroy
+			r.Adopted = true
+			a.state.Put(r)
+		} else if err != nil {
+			return "", err
+		} else {
+			a.created(r)
+			status = ""
+		}
+		if err = a.state.Save(); err != nil {
+			return "", err
+		}
+	}
+	if logGroup.Retention > 0 {
+		// put retention policy in number of days
+		if _, err = a.client.PutRetentionPolicy(logGroup.Group, logGroup.Retention); err != nil {
+			return status, err
+		}
+	}
+	if err = a.setLogGroupRef(logGroup); err != nil {
+		return status, err
+	}
+	return status, nil
+}
+
+// setLogGroupRef sets the log group for "ref:<key>"
+// (the one of the project if it does not exist yet).
+func (a *applier) setLogGroupRef(logGroup project.LogGroup) error {
+	ref := aws.LogGroup{LogGroupName: logGroup.Group, RetentionInDays: logGroup.Retention}
+	results, err := a.client.DescribeLogGroups(logGroup.Group)
+	if err != nil {
+		return err
+	}
+	for _, lg := range results {
+		if lg.LogGroupName == logGroup.Group {
+			ref = lg
+		}
+	}
+	a.refs.Set(project.KindLogGroup, logGroup.Id(), ref, "")
+	return nil
+}
+
+// setTaskDefinitionRef sets the revision of a task definition
+// for "ref:<key>".
+func (a *applier) setTaskDefinitionRef(taskDefinition project.TaskDefinition, arn string, filepath string) {
+	a.refs.Set(project.KindTaskDefinition, taskDefinition.Id(), project.NewTaskDefinitionRef(arn), filepath)
+}
+
+func (a *applier) applyTaskDefinition(taskDefinition project.TaskDefinition) (string, error) {
+	filepath, err := a.renderer.Render(taskDefinition.Value)
+	if err != nil {
+		return "", err
+	}
+	hash, err := project.Hash(filepath)
+	if err != nil {
+		return "", err
+	}
+	if r, ok := a.state.Get(project.KindTaskDefinition, taskDefinition.Id()); ok && r.Hash == hash {
+		a.setTaskDefinitionRef(taskDefinition, r.Arn, filepath)
+		return fmt.Sprintf("%s: %s", statusUpToDate, r.Arn), nil
+	}
+	// create new revision for task definition
+	td, err := a.client.RegisterTaskDefinition(fmt.Sprintf("file://%s", filepath))
+	if err != nil {
+		return "", err
+	}
+	a.created(project.StateResource{
+		Kind: project.KindTaskDefinition,
+		Key:  taskDefinition.Id(),
+		File: taskDefinition.Value,
+		Arn:  td.TaskDefinitionArn,
+		Hash: hash,
+	})
+	a.setTaskDefinitionRef(taskDefinition, td.TaskDefinitionArn, filepath)
+	return "", a.state.Save()
+}
+
+func (a *applier) applyFlow(flow project.Flow) (string, error) {
+	// @TODO create target group, rules and/or service
+
+	var (
+		err           error
+		targetGroup   aws.TargetGroup
+		containerName string
+		containerPort int
+	)
+
+	// create target group
+	if flow.TargetGroup != "" {
+		if _, ok := project.IsRef(flow.TargetGroup); ok {
+			if targetGroup.TargetGroupArn, err = a.targetGroupArn(flow.TargetGroup); err != nil {
+				return "", err
+			}
+		} else {
+			targetGroup, _, err = a.applyTargetGroupFile(flow.Id()+"/targetGroup", flow.TargetGroup, false)
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+
+	// create rules
+	if targetGroup.TargetGroupArn != "" && len(flow.Rules) > 0 {
+		for i, rule := range flow.Rules {
+			listenerArn, err := a.listenerArn(rule.Listener)
+			if err != nil {
+				return "", err
+			}
+			err = a.applyRule(project.RuleId(flow.Id(), i), rule.Value, targetGroup.TargetGroupArn, rule.Priority, listenerArn)
+			if err != nil {
+				return "", fmt.Errorf("rule %s: %v", rule.Value, err)
+			}
+		}
+	}
+
+	if flow.Service == "" {
+		a.refs.Set(project.KindFlow, flow.Id(), project.FlowRef{TargetGroupArn: targetGroup.TargetGroupArn}, "")
+		return "", nil
+	}
+
+	// create service
+	serviceFile, err := a.renderer.Render(flow.Service)
+	if err != nil {
+		return "", err
+	}
+	serviceConf, err := project.ReadResourceFile(serviceFile)
+	if err != nil {
+		return "", err
+	}
+	serviceName := serviceConf.GetString("serviceName")
+	taskDefinition := serviceConf.GetString("taskDefinition")
+
+	// get port mapping named "http"
+	// or the first port mapping
+	if targetGroup.TargetGroupArn != "" {
+		a.logger.Debugf("serviceName %s", serviceName)
+		a.logger.Debugf("taskDefinition %s", taskDefinition)
+
+		containerName, containerPort, err = project.ServicePort(a.client, taskDefinition)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	serviceLoadBalancer := aws.ServiceLoadBalancer{
+		TargetGroupArn: targetGroup.TargetGroupArn,
+		ContainerName:  containerName,
+		ContainerPort:  containerPort,
+	}
+
+	hash, err := project.ServiceHash(serviceFile, targetGroup.TargetGroupArn, containerName, containerPort, flow.HealthCheckGracePeriodSeconds)
+	if err != nil {
+		return "", err
+	}
+
+	if r, ok := a.state.Get(project.KindService, flow.Id()); ok && r.Arn != "" {
+		// created by a previous run
+		a.refs.Set(project.KindFlow, flow.Id(), project.FlowRef{
+			ServiceArn:     r.Arn,
+			ServiceName:    r.Attributes["name"],
+			Cluster:        r.Attributes["cluster"],
+			TargetGroupArn: targetGroup.TargetGroupArn,
+		}, serviceFile)
+		if r.Hash == hash {
+			return statusUpToDate, nil
+		}
+		_, err = a.client.UpdateServiceWithFile(r.Attributes["cluster"], r.Arn, serviceFile, serviceLoadBalancer, flow.HealthCheckGracePeriodSeconds)
+		if err != nil {
+			return "", err
+		}
+		// the new hash only once the service is stable:
+		// a failed rollout is updated again by the next apply
+		if err = a.waitForService(r.Attributes["cluster"], r.Arn); err != nil {
+			return "", err
+		}
+		r.File = flow.Service
+		r.Hash = hash
+		a.state.Put(r)
+		if err = a.state.Save(); err != nil {
+			return "", err
+		}
+		return statusUpdated, nil
+	}
+
+	cluster := serviceConf.GetString("cluster")
+	status := ""
+	service, err := project.FindService(a.client, cluster, serviceName)
+	if err != nil {
+		return "", err
+	}
+	if service.ServiceArn != "" {
+		// the service already exists in the cluster
+		_, err = a.client.UpdateServiceWithFile(cluster, service.ServiceArn, serviceFile, serviceLoadBalancer, flow.HealthCheckGracePeriodSeconds)
+		status = statusAdopted
+	} else {
+		service, err = a.client.CreateService(serviceFile, serviceLoadBalancer, flow.HealthCheckGracePeriodSeconds)
+	}
+	if err != nil {
+		return "", err
+	}
+	a.refs.Set(project.KindFlow, flow.Id(), project.FlowRef{
+		ServiceArn:     service.ServiceArn,
+		ServiceName:    service.ServiceName,
+		Cluster:        cluster,
+		TargetGroupArn: targetGroup.TargetGroupArn,
+	}, serviceFile)
+	// no hash until the service is stable (see above)
+	r := project.StateResource{
+		Kind: project.KindService,
+		Key:  flow.Id(),
+		File: flow.Service,
+		Arn:  service.ServiceArn,
+		Attributes: map[string]string{
+			"cluster": cluster,
+			"name":    service.ServiceName,
+		},
+		Adopted: status == statusAdopted,
+	}
+	if status == "" {
+		a.created(r)
+	} else {
+		a.state.Put(r)
+	}
+	if err = a.state.Save(); err != nil {
+		return "", err
+	}
+	if err = a.waitForService(cluster, service.ServiceArn); err != nil {
+		return "", err
+	}
+	r.Hash = hash
+	a.state.Put(r)
+	if err = a.state.Save(); err != nil {
+		return "", err
+	}
+	return status, nil
+}
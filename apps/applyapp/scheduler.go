@@ -0,0 +1,86 @@
+package applyapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/demingongo/ecx/bubbles/progressmodel"
+	"github.com/demingongo/ecx/project"
+)
+
+type nodeResult struct {
+	node   *project.Node
+	status string
+	err    error
+}
+
+// schedule applies the nodes, at most "parallelism" at a time,
+// each node waiting for its dependencies to be applied.
+// No new node is started after a failure or once ctx is done.
+// The progress is sent to notify (progressmodel messages).
+func schedule(ctx context.Context, nodes []*project.Node, parallelism int, apply func(*project.Node) (string, error), notify func(tea.Msg)) ([]nodeResult, error) {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	var (
+		results    []nodeResult
+		errs       []error
+		ready      []*project.Node
+		running    int
+		pending    = make(map[string]int)
+		dependents = make(map[string][]*project.Node)
+		finished   = make(chan nodeResult)
+	)
+
+	for _, n := range nodes {
+		pending[n.Id()] = len(n.Deps)
+		for _, dep := range n.Deps {
+			dependents[dep] = append(dependents[dep], n)
+		}
+		if len(n.Deps) == 0 {
+			ready = append(ready, n)
+		}
+	}
+
+	for {
+		for len(errs) == 0 && ctx.Err() == nil && running < parallelism && len(ready) > 0 {
+			n := ready[0]
+			ready = ready[1:]
+			running++
+			notify(progressmodel.StartMsg{Id: n.Id()})
+			go func() {
+				status, err := apply(n)
+				finished <- nodeResult{node: n, status: status, err: err}
+			}()
+		}
+		if running == 0 {
+			break
+		}
+
+		r := <-finished
+		running--
+		results = append(results, r)
+		notify(progressmodel.DoneMsg{Id: r.node.Id(), Detail: r.status, Err: r.err})
+		if r.err != nil {
+			errs = append(errs, fmt.Errorf("%s %s: %v", kindLabels[r.node.Kind], r.node.Key, r.err))
+			continue
+		}
+		for _, parent := range dependents[r.node.Id()] {
+			pending[parent.Id()]--
+			if pending[parent.Id()] == 0 {
+				ready = append(ready, parent)
+			}
+		}
+	}
+
+	notify(progressmodel.FinishMsg{})
+
+	if len(errs) == 0 && ctx.Err() != nil {
+		errs = append(errs, ctx.Err())
+	}
+
+	return results, errors.Join(errs...)
+}
@@ -0,0 +1,132 @@
+package applyapp
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/demingongo/ecx/aws"
+	"github.com/spf13/viper"
+)
+
+// time between two describe-services
+// while waiting for a service to be stable
+const stabilityInterval = 10 * time.Second
+
+// max number of events and stopped tasks
+// shown when a service is not stable
+const maxUnstableDetails = 5
+
+// primaryDeployment returns the deployment being rolled out.
+func primaryDeployment(service aws.ServiceStability) aws.ServiceDeployment {
+	for _, d := range service.Deployments {
+		if d.Status == "PRIMARY" {
+			return d
+		}
+	}
+	return aws.ServiceDeployment{}
+}
+
+// isStable reports whether the primary deployment is completed
+// and every desired task is running. Without rollout state
+// (e.g. CODE_DEPLOY controller), it must be the only deployment.
+func isStable(service aws.ServiceStability) bool {
+	if service.RunningCount != service.DesiredCount {
+		return false
+	}
+	primary := primaryDeployment(service)
+	if primary.RolloutState != "" {
+		return primary.RolloutState == "COMPLETED"
+	}
+	return len(service.Deployments) == 1
+}
+
+// waitForService polls the service until it is stable,
+// its deployment failed or --wait-timeout is reached.
+// Nothing is done with --no-wait.
+func (a *applier) waitForService(cluster string, serviceArn string) error {
+	if viper.GetBool("no-wait") {
+		return nil
+	}
+	timeout := viper.GetDuration("wait-timeout")
+	deadline := time.Now().Add(timeout)
+	for {
+		service, err := a.client.DescribeServiceStability(cluster, serviceArn)
+		if err != nil {
+			return err
+		}
+		primary := primaryDeployment(service)
+		a.logger.Debugf("service %s: %d/%d running, deployment %s %s",
+			service.ServiceName, service.RunningCount, service.DesiredCount, primary.Id, primary.RolloutState)
+		if primary.RolloutState == "FAILED" {
+			return a.unstable(cluster, service, fmt.Sprintf("deployment %s failed: %s", primary.Id, primary.RolloutStateReason))
+		}
+		if isStable(service) {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return a.unstable(cluster, service, fmt.Sprintf("not stable after %s (%d/%d tasks running)",
+				timeout, service.RunningCount, service.DesiredCount))
+		}
+		select {
+		case <-a.ctx.Done():
+			return fmt.Errorf("service %s: interrupted while waiting for it to be stable", service.ServiceName)
+		case <-time.After(stabilityInterval):
+		}
+	}
+}
+
+// unstable returns the error of a service that is not stable
+// with its latest events and the reasons of its stopped tasks.
+func (a *applier) unstable(cluster string, service aws.ServiceStability, reason string) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "service %s: %s", service.ServiceName, reason)
+
+	events := service.Events
+	if len(events) > maxUnstableDetails {
+		events = events[:maxUnstableDetails]
+	}
+	if len(events) > 0 {
+		sb.WriteString("\nlatest events:")
+		for _, e := range events {
+			fmt.Fprintf(&sb, "\n  %s %s", e.CreatedAt, e.Message)
+		}
+	}
+
+	taskArns, err := a.client.ListStoppedTasks(cluster, service.ServiceName)
+	if err != nil {
+		a.logger.Warnf("could not list the stopped tasks of %s: %v", service.ServiceName, err)
+	}
+	if len(taskArns) > maxUnstableDetails {
+		taskArns = taskArns[:maxUnstableDetails]
+	}
+	if len(taskArns) > 0 {
+		tasks, err := a.client.DescribeTasks(cluster, taskArns)
+		if err != nil {
+			a.logger.Warnf("could not describe the stopped tasks of %s: %v", service.ServiceName, err)
+		}
+		if len(tasks) > 0 {
+			sb.WriteString("\nstopped tasks:")
+		}
+		for _, t := range tasks {
+			id := t.TaskArn[strings.LastIndex(t.TaskArn, "/")+1:]
+			fmt.Fprintf(&sb, "\n  %s: %s", id, t.StoppedReason)
+			if t.StopCode != "" {
+				fmt.Fprintf(&sb, " (%s)", t.StopCode)
+			}
+			for _, c := range t.Containers {
+				if c.ExitCode == nil && c.Reason == "" {
+					continue
+				}
+				fmt.Fprintf(&sb, "\n    container %s:", c.Name)
+				if c.ExitCode != nil {
+					fmt.Fprintf(&sb, " exit code %d", *c.ExitCode)
+				}
+				if c.Reason != "" {
+					fmt.Fprintf(&sb, " %s", c.Reason)
+				}
+			}
+		}
+	}
+	return fmt.Errorf("%s", sb.String())
+}
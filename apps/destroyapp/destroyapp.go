@@ -0,0 +1,149 @@
+package destroyapp
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/huh"
+	"github.com/charmbracelet/huh/spinner"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/demingongo/ecx/aws"
+	"github.com/demingongo/ecx/globals"
+	"github.com/demingongo/ecx/project"
+	"github.com/spf13/viper"
+)
+
+var (
+	info string
+
+	subtle  = lipgloss.AdaptiveColor{Light: "#D9DCCF", Dark: "#383838"}
+	special = lipgloss.AdaptiveColor{Light: "230", Dark: "#010102"}
+
+	// Titles.
+
+	titleStyle = lipgloss.NewStyle().
+			Padding(0, 1).
+			Background(lipgloss.Color("7")).
+			Foreground(special)
+
+	subtitleStyle = lipgloss.NewStyle().
+			BorderStyle(lipgloss.NormalBorder()).
+			BorderTop(true).
+			BorderForeground(subtle).
+			Foreground(lipgloss.Color("6"))
+
+	// Info block.
+
+	infoStyle = lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(lipgloss.Color("7")).
+			BorderTop(true).
+			BorderLeft(true).
+			BorderRight(true).
+			BorderBottom(true).
+			Width(globals.InfoWidth * 2)
+
+	kindTitles = map[string]string{
+		project.KindService:      "Services",
+		project.KindRule:         "Rules",
+		project.KindListener:     "Listeners",
+		project.KindLoadBalancer: "Load balancers",
+		project.KindTargetGroup:  "Target groups",
+		project.KindLogGroup:     "Log groups",
+	}
+)
+
+func generateInfo(resources []project.StateResource) string {
+	content := []string{
+		titleStyle.Render("TO DESTROY"),
+	}
+	var kind string
+	for _, r := range resources {
+		if r.Kind != kind {
+			kind = r.Kind
+			content = append(content, subtitleStyle.Render(kindTitles[kind]))
+		}
+		content = append(content, "・"+r.Key)
+	}
+
+	return infoStyle.Render(lipgloss.JoinVertical(lipgloss.Left, content...))
+}
+
+func process(client aws.Client, state *project.State, resources []project.StateResource) error {
+	for _, r := range resources {
+		var err error
+		globals.Spin(spinner.MiniDot, fmt.Sprintf(" Deleting %s \"%s\"...", r.Kind, r.Key), func() {
+			err = project.DeleteResource(client, r)
+		})
+		if err != nil {
+			return fmt.Errorf("delete %s \"%s\": %v", r.Kind, r.Key, err)
+		}
+		state.Remove(r.Kind, r.Key)
+		if err = state.Save(); err != nil {
+			return fmt.Errorf("%s: %v", state.File(), err)
+		}
+		fmt.Printf("deleted %s: %s\n", r.Kind, r.Key)
+	}
+	return nil
+}
+
+func Run(client aws.Client) {
+	logger := globals.Logger
+
+	logger.Debugf("ecx destroy %s", viper.GetString("project"))
+
+	config, err := project.Open(viper.GetString("project"))
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+
+	if err := config.Check(); err != nil {
+		logger.Fatal(err)
+	}
+
+	// no other apply or destroy until the end of the run
+	// (nothing is changed by a dry run)
+	unlock := func() error { return nil }
+	if !viper.GetBool("dry-run") {
+		if unlock, err = project.LockProject(client, config, "destroy"); err != nil {
+			logger.Fatalf("%v", err)
+		}
+	}
+	fatalf := func(format string, args ...any) {
+		if err := unlock(); err != nil {
+			logger.Errorf("%v", err)
+		}
+		logger.Fatalf(format, args...)
+	}
+	defer func() {
+		if err := unlock(); err != nil {
+			logger.Errorf("%v", err)
+		}
+	}()
+
+	// the project owns what apply recorded
+	state, err := project.LoadState(config.Env)
+	if err != nil {
+		fatalf("%v", err)
+	}
+
+	resources := state.Destroyable()
+	if len(resources) == 0 {
+		fmt.Printf("Nothing to destroy (no resources recorded in %s).\n", state.File())
+		return
+	}
+
+	info = generateInfo(resources)
+
+	if viper.GetBool("dry-run") {
+		fmt.Println(info)
+		fmt.Println("Dry run: nothing was deleted.")
+		return
+	}
+
+	if form := runFormProcess(); form.State == huh.StateCompleted && form.GetBool("confirm") {
+		if err = process(client, state, resources); err != nil {
+			fatalf("%v", err)
+		}
+		fmt.Println("Done")
+	}
+}
@@ -0,0 +1,500 @@
+package driftapp
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/huh/spinner"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/demingongo/ecx/aws"
+	"github.com/demingongo/ecx/globals"
+	"github.com/demingongo/ecx/project"
+	"github.com/spf13/viper"
+)
+
+// ExitDrift is the exit code when a resource drifted
+// or is missing (1 is for errors).
+const ExitDrift = 2
+
+type Status string
+
+const (
+	StatusInSync  Status = "in sync"
+	StatusDrifted Status = "drifted"
+	StatusMissing Status = "missing"
+)
+
+// Result is the drift of a resource of the project file.
+type Result struct {
+	Kind   string
+	Key    string
+	File   string
+	Arn    string
+	Status Status
+	Diffs  []project.FieldDiff
+}
+
+type detector struct {
+	client   aws.Client
+	config   *project.Config
+	state    *project.State
+	results  []Result
+	renderer *project.Renderer
+
+	// resources found so far
+	refs *project.Refs
+}
+
+func newDetector(client aws.Client, config *project.Config, state *project.State) *detector {
+	d := &detector{
+		client: client,
+		config: config,
+		state:  state,
+		refs:   project.NewRefs(),
+	}
+	d.renderer = project.NewRenderer(config, d.ref)
+	return d
+}
+
+// ref returns the value of a key for {{ ref "key" }} in templates.
+func (d *detector) ref(key string) (string, error) {
+	return d.refs.Resolve("ref:" + key)
+}
+
+// arn resolves a "ref:" value (a raw arn is returned as is).
+func (d *detector) arn(value string, kind string) string {
+	result, _ := d.refs.Resolve(value, kind)
+	return result
+}
+
+// read renders a resource file and reads it.
+func (d *detector) read(value string) (string, map[string]any, error) {
+	filepath, err := d.renderer.Render(value)
+	if err != nil {
+		return "", nil, err
+	}
+	content, err := project.ReadJSONMap(filepath)
+	return filepath, content, err
+}
+
+// compare describes the live resource and adds its result.
+func (d *detector) compare(r Result, want map[string]any, describe func() (map[string]any, error)) error {
+	if r.Arn == "" {
+		r.Status = StatusMissing
+		d.results = append(d.results, r)
+		return nil
+	}
+	got, err := describe()
+	if err != nil && !errors.Is(err, aws.ErrNotFound) {
+		return fmt.Errorf("%s %s: %v", r.Kind, r.Key, err)
+	}
+	if got == nil {
+		r.Status = StatusMissing
+		d.results = append(d.results, r)
+		return nil
+	}
+	r.Diffs = project.Drift(want, got, project.DriftFields[r.Kind])
+	r.Status = StatusInSync
+	if len(r.Diffs) > 0 {
+		r.Status = StatusDrifted
+	}
+	d.results = append(d.results, r)
+	return nil
+}
+
+// forward is the action set by apply for a target group.
+func forward(targetGroupArn string) []any {
+	return []any{map[string]any{"Type": "forward", "TargetGroupArn": targetGroupArn}}
+}
+
+// targetGroupArn returns the arn of a target group created
+// by apply or, if it was not, of the one with the same name.
+func (d *detector) targetGroupArn(id string, content map[string]any) (string, error) {
+	if r, ok := d.state.Get(project.KindTargetGroup, id); ok && r.Arn != "" {
+		return r.Arn, nil
+	}
+	if name, ok := content["Name"].(string); ok && name != "" {
+		results, err := d.client.DescribeTargetGroupsWithNames([]string{name})
+		if err != nil && !errors.Is(err, aws.ErrNotFound) {
+			return "", fmt.Errorf("%s %s: %v", project.KindTargetGroup, id, err)
+		}
+		if len(results) > 0 {
+			return results[0].TargetGroupArn, nil
+		}
+	}
+	return "", nil
+}
+
+func (d *detector) targetGroup(id string, value string) (string, error) {
+	_, want, err := d.read(value)
+	if err != nil {
+		return "", err
+	}
+	arn, err := d.targetGroupArn(id, want)
+	if err != nil {
+		return "", err
+	}
+	r := Result{
+		Kind: project.KindTargetGroup,
+		Key:  id,
+		File: value,
+		Arn:  arn,
+	}
+	return r.Arn, d.compare(r, want, func() (map[string]any, error) {
+		return d.client.DescribeTargetGroupByArn(r.Arn)
+	})
+}
+
+func (d *detector) loadBalancer(loadBalancer project.LoadBalancer) error {
+	if r, ok := d.state.Get(project.KindLoadBalancer, loadBalancer.Id()); ok && r.Arn != "" {
+		d.refs.Set(project.KindLoadBalancer, loadBalancer.Key, aws.LoadBalancer{LoadBalancerArn: r.Arn}, "")
+		return nil
+	}
+	filepath, content, err := d.read(loadBalancer.Value)
+	if err != nil {
+		return err
+	}
+	if name, ok := content["Name"].(string); ok && name != "" {
+		results, err := d.client.DescribeLoadBalancersWithNames([]string{name})
+		if err != nil && !errors.Is(err, aws.ErrNotFound) {
+			return fmt.Errorf("%s %s: %v", project.KindLoadBalancer, loadBalancer.Id(), err)
+		}
+		if len(results) > 0 {
+			d.refs.Set(project.KindLoadBalancer, loadBalancer.Key, results[0], filepath)
+		}
+	}
+	return nil
+}
+
+func (d *detector) rule(id string, value string, priority int, targetGroupArn string, listenerArn string) error {
+	filepath, want, err := d.read(value)
+	if err != nil {
+		return err
+	}
+	if priority, err = project.RulePriority(filepath, priority); err != nil {
+		return err
+	}
+	if priority > 0 {
+		want["Priority"] = fmt.Sprint(priority)
+	}
+	if targetGroupArn != "" {
+		want["Actions"] = forward(targetGroupArn)
+	}
+	r := Result{Kind: project.KindRule, Key: id, File: value}
+	if s, ok := d.state.Get(project.KindRule, id); ok {
+		r.Arn = s.Arn
+	} else if listenerArn != "" {
+		existing, err := project.FindRule(d.client, listenerArn, filepath, priority)
+		if err != nil {
+			return err
+		}
+		r.Arn = existing.RuleArn
+	}
+	return d.compare(r, want, func() (map[string]any, error) {
+		return d.client.DescribeRuleByArn(r.Arn)
+	})
+}
+
+func (d *detector) listener(listener project.Listener) error {
+	filepath, want, err := d.read(listener.Value)
+	if err != nil {
+		return err
+	}
+	if tgArn := d.arn(listener.TargetGroup, project.KindTargetGroup); tgArn != "" {
+		want["DefaultActions"] = forward(tgArn)
+	}
+	r := Result{Kind: project.KindListener, Key: listener.Id(), File: listener.Value}
+	if s, ok := d.state.Get(project.KindListener, listener.Id()); ok {
+		r.Arn = s.Arn
+	} else if lbArn := d.arn(listener.LoadBalancer, project.KindLoadBalancer); lbArn != "" {
+		existing, err := project.FindListener(d.client, lbArn, filepath)
+		if err != nil {
+			return err
+		}
+		r.Arn = existing.ListenerArn
+	}
+	if listener.Key != "" {
+		d.refs.Set(project.KindListener, listener.Key, aws.Listener{ListenerArn: r.Arn}, filepath)
+	}
+	if err = d.compare(r, want, func() (map[string]any, error) {
+		return d.client.DescribeListenerByArn(r.Arn)
+	}); err != nil {
+		return err
+	}
+	for i, rule := range listener.Rules {
+		err = d.rule(project.RuleId(listener.Id(), i), rule.Value, rule.Priority, d.arn(rule.TargetGroup, project.KindTargetGroup), r.Arn)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// taskDefinitions returns the last revisions registered
+// by apply (family => arn).
+func (d *detector) taskDefinitions() map[string]string {
+	result := make(map[string]string)
+	for _, r := range d.state.Resources {
+		if r.Kind == project.KindTaskDefinition && r.Arn != "" {
+			result[aws.ExtractFamilyFromRevision(r.Arn)] = r.Arn
+		}
+	}
+	return result
+}
+
+// revision returns the "family:revision" of a task definition
+// (or its family if it has no revision).
+func revision(taskDefinition string) string {
+	if i := strings.LastIndex(taskDefinition, ":task-definition/"); i > -1 {
+		return taskDefinition[i+len(":task-definition/"):]
+	}
+	return taskDefinition
+}
+
+// normalizeTaskDefinition makes the task definition of a service file
+// comparable with the live one: a family is expected to be at the
+// revision last registered by apply (or at any revision).
+func normalizeTaskDefinition(want string, got string, registered map[string]string) (string, string) {
+	want, got = revision(want), revision(got)
+	if strings.Contains(want, ":") {
+		return want, got
+	}
+	if arn, ok := registered[want]; ok {
+		return revision(arn), got
+	}
+	return want, aws.ExtractFamilyFromRevision(got)
+}
+
+func (d *detector) flow(flow project.Flow) error {
+	var (
+		err            error
+		targetGroupArn = d.arn(flow.TargetGroup, project.KindTargetGroup)
+	)
+	if _, ok := project.IsRef(flow.TargetGroup); !ok && flow.TargetGroup != "" {
+		targetGroupArn, err = d.targetGroup(flow.Id()+"/targetGroup", flow.TargetGroup)
+		if err != nil {
+			return err
+		}
+	}
+	if targetGroupArn != "" {
+		for i, rule := range flow.Rules {
+			err = d.rule(project.RuleId(flow.Id(), i), rule.Value, rule.Priority, targetGroupArn, d.arn(rule.Listener, project.KindListener))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if flow.Service == "" {
+		d.refs.Set(project.KindFlow, flow.Id(), project.FlowRef{TargetGroupArn: targetGroupArn}, "")
+		return nil
+	}
+
+	filepath, want, err := d.read(flow.Service)
+	if err != nil {
+		return err
+	}
+	cluster, _ := want["cluster"].(string)
+	serviceName, _ := want["serviceName"].(string)
+	r := Result{Kind: project.KindService, Key: flow.Id(), File: flow.Service}
+	if s, ok := d.state.Get(project.KindService, flow.Id()); ok {
+		r.Arn = s.Arn
+		cluster = s.Attributes["cluster"]
+		serviceName = s.Attributes["name"]
+	} else {
+		existing, err := project.FindService(d.client, cluster, serviceName)
+		if err != nil {
+			return err
+		}
+		r.Arn = existing.ServiceArn
+	}
+	d.refs.Set(project.KindFlow, flow.Id(), project.FlowRef{
+		ServiceArn:     r.Arn,
+		ServiceName:    serviceName,
+		Cluster:        cluster,
+		TargetGroupArn: targetGroupArn,
+	}, filepath)
+	return d.compare(r, want, func() (map[string]any, error) {
+		got, err := d.client.DescribeServiceByArn(cluster, r.Arn)
+		if err != nil || got == nil {
+			return got, err
+		}
+		if status, _ := got["status"].(string); status != "" && status != "ACTIVE" {
+			return nil, nil
+		}
+		if w, ok := want["taskDefinition"].(string); ok {
+			g, _ := got["taskDefinition"].(string)
+			want["taskDefinition"], got["taskDefinition"] = normalizeTaskDefinition(w, g, d.taskDefinitions())
+		}
+		return got, nil
+	})
+}
+
+// logGroup sets the log group for "ref:<key>"
+// (log groups are not compared).
+func (d *detector) logGroup(logGroup project.LogGroup) error {
+	ref := aws.LogGroup{LogGroupName: logGroup.Group, RetentionInDays: logGroup.Retention}
+	results, err := d.client.DescribeLogGroups(logGroup.Group)
+	if err != nil {
+		return fmt.Errorf("%s %s: %v", project.KindLogGroup, logGroup.Id(), err)
+	}
+	for _, lg := range results {
+		if lg.LogGroupName == logGroup.Group {
+			ref = lg
+		}
+	}
+	d.refs.Set(project.KindLogGroup, logGroup.Id(), ref, "")
+	return nil
+}
+
+// taskDefinition sets the revision registered by apply (or the
+// latest one of the family) for "ref:<key>" (task definitions
+// are not compared, the one of a service is).
+func (d *detector) taskDefinition(taskDefinition project.TaskDefinition) error {
+	filepath, content, err := d.read(taskDefinition.Value)
+	if err != nil {
+		return err
+	}
+	family, _ := content["family"].(string)
+	ref := project.TaskDefinitionRef{Family: family}
+	if r, ok := d.state.Get(project.KindTaskDefinition, taskDefinition.Id()); ok && r.Arn != "" {
+		ref = project.NewTaskDefinitionRef(r.Arn)
+	} else if family != "" {
+		td, err := d.client.DescribeTaskDefinition(family)
+		if err != nil && !errors.Is(err, aws.ErrNotFound) {
+			return fmt.Errorf("%s %s: %v", project.KindTaskDefinition, taskDefinition.Id(), err)
+		}
+		if td.TaskDefinitionArn != "" {
+			ref = project.NewTaskDefinitionRef(td.TaskDefinitionArn)
+		}
+	}
+	d.refs.Set(project.KindTaskDefinition, taskDefinition.Id(), ref, filepath)
+	return nil
+}
+
+// detect compares every resource of the project file with the
+// live resources, in the order they are applied: the references
+// of a resource are set before the ones that use them.
+func (d *detector) detect() error {
+	graph, err := project.BuildGraph(d.config)
+	if err != nil {
+		return err
+	}
+	nodes, err := graph.Sort()
+	if err != nil {
+		return err
+	}
+	for _, n := range nodes {
+		switch n.Kind {
+		case project.KindTargetGroup:
+			err = d.detectTargetGroup(d.config.TargetGroups[n.Index])
+		case project.KindLoadBalancer:
+			if loadBalancer := d.config.LoadBalancers[n.Index]; loadBalancer.Key != "" && loadBalancer.Value != "" {
+				err = d.loadBalancer(loadBalancer)
+			}
+		case project.KindListener:
+			if listener := d.config.Listeners[n.Index]; listener.Value != "" {
+				err = d.listener(listener)
+			}
+		case project.KindLogGroup:
+			err = d.logGroup(d.config.LogGroups[n.Index])
+		case project.KindTaskDefinition:
+			err = d.taskDefinition(d.config.TaskDefinitions[n.Index])
+		case project.KindFlow:
+			err = d.flow(d.config.Flows[n.Index])
+		default:
+			err = fmt.Errorf("unknown resource kind \"%s\"", n.Kind)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *detector) detectTargetGroup(targetGroup project.TargetGroup) error {
+	if targetGroup.Value == "" {
+		return nil
+	}
+	arn, err := d.targetGroup(targetGroup.Id(), targetGroup.Value)
+	if err != nil {
+		return err
+	}
+	if targetGroup.Key != "" {
+		d.refs.Set(project.KindTargetGroup, targetGroup.Key, aws.TargetGroup{TargetGroupArn: arn}, "")
+	}
+	return nil
+}
+
+var (
+	inSyncStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("2"))
+	driftedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("3"))
+	missingStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+	fileStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+)
+
+func render(results []Result) string {
+	var b strings.Builder
+	count := make(map[Status]int)
+	for _, r := range results {
+		count[r.Status]++
+		title := fmt.Sprintf("%s %s", r.Kind, r.Key)
+		file := ""
+		if r.File != "" && r.File != r.Key {
+			file = fileStyle.Render(fmt.Sprintf(" (%s)", r.File))
+		}
+		switch r.Status {
+		case StatusInSync:
+			fmt.Fprintf(&b, "%s%s\n", inSyncStyle.Render("  "+title), file)
+		case StatusMissing:
+			fmt.Fprintf(&b, "%s%s: %s\n", missingStyle.Render("! "+title), file, StatusMissing)
+		case StatusDrifted:
+			fmt.Fprintf(&b, "%s%s\n", driftedStyle.Render("~ "+title), file)
+			for _, diff := range r.Diffs {
+				fmt.Fprintf(&b, "    %s: %s => %s\n", diff.Field, project.FormatValue(diff.Want), project.FormatValue(diff.Got))
+			}
+		}
+	}
+	fmt.Fprintf(&b, "\nDrift: %d drifted, %d missing, %d in sync.\n",
+		count[StatusDrifted], count[StatusMissing], count[StatusInSync])
+	return b.String()
+}
+
+func Run(client aws.Client) {
+	logger := globals.Logger
+
+	logger.Debugf("ecx drift %s", viper.GetString("project"))
+
+	config, err := project.Open(viper.GetString("project"))
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+
+	if err := config.Check(); err != nil {
+		logger.Fatal(err)
+	}
+
+	state, err := project.LoadState(config.Env)
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+
+	d := newDetector(client, config, state)
+	globals.Spin(spinner.Globe, " Describing resources...", func() {
+		err = d.detect()
+	})
+	if cleanErr := d.renderer.Close(); cleanErr != nil {
+		logger.Warnf("%v", cleanErr)
+	}
+	if err != nil {
+		logger.Fatalf("drift: %v", err)
+	}
+
+	fmt.Print(render(d.results))
+	for _, r := range d.results {
+		if r.Status != StatusInSync {
+			os.Exit(ExitDrift)
+		}
+	}
+}
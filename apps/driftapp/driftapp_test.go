@@ -0,0 +1,178 @@
+package driftapp
+
+import (
+	"flag"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/demingongo/ecx/apps/applyapp"
+	"github.com/demingongo/ecx/aws"
+	"github.com/demingongo/ecx/globals"
+	"github.com/demingongo/ecx/project"
+	"github.com/spf13/viper"
+)
+
+var update = flag.Bool("update", false, "record the cassettes of testdata again (with a fake account)")
+
+// cassette returns the client of a test: with -update, a Recorder
+// of fake saving into dir, the Replayer of dir otherwise.
+// The calls are checked against the cassette at the end of the test.
+func cassette(t *testing.T, dir string, fake aws.Client) aws.Client {
+	t.Helper()
+	if *update {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatal(err)
+		}
+		recorder, err := aws.NewRecorder(dir, fake)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return recorder
+	}
+	replayer, err := aws.NewReplayer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := replayer.Check(); err != nil {
+			t.Errorf("%s:\n%v", dir, err)
+		}
+	})
+	return replayer
+}
+
+// copyProject copies a project into a temporary directory
+// (apply writes ecx.state.json next to ecx.yaml).
+func copyProject(t *testing.T, src string) string {
+	t.Helper()
+	dir := t.TempDir()
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dir, strings.TrimPrefix(path, src))
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, content, 0644)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func copyFile(t *testing.T, src string, dst string) {
+	t.Helper()
+	content, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(dst, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// detect runs the drift detection of the project in dir.
+func detect(t *testing.T, dir string, client aws.Client) []Result {
+	t.Helper()
+	config, err := project.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err := project.LoadState(config.Env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := newDetector(client, config, state)
+	err = d.detect()
+	if cleanErr := d.renderer.Close(); cleanErr != nil {
+		t.Error(cleanErr)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d.results
+}
+
+// statuses returns the "<kind> <key>: <status>" of results.
+func statuses(results []Result) []string {
+	var result []string
+	for _, r := range results {
+		result = append(result, r.Kind+" "+r.Key+": "+string(r.Status))
+	}
+	return result
+}
+
+// The project of the apply tests: its service file references
+// the task definition ("ref:td-web.TaskDefinitionArn").
+func TestDetect(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// absolute: the project is opened from another directory
+	testdata, err := filepath.Abs("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := filepath.Abs(filepath.Join("..", "applyapp", "testdata", "project"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		viper.Reset()
+		_ = os.Chdir(wd)
+	})
+	globals.LoadGlobals()
+
+	t.Run("empty account", func(t *testing.T) {
+		dir := copyProject(t, src)
+		results := detect(t, dir, cassette(t, filepath.Join(testdata, "empty"), aws.NewFake()))
+
+		want := []string{
+			"targetGroup tg-app: missing",
+			"listener http-alb: missing",
+			"service app-flow: missing",
+		}
+		if got := statuses(results); !slices.Equal(got, want) {
+			t.Errorf("results:\n got %v\nwant %v", got, want)
+		}
+	})
+
+	// the state and the account of an apply
+	t.Run("applied", func(t *testing.T) {
+		dir := copyProject(t, src)
+		stateFile := filepath.Join(testdata, project.StateFileName)
+		fake := aws.NewFake()
+		if *update {
+			viper.Set("project", dir)
+			viper.Set("parallelism", 1)
+			viper.Set("wait-timeout", time.Minute)
+			applyapp.Run(fake)
+			copyFile(t, filepath.Join(dir, project.StateFileName), stateFile)
+		} else {
+			copyFile(t, stateFile, filepath.Join(dir, project.StateFileName))
+		}
+		results := detect(t, dir, cassette(t, filepath.Join(testdata, "applied"), fake))
+
+		want := []string{
+			"targetGroup tg-app: in sync",
+			"listener http-alb: in sync",
+			"rule app-flow/rules/0: in sync",
+			"service app-flow: in sync",
+		}
+		if got := statuses(results); !slices.Equal(got, want) {
+			t.Errorf("results:\n got %v\nwant %v", got, want)
+		}
+	})
+}
@@ -0,0 +1,641 @@
+package importapp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/huh/spinner"
+	"github.com/charmbracelet/log"
+	"github.com/demingongo/ecx/aws"
+	"github.com/demingongo/ecx/globals"
+	"github.com/demingongo/ecx/project"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// keys of the --cli-input-json of the create-* operations
+// (what describe-* returns on top of them is read-only)
+var (
+	targetGroupKeys = []string{
+		"Name", "Protocol", "ProtocolVersion", "Port", "VpcId", "HealthCheckProtocol",
+		"HealthCheckPort", "HealthCheckEnabled", "HealthCheckPath", "HealthCheckIntervalSeconds",
+		"HealthCheckTimeoutSeconds", "HealthyThresholdCount", "UnhealthyThresholdCount",
+		"Matcher", "TargetType", "IpAddressType",
+	}
+	loadBalancerKeys   = []string{"Name", "Subnets", "SecurityGroups", "Scheme", "Type", "IpAddressType"}
+	listenerKeys       = []string{"Port", "Protocol", "SslPolicy", "Certificates", "AlpnPolicy", "DefaultActions", "MutualAuthentication"}
+	ruleKeys           = []string{"Conditions", "Actions"}
+	taskDefinitionKeys = []string{
+		"family", "taskRoleArn", "executionRoleArn", "networkMode", "containerDefinitions",
+		"volumes", "placementConstraints", "requiresCompatibilities", "cpu", "memory",
+		"pidMode", "ipcMode", "proxyConfiguration", "inferenceAccelerators",
+		"ephemeralStorage", "runtimePlatform",
+	}
+	serviceKeys = []string{
+		"cluster", "serviceName", "taskDefinition", "loadBalancers", "serviceRegistries",
+		"desiredCount", "launchType", "capacityProviderStrategy", "platformVersion",
+		"deploymentConfiguration", "placementConstraints", "placementStrategy",
+		"networkConfiguration", "schedulingStrategy", "deploymentController",
+		"enableECSManagedTags", "propagateTags", "enableExecuteCommand",
+	}
+)
+
+// ecx.yaml written by import
+// (only the fields it sets)
+type projectFile struct {
+	Api             string     `yaml:"api"`
+	ApiVersion      string     `yaml:"apiVersion"`
+	TargetGroups    []keyValue `yaml:"targetGroups,omitempty"`
+	LoadBalancers   []keyValue `yaml:"loadBalancers,omitempty"`
+	Listeners       []listener `yaml:"listeners,omitempty"`
+	LogGroups       []logGroup `yaml:"logGroups,omitempty"`
+	TaskDefinitions []string   `yaml:"taskDefinitions,omitempty"`
+	Flows           []flow     `yaml:"flows,omitempty"`
+}
+
+type keyValue struct {
+	Key   string `yaml:"key"`
+	Value string `yaml:"value"`
+}
+
+type rule struct {
+	Value       string `yaml:"value"`
+	Priority    int    `yaml:"priority,omitempty"`
+	TargetGroup string `yaml:"targetGroup,omitempty"`
+}
+
+type listener struct {
+	Key          string `yaml:"key"`
+	Value        string `yaml:"value"`
+	LoadBalancer string `yaml:"loadBalancer"`
+	TargetGroup  string `yaml:"targetGroup,omitempty"`
+	Rules        []rule `yaml:"rules,omitempty"`
+}
+
+type logGroup struct {
+	Group     string `yaml:"group"`
+	Retention int    `yaml:"retention,omitempty"`
+}
+
+type flow struct {
+	Name                          string `yaml:"name"`
+	Service                       string `yaml:"service"`
+	TargetGroup                   string `yaml:"targetGroup,omitempty"`
+	HealthCheckGracePeriodSeconds int    `yaml:"healthCheckGracePeriodSeconds,omitempty"`
+}
+
+type importer struct {
+	client  aws.Client
+	cluster string
+	file    projectFile
+	state   *project.State
+	logger  *log.Logger
+
+	// arn => key
+	targetGroups map[string]string
+	// family => file
+	taskDefinitions map[string]string
+	logGroups       map[string]bool
+
+	written []string
+}
+
+// pick keeps the keys of a create-* operation
+// and drops the empty values.
+func pick(resource map[string]any, keys []string) map[string]any {
+	result := make(map[string]any)
+	for _, key := range keys {
+		v, ok := resource[key]
+		if !ok || isEmpty(v) {
+			continue
+		}
+		result[key] = v
+	}
+	return result
+}
+
+func isEmpty(v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case []any:
+		return len(v) == 0
+	case map[string]any:
+		return len(v) == 0
+	}
+	return false
+}
+
+// toInt converts a json number (or a number in a string).
+func toInt(v any) int {
+	var result int
+	if v != nil {
+		fmt.Sscan(fmt.Sprint(v), &result)
+	}
+	return result
+}
+
+// fileName makes a name usable as a file name.
+func fileName(name string) string {
+	name = strings.Trim(name, "/")
+	return strings.NewReplacer("/", "-", ":", "-", " ", "-").Replace(name) + ".json"
+}
+
+// write writes a resource file (relative to the project directory).
+func (im *importer) write(path string, content map[string]any) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(content); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, b.Bytes(), 0644); err != nil {
+		return err
+	}
+	im.written = append(im.written, path)
+	return nil
+}
+
+// adopt records an imported resource in the state so the next
+// apply finds it up to date. It is never deleted by destroy.
+func (im *importer) adopt(r project.StateResource, file string, extra ...string) error {
+	if file != "" {
+		hash, err := project.Hash(file, extra...)
+		if err != nil {
+			return err
+		}
+		r.Hash = hash
+	}
+	r.Adopted = true
+	im.state.Put(r)
+	return nil
+}
+
+// forwardTo returns the target group of actions that only
+// forward to one target group (what ecx sets with a "ref:").
+func forwardTo(actions any) string {
+	list, ok := actions.([]any)
+	if !ok || len(list) != 1 {
+		return ""
+	}
+	action, ok := list[0].(map[string]any)
+	if !ok || action["Type"] != "forward" {
+		return ""
+	}
+	if config, ok := action["ForwardConfig"].(map[string]any); ok {
+		if groups, ok := config["TargetGroups"].([]any); ok && len(groups) > 1 {
+			return ""
+		}
+	}
+	arn, _ := action["TargetGroupArn"].(string)
+	return arn
+}
+
+// conditions removes the legacy "Values" of the conditions
+// that have a *Config (both cannot be given to create-rule).
+func conditions(v any) any {
+	list, ok := v.([]any)
+	if !ok {
+		return v
+	}
+	var result []any
+	for _, item := range list {
+		c, ok := item.(map[string]any)
+		if !ok {
+			result = append(result, item)
+			continue
+		}
+		cleaned := make(map[string]any)
+		hasConfig := false
+		for k, v := range c {
+			if strings.HasSuffix(k, "Config") && !isEmpty(v) {
+				hasConfig = true
+			}
+		}
+		for k, v := range c {
+			if (k == "Values" && hasConfig) || isEmpty(v) {
+				continue
+			}
+			cleaned[k] = v
+		}
+		result = append(result, cleaned)
+	}
+	return result
+}
+
+// importTargetGroup writes the file of a target group
+// and returns its key.
+func (im *importer) importTargetGroup(arn string) (string, error) {
+	if key, ok := im.targetGroups[arn]; ok {
+		return key, nil
+	}
+	live, err := im.client.DescribeTargetGroupByArn(arn)
+	if err != nil {
+		return "", fmt.Errorf("target group %s: %v", arn, err)
+	}
+	name, _ := live["TargetGroupName"].(string)
+	live["Name"] = name
+	path := filepath.Join("targetgroups", fileName(name))
+	if err = im.write(path, pick(live, targetGroupKeys)); err != nil {
+		return "", err
+	}
+	im.targetGroups[arn] = name
+	im.file.TargetGroups = append(im.file.TargetGroups, keyValue{Key: name, Value: path})
+	return name, im.adopt(project.StateResource{
+		Kind:       project.KindTargetGroup,
+		Key:        name,
+		File:       path,
+		Arn:        arn,
+		Attributes: map[string]string{"name": name},
+	}, path)
+}
+
+func (im *importer) importLoadBalancer(name string) error {
+	live, err := im.client.DescribeLoadBalancerByName(name)
+	if err != nil && !errors.Is(err, aws.ErrNotFound) {
+		return fmt.Errorf("load balancer %s: %v", name, err)
+	}
+	if live == nil {
+		return fmt.Errorf("load balancer %s not found", name)
+	}
+	lbArn, _ := live["LoadBalancerArn"].(string)
+	live["Name"] = name
+	var subnets []any
+	if zones, ok := live["AvailabilityZones"].([]any); ok {
+		for _, z := range zones {
+			if zone, ok := z.(map[string]any); ok && zone["SubnetId"] != nil {
+				subnets = append(subnets, zone["SubnetId"])
+			}
+		}
+	}
+	live["Subnets"] = subnets
+	path := filepath.Join("loadbalancers", fileName(name))
+	if err = im.write(path, pick(live, loadBalancerKeys)); err != nil {
+		return err
+	}
+	im.file.LoadBalancers = append(im.file.LoadBalancers, keyValue{Key: name, Value: path})
+	attributes := map[string]string{"name": name}
+	for attribute, field := range map[string]string{"type": "Type", "dnsName": "DNSName", "vpcId": "VpcId", "scheme": "Scheme"} {
+		if v, ok := live[field].(string); ok {
+			attributes[attribute] = v
+		}
+	}
+	err = im.adopt(project.StateResource{
+		Kind:       project.KindLoadBalancer,
+		Key:        name,
+		File:       path,
+		Arn:        lbArn,
+		Attributes: attributes,
+	}, path)
+	if err != nil {
+		return err
+	}
+
+	listeners, err := im.client.DescribeListenersFull(lbArn)
+	if err != nil {
+		return fmt.Errorf("listeners of %s: %v", name, err)
+	}
+	for _, l := range listeners {
+		if err = im.importListener(name, lbArn, l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (im *importer) importListener(lbKey string, lbArn string, live map[string]any) error {
+	listenerArn, _ := live["ListenerArn"].(string)
+	protocol, _ := live["Protocol"].(string)
+	key := fmt.Sprintf("%s-%v", strings.ToLower(protocol), live["Port"])
+	l := listener{
+		Key:          key,
+		Value:        filepath.Join("listeners", fileName(key)),
+		LoadBalancer: "ref:" + lbKey,
+	}
+	content := pick(live, listenerKeys)
+	tgArn := forwardTo(live["DefaultActions"])
+	if tgArn != "" {
+		tgKey, err := im.importTargetGroup(tgArn)
+		if err != nil {
+			return err
+		}
+		l.TargetGroup = "ref:" + tgKey
+		delete(content, "DefaultActions")
+	}
+	if err := im.write(l.Value, content); err != nil {
+		return err
+	}
+
+	rules, err := im.client.DescribeRulesFull(listenerArn)
+	if err != nil {
+		return fmt.Errorf("rules of listener %s: %v", key, err)
+	}
+	for _, live := range rules {
+		if isDefault, _ := live["IsDefault"].(bool); isDefault {
+			continue
+		}
+		priority := toInt(live["Priority"])
+		r := rule{
+			Value:    filepath.Join("rules", fileName(fmt.Sprintf("%s-%d", key, priority))),
+			Priority: priority,
+		}
+		live["Conditions"] = conditions(live["Conditions"])
+		content := pick(live, ruleKeys)
+		ruleTgArn := forwardTo(live["Actions"])
+		if ruleTgArn != "" {
+			tgKey, err := im.importTargetGroup(ruleTgArn)
+			if err != nil {
+				return err
+			}
+			r.TargetGroup = "ref:" + tgKey
+			delete(content, "Actions")
+		}
+		if err = im.write(r.Value, content); err != nil {
+			return err
+		}
+		ruleArn, _ := live["RuleArn"].(string)
+		err = im.adopt(project.StateResource{
+			Kind: project.KindRule,
+			Key:  project.RuleId(key, len(l.Rules)),
+			File: r.Value,
+			Arn:  ruleArn,
+		}, r.Value, ruleTgArn, fmt.Sprint(priority), listenerArn)
+		if err != nil {
+			return err
+		}
+		l.Rules = append(l.Rules, r)
+	}
+
+	im.file.Listeners = append(im.file.Listeners, l)
+	return im.adopt(project.StateResource{
+		Kind: project.KindListener,
+		Key:  key,
+		File: l.Value,
+		Arn:  listenerArn,
+	}, l.Value, lbArn, tgArn)
+}
+
+// importTaskDefinition writes the file of the task definition
+// (and adds its log groups) and returns its family.
+func (im *importer) importTaskDefinition(arn string) (string, error) {
+	family := aws.ExtractFamilyFromRevision(arn)
+	if _, ok := im.taskDefinitions[family]; ok {
+		return family, nil
+	}
+	live, err := im.client.DescribeTaskDefinitionByArn(arn)
+	if err != nil {
+		return "", fmt.Errorf("task definition %s: %v", arn, err)
+	}
+	path := filepath.Join("taskdefinitions", fileName(family))
+	if err = im.write(path, pick(live, taskDefinitionKeys)); err != nil {
+		return "", err
+	}
+	im.taskDefinitions[family] = path
+	im.file.TaskDefinitions = append(im.file.TaskDefinitions, path)
+	tdArn, _ := live["taskDefinitionArn"].(string)
+	if tdArn == "" {
+		tdArn = arn
+	}
+	err = im.adopt(project.StateResource{
+		Kind: project.KindTaskDefinition,
+		Key:  path,
+		File: path,
+		Arn:  tdArn,
+	}, path)
+	if err != nil {
+		return "", err
+	}
+
+	// log groups
+	containers, _ := live["containerDefinitions"].([]any)
+	for _, c := range containers {
+		container, _ := c.(map[string]any)
+		logConfiguration, _ := container["logConfiguration"].(map[string]any)
+		options, _ := logConfiguration["options"].(map[string]any)
+		group, _ := options["awslogs-group"].(string)
+		if group == "" || im.logGroups[group] {
+			continue
+		}
+		im.logGroups[group] = true
+		lg := logGroup{Group: group}
+		results, err := im.client.DescribeLogGroups(group)
+		if err != nil {
+			return "", fmt.Errorf("log group %s: %v", group, err)
+		}
+		for _, r := range results {
+			if r.LogGroupName == group {
+				lg.Retention = r.RetentionInDays
+			}
+		}
+		im.file.LogGroups = append(im.file.LogGroups, lg)
+		im.state.Put(project.StateResource{
+			Kind:    project.KindLogGroup,
+			Key:     group,
+			Adopted: true,
+		})
+	}
+	return family, nil
+}
+
+func (im *importer) importService(serviceArn string) error {
+	live, err := im.client.DescribeServiceByArn(im.cluster, serviceArn)
+	if err != nil {
+		return fmt.Errorf("service %s: %v", serviceArn, err)
+	}
+	if status, _ := live["status"].(string); status != "ACTIVE" {
+		return nil
+	}
+	name, _ := live["serviceName"].(string)
+	f := flow{
+		Name:    name,
+		Service: filepath.Join("services", fileName(name)),
+	}
+	f.HealthCheckGracePeriodSeconds = toInt(live["healthCheckGracePeriodSeconds"])
+
+	taskDefinition, _ := live["taskDefinition"].(string)
+	family, err := im.importTaskDefinition(taskDefinition)
+	if err != nil {
+		return err
+	}
+	live["cluster"] = im.cluster
+	// the last revision registered by apply
+	live["taskDefinition"] = family
+
+	content := pick(live, serviceKeys)
+	var (
+		tgArn         string
+		containerName string
+		containerPort int
+	)
+	if loadBalancers, ok := live["loadBalancers"].([]any); ok && len(loadBalancers) == 1 {
+		lb, _ := loadBalancers[0].(map[string]any)
+		tgArn, _ = lb["targetGroupArn"].(string)
+	}
+	if tgArn != "" {
+		tgKey, err := im.importTargetGroup(tgArn)
+		if err != nil {
+			return err
+		}
+		f.TargetGroup = "ref:" + tgKey
+		delete(content, "loadBalancers")
+		containerName, containerPort, err = project.ServicePort(im.client, family)
+		if err != nil {
+			return err
+		}
+		lb := live["loadBalancers"].([]any)[0].(map[string]any)
+		if lb["containerName"] != containerName || fmt.Sprint(lb["containerPort"]) != fmt.Sprint(containerPort) {
+			im.logger.Warnf("service %s: apply registers %s:%d in the target group (the \"http\" port mapping or the first one), not %v:%v",
+				name, containerName, containerPort, lb["containerName"], lb["containerPort"])
+		}
+	}
+	if err = im.write(f.Service, content); err != nil {
+		return err
+	}
+	im.file.Flows = append(im.file.Flows, f)
+
+	hash, err := project.ServiceHash(f.Service, tgArn, containerName, containerPort, f.HealthCheckGracePeriodSeconds)
+	if err != nil {
+		return err
+	}
+	im.state.Put(project.StateResource{
+		Kind: project.KindService,
+		Key:  name,
+		File: f.Service,
+		Arn:  serviceArn,
+		Hash: hash,
+		Attributes: map[string]string{
+			"cluster": im.cluster,
+			"name":    name,
+		},
+		Adopted: true,
+	})
+	return nil
+}
+
+func (im *importer) run(loadBalancer string) error {
+	if loadBalancer != "" {
+		if err := im.importLoadBalancer(loadBalancer); err != nil {
+			return err
+		}
+	}
+	serviceArns, err := im.client.ListServices(im.cluster)
+	if err != nil {
+		return fmt.Errorf("services of %s: %v", im.cluster, err)
+	}
+	for _, serviceArn := range serviceArns {
+		if err = im.importService(serviceArn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeProject writes ecx.yaml.
+func (im *importer) writeProject(loadBalancer string) error {
+	var b bytes.Buffer
+	command := fmt.Sprintf("ecx import --cluster %s", im.cluster)
+	if loadBalancer != "" {
+		command += fmt.Sprintf(" --load-balancer %s", loadBalancer)
+	}
+	fmt.Fprintf(&b, "# generated by \"%s\"\n", command)
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(im.file); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	return os.WriteFile(project.FileName, b.Bytes(), 0644)
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return !errors.Is(err, fs.ErrNotExist)
+}
+
+func Run(client aws.Client) {
+	logger := globals.Logger
+
+	cluster := viper.GetString("cluster")
+	loadBalancer := viper.GetString("load-balancer")
+	dir := viper.GetString("project")
+	if dir == "" {
+		dir = "."
+	}
+
+	logger.Debugf("ecx import --cluster %s --load-balancer %s %s", cluster, loadBalancer, dir)
+
+	if cluster == "" {
+		logger.Fatal("--cluster is required")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		logger.Fatalf("%v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		logger.Fatalf("%v", err)
+	}
+	state := project.NewState(viper.GetString("env"))
+	if !viper.GetBool("force") {
+		for _, name := range []string{project.FileName, state.File()} {
+			if exists(name) {
+				logger.Fatalf("%s already exists in %s (use --force to overwrite it)", name, dir)
+			}
+		}
+	}
+
+	im := &importer{
+		client:  client,
+		cluster: cluster,
+		file: projectFile{
+			Api:        project.ValidApi,
+			ApiVersion: project.ValidApiVersion,
+		},
+		state:           state,
+		logger:          logger,
+		targetGroups:    make(map[string]string),
+		taskDefinitions: make(map[string]string),
+		logGroups:       make(map[string]bool),
+	}
+
+	var err error
+	globals.Spin(spinner.Globe, " Describing resources...", func() {
+		err = im.run(loadBalancer)
+	})
+	if err != nil {
+		logger.Fatalf("import: %v", err)
+	}
+
+	if err = im.writeProject(loadBalancer); err != nil {
+		logger.Fatalf("%v", err)
+	}
+	if err = im.state.Save(); err != nil {
+		logger.Fatalf("%v", err)
+	}
+
+	sort.Strings(im.written)
+	for _, path := range im.written {
+		fmt.Printf("wrote %s\n", path)
+	}
+	fmt.Printf("wrote %s\n", project.FileName)
+	if viper.GetBool("dummy") {
+		// the resources of the fake account are not recorded
+		fmt.Printf("Would import %d resource(s) into %s.\n", len(im.state.Resources), dir)
+		fmt.Printf("Dry run: %s was not written.\n", im.state.File())
+		return
+	}
+	fmt.Printf("Imported %d resource(s) into %s.\n", len(im.state.Resources), dir)
+}
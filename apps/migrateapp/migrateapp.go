@@ -0,0 +1,299 @@
+package migrateapp
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/demingongo/ecx/globals"
+	"github.com/demingongo/ecx/project"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// BackupFileName is the copy of ecx.yaml before migration.
+const BackupFileName = project.FileName + ".bak"
+
+// blockStyle turns a json document into block style yaml.
+// Strings that would not stay strings without quotes keep them.
+func blockStyle(n *yaml.Node) {
+	n.Style = 0
+	if n.Kind == yaml.ScalarNode && n.Tag == "!!str" {
+		var value any
+		if err := yaml.Unmarshal([]byte(n.Value), &value); err != nil || value != n.Value {
+			n.Style = yaml.DoubleQuotedStyle
+		}
+	}
+	for _, child := range n.Content {
+		blockStyle(child)
+	}
+}
+
+// inlinable reports whether the value is a resource file that can be
+// inlined (references, templates and paths with variables are kept).
+func inlinable(n *yaml.Node) bool {
+	if n == nil || n.Kind != yaml.ScalarNode || n.Value == "" {
+		return false
+	}
+	if _, ok := project.IsRef(n.Value); ok {
+		return false
+	}
+	return !project.IsTemplate(n.Value) && !strings.Contains(n.Value, "${")
+}
+
+// keepIds adds a key (or a name for flows) to the items identified
+// by a file that is going to be inlined, so they keep the same id
+// in ecx.state.json. It returns the ids that were added.
+func keepIds(root *yaml.Node) []string {
+	var ids []string
+	prepend := func(item *yaml.Node, key string, value string) {
+		item.Content = append([]*yaml.Node{
+			{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+			{Kind: yaml.ScalarNode, Tag: "!!str", Value: value},
+		}, item.Content...)
+		ids = append(ids, value)
+	}
+	for _, section := range []string{"targetGroups", "loadBalancers", "listeners"} {
+		for _, item := range project.Sequence(project.MappingValue(root, section)) {
+			if project.MappingValue(item, "key") == nil && inlinable(project.MappingValue(item, "value")) {
+				prepend(item, "key", project.MappingValue(item, "value").Value)
+			}
+		}
+	}
+	for _, flow := range project.Sequence(project.MappingValue(root, "flows")) {
+		if project.MappingValue(flow, "name") != nil {
+			continue
+		}
+		id := project.MappingValue(flow, "service")
+		if id == nil || id.Value == "" {
+			id = project.MappingValue(flow, "targetGroup")
+		}
+		if inlinable(id) {
+			prepend(flow, "name", id.Value)
+		}
+	}
+	return ids
+}
+
+// rename is an id of the project that changes with the migration,
+// with the kinds of the resources of the state it is the key of.
+type rename struct {
+	from  string
+	to    string
+	kinds []string
+}
+
+// renames returns the ids that are not the same before and after
+// the migration (the resources are in the same order).
+func renames(before project.Config, after project.Config) []rename {
+	var result []rename
+	add := func(from string, to string, kinds ...string) {
+		if from != to {
+			result = append(result, rename{from: from, to: to, kinds: kinds})
+		}
+	}
+	for i, targetGroup := range after.TargetGroups {
+		add(before.TargetGroups[i].Id(), targetGroup.Id(), project.KindTargetGroup)
+	}
+	for i, loadBalancer := range after.LoadBalancers {
+		add(before.LoadBalancers[i].Id(), loadBalancer.Id(), project.KindLoadBalancer)
+	}
+	for i, listener := range after.Listeners {
+		add(before.Listeners[i].Id(), listener.Id(), project.KindListener, project.KindRule)
+	}
+	for i, taskDefinition := range after.TaskDefinitions {
+		add(before.TaskDefinitions[i].Id(), taskDefinition.Id(), project.KindTaskDefinition)
+	}
+	for i, flow := range after.Flows {
+		// "<flow>/rules/<i>" and "<flow>/targetGroup" too
+		add(before.Flows[i].Id(), flow.Id(), project.KindService, project.KindRule, project.KindTargetGroup)
+	}
+	return result
+}
+
+// inline replaces a resource file path with its content.
+func inline(n *yaml.Node) (bool, error) {
+	if !inlinable(n) {
+		return false, nil
+	}
+	file := n.Value
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return false, err
+	}
+	var body yaml.Node
+	if err = yaml.Unmarshal(content, &body); err != nil {
+		return false, fmt.Errorf("%s: %v", file, err)
+	}
+	if len(body.Content) == 0 || body.Content[0].Kind != yaml.MappingNode {
+		return false, fmt.Errorf("%s: not a json object", file)
+	}
+	mapping := body.Content[0]
+	blockStyle(mapping)
+	mapping.HeadComment = n.HeadComment
+	mapping.LineComment = n.LineComment
+	mapping.FootComment = n.FootComment
+	*n = *mapping
+	return true, nil
+}
+
+func Run() {
+	logger := globals.Logger
+
+	logger.Debugf("ecx migrate %s", viper.GetString("project"))
+
+	if dir := viper.GetString("project"); dir != "" {
+		if err := os.Chdir(dir); err != nil {
+			logger.Fatalf("project: %v", err)
+		}
+	}
+
+	content, err := os.ReadFile(project.FileName)
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+	var doc yaml.Node
+	if err = yaml.Unmarshal(content, &doc); err != nil {
+		logger.Fatalf("%s: %v", project.FileName, err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		logger.Fatalf("%s: not a project file", project.FileName)
+	}
+	root := doc.Content[0]
+
+	var api, apiVersion *yaml.Node
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		switch root.Content[i].Value {
+		case "api":
+			api = root.Content[i+1]
+		case "apiVersion":
+			apiVersion = root.Content[i+1]
+		}
+	}
+	if api == nil || api.Value != project.ValidApi {
+		logger.Fatalf("Value for \"%s\" is not valid. Expected \"%s\".", "api", project.ValidApi)
+	}
+	if apiVersion == nil {
+		logger.Fatalf("Value for \"%s\" is not valid. Expected \"%s\".", "apiVersion", "0.1")
+	}
+
+	doInline := viper.GetBool("inline")
+	if apiVersion.Value == project.ValidApiVersion && !doInline {
+		fmt.Printf("%s is already in apiVersion %s\n", project.FileName, project.ValidApiVersion)
+		return
+	}
+	apiVersion.Value = project.ValidApiVersion
+
+	var inlined, ids, envs []string
+	var renamed []rename
+	if doInline {
+		var before, after project.Config
+		if err = doc.Decode(&before); err != nil {
+			logger.Fatalf("%s: %v", project.FileName, err)
+		}
+		ids = keepIds(root)
+
+		// the ids of the resources that keep no file
+		// are the paths of their inline bodies
+		values := project.ResourceValues(&doc)
+		files := make([]string, len(values))
+		for i, rv := range values {
+			files[i] = rv.Node.Value
+			if inlinable(rv.Node) {
+				rv.Node.Value = project.InlinePrefix + rv.Path
+			}
+		}
+		if err = doc.Decode(&after); err != nil {
+			logger.Fatalf("%s: %v", project.FileName, err)
+		}
+		renamed = renames(before, after)
+		envs = append(envs, "")
+		for env := range before.Environments {
+			envs = append(envs, env)
+		}
+		sort.Strings(envs[1:])
+
+		for i, rv := range values {
+			rv.Node.Value = files[i]
+			ok, err := inline(rv.Node)
+			if err != nil {
+				logger.Fatalf("%s: %v", rv.Path, err)
+			}
+			if ok {
+				logger.Debugf("%s: inlined %s", rv.Path, files[i])
+				inlined = append(inlined, files[i])
+			}
+		}
+	}
+
+	var b bytes.Buffer
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err = enc.Encode(&doc); err != nil {
+		logger.Fatalf("%v", err)
+	}
+	enc.Close()
+
+	if viper.GetBool("dry-run") {
+		fmt.Print(b.String())
+		return
+	}
+
+	// the keys of the state files (of every environment) follow
+	// the ids that changed, or the next apply would create the
+	// resources again
+	var states []*project.State
+	var renamedKeys []string
+	if len(renamed) > 0 {
+		for _, env := range envs {
+			state, err := project.LoadState(env)
+			if err != nil {
+				logger.Fatalf("%v", err)
+			}
+			var n int
+			for _, r := range renamed {
+				if state.Rename(r.from, r.to, r.kinds...) > 0 {
+					renamedKeys = append(renamedKeys, fmt.Sprintf("%s: %s => %s", state.File(), r.from, r.to))
+					n++
+				}
+			}
+			if n > 0 {
+				states = append(states, state)
+			}
+		}
+	}
+
+	if err = os.WriteFile(BackupFileName, content, 0644); err != nil {
+		logger.Fatalf("%v", err)
+	}
+	if err = os.WriteFile(project.FileName, b.Bytes(), 0644); err != nil {
+		logger.Fatalf("%v", err)
+	}
+	for _, state := range states {
+		if err = state.Save(); err != nil {
+			logger.Fatalf("%v", err)
+		}
+	}
+
+	fmt.Printf("%s migrated to apiVersion %s (previous version in %s)\n", project.FileName, project.ValidApiVersion, BackupFileName)
+	if len(inlined) > 0 {
+		fmt.Printf("%d resource file(s) inlined (the files were not deleted):\n", len(inlined))
+		for _, file := range inlined {
+			fmt.Printf("  - %s\n", file)
+		}
+	}
+	if len(ids) > 0 {
+		fmt.Printf("%d key(s)/name(s) added to keep the ids of the state:\n", len(ids))
+		for _, id := range ids {
+			fmt.Printf("  - %s\n", id)
+		}
+	}
+	if len(renamedKeys) > 0 {
+		fmt.Printf("%d id(s) renamed in the state:\n", len(renamedKeys))
+		for _, key := range renamedKeys {
+			fmt.Printf("  - %s\n", key)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package outputapp
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/demingongo/ecx/globals"
+	"github.com/demingongo/ecx/project"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	envNamePattern   = regexp.MustCompile(`[^A-Za-z0-9_]`)
+	camelCasePattern = regexp.MustCompile(`([a-z0-9])([A-Z])`)
+)
+
+// envName turns an output name into an environment variable name
+// (e.g. "alb-dns" or "albDns" => "ALB_DNS").
+func envName(name string) string {
+	name = camelCasePattern.ReplaceAllString(name, "${1}_${2}")
+	return strings.ToUpper(envNamePattern.ReplaceAllString(name, "_"))
+}
+
+// shellQuote quotes a value for a shell (source or eval).
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+func format(outputs map[string]string, f string) (string, error) {
+	switch f {
+	case "json":
+		content, err := json.MarshalIndent(outputs, "", "  ")
+		return string(content) + "\n", err
+	case "yaml":
+		content, err := yaml.Marshal(outputs)
+		return string(content), err
+	case "env":
+		var b strings.Builder
+		for _, name := range project.OutputNames(outputs) {
+			fmt.Fprintf(&b, "%s=%s\n", envName(name), shellQuote(outputs[name]))
+		}
+		return b.String(), nil
+	}
+	return "", fmt.Errorf("unknown format \"%s\" (expected json, env or yaml)", f)
+}
+
+func Run(name string) {
+	logger := globals.Logger
+
+	logger.Debugf("ecx output %s", viper.GetString("project"))
+
+	if dir := viper.GetString("project"); dir != "" {
+		if err := os.Chdir(dir); err != nil {
+			logger.Fatalf("project: %v", err)
+		}
+	}
+
+	// the outputs of the last apply
+	state, err := project.LoadState(viper.GetString("env"))
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+	if len(state.Outputs) == 0 {
+		logger.Fatalf("no outputs in %s (is there an outputs section in %s? was it applied?)", state.File(), project.FileName)
+	}
+
+	if name != "" {
+		value, ok := state.Outputs[name]
+		if !ok {
+			logger.Fatalf("unknown output \"%s\"", name)
+		}
+		fmt.Println(value)
+		return
+	}
+
+	result, err := format(state.Outputs, viper.GetString("format"))
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+	fmt.Print(result)
+}
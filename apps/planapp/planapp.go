@@ -0,0 +1,792 @@
+package planapp
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/huh/spinner"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/demingongo/ecx/aws"
+	"github.com/demingongo/ecx/globals"
+	"github.com/demingongo/ecx/project"
+	"github.com/spf13/viper"
+)
+
+type Action string
+
+const (
+	ActionCreate   Action = "create"
+	ActionUpdate   Action = "update"
+	ActionNoop     Action = "no-op"
+	ActionConflict Action = "conflict"
+
+	knownAfterApply = "(known after apply)"
+)
+
+// Ref is a "ref:" value (or a raw arn) used by a resource
+// and what it would resolve to.
+type Ref struct {
+	Field string
+	Value string
+	Arn   string
+}
+
+type Change struct {
+	Action Action
+	Kind   string
+	Key    string
+	Name   string
+	Arn    string
+	Detail string
+	Refs   []Ref
+}
+
+type planner struct {
+	client  aws.Client
+	config  *project.Config
+	state   *project.State
+	changes []Change
+
+	// resources planned so far
+	refs *project.Refs
+
+	// priorities claimed per listener (key or arn)
+	priorities map[string]map[int]string
+
+	// templated resource files
+	renderer *project.Renderer
+}
+
+var (
+	createStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("2"))
+	updateStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("3"))
+	noopStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+	conflictStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("1")).Bold(true)
+
+	subtleText = lipgloss.NewStyle().Foreground(lipgloss.Color("8")).Render
+)
+
+func newPlanner(client aws.Client, config *project.Config, state *project.State) *planner {
+	p := &planner{
+		client:     client,
+		config:     config,
+		state:      state,
+		refs:       project.NewRefs(),
+		priorities: make(map[string]map[int]string),
+	}
+	p.renderer = project.NewRenderer(config, p.ref)
+	return p
+}
+
+// ref returns what {{ ref "key" }} would resolve to in templates.
+func (p *planner) ref(key string) (string, error) {
+	if value, err := p.refs.Resolve("ref:" + key); err == nil && value != "" {
+		return value, nil
+	}
+	return knownAfterApply, nil
+}
+
+func (p *planner) add(change Change) {
+	p.changes = append(p.changes, change)
+}
+
+// fromState fills the change if the resource was created by a previous
+// apply and returns false if it is not in the state file.
+func (p *planner) fromState(change *Change, kind string, id string, filepath string, extra ...string) (bool, error) {
+	return p.fromStateHash(change, kind, id, func() (string, error) {
+		return project.Hash(filepath, extra...)
+	})
+}
+
+// fromStateHash is fromState with the hash apply records
+// for the resource (computed only if it is in the state file).
+func (p *planner) fromStateHash(change *Change, kind string, id string, hashOf func() (string, error)) (bool, error) {
+	r, ok := p.state.Get(kind, id)
+	if !ok || (r.Arn == "" && kind != project.KindLogGroup) {
+		return false, nil
+	}
+	hash, err := hashOf()
+	if err != nil {
+		return true, err
+	}
+	change.Arn = r.Arn
+	if r.Hash == hash {
+		change.Action = ActionNoop
+		change.Detail = "unchanged since last apply"
+	} else {
+		change.Action = ActionUpdate
+		change.Detail = "changed since last apply"
+	}
+	return true, nil
+}
+
+// resolve returns the arn a "ref:" value would resolve to.
+func (p *planner) resolve(field string, value string, kind string) (Ref, error) {
+	arn, err := p.refs.Resolve(value, kind)
+	return Ref{Field: field, Value: value, Arn: arn}, err
+}
+
+// claimPriority registers a rule priority on a listener
+// and returns who already claimed it (if any).
+func (p *planner) claimPriority(listener string, priority int, owner string) string {
+	if priority <= 0 {
+		return ""
+	}
+	if p.priorities[listener] == nil {
+		p.priorities[listener] = make(map[int]string)
+	}
+	if claimedBy, ok := p.priorities[listener][priority]; ok {
+		return claimedBy
+	}
+	p.priorities[listener][priority] = owner
+	return ""
+}
+
+// releasePriority removes the priorities claimed by a live rule
+// (it is modified in place by the rule of the project).
+func (p *planner) releasePriority(listener string, ruleArn string) {
+	for priority, claimedBy := range p.priorities[listener] {
+		if claimedBy == ruleArn {
+			delete(p.priorities[listener], priority)
+		}
+	}
+}
+
+// claimLivePriorities registers the priorities of
+// the rules already existing on a listener (none if it does not exist).
+func (p *planner) claimLivePriorities(listener string, listenerArn string) error {
+	rules, err := p.client.DescribeRules(listenerArn)
+	if errors.Is(err, aws.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("checking listener %s: %v", listener, err)
+	}
+	for _, rule := range rules {
+		if priority, err := strconv.Atoi(rule.Priority); err == nil {
+			p.claimPriority(listener, priority, rule.RuleArn)
+		}
+	}
+	return nil
+}
+
+func (p *planner) planTargetGroup(targetGroup project.TargetGroup) error {
+	if targetGroup.Value == "" {
+		return nil
+	}
+	file, err := p.renderer.Render(targetGroup.Value)
+	if err != nil {
+		return fmt.Errorf("checking target group %s: %v", targetGroup.Key, err)
+	}
+	content, err := project.ReadResourceFile(file)
+	if err != nil {
+		return fmt.Errorf("checking target group %s: %v", targetGroup.Key, err)
+	}
+	change := Change{
+		Action: ActionCreate,
+		Kind:   "target group",
+		Key:    targetGroup.Key,
+		Name:   content.GetString("Name"),
+		Arn:    knownAfterApply,
+	}
+	inState, err := p.fromState(&change, project.KindTargetGroup, targetGroup.Id(), file)
+	if err != nil {
+		return err
+	}
+	if !inState && change.Name != "" {
+		results, err := p.client.DescribeTargetGroupsWithNames([]string{change.Name})
+		if err != nil && !errors.Is(err, aws.ErrNotFound) {
+			return fmt.Errorf("checking target group %s: %v", targetGroup.Value, err)
+		}
+		if len(results) > 0 {
+			change.Action = ActionNoop
+			change.Arn = results[0].TargetGroupArn
+			change.Detail = "already exists"
+		}
+	}
+	if targetGroup.Key != "" {
+		p.refs.Set(project.KindTargetGroup, targetGroup.Key, aws.TargetGroup{TargetGroupArn: change.Arn}, file)
+	}
+	p.add(change)
+	return nil
+}
+
+func (p *planner) planLoadBalancer(loadBalancer project.LoadBalancer) error {
+	if loadBalancer.Value == "" {
+		return nil
+	}
+	file, err := p.renderer.Render(loadBalancer.Value)
+	if err != nil {
+		return fmt.Errorf("checking load balancer %s: %v", loadBalancer.Key, err)
+	}
+	content, err := project.ReadResourceFile(file)
+	if err != nil {
+		return fmt.Errorf("checking load balancer %s: %v", loadBalancer.Key, err)
+	}
+	change := Change{
+		Action: ActionCreate,
+		Kind:   "load balancer",
+		Key:    loadBalancer.Key,
+		Name:   content.GetString("Name"),
+		Arn:    knownAfterApply,
+	}
+	inState, err := p.fromState(&change, project.KindLoadBalancer, loadBalancer.Id(), file)
+	if err != nil {
+		return err
+	}
+	if change.Action == ActionUpdate {
+		change.Action = ActionNoop
+		change.Detail = "changed since last apply (load balancers are not updated in place)"
+	}
+	if !inState && change.Name != "" {
+		results, err := p.client.DescribeLoadBalancersWithNames([]string{change.Name})
+		if err != nil && !errors.Is(err, aws.ErrNotFound) {
+			return fmt.Errorf("checking load balancer %s: %v", loadBalancer.Key, err)
+		}
+		if len(results) > 0 {
+			change.Action = ActionNoop
+			change.Arn = results[0].LoadBalancerArn
+			change.Detail = "already exists"
+		}
+	}
+	if loadBalancer.Key != "" {
+		p.refs.Set(project.KindLoadBalancer, loadBalancer.Key, aws.LoadBalancer{LoadBalancerArn: change.Arn}, file)
+	}
+	p.add(change)
+	return nil
+}
+
+func (p *planner) planRule(owner string, id string, listener string, value string, priority int, refs []Ref) error {
+	file, err := p.renderer.Render(value)
+	if err != nil {
+		return fmt.Errorf("checking rule %s: %v", value, err)
+	}
+	content, err := project.ReadResourceFile(file)
+	if err != nil {
+		return fmt.Errorf("checking rule %s: %v", value, err)
+	}
+	rulePriority := priority
+	if priority <= 0 {
+		priority = content.GetInt("Priority")
+	}
+	change := Change{
+		Action: ActionCreate,
+		Kind:   "rule",
+		Key:    owner,
+		Name:   value,
+		Arn:    knownAfterApply,
+		Detail: fmt.Sprintf("priority %d", priority),
+		Refs:   refs,
+	}
+	// values the rule is applied with (see applyapp)
+	var tgArn, listenerArn string
+	for _, ref := range refs {
+		switch ref.Field {
+		case "targetGroup":
+			tgArn = ref.Arn
+		case "listener":
+			listenerArn = ref.Arn
+		}
+	}
+	inState, err := p.fromState(&change, project.KindRule, id, file, tgArn, fmt.Sprint(rulePriority), listenerArn)
+	if err != nil {
+		return err
+	}
+	if inState {
+		p.claimPriority(listener, priority, value)
+		change.Detail = fmt.Sprintf("priority %d, %s", priority, change.Detail)
+		p.add(change)
+		return nil
+	}
+	if listenerArn != "" && listenerArn != knownAfterApply {
+		existing, err := project.FindRule(p.client, listenerArn, file, rulePriority)
+		if err != nil {
+			return fmt.Errorf("checking rule %s: %v", value, err)
+		}
+		if existing.RuleArn != "" {
+			p.releasePriority(listener, existing.RuleArn)
+			change.Action = ActionUpdate
+			change.Arn = existing.RuleArn
+			change.Detail = fmt.Sprintf("priority %d, existing rule (priority %s) is modified in place", priority, existing.Priority)
+		}
+	}
+	if claimedBy := p.claimPriority(listener, priority, value); claimedBy != "" {
+		change.Action = ActionConflict
+		change.Detail = fmt.Sprintf("priority %d is already used by %s", priority, claimedBy)
+	}
+	p.add(change)
+	return nil
+}
+
+func (p *planner) planListener(listener project.Listener) error {
+	if listener.Value == "" {
+		return nil
+	}
+	file, err := p.renderer.Render(listener.Value)
+	if err != nil {
+		return fmt.Errorf("checking listener %s: %v", listener.Key, err)
+	}
+	content, err := project.ReadResourceFile(file)
+	if err != nil {
+		return fmt.Errorf("checking listener %s: %v", listener.Key, err)
+	}
+	change := Change{
+		Action: ActionCreate,
+		Kind:   "listener",
+		Key:    listener.Key,
+		Name:   fmt.Sprintf("%s:%d", content.GetString("Protocol"), content.GetInt("Port")),
+		Arn:    knownAfterApply,
+	}
+	// refLbArn is the arn apply creates the listener with
+	// (and hashes), lbArn the load balancer it ends up on
+	var refLbArn, lbArn string
+	if listener.LoadBalancer != "" {
+		ref, err := p.resolve("loadBalancer", listener.LoadBalancer, project.KindLoadBalancer)
+		if err != nil {
+			return fmt.Errorf("listener %s: %v", listener.Key, err)
+		}
+		refLbArn = ref.Arn
+		lbArn = ref.Arn
+		change.Refs = append(change.Refs, ref)
+	} else {
+		lbArn = content.GetString("LoadBalancerArn")
+	}
+	if listener.TargetGroup != "" {
+		ref, err := p.resolve("targetGroup", listener.TargetGroup, project.KindTargetGroup)
+		if err != nil {
+			return fmt.Errorf("listener %s: %v", listener.Key, err)
+		}
+		change.Refs = append(change.Refs, ref)
+	}
+
+	var tgArn string
+	if len(change.Refs) > 0 && change.Refs[len(change.Refs)-1].Field == "targetGroup" {
+		tgArn = change.Refs[len(change.Refs)-1].Arn
+	}
+	inState, err := p.fromStateHash(&change, project.KindListener, listener.Id(), func() (string, error) {
+		return project.ListenerHash(file, refLbArn, tgArn)
+	})
+	if err != nil {
+		return err
+	}
+
+	// a load balancer cannot have two listeners on the same port
+	listenerId := listener.Key
+	if inState {
+		if err := p.claimLivePriorities(listenerId, change.Arn); err != nil {
+			return err
+		}
+	} else if lbArn != "" && lbArn != knownAfterApply {
+		existing, err := project.FindListener(p.client, lbArn, file)
+		if err != nil {
+			return fmt.Errorf("checking listener %s: %v", listener.Value, err)
+		}
+		if existing.ListenerArn != "" {
+			change.Action = ActionUpdate
+			change.Arn = existing.ListenerArn
+			change.Detail = fmt.Sprintf("port %d already has a listener, it is modified in place", existing.Port)
+			if err := p.claimLivePriorities(listenerId, existing.ListenerArn); err != nil {
+				return err
+			}
+		}
+	}
+	if listener.Key != "" {
+		p.refs.Set(project.KindListener, listener.Key, aws.Listener{ListenerArn: change.Arn}, file)
+	}
+	p.add(change)
+
+	for i, rule := range listener.Rules {
+		var refs []Ref
+		ref, err := p.resolve("targetGroup", rule.TargetGroup, project.KindTargetGroup)
+		if err != nil {
+			return fmt.Errorf("listener %s: %v", listener.Key, err)
+		}
+		if rule.TargetGroup != "" {
+			refs = append(refs, ref)
+		}
+		refs = append(refs, Ref{Field: "listener", Value: listener.Key, Arn: change.Arn})
+		if err := p.planRule(listener.Key, project.RuleId(listener.Id(), i), listenerId, rule.Value, rule.Priority, refs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *planner) planLogGroup(logGroup project.LogGroup) error {
+	change := Change{
+		Action: ActionCreate,
+		Kind:   "log group",
+		Name:   logGroup.Group,
+		Arn:    knownAfterApply,
+	}
+	if _, ok := p.state.Get(project.KindLogGroup, logGroup.Group); ok {
+		change.Detail = "created by a previous apply"
+	}
+	results, err := p.client.DescribeLogGroups(logGroup.Group)
+	if err != nil {
+		return fmt.Errorf("checking log group %s: %v", logGroup.Group, err)
+	}
+	ref := aws.LogGroup{LogGroupName: logGroup.Group, RetentionInDays: logGroup.Retention, Arn: knownAfterApply}
+	for _, lg := range results {
+		if lg.LogGroupName != logGroup.Group {
+			continue
+		}
+		change.Action = ActionNoop
+		change.Arn = lg.Arn
+		if logGroup.Retention > 0 && lg.RetentionInDays != logGroup.Retention {
+			change.Action = ActionUpdate
+			change.Detail = fmt.Sprintf("retention %d => %d", lg.RetentionInDays, logGroup.Retention)
+		}
+		ref = lg
+		break
+	}
+	p.refs.Set(project.KindLogGroup, logGroup.Id(), ref, "")
+	p.add(change)
+	return nil
+}
+
+func (p *planner) planTaskDefinition(taskDefinition project.TaskDefinition) error {
+	file, err := p.renderer.Render(taskDefinition.Value)
+	if err != nil {
+		return fmt.Errorf("checking task definition %s: %v", taskDefinition.Value, err)
+	}
+	content, err := project.ReadResourceFile(file)
+	if err != nil {
+		return fmt.Errorf("checking task definition %s: %v", taskDefinition.Value, err)
+	}
+	change := Change{
+		Action: ActionCreate,
+		Kind:   "task definition",
+		Key:    taskDefinition.Id(),
+		Name:   content.GetString("family"),
+		Arn:    knownAfterApply,
+	}
+	inState, err := p.fromState(&change, project.KindTaskDefinition, taskDefinition.Id(), file)
+	if err != nil {
+		return err
+	}
+	if change.Action == ActionUpdate {
+		change.Detail = fmt.Sprintf("new revision after %s", change.Arn)
+		change.Arn = knownAfterApply
+	}
+	if !inState && change.Name != "" {
+		// a new revision is registered
+		td, err := p.client.DescribeTaskDefinition(change.Name)
+		if err != nil && !errors.Is(err, aws.ErrNotFound) {
+			return fmt.Errorf("checking task definition %s: %v", taskDefinition.Value, err)
+		}
+		if err == nil && td.TaskDefinitionArn != "" {
+			change.Action = ActionUpdate
+			change.Detail = fmt.Sprintf("new revision after %s", td.TaskDefinitionArn)
+		}
+	}
+	ref := project.TaskDefinitionRef{
+		TaskDefinitionArn: knownAfterApply,
+		Family:            change.Name,
+		Revision:          knownAfterApply,
+	}
+	if change.Arn != knownAfterApply {
+		ref = project.NewTaskDefinitionRef(change.Arn)
+	}
+	p.refs.Set(project.KindTaskDefinition, taskDefinition.Id(), ref, file)
+	p.add(change)
+	return nil
+}
+
+func (p *planner) planFlow(i int, flow project.Flow) error {
+	flowKey := flow.Name
+	if flowKey == "" {
+		flowKey = fmt.Sprintf("flows[%d]", i)
+	}
+
+	var tgArn string
+	if flow.TargetGroup != "" {
+		if _, ok := project.IsRef(flow.TargetGroup); ok {
+			ref, err := p.resolve("targetGroup", flow.TargetGroup, project.KindTargetGroup)
+			if err != nil {
+				return fmt.Errorf("flow %s: %v", flowKey, err)
+			}
+			tgArn = ref.Arn
+		} else {
+			file, err := p.renderer.Render(flow.TargetGroup)
+			if err != nil {
+				return fmt.Errorf("flow %s: %v", flowKey, err)
+			}
+			content, err := project.ReadResourceFile(file)
+			if err != nil {
+				return fmt.Errorf("flow %s: %v", flowKey, err)
+			}
+			change := Change{
+				Action: ActionCreate,
+				Kind:   "target group",
+				Key:    flowKey,
+				Name:   content.GetString("Name"),
+				Arn:    knownAfterApply,
+			}
+			inState, err := p.fromState(&change, project.KindTargetGroup, flow.Id()+"/targetGroup", file)
+			if err != nil {
+				return err
+			}
+			if !inState && change.Name != "" {
+				results, err := p.client.DescribeTargetGroupsWithNames([]string{change.Name})
+				if err != nil && !errors.Is(err, aws.ErrNotFound) {
+					return fmt.Errorf("flow %s: %v", flowKey, err)
+				}
+				if len(results) > 0 {
+					change.Action = ActionNoop
+					change.Arn = results[0].TargetGroupArn
+					change.Detail = "already exists"
+				}
+			}
+			tgArn = change.Arn
+			p.add(change)
+		}
+	}
+
+	if tgArn != "" {
+		for i, rule := range flow.Rules {
+			ref, err := p.resolve("listener", rule.Listener, project.KindListener)
+			if err != nil {
+				return fmt.Errorf("flow %s: %v", flowKey, err)
+			}
+			listenerId := rule.Listener
+			if key, ok := project.IsRef(rule.Listener); ok {
+				listenerId = key
+			} else if _, claimed := p.priorities[listenerId]; !claimed && rule.Listener != "" {
+				if err := p.claimLivePriorities(listenerId, rule.Listener); err != nil {
+					return fmt.Errorf("flow %s: %v", flowKey, err)
+				}
+			}
+			refs := []Ref{
+				{Field: "targetGroup", Value: flow.TargetGroup, Arn: tgArn},
+				ref,
+			}
+			if err := p.planRule(flowKey, project.RuleId(flow.Id(), i), listenerId, rule.Value, rule.Priority, refs); err != nil {
+				return err
+			}
+		}
+	}
+
+	if flow.Service == "" {
+		p.refs.Set(project.KindFlow, flow.Id(), project.FlowRef{TargetGroupArn: tgArn}, "")
+		return nil
+	}
+	file, err := p.renderer.Render(flow.Service)
+	if err != nil {
+		return fmt.Errorf("flow %s: %v", flowKey, err)
+	}
+	content, err := project.ReadResourceFile(file)
+	if err != nil {
+		return fmt.Errorf("flow %s: %v", flowKey, err)
+	}
+	change := Change{
+		Action: ActionCreate,
+		Kind:   "service",
+		Key:    flowKey,
+		Name:   content.GetString("serviceName"),
+		Arn:    knownAfterApply,
+		Detail: fmt.Sprintf("task definition %s", content.GetString("taskDefinition")),
+	}
+	if tgArn != "" {
+		change.Refs = append(change.Refs, Ref{Field: "targetGroup", Value: flow.TargetGroup, Arn: tgArn})
+	}
+	if r, ok := p.state.Get(project.KindService, flow.Id()); ok && r.Arn != "" {
+		change.Action = ActionUpdate
+		change.Arn = r.Arn
+		change.Detail = fmt.Sprintf("%s (changed since last apply)", change.Detail)
+		// a new revision of the task definition is registered by apply:
+		// the service is updated to it, whatever its hash
+		if content.GetString("taskDefinition") != knownAfterApply {
+			var (
+				containerName string
+				containerPort int
+			)
+			if tgArn != "" && tgArn != knownAfterApply {
+				containerName, containerPort, err = project.ServicePort(p.client, content.GetString("taskDefinition"))
+				if err != nil {
+					return fmt.Errorf("flow %s: %v", flowKey, err)
+				}
+			}
+			hash, err := project.ServiceHash(file, tgArn, containerName, containerPort, flow.HealthCheckGracePeriodSeconds)
+			if err != nil {
+				return fmt.Errorf("flow %s: %v", flowKey, err)
+			}
+			if r.Hash == hash {
+				change.Action = ActionNoop
+				change.Detail = "unchanged since last apply"
+			}
+		}
+		p.refs.Set(project.KindFlow, flow.Id(), project.FlowRef{
+			ServiceArn:     r.Arn,
+			ServiceName:    r.Attributes["name"],
+			Cluster:        r.Attributes["cluster"],
+			TargetGroupArn: tgArn,
+		}, file)
+		p.add(change)
+		return nil
+	}
+	cluster := content.GetString("cluster")
+	existing, err := project.FindService(p.client, cluster, change.Name)
+	if err != nil {
+		return fmt.Errorf("flow %s: %v", flowKey, err)
+	}
+	if existing.ServiceArn != "" {
+		change.Action = ActionUpdate
+		change.Arn = existing.ServiceArn
+		change.Detail = fmt.Sprintf("service already exists in cluster %s, it is updated in place", cluster)
+	}
+	p.refs.Set(project.KindFlow, flow.Id(), project.FlowRef{
+		ServiceArn:     change.Arn,
+		ServiceName:    change.Name,
+		Cluster:        cluster,
+		TargetGroupArn: tgArn,
+	}, file)
+	p.add(change)
+	return nil
+}
+
+// plan plans the resources in the order they are applied
+// (dependencies first), so the refs are the ones apply would use.
+func (p *planner) plan(nodes []*project.Node) error {
+	for _, n := range nodes {
+		var err error
+		switch n.Kind {
+		case project.KindTargetGroup:
+			err = p.planTargetGroup(p.config.TargetGroups[n.Index])
+		case project.KindLoadBalancer:
+			err = p.planLoadBalancer(p.config.LoadBalancers[n.Index])
+		case project.KindListener:
+			err = p.planListener(p.config.Listeners[n.Index])
+		case project.KindLogGroup:
+			err = p.planLogGroup(p.config.LogGroups[n.Index])
+		case project.KindTaskDefinition:
+			err = p.planTaskDefinition(p.config.TaskDefinitions[n.Index])
+		case project.KindFlow:
+			err = p.planFlow(n.Index, p.config.Flows[n.Index])
+		default:
+			err = fmt.Errorf("unknown resource kind \"%s\"", n.Kind)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func renderAction(action Action) string {
+	switch action {
+	case ActionCreate:
+		return createStyle.Render(fmt.Sprintf("+ %-8s", action))
+	case ActionUpdate:
+		return updateStyle.Render(fmt.Sprintf("~ %-8s", action))
+	case ActionConflict:
+		return conflictStyle.Render(fmt.Sprintf("! %-8s", action))
+	}
+	return noopStyle.Render(fmt.Sprintf("= %-8s", action))
+}
+
+// renderVariables lists the resolved variables
+// the plan was made with.
+func renderVariables(config *project.Config) string {
+	var b strings.Builder
+	if config.Env != "" {
+		fmt.Fprintf(&b, "Environment: %s\n", config.Env)
+	}
+	if len(config.Vars) > 0 {
+		b.WriteString("Variables:\n")
+		for _, name := range config.VarNames() {
+			fmt.Fprintf(&b, "    %s = %s\n", name, config.Vars[name])
+		}
+	}
+	if b.Len() > 0 {
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func render(changes []Change) string {
+	var (
+		b     strings.Builder
+		count = make(map[Action]int)
+	)
+	for _, change := range changes {
+		count[change.Action]++
+
+		fmt.Fprintf(&b, "%s %-16s %s", renderAction(change.Action), change.Kind, change.Name)
+		if change.Key != "" {
+			b.WriteString(subtleText(fmt.Sprintf(" [%s]", change.Key)))
+		}
+		b.WriteString("\n")
+		fmt.Fprintf(&b, "    arn: %s\n", change.Arn)
+		if change.Detail != "" {
+			fmt.Fprintf(&b, "    %s\n", change.Detail)
+		}
+		for _, ref := range change.Refs {
+			if ref.Value != ref.Arn {
+				fmt.Fprintf(&b, "    %s: %s => %s\n", ref.Field, ref.Value, ref.Arn)
+			} else {
+				fmt.Fprintf(&b, "    %s: %s\n", ref.Field, ref.Arn)
+			}
+		}
+	}
+	fmt.Fprintf(&b, "\nPlan: %d to create, %d to update, %d unchanged, %d conflict(s).\n",
+		count[ActionCreate], count[ActionUpdate], count[ActionNoop], count[ActionConflict])
+	return b.String()
+}
+
+func Run(client aws.Client) {
+	logger := globals.Logger
+
+	logger.Debugf("ecx plan %s", viper.GetString("project"))
+
+	config, err := project.Open(viper.GetString("project"))
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+
+	logger.Debug(*config)
+	if config.Env != "" {
+		logger.Debugf("env %s", config.Env)
+	}
+	for _, name := range config.VarNames() {
+		logger.Debugf("var.%s = %s", name, config.Vars[name])
+	}
+
+	if err := config.Check(); err != nil {
+		logger.Fatal(err)
+	}
+
+	// dangling refs (also in templates)
+	graph, err := project.BuildGraph(config)
+	if err != nil {
+		logger.Fatalf("%s:\n%v", project.FileName, err)
+	}
+	nodes, err := graph.Sort()
+	if err != nil {
+		logger.Fatalf("%s: %v", project.FileName, err)
+	}
+
+	state, err := project.LoadState(config.Env)
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+
+	p := newPlanner(client, config, state)
+	globals.Spin(spinner.Globe, " Describing resources...", func() {
+		err = p.plan(nodes)
+	})
+	if dir := p.renderer.Dir(); dir != "" && viper.GetBool("keep-rendered") {
+		logger.Infof("rendered files kept in %s", dir)
+	}
+	if cleanErr := p.renderer.Close(); cleanErr != nil {
+		logger.Warnf("%v", cleanErr)
+	}
+	if err != nil {
+		logger.Fatalf("plan: %v", err)
+	}
+
+	fmt.Print(renderVariables(config))
+	fmt.Print(render(p.changes))
+}
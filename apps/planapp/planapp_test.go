@@ -0,0 +1,173 @@
+package planapp
+
+import (
+	"flag"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/demingongo/ecx/apps/applyapp"
+	"github.com/demingongo/ecx/apps/migrateapp"
+	"github.com/demingongo/ecx/aws"
+	"github.com/demingongo/ecx/globals"
+	"github.com/demingongo/ecx/project"
+	"github.com/spf13/viper"
+)
+
+var update = flag.Bool("update", false, "record the cassettes of testdata again (with a fake account)")
+
+// cassette returns the client of a test: with -update, a Recorder
+// of fake saving into dir, the Replayer of dir otherwise.
+// The calls are checked against the cassette at the end of the test.
+func cassette(t *testing.T, dir string, fake aws.Client) aws.Client {
+	t.Helper()
+	if *update {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatal(err)
+		}
+		recorder, err := aws.NewRecorder(dir, fake)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return recorder
+	}
+	replayer, err := aws.NewReplayer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := replayer.Check(); err != nil {
+			t.Errorf("%s:\n%v", dir, err)
+		}
+	})
+	return replayer
+}
+
+// copyProject copies a project into a temporary directory
+// (apply writes ecx.state.json next to ecx.yaml).
+func copyProject(t *testing.T, src string) string {
+	t.Helper()
+	dir := t.TempDir()
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dir, strings.TrimPrefix(path, src))
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, content, 0644)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func copyFile(t *testing.T, src string, dst string) {
+	t.Helper()
+	content, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(dst, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// plan plans the project in dir.
+func plan(t *testing.T, dir string, client aws.Client) []Change {
+	t.Helper()
+	config, err := project.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	graph, err := project.BuildGraph(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes, err := graph.Sort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err := project.LoadState(config.Env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := newPlanner(client, config, state)
+	err = p.plan(nodes)
+	if cleanErr := p.renderer.Close(); cleanErr != nil {
+		t.Error(cleanErr)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p.changes
+}
+
+// The resource files inlined by "ecx migrate --inline" are
+// the same resources: nothing to apply after the migration.
+func TestPlanAfterMigrateInline(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// absolute: the project is opened from another directory
+	testdata, err := filepath.Abs("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := filepath.Abs(filepath.Join("..", "applyapp", "testdata", "project"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		viper.Reset()
+		_ = os.Chdir(wd)
+	})
+	globals.LoadGlobals()
+
+	// the state and the account of an apply
+	dir := copyProject(t, src)
+	stateFile := filepath.Join(testdata, project.StateFileName)
+	fake := aws.NewFake()
+	viper.Set("project", dir)
+	if *update {
+		viper.Set("parallelism", 1)
+		viper.Set("wait-timeout", time.Minute)
+		applyapp.Run(fake)
+		if err = os.MkdirAll(testdata, 0755); err != nil {
+			t.Fatal(err)
+		}
+		copyFile(t, filepath.Join(dir, project.StateFileName), stateFile)
+	} else {
+		copyFile(t, stateFile, filepath.Join(dir, project.StateFileName))
+	}
+
+	viper.Set("inline", true)
+	migrateapp.Run()
+	content, err := os.ReadFile(filepath.Join(dir, project.FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), ".json") {
+		t.Fatalf("%s: files not inlined:\n%s", project.FileName, content)
+	}
+
+	changes := plan(t, dir, cassette(t, filepath.Join(testdata, "migrated"), fake))
+	if len(changes) == 0 {
+		t.Fatal("no changes planned")
+	}
+	for _, change := range changes {
+		if change.Action != ActionNoop {
+			t.Errorf("%s %s: %s (%s)", change.Kind, change.Key, change.Action, change.Detail)
+		}
+	}
+}
@@ -208,19 +208,16 @@ func generateDescription(name string, filepath string) string {
 	return r
 }
 
-func process(logger *log.Logger) {
+func process(logger *log.Logger, client aws.Client) {
 	if config.targetGroup.IsNew() {
 		logger.Debug(fmt.Sprintf("create target group \"%s\"", config.targetGroup.Name))
 		var (
 			result aws.TargetGroup
 			err    error
 		)
-		_ = spinner.New().Type(spinner.Meter).
-			Title(fmt.Sprintf(" Creating target group \"%s\"...", config.targetGroup.Name)).
-			Action(func() {
-				result, err = aws.CreateTargetGroup(config.targetGroup.Filepath)
-			}).
-			Run()
+		globals.Spin(spinner.Meter, fmt.Sprintf(" Creating target group \"%s\"...", config.targetGroup.Name), func() {
+			result, err = client.CreateTargetGroup(config.targetGroup.Filepath)
+		})
 
 		if err != nil {
 			config.targetGroupLogo = globals.LogoError
@@ -236,12 +233,9 @@ func process(logger *log.Logger) {
 		logger.Debug(fmt.Sprintf("create rules for target group \"%s\"", config.targetGroup.Name))
 		for i, v := range config.rules {
 			var err error
-			_ = spinner.New().Type(spinner.Meter).
-				Title(fmt.Sprintf(" Creating rules (%d/%d)...", i+1, len(config.rules))).
-				Action(func() {
-					_, err = aws.CreateRule(v, config.targetGroup.Arn)
-				}).
-				Run()
+			globals.Spin(spinner.Meter, fmt.Sprintf(" Creating rules (%d/%d)...", i+1, len(config.rules)), func() {
+				_, err = client.CreateRule(v, config.targetGroup.Arn)
+			})
 			if err != nil {
 				config.rulesLogo = globals.LogoError
 				info = generateInfo()
@@ -255,16 +249,13 @@ func process(logger *log.Logger) {
 	if len(config.service.Filepath) > 0 {
 		logger.Debug(fmt.Sprintf("create service \"%s\"", config.service.Name))
 		var err error
-		_ = spinner.New().Type(spinner.Meter).
-			Title(fmt.Sprintf(" Creating service \"%s\"...", config.service.Name)).
-			Action(func() {
-				_, err = aws.CreateService(config.service.Filepath, aws.ServiceLoadBalancer{
-					TargetGroupArn: config.targetGroup.Arn,
-					ContainerName:  config.containerName,
-					ContainerPort:  config.containerPort,
-				}, 0)
-			}).
-			Run()
+		globals.Spin(spinner.Meter, fmt.Sprintf(" Creating service \"%s\"...", config.service.Name), func() {
+			_, err = client.CreateService(config.service.Filepath, aws.ServiceLoadBalancer{
+				TargetGroupArn: config.targetGroup.Arn,
+				ContainerName:  config.containerName,
+				ContainerPort:  config.containerPort,
+			}, 0)
+		})
 		if err != nil {
 			config.serviceLogo = globals.LogoError
 			info = generateInfo()
@@ -278,7 +269,7 @@ func process(logger *log.Logger) {
 	fmt.Println(info)
 }
 
-func Run() {
+func Run(client aws.Client) {
 
 	logger := globals.Logger
 
@@ -315,12 +306,9 @@ func Run() {
 				targetgroups []aws.TargetGroup
 				err          error
 			)
-			_ = spinner.New().Type(spinner.Globe).
-				Title(" Searching target groups...").
-				Action(func() {
-					targetgroups, err = aws.DescribeTargetGroups()
-				}).
-				Run()
+			globals.Spin(spinner.Globe, " Searching target groups...", func() {
+				targetgroups, err = client.DescribeTargetGroups()
+			})
 			if err != nil {
 				logger.Fatal(err)
 			}
@@ -400,12 +388,9 @@ func Run() {
 				containers []aws.ContainerPortMapping
 				err        error
 			)
-			_ = spinner.New().Type(spinner.Points).
-				Title(" Checking task definition containers...").
-				Action(func() {
-					containers, err = aws.ListPortMapping(config.service.TaskDefinition)
-				}).
-				Run()
+			globals.Spin(spinner.Points, " Checking task definition containers...", func() {
+				containers, err = aws.ListPortMapping(client, config.service.TaskDefinition)
+			})
 			if err != nil {
 				logger.Fatal(err)
 			}
@@ -425,7 +410,7 @@ func Run() {
 
 		if isProcessable() {
 			if form := runFormProcess(); form.State == huh.StateCompleted && form.GetBool("confirm") {
-				process(logger)
+				process(logger, client)
 			}
 		}
 	}
@@ -0,0 +1,122 @@
+package starterapp
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/demingongo/ecx/aws"
+	"github.com/demingongo/ecx/globals"
+)
+
+var update = flag.Bool("update", false, "record the cassettes of testdata again (with a fake account)")
+
+// cassette returns the client of a test: with -update, a Recorder
+// of fake saving into dir, the Replayer of dir otherwise.
+// The calls are checked against the cassette at the end of the test.
+func cassette(t *testing.T, dir string, fake aws.Client) aws.Client {
+	t.Helper()
+	if *update {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatal(err)
+		}
+		recorder, err := aws.NewRecorder(dir, fake)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return recorder
+	}
+	replayer, err := aws.NewReplayer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := replayer.Check(); err != nil {
+			t.Errorf("%s:\n%v", dir, err)
+		}
+	})
+	return replayer
+}
+
+// operations returns the operations of the cassette in dir, in order.
+func operations(t *testing.T, dir string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result []string
+	for _, file := range files {
+		_, operation, _ := strings.Cut(strings.TrimSuffix(filepath.Base(file), ".json"), "-")
+		result = append(result, operation)
+	}
+	return result
+}
+
+const (
+	listenerArn    = "arn:aws:elasticloadbalancing:us-west-2:123456789012:listener/app/app-alb/50dc6c495c0c9188/f2f7dc8efc522ab2"
+	targetGroupArn = "arn:aws:elasticloadbalancing:us-west-2:123456789012:targetgroup/app-tg/4b5443657682d3b1"
+)
+
+func TestProcess(t *testing.T) {
+	globals.LoadGlobals()
+	fixture, err := aws.ReadFixture("testdata/account.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake, err := aws.NewFakeFromFixture(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := cassette(t, "testdata/process", fake)
+
+	config = Config{
+		targetGroup:   TargetGroupConfig{New: true, Filepath: "testdata/targetgroup.json", Name: "app-tg"},
+		rules:         []string{"testdata/rule.json"},
+		service:       ServiceConfig{Filepath: "testdata/service.json", Name: "app"},
+		containerName: "web",
+		containerPort: 8080,
+	}
+	t.Cleanup(func() { config = Config{} })
+
+	process(globals.Logger, client)
+
+	if config.targetGroup.Arn != targetGroupArn {
+		t.Errorf("target group %q, want %q", config.targetGroup.Arn, targetGroupArn)
+	}
+
+	// the rule forwards to the new target group
+	rules, err := client.DescribeRules(listenerArn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := slices.IndexFunc(rules, func(r aws.Rule) bool { return r.Priority == "10" })
+	if i < 0 {
+		t.Fatalf("no rule of priority 10 in %v", rules)
+	}
+	if actions, _ := json.Marshal(rules[i].Actions); !strings.Contains(string(actions), targetGroupArn) {
+		t.Errorf("rule actions %s: no forward to %s", actions, targetGroupArn)
+	}
+
+	// the service is registered in the new target group
+	service, err := client.DescribeServiceByArn("app-cluster", "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"containerName":"web","containerPort":8080,"targetGroupArn":"` + targetGroupArn + `"}]`
+	if loadBalancers, _ := json.Marshal(service["loadBalancers"]); string(loadBalancers) != want {
+		t.Errorf("service load balancers:\n got %s\nwant %s", loadBalancers, want)
+	}
+
+	wantOperations := []string{
+		"CreateTargetGroup", "CreateRule", "CreateService",
+		"DescribeRules", "DescribeServiceByArn",
+	}
+	if got := operations(t, "testdata/process"); !slices.Equal(got, wantOperations) {
+		t.Errorf("operations:\n got %v\nwant %v", got, wantOperations)
+	}
+}
@@ -0,0 +1,42 @@
+package unlockapp
+
+import (
+	"fmt"
+
+	"github.com/demingongo/ecx/aws"
+	"github.com/demingongo/ecx/globals"
+	"github.com/demingongo/ecx/project"
+	"github.com/spf13/viper"
+)
+
+// Run releases the lock of the project
+// if its id is the given one.
+func Run(client aws.Client, id string) {
+	logger := globals.Logger
+
+	logger.Debugf("ecx force-unlock %s %s", viper.GetString("project"), id)
+
+	config, err := project.Open(viper.GetString("project"))
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+
+	backend, err := config.LockBackend(client)
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+	holder, err := backend.Info()
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+	if holder == nil {
+		logger.Fatalf("%v", project.ErrNotLocked)
+	}
+	if holder.ID != id {
+		logger.Fatalf("the lock id is %s, not %s (%s)", holder.ID, id, holder)
+	}
+	if err = backend.ForceUnlock(); err != nil {
+		logger.Fatalf("%v", err)
+	}
+	fmt.Printf("Unlocked (was %s).\n", holder)
+}
@@ -2,6 +2,7 @@ package updateserviceapp
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	"github.com/charmbracelet/huh"
@@ -254,20 +255,17 @@ func isServiceUpToDate() bool {
 		currentTaskDefinitionArn == config.taskDefinition.TaskDefinitionArn
 }
 
-func updateService(logger *log.Logger, taskDefinitionArn string) {
+func updateService(logger *log.Logger, client aws.Client, taskDefinitionArn string) {
 	var err error
-	_ = spinner.New().Type(spinner.Meter).
-		Title(fmt.Sprintf(" Updating service \"%s\"...", config.service.ServiceName)).
-		Action(func() {
-			// update service
-			var jsonByte []byte
-			if jsonByte, err = json.Marshal(UpdateServiceInputJson{
-				TaskDefinition: taskDefinitionArn,
-			}); err == nil {
-				_, err = aws.UpdateService(config.cluster, config.service.ServiceArn, string(jsonByte))
-			}
-		}).
-		Run()
+	globals.Spin(spinner.Meter, fmt.Sprintf(" Updating service \"%s\"...", config.service.ServiceName), func() {
+		// update service
+		var jsonByte []byte
+		if jsonByte, err = json.Marshal(UpdateServiceInputJson{
+			TaskDefinition: taskDefinitionArn,
+		}); err == nil {
+			_, err = client.UpdateService(config.cluster, config.service.ServiceArn, string(jsonByte))
+		}
+	})
 	if err != nil {
 		config.serviceLogo = globals.LogoError
 		info = generateInfo()
@@ -280,20 +278,17 @@ func updateService(logger *log.Logger, taskDefinitionArn string) {
 	fmt.Println(info)
 }
 
-func process(logger *log.Logger) {
+func process(logger *log.Logger, client aws.Client) {
 	var err error
 	var revisionedTaskDef aws.TaskDefinition
 
-	_ = spinner.New().Type(spinner.Meter).
-		Title(fmt.Sprintf(" Registering task definition \"%s\"...", config.taskDefinition.Family)).
-		Action(func() {
-			// create new revision for task definition
-			var jsonByte []byte
-			if jsonByte, err = removeJSONKey(config.taskDefinition, "taskDefinitionArn"); err == nil {
-				revisionedTaskDef, err = aws.RegisterTaskDefinition(string(jsonByte))
-			}
-		}).
-		Run()
+	globals.Spin(spinner.Meter, fmt.Sprintf(" Registering task definition \"%s\"...", config.taskDefinition.Family), func() {
+		// create new revision for task definition
+		var jsonByte []byte
+		if jsonByte, err = removeJSONKey(config.taskDefinition, "taskDefinitionArn"); err == nil {
+			revisionedTaskDef, err = client.RegisterTaskDefinition(string(jsonByte))
+		}
+	})
 	if err != nil {
 		config.taskDefinitionLogo = globals.LogoError
 		config.containersLogo = globals.LogoError
@@ -304,10 +299,10 @@ func process(logger *log.Logger) {
 	config.taskDefinitionLogo = globals.LogoSuccess
 	config.containersLogo = globals.LogoSuccess
 
-	updateService(logger, revisionedTaskDef.TaskDefinitionArn)
+	updateService(logger, client, revisionedTaskDef.TaskDefinitionArn)
 }
 
-func Run() {
+func Run(client aws.Client) {
 
 	logger := globals.Logger
 
@@ -321,19 +316,16 @@ func Run() {
 
 	if config.service.ServiceArn != "" {
 		var err error
-		config.service, err = aws.DescribeService(config.cluster, config.service.ServiceArn)
+		config.service, err = client.DescribeService(config.cluster, config.service.ServiceArn)
 		if err != nil {
 			log.Fatalf("DescribeService %v", err)
 		}
 	} else {
 		var err error
 		var list []aws.Service
-		_ = spinner.New().Type(spinner.Globe).
-			Title(" Searching services...").
-			Action(func() {
-				list, err = aws.ListServices2(config.cluster)
-			}).
-			Run()
+		globals.Spin(spinner.Globe, " Searching services...", func() {
+			list, err = aws.ListServices2(client, config.cluster)
+		})
 		if err != nil {
 			log.Fatalf("ListServices2 %v", err)
 		}
@@ -358,7 +350,7 @@ func Run() {
 		// retrieve the last revision from aws
 		if config.CurrentTaskDefinitionFamily() != "" {
 			var err error
-			config.taskDefinition, err = aws.DescribeTaskDefinition(config.CurrentTaskDefinitionFamily())
+			config.taskDefinition, err = client.DescribeTaskDefinition(config.CurrentTaskDefinitionFamily())
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -382,8 +374,9 @@ func Run() {
 				for _, container := range containersList {
 					ecrRepositoryName := aws.ExtractNameFromURI(container.Image)
 					if ecrRepositoryName != "" {
-						images, err := aws.ListImages(ecrRepositoryName)
-						if err != nil {
+						// not an ecr repository if it is not found
+						images, err := client.ListImages(ecrRepositoryName)
+						if err != nil && !errors.Is(err, aws.ErrNotFound) {
 							log.Error(err)
 						}
 
@@ -410,11 +403,11 @@ func Run() {
 	info = generateInfo()
 	if isProcessable() {
 		if form := runFormProcess(); form.State == huh.StateCompleted && form.GetBool("confirm") {
-			process(logger)
+			process(logger, client)
 		}
 	} else if isServiceUpdatable() {
 		if form := runFormUpdateService(); form.State == huh.StateCompleted && form.GetBool("confirm") {
-			updateService(logger, config.taskDefinition.TaskDefinitionArn)
+			updateService(logger, client, config.taskDefinition.TaskDefinitionArn)
 		}
 	} else if isServiceUpToDate() {
 		fmt.Printf("Service \"%s\" in cluster \"%s\" is already up to date.\n", config.service.ServiceName, config.cluster)
@@ -0,0 +1,115 @@
+package updateserviceapp
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/demingongo/ecx/aws"
+	"github.com/demingongo/ecx/globals"
+)
+
+var update = flag.Bool("update", false, "record the cassettes of testdata again (with a fake account)")
+
+// cassette returns the client of a test: with -update, a Recorder
+// of fake saving into dir, the Replayer of dir otherwise.
+// The calls are checked against the cassette at the end of the test.
+func cassette(t *testing.T, dir string, fake aws.Client) aws.Client {
+	t.Helper()
+	if *update {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatal(err)
+		}
+		recorder, err := aws.NewRecorder(dir, fake)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return recorder
+	}
+	replayer, err := aws.NewReplayer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := replayer.Check(); err != nil {
+			t.Errorf("%s:\n%v", dir, err)
+		}
+	})
+	return replayer
+}
+
+// operations returns the operations of the cassette in dir, in order.
+func operations(t *testing.T, dir string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result []string
+	for _, file := range files {
+		_, operation, _ := strings.Cut(strings.TrimSuffix(filepath.Base(file), ".json"), "-")
+		result = append(result, operation)
+	}
+	return result
+}
+
+func TestProcess(t *testing.T) {
+	globals.LoadGlobals()
+	fixture, err := aws.ReadFixture("testdata/account.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake, err := aws.NewFakeFromFixture(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := cassette(t, "testdata/process", fake)
+
+	// the service and the image of its container
+	// as selected by Run
+	config = Config{cluster: "app-cluster"}
+	t.Cleanup(func() { config = Config{} })
+	if config.service, err = client.DescribeService(config.cluster, "app"); err != nil {
+		t.Fatal(err)
+	}
+	if config.taskDefinition, err = client.DescribeTaskDefinition(config.CurrentTaskDefinitionFamily()); err != nil {
+		t.Fatal(err)
+	}
+	container := config.findContainerDefinition("web")
+	config.addContainerToUpdate(container.Name, container.Image, "nginx:1.27")
+	container.Image = "nginx:1.27"
+	if !isProcessable() {
+		t.Fatal("not processable")
+	}
+
+	process(globals.Logger, client)
+
+	// the service runs a new revision with the new image
+	const revision = "arn:aws:ecs:us-west-2:123456789012:task-definition/web:2"
+	service, err := client.DescribeService(config.cluster, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(service.Deployments) == 0 || service.Deployments[0].TaskDefinition != revision {
+		t.Errorf("service deployments %v, want %s first", service.Deployments, revision)
+	}
+	taskDefinition, err := client.DescribeTaskDefinition(revision)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if image := taskDefinition.ContainerDefinitions[0].Image; image != "nginx:1.27" {
+		t.Errorf("image %q, want %q", image, "nginx:1.27")
+	}
+
+	wantOperations := []string{
+		"DescribeService", "DescribeTaskDefinition",
+		"RegisterTaskDefinition", "UpdateService",
+		"DescribeService", "DescribeTaskDefinition",
+	}
+	if got := operations(t, "testdata/process"); !slices.Equal(got, wantOperations) {
+		t.Errorf("operations:\n got %v\nwant %v", got, wantOperations)
+	}
+}
@@ -0,0 +1,95 @@
+package validateapp
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/demingongo/ecx/globals"
+	"github.com/demingongo/ecx/project"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	errorStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+	warningStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("3"))
+	validStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("2"))
+)
+
+// validate loads the project and returns its diagnostics.
+func validate(dir string) ([]project.Diagnostic, error) {
+	inputs, err := project.InputsFromFlags()
+	if err != nil {
+		return nil, err
+	}
+	if dir != "" {
+		if err := os.Chdir(dir); err != nil {
+			return nil, fmt.Errorf("project: %v", err)
+		}
+	}
+	config, doc, err := project.LoadDocument(inputs)
+	if err != nil {
+		if doc == nil && os.IsNotExist(err) {
+			return nil, err
+		}
+		diags := project.YAMLDiagnostics(err)
+		var typeErr *yaml.TypeError
+		if errors.As(err, &typeErr) {
+			// what could be decoded is checked anyway
+			diags = append(diags, project.Validate(config, doc)...)
+		}
+		return diags, nil
+	}
+	return project.Validate(config, doc), nil
+}
+
+func Run() {
+	logger := globals.Logger
+
+	dir := viper.GetString("project")
+
+	logger.Debugf("ecx validate %s", dir)
+
+	diags, err := validate(dir)
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+
+	sort.SliceStable(diags, func(i, j int) bool {
+		if diags[i].File != diags[j].File {
+			return diags[i].File == project.FileName
+		}
+		if diags[i].Line != diags[j].Line {
+			return diags[i].Line < diags[j].Line
+		}
+		return diags[i].Column < diags[j].Column
+	})
+
+	var errorCount, warningCount int
+	for _, d := range diags {
+		// paths relative to where ecx was run
+		if dir != "" && !filepath.IsAbs(d.File) {
+			d.File = filepath.Join(dir, d.File)
+		}
+		if d.Warning {
+			warningCount++
+			fmt.Println(warningStyle.Render(d.String()))
+		} else {
+			errorCount++
+			fmt.Println(errorStyle.Render(d.String()))
+		}
+	}
+
+	if errorCount > 0 {
+		fmt.Printf("\n%d error(s), %d warning(s)\n", errorCount, warningCount)
+		os.Exit(1)
+	}
+	if warningCount > 0 {
+		fmt.Println()
+	}
+	fmt.Println(validStyle.Render(fmt.Sprintf("%s is valid", filepath.Join(dir, project.FileName))))
+}
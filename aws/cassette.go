@@ -0,0 +1,95 @@
+package aws
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Interaction is an aws call saved in a cassette (--record):
+// the operation of the Client, its input (with the content
+// of the files it reads), its output and, if it failed,
+// its error, stderr and exit code (aws cli).
+type Interaction struct {
+	Operation string          `json:"operation"`
+	Input     map[string]any  `json:"input"`
+	Output    json.RawMessage `json:"output,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	Stderr    string          `json:"stderr,omitempty"`
+	ExitCode  int             `json:"exitCode,omitempty"`
+}
+
+// input is the input of an operation.
+type input map[string]any
+
+// fileInput returns the content of a file read by an operation
+// (its path changes from a run to another).
+func fileInput(filepath string) any {
+	content, err := os.ReadFile(filepath)
+	if err != nil {
+		return nil
+	}
+	var result any
+	if json.Unmarshal(content, &result) != nil {
+		return string(content)
+	}
+	return result
+}
+
+// jsonInput returns a --cli-input-json value
+// ("file://<path>" or json).
+func jsonInput(inputJson string) any {
+	if filepath, ok := strings.CutPrefix(inputJson, "file://"); ok {
+		return fileInput(filepath)
+	}
+	var result any
+	if json.Unmarshal([]byte(inputJson), &result) != nil {
+		return inputJson
+	}
+	return result
+}
+
+// err returns the error of the interaction (nil if none),
+// an Error if it was returned by aws.
+func (i Interaction) err() error {
+	if i.Error == "" {
+		return nil
+	}
+	result := parseError(i.Error)
+	if result.Code == "" && i.Stderr == "" && i.ExitCode == 0 {
+		return errors.New(i.Error)
+	}
+	result.Stderr = i.Stderr
+	result.ExitCode = i.ExitCode
+	return result
+}
+
+// setErr saves how an operation failed.
+func (i *Interaction) setErr(err error) {
+	if err == nil {
+		return
+	}
+	i.Error = err.Error()
+	var awsErr *Error
+	if errors.As(err, &awsErr) {
+		i.Stderr = awsErr.Stderr
+		i.ExitCode = awsErr.ExitCode
+	}
+}
+
+// interactionFiles returns the interaction files
+// of a cassette (in order).
+func interactionFiles(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	sort.Strings(files)
+	return files, err
+}
+
+// interactionFile returns the file of the nth interaction.
+func interactionFile(dir string, n int, operation string) string {
+	return filepath.Join(dir, fmt.Sprintf("%04d-%s.json", n, operation))
+}
@@ -0,0 +1,6 @@
+package aws
+
+// CLI is the Client running the aws cli (aws cli v2 installed
+// and configured): an operation is a command whose json output
+// is decoded.
+type CLI struct{}
@@ -0,0 +1,149 @@
+package aws
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	// BackendCLI runs the aws cli (v2 installed).
+	BackendCLI = "cli"
+	// BackendSDK calls the aws apis with aws-sdk-go-v2.
+	BackendSDK = "sdk"
+)
+
+// Client runs the aws operations of ecx.
+//
+// Resource files are --cli-input-json files: the sdk backend
+// reads them into the inputs of the apis, so both backends
+// accept the same files.
+type Client interface {
+	// elbv2 target groups
+
+	DescribeTargetGroups() ([]TargetGroup, error)
+	DescribeTargetGroupsWithNames(names []string) ([]TargetGroup, error)
+	CreateTargetGroup(filepath string) (TargetGroup, error)
+	ModifyTargetGroup(targetGroupArn string, filepath string) (string, error)
+	DeleteTargetGroup(targetGroupArn string) (string, error)
+	DescribeTargetGroupByArn(targetGroupArn string) (map[string]any, error)
+
+	// elbv2 load balancers
+
+	DescribeLoadBalancersWithNames(names []string) ([]LoadBalancer, error)
+	CreateLoadBalancer(filepath string) (LoadBalancer, error)
+	DeleteLoadBalancer(loadBalancerArn string) (string, error)
+	WaitLoadBalancersDeleted(loadBalancerArn string) error
+	DescribeLoadBalancerByName(name string) (map[string]any, error)
+
+	// elbv2 listeners
+
+	DescribeListeners(loadBalancerArn string) ([]Listener, error)
+	CreateListener(filepath string, loadBalancerArn string, targetGroupArn string) (Listener, error)
+	ModifyListener(listenerArn string, filepath string, targetGroupArn string) (string, error)
+	DeleteListener(listenerArn string) (string, error)
+	DescribeListenerByArn(listenerArn string) (map[string]any, error)
+	DescribeListenersFull(loadBalancerArn string) ([]map[string]any, error)
+
+	// elbv2 rules
+
+	DescribeRules(listenerArn string) ([]Rule, error)
+	CreateRule(filepath string, targetGroupArn string) (string, error)
+	CreateRule2(filepath string, targetGroupArn string, priority int, listenerArn string) (Rule, error)
+	ModifyRule(ruleArn string, filepath string, targetGroupArn string) (string, error)
+	SetRulePriority(ruleArn string, priority int) (string, error)
+	DeleteRule(ruleArn string) (string, error)
+	DescribeRuleByArn(ruleArn string) (map[string]any, error)
+	DescribeRulesFull(listenerArn string) ([]map[string]any, error)
+
+	// cloudwatch log groups
+
+	DescribeLogGroups(logGroupNamePrefix string) ([]LogGroup, error)
+	CreateLogGroup(logGroupName string) (string, error)
+	PutRetentionPolicy(logGroupName string, retentionInDays int) (string, error)
+	DeleteLogGroup(logGroupName string) (string, error)
+
+	// ecr images
+
+	ListImages(ecrRepositoryName string) ([]Image, error)
+
+	// ecs services
+
+	CreateService(filepath string, loadBalancer ServiceLoadBalancer, healthCheckGracePeriodSeconds int) (Service, error)
+	UpdateServiceWithFile(cluster string, serviceArn string, filepath string, loadBalancer ServiceLoadBalancer, healthCheckGracePeriodSeconds int) (string, error)
+	DescribeService(cluster string, serviceArn string) (Service, error)
+	DescribeServiceStability(cluster string, serviceArn string) (ServiceStability, error)
+	DescribeServiceByArn(cluster string, serviceArn string) (map[string]any, error)
+	DescribeServices(cluster string, serviceArns ...string) ([]Service, error)
+	ListServices(cluster string) ([]string, error)
+	UpdateService(cluster string, serviceArn string, inputJson string) (string, error)
+	UpdateServiceDesiredCount(cluster string, serviceArn string, desiredCount int) (string, error)
+	DeleteService(cluster string, serviceArn string) (string, error)
+	WaitServicesInactive(cluster string, serviceArn string) error
+
+	// ecs task definitions and tasks
+
+	DescribeTaskDefinition(taskDefinition string) (TaskDefinition, error)
+	RegisterTaskDefinition(inputJson string) (TaskDefinition, error)
+	DescribeTaskDefinitionByArn(taskDefinition string) (map[string]any, error)
+	ListStoppedTasks(cluster string, serviceName string) ([]string, error)
+	DescribeTasks(cluster string, taskArns []string) ([]Task, error)
+
+	// dynamodb lock items
+
+	PutLockItem(table LockTable, key string, id string, info string) error
+	GetLockItem(table LockTable, key string) (string, error)
+	DeleteLockItem(table LockTable, key string, id string) error
+}
+
+// NewClient returns the client of a backend (cli by default).
+// With --dummy, it is a Fake (of --fixture) whatever the backend.
+// With --replay, the calls are served from a cassette and
+// with --record, they are saved in one (every attempt).
+// The calls are retried with the RetryPolicy of the config.
+func NewClient(backend string) (Client, error) {
+	if backend != "" && backend != BackendCLI && backend != BackendSDK {
+		return nil, fmt.Errorf("unknown backend \"%s\" (cli or sdk)", backend)
+	}
+	record, replay := viper.GetString("record"), viper.GetString("replay")
+	if record != "" && replay != "" {
+		return nil, fmt.Errorf("--record and --replay can't be used together")
+	}
+	if replay != "" {
+		client, err := NewReplayer(replay)
+		if err != nil {
+			return nil, err
+		}
+		// the attempts of the cassette are replayed without waiting
+		return &Retrier{client: client, noDelay: true}, nil
+	}
+	client, err := newBackend(backend)
+	if err != nil {
+		return nil, err
+	}
+	if record != "" {
+		if client, err = NewRecorder(record, client); err != nil {
+			return nil, err
+		}
+	}
+	return NewRetrier(client), nil
+}
+
+func newBackend(backend string) (Client, error) {
+	if viper.GetBool("dummy") {
+		return LoadFake(viper.GetString("fixture"))
+	}
+	if backend == BackendSDK {
+		return NewSDK()
+	}
+	return CLI{}, nil
+}
+
+var (
+	_ Client = CLI{}
+	_ Client = (*SDK)(nil)
+	_ Client = (*Fake)(nil)
+	_ Client = (*Recorder)(nil)
+	_ Client = (*Replayer)(nil)
+	_ Client = (*Retrier)(nil)
+)
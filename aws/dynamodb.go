@@ -0,0 +1,94 @@
+package aws
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/charmbracelet/log"
+)
+
+// ErrConditionalCheckFailed is the kind of the Error returned when
+// the condition of a DynamoDB write is not met (e.g. the item exists).
+var ErrConditionalCheckFailed = errors.New("conditional check failed")
+
+// LockTable is a DynamoDB table with a "LockID" string partition key.
+// Endpoint is for a local stand-in (e.g. DynamoDB Local).
+type LockTable struct {
+	Name     string
+	Region   string
+	Endpoint string
+}
+
+func (t LockTable) args(operation string) []string {
+	args := []string{"dynamodb", operation, "--output", "json", "--table-name", t.Name}
+	if t.Region != "" {
+		args = append(args, "--region", t.Region)
+	}
+	if t.Endpoint != "" {
+		args = append(args, "--endpoint-url", t.Endpoint)
+	}
+	return args
+}
+
+func lockItemKey(key string) string {
+	content, _ := json.Marshal(map[string]any{
+		"LockID": map[string]string{"S": key},
+	})
+	return string(content)
+}
+
+// PutLockItem creates the lock item of a key
+// unless it exists (ErrConditionalCheckFailed).
+func (c CLI) PutLockItem(table LockTable, key string, id string, info string) error {
+	item, err := json.Marshal(map[string]any{
+		"LockID": map[string]string{"S": key},
+		"ID":     map[string]string{"S": id},
+		"Info":   map[string]string{"S": info},
+	})
+	if err != nil {
+		return err
+	}
+	args := table.args("put-item")
+	args = append(args, "--item", string(item), "--condition-expression", "attribute_not_exists(LockID)")
+	log.Debug(args)
+
+	var resp any
+	_, err = execAWS(args, &resp)
+
+	return err
+}
+
+// GetLockItem returns the info of the lock item of a key
+// (empty if there is none).
+func (c CLI) GetLockItem(table LockTable, key string) (string, error) {
+	var result *string
+	args := table.args("get-item")
+	args = append(args, "--key", lockItemKey(key), "--consistent-read", "--query", "Item.Info.S")
+	log.Debug(args)
+
+	_, err := execAWS(args, &result)
+	if err != nil || result == nil {
+		return "", err
+	}
+	return *result, nil
+}
+
+// DeleteLockItem deletes the lock item of a key if its id
+// is the given one (ErrConditionalCheckFailed otherwise),
+// whatever its id if id is empty.
+func (c CLI) DeleteLockItem(table LockTable, key string, id string) error {
+	args := table.args("delete-item")
+	args = append(args, "--key", lockItemKey(key))
+	if id != "" {
+		values, _ := json.Marshal(map[string]any{
+			":id": map[string]string{"S": id},
+		})
+		args = append(args, "--condition-expression", "ID = :id", "--expression-attribute-values", string(values))
+	}
+	log.Debug(args)
+
+	var resp any
+	_, err := execAWS(args, &resp)
+
+	return err
+}
@@ -0,0 +1,137 @@
+package aws
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// The kinds of the errors returned by aws, for errors.Is
+// (e.g. errors.Is(err, aws.ErrNotFound)).
+var (
+	ErrNotFound      = errors.New("not found")
+	ErrAccessDenied  = errors.New("access denied")
+	ErrThrottling    = errors.New("throttling")
+	ErrValidation    = errors.New("validation error")
+	ErrAlreadyExists = errors.New("already exists")
+	// ErrUnavailable is a failure of aws or of the network
+	// (the call may have been done or not).
+	ErrUnavailable = errors.New("unavailable")
+)
+
+// Error is an error returned by aws: its code (e.g. TargetGroupNotFound),
+// the operation that failed and the message. errors.Is matches its kind
+// (ErrNotFound, ErrThrottling, ...) if the code has one, errors.As the
+// error of the cli (*exec.ExitError) or of the sdk it comes from.
+type Error struct {
+	Code      string
+	Operation string
+	Message   string
+	// Stderr and ExitCode are the ones of the aws cli
+	// (empty with the sdk).
+	Stderr   string
+	ExitCode int
+
+	kind error
+	err  error
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("An error occurred (%s) when calling the %s operation: %s", e.Code, e.Operation, e.Message)
+}
+
+func (e *Error) Unwrap() []error {
+	var result []error
+	for _, err := range []error{e.kind, e.err} {
+		if err != nil {
+			result = append(result, err)
+		}
+	}
+	return result
+}
+
+// newError returns the Error of a code.
+func newError(code string, operation string, message string, err error) *Error {
+	return &Error{
+		Code:      code,
+		Operation: operation,
+		Message:   message,
+		kind:      errorKind(code, message),
+		err:       err,
+	}
+}
+
+// errorPattern is how the aws cli prints the errors of the apis.
+var errorPattern = regexp.MustCompile(`(?s)An error occurred \(([^)]*)\) when calling the (\w+) operation(?: \([^)]*\))?: (.*)`)
+
+// parseError returns the Error printed by the aws cli.
+func parseError(stderr string) *Error {
+	if m := errorPattern.FindStringSubmatch(stderr); m != nil {
+		return newError(m[1], m[2], strings.TrimSpace(m[3]), nil)
+	}
+	message := strings.TrimSpace(stderr)
+	return &Error{Message: message, kind: errorKind("", message)}
+}
+
+// cliError returns the Error of a failed aws cli command.
+func cliError(err error) error {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return err
+	}
+	result := parseError(string(exitErr.Stderr))
+	if result.Message == "" {
+		result.Message = exitErr.Error()
+	}
+	result.Stderr = string(exitErr.Stderr)
+	result.ExitCode = exitErr.ExitCode()
+	result.err = exitErr
+	return result
+}
+
+// errorKind returns the kind of an error code (nil if it has none).
+// The apis don't share their codes, they are matched by what
+// they have in common (e.g. TargetGroupNotFound, ResourceNotFoundException).
+func errorKind(code string, message string) error {
+	code = strings.TrimSuffix(code, "Exception")
+	switch {
+	case code == "ConditionalCheckFailed":
+		return ErrConditionalCheckFailed
+	case strings.HasSuffix(code, "NotFound"), strings.HasPrefix(code, "NoSuch"):
+		return ErrNotFound
+	// ecs
+	case code == "Client" && strings.HasPrefix(message, "Unable to describe task definition"),
+		code == "ServiceNotActive":
+		return ErrNotFound
+	case code == "InvalidParameter" && strings.Contains(message, "not idempotent"):
+		return ErrAlreadyExists
+	case strings.HasPrefix(code, "Duplicate"), strings.HasSuffix(code, "AlreadyExists"), code == "PriorityInUse":
+		return ErrAlreadyExists
+	case strings.HasPrefix(code, "AccessDenied"), strings.HasPrefix(code, "Unauthorized"),
+		code == "UnrecognizedClient", code == "InvalidClientTokenId", code == "ExpiredToken", code == "AuthFailure":
+		return ErrAccessDenied
+	case strings.HasPrefix(code, "Throttl"), code == "TooManyRequests", code == "RequestLimitExceeded",
+		code == "ProvisionedThroughputExceeded", code == "SlowDown":
+		return ErrThrottling
+	case strings.HasPrefix(code, "Validation"), strings.HasPrefix(code, "InvalidParameter"),
+		code == "Client", code == "InvalidConfigurationRequest", code == "MissingParameter", code == "Serialization":
+		return ErrValidation
+	case strings.HasPrefix(code, "Internal"), strings.HasSuffix(code, "Unavailable"),
+		code == "Server", code == "RequestTimeout":
+		return ErrUnavailable
+	// errors of the aws cli itself
+	case code == "" && strings.Contains(message, "Unable to locate credentials"):
+		return ErrAccessDenied
+	case code == "" && (strings.Contains(message, "Parameter validation failed") || strings.HasPrefix(message, "aws: error:")):
+		return ErrValidation
+	case code == "" && (strings.Contains(message, "Could not connect to the endpoint URL") ||
+		strings.Contains(message, "timeout on endpoint URL") || strings.Contains(message, "Connection was closed")):
+		return ErrUnavailable
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package aws
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+)
+
+func TestParseError(t *testing.T) {
+	tests := []struct {
+		name      string
+		stderr    string
+		code      string
+		operation string
+		kind      error
+	}{
+		// elbv2
+		{
+			name:      "target group not found",
+			stderr:    "\nAn error occurred (TargetGroupNotFound) when calling the DescribeTargetGroups operation: One or more target groups not found\n",
+			code:      "TargetGroupNotFound",
+			operation: "DescribeTargetGroups",
+			kind:      ErrNotFound,
+		},
+		{
+			name:      "duplicate target group",
+			stderr:    "An error occurred (DuplicateTargetGroupName) when calling the CreateTargetGroup operation: A target group with the same name 'web' exists",
+			code:      "DuplicateTargetGroupName",
+			operation: "CreateTargetGroup",
+			kind:      ErrAlreadyExists,
+		},
+		{
+			name:      "priority in use",
+			stderr:    "An error occurred (PriorityInUse) when calling the CreateRule operation: Priority '10' is currently in use",
+			code:      "PriorityInUse",
+			operation: "CreateRule",
+			kind:      ErrAlreadyExists,
+		},
+		{
+			name:      "elbv2 validation",
+			stderr:    "An error occurred (ValidationError) when calling the CreateListener operation: 'Port' must be provided",
+			code:      "ValidationError",
+			operation: "CreateListener",
+			kind:      ErrValidation,
+		},
+		{
+			name:      "throttling",
+			stderr:    "An error occurred (Throttling) when calling the DescribeRules operation (reached max retries: 2): Rate exceeded",
+			code:      "Throttling",
+			operation: "DescribeRules",
+			kind:      ErrThrottling,
+		},
+		{
+			name:      "access denied",
+			stderr:    "An error occurred (AccessDenied) when calling the DeleteListener operation: User is not authorized",
+			code:      "AccessDenied",
+			operation: "DeleteListener",
+			kind:      ErrAccessDenied,
+		},
+		// ecs
+		{
+			name:      "unknown task definition",
+			stderr:    "An error occurred (ClientException) when calling the DescribeTaskDefinition operation: Unable to describe task definition.",
+			code:      "ClientException",
+			operation: "DescribeTaskDefinition",
+			kind:      ErrNotFound,
+		},
+		{
+			name:      "ecs client error",
+			stderr:    "An error occurred (ClientException) when calling the RegisterTaskDefinition operation: Invalid 'cpu' setting for task.",
+			code:      "ClientException",
+			operation: "RegisterTaskDefinition",
+			kind:      ErrValidation,
+		},
+		{
+			name:      "service not active",
+			stderr:    "An error occurred (ServiceNotActiveException) when calling the UpdateService operation: Service was not ACTIVE.",
+			code:      "ServiceNotActiveException",
+			operation: "UpdateService",
+			kind:      ErrNotFound,
+		},
+		{
+			name:      "service not idempotent",
+			stderr:    "An error occurred (InvalidParameterException) when calling the CreateService operation: Creation of service was not idempotent.",
+			code:      "InvalidParameterException",
+			operation: "CreateService",
+			kind:      ErrAlreadyExists,
+		},
+		{
+			name:      "ecs server error",
+			stderr:    "An error occurred (ServerException) when calling the DescribeServices operation: Service unavailable",
+			code:      "ServerException",
+			operation: "DescribeServices",
+			kind:      ErrUnavailable,
+		},
+		// logs
+		{
+			name:      "log group already exists",
+			stderr:    "An error occurred (ResourceAlreadyExistsException) when calling the CreateLogGroup operation: The specified log group already exists",
+			code:      "ResourceAlreadyExistsException",
+			operation: "CreateLogGroup",
+			kind:      ErrAlreadyExists,
+		},
+		{
+			name:      "logs unavailable",
+			stderr:    "An error occurred (ServiceUnavailableException) when calling the CreateLogGroup operation: Service unavailable",
+			code:      "ServiceUnavailableException",
+			operation: "CreateLogGroup",
+			kind:      ErrUnavailable,
+		},
+		// ecr
+		{
+			name:      "repository not found",
+			stderr:    "An error occurred (RepositoryNotFoundException) when calling the ListImages operation: The repository does not exist",
+			code:      "RepositoryNotFoundException",
+			operation: "ListImages",
+			kind:      ErrNotFound,
+		},
+		// dynamodb
+		{
+			name:      "conditional check failed",
+			stderr:    "An error occurred (ConditionalCheckFailedException) when calling the PutItem operation: The conditional request failed",
+			code:      "ConditionalCheckFailedException",
+			operation: "PutItem",
+			kind:      ErrConditionalCheckFailed,
+		},
+		{
+			name:      "provisioned throughput exceeded",
+			stderr:    "An error occurred (ProvisionedThroughputExceededException) when calling the GetItem operation: Rate exceeded",
+			code:      "ProvisionedThroughputExceededException",
+			operation: "GetItem",
+			kind:      ErrThrottling,
+		},
+		// sts
+		{
+			name:      "expired token",
+			stderr:    "An error occurred (ExpiredToken) when calling the GetCallerIdentity operation: The security token included in the request is expired",
+			code:      "ExpiredToken",
+			operation: "GetCallerIdentity",
+			kind:      ErrAccessDenied,
+		},
+		// the aws cli itself
+		{
+			name:   "no credentials",
+			stderr: "\nUnable to locate credentials. You can configure credentials by running \"aws configure\".\n",
+			kind:   ErrAccessDenied,
+		},
+		{
+			name:   "bad parameters",
+			stderr: "\nParameter validation failed:\nMissing required parameter in input: \"Name\"\n",
+			kind:   ErrValidation,
+		},
+		{
+			name:   "bad arguments",
+			stderr: "aws: error: argument --port: expected one argument",
+			kind:   ErrValidation,
+		},
+		{
+			name:   "no connection",
+			stderr: "\nCould not connect to the endpoint URL: \"https://elasticloadbalancing.us-west-2.amazonaws.com/\"\n",
+			kind:   ErrUnavailable,
+		},
+		// no code: a plain error
+		{
+			name:   "unknown message",
+			stderr: "something went wrong",
+		},
+		{
+			name:      "unknown code",
+			stderr:    "An error occurred (SomethingElse) when calling the CreateRule operation: no idea",
+			code:      "SomethingElse",
+			operation: "CreateRule",
+		},
+	}
+	kinds := []error{ErrNotFound, ErrAccessDenied, ErrThrottling, ErrValidation, ErrAlreadyExists, ErrUnavailable, ErrConditionalCheckFailed}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := parseError(tt.stderr)
+			if err.Code != tt.code || err.Operation != tt.operation {
+				t.Errorf("code %q of %q, want %q of %q", err.Code, err.Operation, tt.code, tt.operation)
+			}
+			if err.kind != tt.kind {
+				t.Errorf("kind %v, want %v", err.kind, tt.kind)
+			}
+			// one kind only
+			for _, kind := range kinds {
+				if errors.Is(err, kind) != (kind == tt.kind) {
+					t.Errorf("errors.Is(%v) = %v", kind, errors.Is(err, kind))
+				}
+			}
+		})
+	}
+}
+
+func TestCliError(t *testing.T) {
+	// "aws" exiting with 254 and printing nothing
+	// (or not an api error)
+	_, exitErr := exec.Command("sh", "-c", "exit 254").Output()
+	err := cliError(exitErr)
+	var awsErr *Error
+	if !errors.As(err, &awsErr) {
+		t.Fatalf("got %#v, want an *aws.Error", err)
+	}
+	if awsErr.Message != "exit status 254" || awsErr.ExitCode != 254 || awsErr.Code != "" {
+		t.Errorf("got %#v, want the exit status 254 without code", awsErr)
+	}
+	if awsErr.kind != nil {
+		t.Errorf("kind %v, want none", awsErr.kind)
+	}
+	var execErr *exec.ExitError
+	if !errors.As(err, &execErr) {
+		t.Errorf("got %#v, want the *exec.ExitError of the cli", err)
+	}
+
+	// the error of an api
+	_, exitErr = exec.Command("sh", "-c", `echo "An error occurred (ListenerNotFound) when calling the DescribeListeners operation: One or more listeners not found" >&2; exit 254`).Output()
+	err = cliError(exitErr)
+	if !errors.As(err, &awsErr) || awsErr.Code != "ListenerNotFound" || awsErr.ExitCode != 254 {
+		t.Fatalf("got %#v, want the code ListenerNotFound", err)
+	}
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, want %v", err, ErrNotFound)
+	}
+	if awsErr.Stderr == "" {
+		t.Error("no stderr")
+	}
+
+	// not an error of the cli
+	plain := errors.New("plain")
+	if err = cliError(plain); err != plain {
+		t.Errorf("got %#v, want %v", err, plain)
+	}
+}
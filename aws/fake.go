@@ -0,0 +1,341 @@
+package aws
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"gopkg.in/yaml.v3"
+)
+
+// dummyFixture is the account of --dummy
+// when no fixture is given.
+//
+//go:embed dummy.yaml
+var dummyFixture []byte
+
+// Fixture describes the account a Fake starts from: the resources
+// that exist before any call, written as the aws cli describes them
+// (e.g. TargetGroupName, serviceName). Arns, ids and the attributes
+// set by aws are generated when they are not given.
+type Fixture struct {
+	Account string `yaml:"account"`
+	Region  string `yaml:"region"`
+	// VpcId is the vpc of the load balancers
+	// that don't have one.
+	VpcId string `yaml:"vpcId"`
+
+	TargetGroups []map[string]any `yaml:"targetGroups"`
+	// LoadBalancers can have Listeners
+	// that can have Rules.
+	LoadBalancers   []map[string]any          `yaml:"loadBalancers"`
+	LogGroups       []map[string]any          `yaml:"logGroups"`
+	TaskDefinitions []map[string]any          `yaml:"taskDefinitions"`
+	Clusters        map[string]FixtureCluster `yaml:"clusters"`
+	// Repositories are the image tags of ecr repositories
+	// (oldest first).
+	Repositories map[string][]string `yaml:"repositories"`
+}
+
+// FixtureCluster is what runs in an ecs cluster.
+type FixtureCluster struct {
+	Services []map[string]any `yaml:"services"`
+	Tasks    []map[string]any `yaml:"tasks"`
+}
+
+// ReadFixture reads a yaml fixture file.
+func ReadFixture(filepath string) (Fixture, error) {
+	var result Fixture
+	content, err := os.ReadFile(filepath)
+	if err != nil {
+		return result, err
+	}
+	err = yaml.Unmarshal(content, &result)
+	return result, err
+}
+
+// Fake is the Client of --dummy (and of tests): an in-memory account
+// where what is created can be described, modified and deleted
+// afterwards, as it would be in aws. Nothing is saved, a Fake starts
+// from its fixture every time.
+type Fake struct {
+	mu sync.Mutex
+
+	account string
+	region  string
+	vpcId   string
+	seq     int
+
+	targetGroups    *fakeTable
+	loadBalancers   *fakeTable
+	listeners       *fakeTable // parent: load balancer arn
+	rules           *fakeTable // parent: listener arn
+	services        *fakeTable // parent: cluster
+	tasks           *fakeTable // parent: cluster
+	taskDefinitions *fakeTable // parent: family
+	logGroups       *fakeTable // by name
+	repositories    map[string][]Image
+	tags            map[string][]any
+	locks           map[string]map[string]string
+}
+
+// NewFake returns an empty account.
+func NewFake() *Fake {
+	return &Fake{
+		account:         "123456789012",
+		region:          "us-west-2",
+		vpcId:           "vpc-3ac0fb5f",
+		targetGroups:    newFakeTable(),
+		loadBalancers:   newFakeTable(),
+		listeners:       newFakeTable(),
+		rules:           newFakeTable(),
+		services:        newFakeTable(),
+		tasks:           newFakeTable(),
+		taskDefinitions: newFakeTable(),
+		logGroups:       newFakeTable(),
+		repositories:    make(map[string][]Image),
+		tags:            make(map[string][]any),
+		locks:           make(map[string]map[string]string),
+	}
+}
+
+// NewFakeFromFixture returns the account of a fixture.
+func NewFakeFromFixture(fixture Fixture) (*Fake, error) {
+	f := NewFake()
+	if fixture.Account != "" {
+		f.account = fixture.Account
+	}
+	if fixture.Region != "" {
+		f.region = fixture.Region
+	}
+	if fixture.VpcId != "" {
+		f.vpcId = fixture.VpcId
+	}
+	for _, tg := range fixture.TargetGroups {
+		if _, err := f.addTargetGroup(normalize(tg)); err != nil {
+			return nil, err
+		}
+	}
+	for _, lb := range fixture.LoadBalancers {
+		if err := f.addFixtureLoadBalancer(normalize(lb)); err != nil {
+			return nil, err
+		}
+	}
+	for _, lg := range fixture.LogGroups {
+		if _, err := f.addLogGroup(normalize(lg)); err != nil {
+			return nil, err
+		}
+	}
+	for _, td := range fixture.TaskDefinitions {
+		if _, err := f.addTaskDefinition(normalize(td)); err != nil {
+			return nil, err
+		}
+	}
+	var clusters []string
+	for name := range fixture.Clusters {
+		clusters = append(clusters, name)
+	}
+	sort.Strings(clusters)
+	for _, cluster := range clusters {
+		for _, service := range fixture.Clusters[cluster].Services {
+			if _, err := f.addService(cluster, normalize(service)); err != nil {
+				return nil, err
+			}
+		}
+		for _, task := range fixture.Clusters[cluster].Tasks {
+			f.addTask(cluster, normalize(task))
+		}
+	}
+	var repositories []string
+	for name := range fixture.Repositories {
+		repositories = append(repositories, name)
+	}
+	sort.Strings(repositories)
+	for _, name := range repositories {
+		f.repositories[name] = []Image{}
+		for _, tag := range fixture.Repositories[name] {
+			f.repositories[name] = append(f.repositories[name], Image{
+				ImageDigest: "sha256:" + f.hex(name+":"+tag, 64),
+				ImageTag:    tag,
+			})
+		}
+	}
+	return f, nil
+}
+
+// LoadFake returns the account of a fixture file
+// (the one of --dummy if filepath is empty).
+func LoadFake(filepath string) (*Fake, error) {
+	var fixture Fixture
+	if filepath == "" {
+		if err := yaml.Unmarshal(dummyFixture, &fixture); err != nil {
+			return nil, err
+		}
+		return NewFakeFromFixture(fixture)
+	}
+	fixture, err := ReadFixture(filepath)
+	if err != nil {
+		return nil, err
+	}
+	f, err := NewFakeFromFixture(fixture)
+	if err != nil {
+		return nil, fmt.Errorf("fixture %s: %v", filepath, err)
+	}
+	return f, nil
+}
+
+// fakeTable holds the resources of a kind
+// by arn (in creation order).
+type fakeTable struct {
+	arns  []string
+	items map[string]*fakeItem
+}
+
+type fakeItem struct {
+	arn        string
+	parent     string
+	attributes map[string]any
+}
+
+func newFakeTable() *fakeTable {
+	return &fakeTable{items: make(map[string]*fakeItem)}
+}
+
+func (t *fakeTable) get(arn string) (*fakeItem, bool) {
+	item, ok := t.items[arn]
+	return item, ok
+}
+
+func (t *fakeTable) put(arn string, parent string, attributes map[string]any) *fakeItem {
+	if _, ok := t.items[arn]; !ok {
+		t.arns = append(t.arns, arn)
+	}
+	item := &fakeItem{arn: arn, parent: parent, attributes: attributes}
+	t.items[arn] = item
+	return item
+}
+
+func (t *fakeTable) delete(arn string) {
+	if _, ok := t.items[arn]; !ok {
+		return
+	}
+	delete(t.items, arn)
+	for i, v := range t.arns {
+		if v == arn {
+			t.arns = append(t.arns[:i], t.arns[i+1:]...)
+			break
+		}
+	}
+}
+
+// find returns the items that match (all if match is nil).
+func (t *fakeTable) find(match func(item *fakeItem) bool) []*fakeItem {
+	var result []*fakeItem
+	for _, arn := range t.arns {
+		if item := t.items[arn]; match == nil || match(item) {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// children returns the items of a parent.
+func (t *fakeTable) children(parent string) []*fakeItem {
+	return t.find(func(item *fakeItem) bool {
+		return item.parent == parent
+	})
+}
+
+// fakeError is an error as returned by aws.
+func fakeError(code string, operation string, message string) error {
+	return newError(code, operation, message, nil)
+}
+
+// call logs an operation of the fake
+// (as the args of the cli).
+func (f *Fake) call(operation string, args ...any) {
+	log.Debug("dummy "+operation, args...)
+}
+
+// hex returns n hexadecimal digits derived from a seed
+// (the same ones from a run to another).
+func (f *Fake) hex(seed string, n int) string {
+	sum := sha256.Sum256([]byte(f.account + "/" + seed))
+	result := hex.EncodeToString(sum[:])
+	for len(result) < n {
+		sum = sha256.Sum256(sum[:])
+		result += hex.EncodeToString(sum[:])
+	}
+	return result[:n]
+}
+
+// id returns a new id of n hexadecimal digits.
+func (f *Fake) id(n int) string {
+	f.seq++
+	return f.hex(strconv.Itoa(f.seq), n)
+}
+
+// digits returns a new id of n decimal digits.
+func (f *Fake) digits(n int) string {
+	var result string
+	for _, c := range f.id(n) {
+		result += strconv.Itoa(int(c) % 10)
+	}
+	return result
+}
+
+func now() string {
+	return time.Now().Format(timeLayout)
+}
+
+// normalize returns attributes as decoded from json
+// (numbers are float64, a copy is returned).
+func normalize(attributes map[string]any) map[string]any {
+	var result map[string]any
+	content, err := json.Marshal(attributes)
+	if err != nil || json.Unmarshal(content, &result) != nil || result == nil {
+		return make(map[string]any)
+	}
+	return result
+}
+
+// decode decodes attributes into a type of this package.
+func decode(from any, to any) error {
+	content, err := json.Marshal(from)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(content, to)
+}
+
+func str(attributes map[string]any, key string) string {
+	result, _ := attributes[key].(string)
+	return result
+}
+
+func num(attributes map[string]any, key string) int {
+	switch v := attributes[key].(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	case string:
+		result, _ := strconv.Atoi(v)
+		return result
+	}
+	return 0
+}
+
+func setDefault(attributes map[string]any, key string, value any) {
+	if _, ok := attributes[key]; !ok {
+		attributes[key] = value
+	}
+}
@@ -0,0 +1,36 @@
+package aws
+
+// lockItem returns the key of the lock item of a table.
+func lockItem(table LockTable, key string) string {
+	return table.Endpoint + "/" + table.Region + "/" + table.Name + "/" + key
+}
+
+func (f *Fake) PutLockItem(table LockTable, key string, id string, info string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.call("dynamodb put-item", "table", table.Name, "key", key, "id", id)
+	if _, ok := f.locks[lockItem(table, key)]; ok {
+		return fakeError("ConditionalCheckFailedException", "PutItem", "The conditional request failed")
+	}
+	f.locks[lockItem(table, key)] = map[string]string{"ID": id, "Info": info}
+	return nil
+}
+
+func (f *Fake) GetLockItem(table LockTable, key string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.call("dynamodb get-item", "table", table.Name, "key", key)
+	return f.locks[lockItem(table, key)]["Info"], nil
+}
+
+func (f *Fake) DeleteLockItem(table LockTable, key string, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.call("dynamodb delete-item", "table", table.Name, "key", key, "id", id)
+	item, ok := f.locks[lockItem(table, key)]
+	if id != "" && (!ok || item["ID"] != id) {
+		return fakeError("ConditionalCheckFailedException", "DeleteItem", "The conditional request failed")
+	}
+	delete(f.locks, lockItem(table, key))
+	return nil
+}
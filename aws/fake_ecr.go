@@ -0,0 +1,21 @@
+package aws
+
+import "fmt"
+
+func (f *Fake) ListImages(ecrRepositoryName string) ([]Image, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.call("ecr list-images", "repository", ecrRepositoryName)
+	images, ok := f.repositories[ecrRepositoryName]
+	if !ok {
+		return nil, fakeError("RepositoryNotFoundException", "ListImages", fmt.Sprintf(
+			"The repository with name '%s' does not exist in the registry with id '%s'", ecrRepositoryName, f.account,
+		))
+	}
+	var result []Image
+	// reverse array
+	for i := len(images) - 1; i >= 0; i-- {
+		result = append(result, images[i])
+	}
+	return result, nil
+}
@@ -0,0 +1,498 @@
+package aws
+
+import (
+	"fmt"
+	"strings"
+)
+
+func (f *Fake) ecsArn(resource string) string {
+	return fmt.Sprintf("arn:aws:ecs:%s:%s:%s", f.region, f.account, resource)
+}
+
+// clusterName returns the name of a cluster
+// (or of the default one).
+func clusterName(cluster string) string {
+	if _, name, ok := strings.Cut(cluster, ":cluster/"); ok {
+		return name
+	}
+	if cluster == "" {
+		return "default"
+	}
+	return cluster
+}
+
+// taskDefinition returns the task definition of a
+// family (latest active revision), family:revision or arn.
+func (f *Fake) taskDefinition(taskDefinition string) (*fakeItem, bool) {
+	if strings.Contains(taskDefinition, ":task-definition/") {
+		return f.taskDefinitions.get(taskDefinition)
+	}
+	if strings.Contains(taskDefinition, ":") {
+		return f.taskDefinitions.get(f.ecsArn("task-definition/" + taskDefinition))
+	}
+	var result *fakeItem
+	for _, td := range f.taskDefinitions.children(taskDefinition) {
+		if str(td.attributes, "status") == "ACTIVE" && (result == nil || num(td.attributes, "revision") > num(result.attributes, "revision")) {
+			result = td
+		}
+	}
+	return result, result != nil
+}
+
+func (f *Fake) addTaskDefinition(attributes map[string]any) (*fakeItem, error) {
+	family := str(attributes, "family")
+	if family == "" {
+		return nil, fakeError("ClientException", "RegisterTaskDefinition", "Family is required.")
+	}
+	revision := num(attributes, "revision")
+	if revision <= 0 {
+		for _, td := range f.taskDefinitions.children(family) {
+			revision = max(revision, num(td.attributes, "revision"))
+		}
+		revision++
+	}
+	arn := f.ecsArn(fmt.Sprintf("task-definition/%s:%d", family, revision))
+	if tags, ok := attributes["tags"].([]any); ok {
+		f.tags[arn] = tags
+	}
+	delete(attributes, "tags")
+	attributes["taskDefinitionArn"] = arn
+	attributes["revision"] = float64(revision)
+	setDefault(attributes, "status", "ACTIVE")
+	setDefault(attributes, "containerDefinitions", []any{})
+	setDefault(attributes, "volumes", []any{})
+	setDefault(attributes, "placementConstraints", []any{})
+	setDefault(attributes, "requiresAttributes", []any{})
+	setDefault(attributes, "registeredAt", now())
+	setDefault(attributes, "registeredBy", fmt.Sprintf("arn:aws:iam::%s:user/dummy", f.account))
+	if str(attributes, "networkMode") == "awsvpc" {
+		setDefault(attributes, "compatibilities", []any{"EC2", "FARGATE"})
+	} else {
+		setDefault(attributes, "compatibilities", []any{"EC2"})
+	}
+	return f.taskDefinitions.put(arn, family, attributes), nil
+}
+
+func (f *Fake) DescribeTaskDefinition(taskDefinition string) (TaskDefinition, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.call("ecs describe-task-definition", "taskDefinition", taskDefinition)
+	result := TaskDefinition{}
+	td, ok := f.taskDefinition(taskDefinition)
+	if !ok {
+		return result, fakeError("ClientException", "DescribeTaskDefinition", "Unable to describe task definition.")
+	}
+	if err := decode(td.attributes, &result); err != nil {
+		return result, err
+	}
+	result.Tags = f.tags[td.arn]
+	return result, nil
+}
+
+func (f *Fake) RegisterTaskDefinition(inputJson string) (TaskDefinition, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	result := TaskDefinition{}
+	var attributes map[string]any
+	if err := readInputJson(inputJson, &attributes); err != nil {
+		return result, err
+	}
+	f.call("ecs register-task-definition", "input", attributes)
+	// only aws sets them
+	for _, key := range []string{"taskDefinitionArn", "revision", "status", "registeredAt", "registeredBy", "compatibilities", "requiresAttributes"} {
+		delete(attributes, key)
+	}
+	td, err := f.addTaskDefinition(normalize(attributes))
+	if err != nil {
+		return result, err
+	}
+	err = decode(td.attributes, &result)
+	return result, err
+}
+
+func (f *Fake) DescribeTaskDefinitionByArn(taskDefinition string) (map[string]any, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.call("ecs describe-task-definition", "taskDefinition", taskDefinition)
+	td, ok := f.taskDefinition(taskDefinition)
+	if !ok {
+		return nil, fakeError("ClientException", "DescribeTaskDefinition", "Unable to describe task definition.")
+	}
+	return normalize(td.attributes), nil
+}
+
+// service returns the service of a cluster by arn or name.
+func (f *Fake) service(cluster string, service string) (*fakeItem, bool) {
+	if !strings.Contains(service, ":service/") {
+		service = f.ecsArn("service/" + clusterName(cluster) + "/" + service)
+	}
+	item, ok := f.services.get(service)
+	if !ok || item.parent != clusterName(cluster) {
+		return nil, false
+	}
+	return item, true
+}
+
+// deploy rolls out the task definition of a service: the fake
+// reaches a steady state at once.
+func (f *Fake) deploy(service map[string]any) {
+	desiredCount := service["desiredCount"]
+	id := "ecs-svc/" + f.digits(19)
+	createdAt := now()
+	service["runningCount"] = desiredCount
+	service["pendingCount"] = float64(0)
+	service["deployments"] = []any{
+		map[string]any{
+			"id":                 id,
+			"status":             "PRIMARY",
+			"taskDefinition":     service["taskDefinition"],
+			"desiredCount":       desiredCount,
+			"pendingCount":       float64(0),
+			"runningCount":       desiredCount,
+			"failedTasks":        float64(0),
+			"createdAt":          createdAt,
+			"updatedAt":          createdAt,
+			"rolloutState":       "COMPLETED",
+			"rolloutStateReason": "ECS deployment " + id + " completed.",
+		},
+	}
+	f.event(service, "has reached a steady state.")
+}
+
+// event adds an event to a service (most recent first).
+func (f *Fake) event(service map[string]any, message string) {
+	events, _ := service["events"].([]any)
+	service["events"] = append([]any{
+		map[string]any{
+			"id":        f.id(8) + "-" + f.id(4) + "-" + f.id(4) + "-" + f.id(4) + "-" + f.id(12),
+			"createdAt": now(),
+			"message":   fmt.Sprintf("(service %s) %s", str(service, "serviceName"), message),
+		},
+	}, events...)
+}
+
+func (f *Fake) addService(cluster string, attributes map[string]any) (*fakeItem, error) {
+	cluster = clusterName(cluster)
+	name := str(attributes, "serviceName")
+	if name == "" {
+		return nil, fakeError("InvalidParameterException", "CreateService", "Service name is required.")
+	}
+	if existing, ok := f.service(cluster, name); ok && str(existing.attributes, "status") != "INACTIVE" {
+		return nil, fakeError("InvalidParameterException", "CreateService", "Creation of service was not idempotent.")
+	}
+	td, ok := f.taskDefinition(str(attributes, "taskDefinition"))
+	if !ok {
+		return nil, fakeError("ClientException", "CreateService", "TaskDefinition not found.")
+	}
+	for _, arn := range f.serviceTargetGroups(attributes) {
+		if _, ok := f.targetGroups.get(arn); !ok {
+			return nil, fakeError("InvalidParameterException", "CreateService", "Unable to assume role and validate the specified targetGroupArn. Please verify that the ECS service role being passed has the proper permissions.")
+		}
+	}
+	for _, key := range []string{"cluster", "clientToken", "tags"} {
+		delete(attributes, key)
+	}
+	arn := f.ecsArn("service/" + cluster + "/" + name)
+	attributes["serviceArn"] = arn
+	attributes["clusterArn"] = f.ecsArn("cluster/" + cluster)
+	attributes["taskDefinition"] = td.arn
+	setDefault(attributes, "status", "ACTIVE")
+	setDefault(attributes, "desiredCount", float64(0))
+	setDefault(attributes, "loadBalancers", []any{})
+	setDefault(attributes, "serviceRegistries", []any{})
+	setDefault(attributes, "schedulingStrategy", "REPLICA")
+	setDefault(attributes, "propagateTags", "NONE")
+	setDefault(attributes, "enableECSManagedTags", false)
+	setDefault(attributes, "enableExecuteCommand", false)
+	setDefault(attributes, "createdAt", now())
+	setDefault(attributes, "deploymentConfiguration", map[string]any{
+		"deploymentCircuitBreaker": map[string]any{"enable": false, "rollback": false},
+		"maximumPercent":           float64(200),
+		"minimumHealthyPercent":    float64(100),
+	})
+	if attributes["capacityProviderStrategy"] == nil {
+		setDefault(attributes, "launchType", "EC2")
+	}
+	if str(attributes, "launchType") == "FARGATE" {
+		setDefault(attributes, "platformVersion", "LATEST")
+	}
+	if _, ok := attributes["deployments"]; !ok {
+		f.deploy(attributes)
+	}
+	setDefault(attributes, "events", []any{})
+	return f.services.put(arn, cluster, attributes), nil
+}
+
+// serviceTargetGroups returns the target groups of a service.
+func (f *Fake) serviceTargetGroups(attributes map[string]any) []string {
+	var result []string
+	loadBalancers, _ := attributes["loadBalancers"].([]any)
+	for _, lb := range loadBalancers {
+		if arn := str(lb.(map[string]any), "targetGroupArn"); arn != "" {
+			result = append(result, arn)
+		}
+	}
+	return result
+}
+
+// fakeLoadBalancers is "targetGroupArn=<arn>,containerName=<name>,containerPort=<port>".
+func fakeLoadBalancers(loadBalancer ServiceLoadBalancer) []any {
+	return []any{
+		map[string]any{
+			"targetGroupArn": loadBalancer.TargetGroupArn,
+			"containerName":  loadBalancer.ContainerName,
+			"containerPort":  float64(loadBalancer.ContainerPort),
+		},
+	}
+}
+
+// updateService updates a service and deploys it
+// when its task definition or desired count changed.
+func (f *Fake) updateService(cluster string, serviceArn string, attributes map[string]any) (*fakeItem, error) {
+	service, ok := f.service(cluster, serviceArn)
+	if !ok {
+		return nil, fakeError("ServiceNotFoundException", "UpdateService", "Service not found.")
+	}
+	if str(service.attributes, "status") != "ACTIVE" {
+		return nil, fakeError("ServiceNotActiveException", "UpdateService", "Service was not ACTIVE.")
+	}
+	deploy := attributes["forceNewDeployment"] == true
+	for _, key := range []string{"cluster", "service", "forceNewDeployment"} {
+		delete(attributes, key)
+	}
+	if taskDefinition := str(attributes, "taskDefinition"); taskDefinition != "" {
+		td, ok := f.taskDefinition(taskDefinition)
+		if !ok {
+			return nil, fakeError("ClientException", "UpdateService", "TaskDefinition not found.")
+		}
+		attributes["taskDefinition"] = td.arn
+	}
+	for _, arn := range f.serviceTargetGroups(attributes) {
+		if _, ok := f.targetGroups.get(arn); !ok {
+			return nil, fakeError("InvalidParameterException", "UpdateService", "Unable to assume role and validate the specified targetGroupArn. Please verify that the ECS service role being passed has the proper permissions.")
+		}
+	}
+	for _, key := range []string{"taskDefinition", "desiredCount"} {
+		if v, ok := attributes[key]; ok && fmt.Sprint(v) != fmt.Sprint(service.attributes[key]) {
+			deploy = true
+		}
+	}
+	for k, v := range attributes {
+		service.attributes[k] = v
+	}
+	if deploy {
+		f.deploy(service.attributes)
+	}
+	return service, nil
+}
+
+func (f *Fake) CreateService(filepath string, loadBalancer ServiceLoadBalancer, healthCheckGracePeriodSeconds int) (Service, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var result Service
+	var attributes map[string]any
+	if err := readInput(filepath, nil, &attributes); err != nil {
+		return result, err
+	}
+	attributes = normalize(attributes)
+	if loadBalancer.TargetGroupArn != "" && loadBalancer.ContainerName != "" {
+		attributes["loadBalancers"] = fakeLoadBalancers(loadBalancer)
+	}
+	if healthCheckGracePeriodSeconds > 0 {
+		attributes["healthCheckGracePeriodSeconds"] = float64(healthCheckGracePeriodSeconds)
+	}
+	f.call("ecs create-service", "input", attributes)
+	service, err := f.addService(str(attributes, "cluster"), attributes)
+	if err != nil {
+		return result, err
+	}
+	err = decode(service.attributes, &result)
+	return result, err
+}
+
+func (f *Fake) UpdateServiceWithFile(cluster string, serviceArn string, filepath string, loadBalancer ServiceLoadBalancer, healthCheckGracePeriodSeconds int) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var attributes map[string]any
+	if err := readInput(filepath, updateServiceKeys, &attributes); err != nil {
+		return "", err
+	}
+	attributes = normalize(attributes)
+	if loadBalancer.TargetGroupArn != "" && loadBalancer.ContainerName != "" {
+		attributes["loadBalancers"] = fakeLoadBalancers(loadBalancer)
+	}
+	if healthCheckGracePeriodSeconds > 0 {
+		attributes["healthCheckGracePeriodSeconds"] = float64(healthCheckGracePeriodSeconds)
+	}
+	f.call("ecs update-service", "cluster", cluster, "service", serviceArn, "input", attributes)
+	_, err := f.updateService(cluster, serviceArn, attributes)
+	return "", err
+}
+
+// describeServices returns the services found
+// (the missing ones are failures, not errors).
+func (f *Fake) describeServices(cluster string, services []string) ([]map[string]any, error) {
+	if len(services) > 10 {
+		return nil, fakeError("InvalidParameterException", "DescribeServices", "service names can have at most 10 items.")
+	}
+	result := []map[string]any{}
+	for _, s := range services {
+		if service, ok := f.service(cluster, s); ok {
+			result = append(result, normalize(service.attributes))
+		}
+	}
+	return result, nil
+}
+
+func (f *Fake) DescribeService(cluster string, serviceArn string) (Service, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.call("ecs describe-services", "cluster", cluster, "services", serviceArn)
+	var result Service
+	services, err := f.describeServices(cluster, []string{serviceArn})
+	if err != nil || len(services) == 0 {
+		return result, err
+	}
+	err = decode(services[0], &result)
+	return result, err
+}
+
+func (f *Fake) DescribeServiceStability(cluster string, serviceArn string) (ServiceStability, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.call("ecs describe-services", "cluster", cluster, "services", serviceArn)
+	var result ServiceStability
+	services, err := f.describeServices(cluster, []string{serviceArn})
+	if err != nil || len(services) == 0 {
+		return result, err
+	}
+	if events, _ := services[0]["events"].([]any); len(events) > 10 {
+		services[0]["events"] = events[:10]
+	}
+	err = decode(services[0], &result)
+	return result, err
+}
+
+func (f *Fake) DescribeServiceByArn(cluster string, serviceArn string) (map[string]any, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.call("ecs describe-services", "cluster", cluster, "services", serviceArn)
+	services, err := f.describeServices(cluster, []string{serviceArn})
+	if err != nil || len(services) == 0 {
+		return nil, err
+	}
+	return services[0], nil
+}
+
+func (f *Fake) DescribeServices(cluster string, serviceArns ...string) ([]Service, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.call("ecs describe-services", "cluster", cluster, "services", serviceArns)
+	var result []Service
+	services, err := f.describeServices(cluster, serviceArns)
+	if err != nil {
+		return result, err
+	}
+	err = decode(services, &result)
+	return result, err
+}
+
+func (f *Fake) ListServices(cluster string) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.call("ecs list-services", "cluster", cluster)
+	result := []string{}
+	for _, service := range f.services.children(clusterName(cluster)) {
+		if str(service.attributes, "status") != "INACTIVE" {
+			result = append(result, service.arn)
+		}
+	}
+	return result, nil
+}
+
+func (f *Fake) UpdateService(cluster string, serviceArn string, inputJson string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var attributes map[string]any
+	if err := readInputJson(inputJson, &attributes); err != nil {
+		return "", err
+	}
+	f.call("ecs update-service", "cluster", cluster, "service", serviceArn, "input", attributes)
+	_, err := f.updateService(cluster, serviceArn, normalize(attributes))
+	return "", err
+}
+
+func (f *Fake) UpdateServiceDesiredCount(cluster string, serviceArn string, desiredCount int) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.call("ecs update-service", "cluster", cluster, "service", serviceArn, "desiredCount", desiredCount)
+	service, err := f.updateService(cluster, serviceArn, map[string]any{"desiredCount": float64(desiredCount)})
+	if err != nil {
+		return "", err
+	}
+	return service.arn, nil
+}
+
+func (f *Fake) DeleteService(cluster string, serviceArn string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.call("ecs delete-service", "cluster", cluster, "service", serviceArn)
+	service, ok := f.service(cluster, serviceArn)
+	if !ok || str(service.attributes, "status") == "INACTIVE" {
+		return "", fakeError("ServiceNotFoundException", "DeleteService", "Service not found.")
+	}
+	if num(service.attributes, "desiredCount") > 0 {
+		return "", fakeError("InvalidParameterException", "DeleteService", "The service cannot be stopped while it is scaled above 0.")
+	}
+	service.attributes["status"] = "DRAINING"
+	return service.arn, nil
+}
+
+func (f *Fake) WaitServicesInactive(cluster string, serviceArn string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.call("ecs wait services-inactive", "cluster", cluster, "service", serviceArn)
+	if service, ok := f.service(cluster, serviceArn); ok && str(service.attributes, "status") == "DRAINING" {
+		service.attributes["status"] = "INACTIVE"
+	}
+	return nil
+}
+
+func (f *Fake) addTask(cluster string, attributes map[string]any) {
+	cluster = clusterName(cluster)
+	setDefault(attributes, "taskArn", f.ecsArn("task/"+cluster+"/"+f.id(32)))
+	setDefault(attributes, "clusterArn", f.ecsArn("cluster/"+cluster))
+	setDefault(attributes, "lastStatus", "STOPPED")
+	setDefault(attributes, "containers", []any{})
+	f.tasks.put(str(attributes, "taskArn"), cluster, attributes)
+}
+
+func (f *Fake) ListStoppedTasks(cluster string, serviceName string) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.call("ecs list-tasks", "cluster", cluster, "serviceName", serviceName)
+	result := []string{}
+	tasks := f.tasks.children(clusterName(cluster))
+	// most recent first
+	for i := len(tasks) - 1; i >= 0 && len(result) < 5; i-- {
+		if str(tasks[i].attributes, "group") == "service:"+serviceName && str(tasks[i].attributes, "lastStatus") == "STOPPED" {
+			result = append(result, tasks[i].arn)
+		}
+	}
+	return result, nil
+}
+
+func (f *Fake) DescribeTasks(cluster string, taskArns []string) ([]Task, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.call("ecs describe-tasks", "cluster", cluster, "tasks", taskArns)
+	var result []Task
+	var tasks []map[string]any
+	for _, arn := range taskArns {
+		if task, ok := f.tasks.get(arn); ok && task.parent == clusterName(cluster) {
+			tasks = append(tasks, task.attributes)
+		}
+	}
+	err := decode(tasks, &result)
+	return result, err
+}
@@ -0,0 +1,712 @@
+package aws
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func (f *Fake) elbv2Arn(resource string) string {
+	return fmt.Sprintf("arn:aws:elasticloadbalancing:%s:%s:%s", f.region, f.account, resource)
+}
+
+// fakeForward is a forward action as described by aws.
+func fakeForward(targetGroupArn string) []any {
+	return normalizeActions([]any{map[string]any{"Type": "forward", "TargetGroupArn": targetGroupArn}})
+}
+
+// normalizeActions adds the ForwardConfig aws adds
+// to the forward actions.
+func normalizeActions(actions any) []any {
+	list, _ := actions.([]any)
+	result := []any{}
+	for _, a := range list {
+		action, ok := a.(map[string]any)
+		if !ok {
+			continue
+		}
+		action = normalize(action)
+		if arn := str(action, "TargetGroupArn"); arn != "" && action["ForwardConfig"] == nil {
+			action["ForwardConfig"] = map[string]any{
+				"TargetGroups": []any{
+					map[string]any{"TargetGroupArn": arn, "Weight": float64(1)},
+				},
+				"TargetGroupStickinessConfig": map[string]any{"Enabled": false},
+			}
+		}
+		result = append(result, action)
+	}
+	return result
+}
+
+// actionTargetGroups returns the target groups actions forward to.
+func actionTargetGroups(actions any) []string {
+	var result []string
+	list, _ := actions.([]any)
+	for _, a := range list {
+		action, _ := a.(map[string]any)
+		if arn := str(action, "TargetGroupArn"); arn != "" {
+			result = append(result, arn)
+			continue
+		}
+		config, _ := action["ForwardConfig"].(map[string]any)
+		targetGroups, _ := config["TargetGroups"].([]any)
+		for _, tg := range targetGroups {
+			if arn := str(tg.(map[string]any), "TargetGroupArn"); arn != "" {
+				result = append(result, arn)
+			}
+		}
+	}
+	return result
+}
+
+// checkActions fails if actions forward to a target group
+// that does not exist.
+func (f *Fake) checkActions(operation string, actions any) error {
+	for _, arn := range actionTargetGroups(actions) {
+		if _, ok := f.targetGroups.get(arn); !ok {
+			return fakeError("TargetGroupNotFound", operation, fmt.Sprintf("Target groups '%s' not found", arn))
+		}
+	}
+	return nil
+}
+
+// targetGroupLoadBalancers returns the load balancers
+// that forward to a target group.
+func (f *Fake) targetGroupLoadBalancers(targetGroupArn string) []any {
+	result := []any{}
+	for _, lb := range f.loadBalancers.find(nil) {
+		used := false
+		for _, listener := range f.listeners.children(lb.arn) {
+			for _, rule := range f.rules.children(listener.arn) {
+				for _, arn := range actionTargetGroups(rule.attributes["Actions"]) {
+					used = used || arn == targetGroupArn
+				}
+			}
+		}
+		if used {
+			result = append(result, lb.arn)
+		}
+	}
+	return result
+}
+
+func (f *Fake) addTargetGroup(attributes map[string]any) (*fakeItem, error) {
+	if name := str(attributes, "Name"); name != "" {
+		attributes["TargetGroupName"] = name
+	}
+	delete(attributes, "Name")
+	delete(attributes, "Tags")
+	name := str(attributes, "TargetGroupName")
+	if name == "" {
+		return nil, fakeError("ValidationError", "CreateTargetGroup", "A target group name is required")
+	}
+	for _, tg := range f.targetGroups.find(nil) {
+		if str(tg.attributes, "TargetGroupName") == name {
+			return nil, fakeError("DuplicateTargetGroupName", "CreateTargetGroup", "A target group with the same name '"+name+"' exists, but with different settings")
+		}
+	}
+	setDefault(attributes, "TargetGroupArn", f.elbv2Arn("targetgroup/"+name+"/"+f.id(16)))
+	setDefault(attributes, "TargetType", "instance")
+	setDefault(attributes, "IpAddressType", "ipv4")
+	setDefault(attributes, "HealthCheckEnabled", true)
+	setDefault(attributes, "HealthCheckPort", "traffic-port")
+	setDefault(attributes, "HealthCheckIntervalSeconds", float64(30))
+	setDefault(attributes, "HealthCheckTimeoutSeconds", float64(5))
+	setDefault(attributes, "HealthyThresholdCount", float64(5))
+	setDefault(attributes, "UnhealthyThresholdCount", float64(2))
+	if protocol := str(attributes, "Protocol"); protocol != "" {
+		setDefault(attributes, "HealthCheckProtocol", protocol)
+		if protocol == "HTTP" || protocol == "HTTPS" {
+			setDefault(attributes, "HealthCheckPath", "/")
+			setDefault(attributes, "Matcher", map[string]any{"HttpCode": "200"})
+			setDefault(attributes, "ProtocolVersion", "HTTP1")
+		}
+	}
+	return f.targetGroups.put(str(attributes, "TargetGroupArn"), "", attributes), nil
+}
+
+// targetGroup returns every attribute of a target group.
+func (f *Fake) targetGroup(item *fakeItem) map[string]any {
+	result := normalize(item.attributes)
+	result["LoadBalancerArns"] = f.targetGroupLoadBalancers(item.arn)
+	return result
+}
+
+func (f *Fake) DescribeTargetGroups() ([]TargetGroup, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.call("elbv2 describe-target-groups")
+	result := []TargetGroup{}
+	var targetGroups []map[string]any
+	for _, tg := range f.targetGroups.find(nil) {
+		targetGroups = append(targetGroups, f.targetGroup(tg))
+	}
+	err := decode(targetGroups, &result)
+	return result, err
+}
+
+func (f *Fake) DescribeTargetGroupsWithNames(names []string) ([]TargetGroup, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.call("elbv2 describe-target-groups", "names", names)
+	result := []TargetGroup{}
+	var targetGroups []map[string]any
+	for _, name := range names {
+		found := f.targetGroups.find(func(item *fakeItem) bool {
+			return str(item.attributes, "TargetGroupName") == name
+		})
+		if len(found) == 0 {
+			return result, fakeError("TargetGroupNotFound", "DescribeTargetGroups", "One or more target groups not found")
+		}
+		targetGroups = append(targetGroups, f.targetGroup(found[0]))
+	}
+	err := decode(targetGroups, &result)
+	return result, err
+}
+
+func (f *Fake) CreateTargetGroup(filepath string) (TargetGroup, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var result TargetGroup
+	var attributes map[string]any
+	if err := readInput(filepath, nil, &attributes); err != nil {
+		return result, err
+	}
+	f.call("elbv2 create-target-group", "input", attributes)
+	tg, err := f.addTargetGroup(normalize(attributes))
+	if err != nil {
+		return result, err
+	}
+	err = decode(f.targetGroup(tg), &result)
+	return result, err
+}
+
+func (f *Fake) ModifyTargetGroup(targetGroupArn string, filepath string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var attributes map[string]any
+	if err := readInput(filepath, modifyTargetGroupKeys, &attributes); err != nil {
+		return "", err
+	}
+	f.call("elbv2 modify-target-group", "arn", targetGroupArn, "input", attributes)
+	tg, ok := f.targetGroups.get(targetGroupArn)
+	if !ok {
+		return "", fakeError("TargetGroupNotFound", "ModifyTargetGroup", "Target groups '"+targetGroupArn+"' not found")
+	}
+	for k, v := range normalize(attributes) {
+		tg.attributes[k] = v
+	}
+	return "", nil
+}
+
+func (f *Fake) DeleteTargetGroup(targetGroupArn string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.call("elbv2 delete-target-group", "arn", targetGroupArn)
+	if _, ok := f.targetGroups.get(targetGroupArn); !ok {
+		return "", fakeError("TargetGroupNotFound", "DeleteTargetGroup", "Target groups '"+targetGroupArn+"' not found")
+	}
+	if len(f.targetGroupLoadBalancers(targetGroupArn)) > 0 {
+		return "", fakeError("ResourceInUse", "DeleteTargetGroup", "Target group '"+targetGroupArn+"' is currently in use by a listener or a rule")
+	}
+	f.targetGroups.delete(targetGroupArn)
+	return "", nil
+}
+
+func (f *Fake) DescribeTargetGroupByArn(targetGroupArn string) (map[string]any, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.call("elbv2 describe-target-groups", "arn", targetGroupArn)
+	tg, ok := f.targetGroups.get(targetGroupArn)
+	if !ok {
+		return nil, fakeError("TargetGroupNotFound", "DescribeTargetGroups", "One or more target groups not found")
+	}
+	return f.targetGroup(tg), nil
+}
+
+func (f *Fake) addLoadBalancer(attributes map[string]any) (*fakeItem, error) {
+	if name := str(attributes, "Name"); name != "" {
+		attributes["LoadBalancerName"] = name
+	}
+	name := str(attributes, "LoadBalancerName")
+	if name == "" {
+		return nil, fakeError("ValidationError", "CreateLoadBalancer", "A load balancer name is required")
+	}
+	for _, lb := range f.loadBalancers.find(nil) {
+		if str(lb.attributes, "LoadBalancerName") == name {
+			return nil, fakeError("DuplicateLoadBalancerName", "CreateLoadBalancer", "A load balancer with the same name '"+name+"' exists, but with different settings")
+		}
+	}
+	setDefault(attributes, "Type", "application")
+	setDefault(attributes, "Scheme", "internet-facing")
+	setDefault(attributes, "IpAddressType", "ipv4")
+	setDefault(attributes, "VpcId", f.vpcId)
+	setDefault(attributes, "CanonicalHostedZoneId", "Z1H1FL5HABSF5")
+	setDefault(attributes, "CreatedTime", now())
+	setDefault(attributes, "State", map[string]any{"Code": "active"})
+	prefix := map[string]string{"network": "net", "gateway": "gwy"}[str(attributes, "Type")]
+	if prefix == "" {
+		prefix = "app"
+	}
+	setDefault(attributes, "LoadBalancerArn", f.elbv2Arn("loadbalancer/"+prefix+"/"+name+"/"+f.id(16)))
+	dnsName := name + "-" + f.digits(9) + "." + f.region + ".elb.amazonaws.com"
+	if str(attributes, "Scheme") == "internal" {
+		dnsName = "internal-" + dnsName
+	}
+	setDefault(attributes, "DNSName", dnsName)
+	if subnets, ok := attributes["Subnets"].([]any); ok {
+		var zones []any
+		for i, subnet := range subnets {
+			zones = append(zones, map[string]any{
+				"SubnetId": subnet,
+				"ZoneName": f.region + string(rune('a'+i%6)),
+			})
+		}
+		setDefault(attributes, "AvailabilityZones", zones)
+	}
+	for _, key := range []string{"Name", "Subnets", "SubnetMappings", "Tags"} {
+		delete(attributes, key)
+	}
+	return f.loadBalancers.put(str(attributes, "LoadBalancerArn"), "", attributes), nil
+}
+
+// addFixtureLoadBalancer adds a load balancer,
+// its listeners and their rules.
+func (f *Fake) addFixtureLoadBalancer(attributes map[string]any) error {
+	listeners, _ := attributes["Listeners"].([]any)
+	delete(attributes, "Listeners")
+	lb, err := f.addLoadBalancer(attributes)
+	if err != nil {
+		return err
+	}
+	for _, l := range listeners {
+		listenerAttributes, _ := l.(map[string]any)
+		rules, _ := listenerAttributes["Rules"].([]any)
+		delete(listenerAttributes, "Rules")
+		listener, err := f.addListener(lb.arn, normalize(listenerAttributes))
+		if err != nil {
+			return err
+		}
+		for _, r := range rules {
+			ruleAttributes, _ := r.(map[string]any)
+			if _, err = f.addRule(listener.arn, normalize(ruleAttributes)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (f *Fake) DescribeLoadBalancersWithNames(names []string) ([]LoadBalancer, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.call("elbv2 describe-load-balancers", "names", names)
+	result := []LoadBalancer{}
+	var loadBalancers []map[string]any
+	for _, lb := range f.loadBalancers.find(nil) {
+		if len(names) == 0 {
+			loadBalancers = append(loadBalancers, lb.attributes)
+		}
+	}
+	for _, name := range names {
+		found := f.loadBalancers.find(func(item *fakeItem) bool {
+			return str(item.attributes, "LoadBalancerName") == name
+		})
+		if len(found) == 0 {
+			return result, fakeError("LoadBalancerNotFound", "DescribeLoadBalancers", "Load balancers '["+name+"]' not found")
+		}
+		loadBalancers = append(loadBalancers, found[0].attributes)
+	}
+	err := decode(loadBalancers, &result)
+	return result, err
+}
+
+func (f *Fake) CreateLoadBalancer(filepath string) (LoadBalancer, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var result LoadBalancer
+	var attributes map[string]any
+	if err := readInput(filepath, nil, &attributes); err != nil {
+		return result, err
+	}
+	f.call("elbv2 create-load-balancer", "input", attributes)
+	lb, err := f.addLoadBalancer(normalize(attributes))
+	if err != nil {
+		return result, err
+	}
+	err = decode(lb.attributes, &result)
+	return result, err
+}
+
+func (f *Fake) DeleteLoadBalancer(loadBalancerArn string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.call("elbv2 delete-load-balancer", "arn", loadBalancerArn)
+	// deleting a load balancer that does not exist succeeds
+	for _, listener := range f.listeners.children(loadBalancerArn) {
+		f.deleteListener(listener.arn)
+	}
+	f.loadBalancers.delete(loadBalancerArn)
+	return "", nil
+}
+
+func (f *Fake) WaitLoadBalancersDeleted(loadBalancerArn string) error {
+	f.call("elbv2 wait load-balancers-deleted", "arn", loadBalancerArn)
+	return nil
+}
+
+func (f *Fake) DescribeLoadBalancerByName(name string) (map[string]any, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.call("elbv2 describe-load-balancers", "name", name)
+	found := f.loadBalancers.find(func(item *fakeItem) bool {
+		return str(item.attributes, "LoadBalancerName") == name
+	})
+	if len(found) == 0 {
+		return nil, fakeError("LoadBalancerNotFound", "DescribeLoadBalancers", "Load balancers '["+name+"]' not found")
+	}
+	return normalize(found[0].attributes), nil
+}
+
+func (f *Fake) addListener(loadBalancerArn string, attributes map[string]any) (*fakeItem, error) {
+	if _, ok := f.loadBalancers.get(loadBalancerArn); !ok {
+		return nil, fakeError("LoadBalancerNotFound", "CreateListener", "Load balancers '"+loadBalancerArn+"' not found")
+	}
+	port := num(attributes, "Port")
+	for _, listener := range f.listeners.children(loadBalancerArn) {
+		if num(listener.attributes, "Port") == port {
+			return nil, fakeError("DuplicateListener", "CreateListener", "A listener already exists on this port for this load balancer '"+loadBalancerArn+"'")
+		}
+	}
+	if err := f.checkActions("CreateListener", attributes["DefaultActions"]); err != nil {
+		return nil, err
+	}
+	attributes["LoadBalancerArn"] = loadBalancerArn
+	attributes["DefaultActions"] = normalizeActions(attributes["DefaultActions"])
+	delete(attributes, "Tags")
+	_, lbPath, _ := strings.Cut(loadBalancerArn, ":loadbalancer/")
+	setDefault(attributes, "ListenerArn", f.elbv2Arn("listener/"+lbPath+"/"+f.id(16)))
+	listener := f.listeners.put(str(attributes, "ListenerArn"), loadBalancerArn, attributes)
+	// the default rule
+	_, listenerPath, _ := strings.Cut(listener.arn, ":listener/")
+	ruleArn := f.elbv2Arn("listener-rule/" + listenerPath + "/" + f.id(16))
+	f.rules.put(ruleArn, listener.arn, map[string]any{
+		"RuleArn":    ruleArn,
+		"Priority":   "default",
+		"Conditions": []any{},
+		"Actions":    attributes["DefaultActions"],
+		"IsDefault":  true,
+	})
+	return listener, nil
+}
+
+// deleteListener deletes a listener and its rules.
+func (f *Fake) deleteListener(listenerArn string) {
+	for _, rule := range f.rules.children(listenerArn) {
+		f.rules.delete(rule.arn)
+	}
+	f.listeners.delete(listenerArn)
+}
+
+func (f *Fake) DescribeListeners(loadBalancerArn string) ([]Listener, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.call("elbv2 describe-listeners", "loadBalancerArn", loadBalancerArn)
+	result := []Listener{}
+	if _, ok := f.loadBalancers.get(loadBalancerArn); !ok {
+		return result, fakeError("LoadBalancerNotFound", "DescribeListeners", "One or more load balancers not found")
+	}
+	var listeners []map[string]any
+	for _, listener := range f.listeners.children(loadBalancerArn) {
+		listeners = append(listeners, listener.attributes)
+	}
+	err := decode(listeners, &result)
+	return result, err
+}
+
+func (f *Fake) CreateListener(filepath string, loadBalancerArn string, targetGroupArn string) (Listener, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var result Listener
+	var attributes map[string]any
+	if err := readInput(filepath, nil, &attributes); err != nil {
+		return result, err
+	}
+	attributes = normalize(attributes)
+	if loadBalancerArn != "" {
+		attributes["LoadBalancerArn"] = loadBalancerArn
+	}
+	if targetGroupArn != "" {
+		attributes["DefaultActions"] = fakeForward(targetGroupArn)
+	}
+	f.call("elbv2 create-listener", "input", attributes)
+	listener, err := f.addListener(str(attributes, "LoadBalancerArn"), attributes)
+	if err != nil {
+		return result, err
+	}
+	err = decode(listener.attributes, &result)
+	return result, err
+}
+
+func (f *Fake) ModifyListener(listenerArn string, filepath string, targetGroupArn string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var attributes map[string]any
+	if err := readInput(filepath, modifyListenerKeys, &attributes); err != nil {
+		return "", err
+	}
+	attributes = normalize(attributes)
+	if targetGroupArn != "" {
+		attributes["DefaultActions"] = fakeForward(targetGroupArn)
+	}
+	f.call("elbv2 modify-listener", "arn", listenerArn, "input", attributes)
+	listener, ok := f.listeners.get(listenerArn)
+	if !ok {
+		return "", fakeError("ListenerNotFound", "ModifyListener", "One or more listeners not found")
+	}
+	if err := f.checkActions("ModifyListener", attributes["DefaultActions"]); err != nil {
+		return "", err
+	}
+	for k, v := range attributes {
+		listener.attributes[k] = v
+	}
+	if actions, ok := attributes["DefaultActions"]; ok {
+		listener.attributes["DefaultActions"] = normalizeActions(actions)
+		for _, rule := range f.rules.children(listenerArn) {
+			if rule.attributes["IsDefault"] == true {
+				rule.attributes["Actions"] = listener.attributes["DefaultActions"]
+			}
+		}
+	}
+	return "", nil
+}
+
+func (f *Fake) DeleteListener(listenerArn string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.call("elbv2 delete-listener", "arn", listenerArn)
+	if _, ok := f.listeners.get(listenerArn); !ok {
+		return "", fakeError("ListenerNotFound", "DeleteListener", "One or more listeners not found")
+	}
+	f.deleteListener(listenerArn)
+	return "", nil
+}
+
+func (f *Fake) DescribeListenerByArn(listenerArn string) (map[string]any, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.call("elbv2 describe-listeners", "arn", listenerArn)
+	listener, ok := f.listeners.get(listenerArn)
+	if !ok {
+		return nil, fakeError("ListenerNotFound", "DescribeListeners", "One or more listeners not found")
+	}
+	return normalize(listener.attributes), nil
+}
+
+func (f *Fake) DescribeListenersFull(loadBalancerArn string) ([]map[string]any, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.call("elbv2 describe-listeners", "loadBalancerArn", loadBalancerArn)
+	if _, ok := f.loadBalancers.get(loadBalancerArn); !ok {
+		return nil, fakeError("LoadBalancerNotFound", "DescribeListeners", "One or more load balancers not found")
+	}
+	result := []map[string]any{}
+	for _, listener := range f.listeners.children(loadBalancerArn) {
+		result = append(result, normalize(listener.attributes))
+	}
+	return result, nil
+}
+
+func (f *Fake) addRule(listenerArn string, attributes map[string]any) (*fakeItem, error) {
+	if _, ok := f.listeners.get(listenerArn); !ok {
+		return nil, fakeError("ListenerNotFound", "CreateRule", "One or more listeners not found")
+	}
+	priority := num(attributes, "Priority")
+	if priority <= 0 {
+		return nil, fakeError("ValidationError", "CreateRule", "A priority is required")
+	}
+	if f.priorityInUse(listenerArn, priority, "") {
+		return nil, fakeError("PriorityInUse", "CreateRule", fmt.Sprintf("Priority '%d' is currently in use", priority))
+	}
+	if err := f.checkActions("CreateRule", attributes["Actions"]); err != nil {
+		return nil, err
+	}
+	attributes["Priority"] = strconv.Itoa(priority)
+	attributes["Actions"] = normalizeActions(attributes["Actions"])
+	attributes["IsDefault"] = false
+	setDefault(attributes, "Conditions", []any{})
+	delete(attributes, "ListenerArn")
+	delete(attributes, "Tags")
+	_, listenerPath, _ := strings.Cut(listenerArn, ":listener/")
+	setDefault(attributes, "RuleArn", f.elbv2Arn("listener-rule/"+listenerPath+"/"+f.id(16)))
+	return f.rules.put(str(attributes, "RuleArn"), listenerArn, attributes), nil
+}
+
+// priorityInUse returns whether another rule
+// of the listener has the priority.
+func (f *Fake) priorityInUse(listenerArn string, priority int, ruleArn string) bool {
+	for _, rule := range f.rules.children(listenerArn) {
+		if rule.arn != ruleArn && str(rule.attributes, "Priority") == strconv.Itoa(priority) {
+			return true
+		}
+	}
+	return false
+}
+
+// listenerRules returns the rules of a listener
+// by priority (the default one last).
+func (f *Fake) listenerRules(listenerArn string) []map[string]any {
+	rules := f.rules.children(listenerArn)
+	sort.SliceStable(rules, func(i, j int) bool {
+		a, b := str(rules[i].attributes, "Priority"), str(rules[j].attributes, "Priority")
+		if a == "default" || b == "default" {
+			return b == "default" && a != "default"
+		}
+		return num(rules[i].attributes, "Priority") < num(rules[j].attributes, "Priority")
+	})
+	result := []map[string]any{}
+	for _, rule := range rules {
+		result = append(result, normalize(rule.attributes))
+	}
+	return result
+}
+
+func (f *Fake) DescribeRules(listenerArn string) ([]Rule, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.call("elbv2 describe-rules", "listenerArn", listenerArn)
+	result := []Rule{}
+	if _, ok := f.listeners.get(listenerArn); !ok {
+		return result, fakeError("ListenerNotFound", "DescribeRules", "One or more listeners not found")
+	}
+	err := decode(f.listenerRules(listenerArn), &result)
+	return result, err
+}
+
+// createRule creates a rule from its file.
+func (f *Fake) createRule(filepath string, targetGroupArn string, priority int, listenerArn string) (*fakeItem, error) {
+	var attributes map[string]any
+	if err := readInput(filepath, nil, &attributes); err != nil {
+		return nil, err
+	}
+	attributes = normalize(attributes)
+	if targetGroupArn != "" {
+		attributes["Actions"] = fakeForward(targetGroupArn)
+	}
+	if priority > 0 {
+		attributes["Priority"] = float64(priority)
+	}
+	if listenerArn != "" {
+		attributes["ListenerArn"] = listenerArn
+	}
+	f.call("elbv2 create-rule", "input", attributes)
+	return f.addRule(str(attributes, "ListenerArn"), attributes)
+}
+
+func (f *Fake) CreateRule(filepath string, targetGroupArn string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	rule, err := f.createRule(filepath, targetGroupArn, 0, "")
+	if err != nil {
+		return "", err
+	}
+	content, err := json.Marshal(map[string]any{"Rules": []any{rule.attributes}})
+	return string(content), err
+}
+
+func (f *Fake) CreateRule2(filepath string, targetGroupArn string, priority int, listenerArn string) (Rule, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var result Rule
+	rule, err := f.createRule(filepath, targetGroupArn, priority, listenerArn)
+	if err != nil {
+		return result, err
+	}
+	err = decode(rule.attributes, &result)
+	return result, err
+}
+
+func (f *Fake) ModifyRule(ruleArn string, filepath string, targetGroupArn string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var attributes map[string]any
+	if err := readInput(filepath, modifyRuleKeys, &attributes); err != nil {
+		return "", err
+	}
+	attributes = normalize(attributes)
+	if targetGroupArn != "" {
+		attributes["Actions"] = fakeForward(targetGroupArn)
+	}
+	f.call("elbv2 modify-rule", "arn", ruleArn, "input", attributes)
+	rule, ok := f.rules.get(ruleArn)
+	if !ok {
+		return "", fakeError("RuleNotFound", "ModifyRule", "One or more rules not found")
+	}
+	if err := f.checkActions("ModifyRule", attributes["Actions"]); err != nil {
+		return "", err
+	}
+	for k, v := range attributes {
+		rule.attributes[k] = v
+	}
+	if actions, ok := attributes["Actions"]; ok {
+		rule.attributes["Actions"] = normalizeActions(actions)
+	}
+	return "", nil
+}
+
+func (f *Fake) SetRulePriority(ruleArn string, priority int) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.call("elbv2 set-rule-priorities", "arn", ruleArn, "priority", priority)
+	rule, ok := f.rules.get(ruleArn)
+	if !ok {
+		return "", fakeError("RuleNotFound", "SetRulePriorities", "One or more rules not found")
+	}
+	if rule.attributes["IsDefault"] == true {
+		return "", fakeError("OperationNotPermitted", "SetRulePriorities", "Default rule '"+ruleArn+"' cannot have its priority changed")
+	}
+	if f.priorityInUse(rule.parent, priority, ruleArn) {
+		return "", fakeError("PriorityInUse", "SetRulePriorities", fmt.Sprintf("Priority '%d' is currently in use", priority))
+	}
+	rule.attributes["Priority"] = strconv.Itoa(priority)
+	return "", nil
+}
+
+func (f *Fake) DeleteRule(ruleArn string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.call("elbv2 delete-rule", "arn", ruleArn)
+	rule, ok := f.rules.get(ruleArn)
+	if !ok {
+		return "", fakeError("RuleNotFound", "DeleteRule", "One or more rules not found")
+	}
+	if rule.attributes["IsDefault"] == true {
+		return "", fakeError("OperationNotPermitted", "DeleteRule", "Default rule '"+ruleArn+"' cannot be deleted")
+	}
+	f.rules.delete(ruleArn)
+	return "", nil
+}
+
+func (f *Fake) DescribeRuleByArn(ruleArn string) (map[string]any, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.call("elbv2 describe-rules", "arn", ruleArn)
+	rule, ok := f.rules.get(ruleArn)
+	if !ok {
+		return nil, fakeError("RuleNotFound", "DescribeRules", "One or more rules not found")
+	}
+	return normalize(rule.attributes), nil
+}
+
+func (f *Fake) DescribeRulesFull(listenerArn string) ([]map[string]any, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.call("elbv2 describe-rules", "listenerArn", listenerArn)
+	if _, ok := f.listeners.get(listenerArn); !ok {
+		return nil, fakeError("ListenerNotFound", "DescribeRules", "One or more listeners not found")
+	}
+	return f.listenerRules(listenerArn), nil
+}
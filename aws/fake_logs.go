@@ -0,0 +1,74 @@
+package aws
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+func (f *Fake) addLogGroup(attributes map[string]any) (*fakeItem, error) {
+	name := str(attributes, "logGroupName")
+	if name == "" {
+		return nil, fakeError("InvalidParameterException", "CreateLogGroup", "A log group name is required")
+	}
+	if _, ok := f.logGroups.get(name); ok {
+		return nil, fakeError("ResourceAlreadyExistsException", "CreateLogGroup", "The specified log group already exists")
+	}
+	setDefault(attributes, "arn", fmt.Sprintf("arn:aws:logs:%s:%s:log-group:%s:*", f.region, f.account, name))
+	setDefault(attributes, "creationTime", float64(time.Now().UnixMilli()))
+	setDefault(attributes, "metricFilterCount", float64(0))
+	setDefault(attributes, "storedBytes", float64(0))
+	setDefault(attributes, "logGroupClass", "STANDARD")
+	return f.logGroups.put(name, "", attributes), nil
+}
+
+func (f *Fake) DescribeLogGroups(logGroupNamePrefix string) ([]LogGroup, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.call("logs describe-log-groups", "prefix", logGroupNamePrefix)
+	result := []LogGroup{}
+	logGroups := f.logGroups.find(func(item *fakeItem) bool {
+		return strings.HasPrefix(item.arn, logGroupNamePrefix)
+	})
+	sort.Slice(logGroups, func(i, j int) bool {
+		return logGroups[i].arn < logGroups[j].arn
+	})
+	var attributes []map[string]any
+	for _, lg := range logGroups {
+		attributes = append(attributes, lg.attributes)
+	}
+	err := decode(attributes, &result)
+	return result, err
+}
+
+func (f *Fake) CreateLogGroup(logGroupName string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.call("logs create-log-group", "name", logGroupName)
+	_, err := f.addLogGroup(map[string]any{"logGroupName": logGroupName})
+	return "", err
+}
+
+func (f *Fake) PutRetentionPolicy(logGroupName string, retentionInDays int) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.call("logs put-retention-policy", "name", logGroupName, "retentionInDays", retentionInDays)
+	lg, ok := f.logGroups.get(logGroupName)
+	if !ok {
+		return "", fakeError("ResourceNotFoundException", "PutRetentionPolicy", "The specified log group does not exist.")
+	}
+	lg.attributes["retentionInDays"] = float64(retentionInDays)
+	return "", nil
+}
+
+func (f *Fake) DeleteLogGroup(logGroupName string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.call("logs delete-log-group", "name", logGroupName)
+	if _, ok := f.logGroups.get(logGroupName); !ok {
+		return "", fakeError("ResourceNotFoundException", "DeleteLogGroup", "The specified log group does not exist.")
+	}
+	f.logGroups.delete(logGroupName)
+	return "", nil
+}
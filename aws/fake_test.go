@@ -0,0 +1,525 @@
+package aws
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeInput writes a --cli-input-json file.
+func writeInput(t *testing.T, name string, input any) string {
+	t.Helper()
+	content, err := json.Marshal(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), name)
+	if err = os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// fakeTargetGroup creates a target group in f.
+func fakeTargetGroup(t *testing.T, f *Fake, name string) TargetGroup {
+	t.Helper()
+	tg, err := f.CreateTargetGroup(writeInput(t, "targetgroup.json", map[string]any{
+		"Name": name, "Protocol": "HTTP", "Port": 80, "VpcId": "vpc-1",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tg
+}
+
+// fakeListener creates a load balancer, a target group
+// and a listener forwarding to it in f.
+func fakeListener(t *testing.T, f *Fake) (Listener, TargetGroup) {
+	t.Helper()
+	tg := fakeTargetGroup(t, f, "web")
+	lb, err := f.CreateLoadBalancer(writeInput(t, "alb.json", map[string]any{
+		"Name": "alb", "Subnets": []string{"subnet-1", "subnet-2"},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := f.CreateListener(writeInput(t, "listener.json", map[string]any{
+		"Protocol": "HTTP", "Port": 80,
+	}), lb.LoadBalancerArn, tg.TargetGroupArn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return listener, tg
+}
+
+// fakeTaskDefinition registers a revision of the family web.
+func fakeTaskDefinition(t *testing.T, f *Fake, cpu string) TaskDefinition {
+	t.Helper()
+	td, err := f.RegisterTaskDefinition("file://" + writeInput(t, "taskdefinition.json", map[string]any{
+		"family": "web",
+		"cpu":    cpu,
+		"containerDefinitions": []any{
+			map[string]any{"name": "web", "image": "nginx"},
+		},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return td
+}
+
+func TestFakeLifecycle(t *testing.T) {
+	tests := []struct {
+		name string
+		// create returns the arn (or name) of the resource
+		create   func(t *testing.T, f *Fake) string
+		describe func(f *Fake, id string) (map[string]any, error)
+		modify   func(t *testing.T, f *Fake, id string) error
+		// the attribute modify changes
+		field string
+		want  any
+		// remove is nil for what can't be deleted
+		remove func(f *Fake, id string) error
+		// gone fails with ErrNotFound once the resource is removed
+		gone func(f *Fake, id string) error
+	}{
+		{
+			name: "target group",
+			create: func(t *testing.T, f *Fake) string {
+				return fakeTargetGroup(t, f, "web").TargetGroupArn
+			},
+			describe: func(f *Fake, id string) (map[string]any, error) {
+				return f.DescribeTargetGroupByArn(id)
+			},
+			modify: func(t *testing.T, f *Fake, id string) error {
+				_, err := f.ModifyTargetGroup(id, writeInput(t, "targetgroup.json", map[string]any{
+					"Name": "web", "HealthCheckPath": "/health",
+				}))
+				return err
+			},
+			field: "HealthCheckPath",
+			want:  "/health",
+			remove: func(f *Fake, id string) error {
+				_, err := f.DeleteTargetGroup(id)
+				return err
+			},
+			gone: func(f *Fake, id string) error {
+				_, err := f.DeleteTargetGroup(id)
+				return err
+			},
+		},
+		{
+			name: "load balancer",
+			create: func(t *testing.T, f *Fake) string {
+				lb, err := f.CreateLoadBalancer(writeInput(t, "alb.json", map[string]any{
+					"Name": "alb", "Scheme": "internal",
+				}))
+				if err != nil {
+					t.Fatal(err)
+				}
+				return lb.LoadBalancerName
+			},
+			describe: func(f *Fake, id string) (map[string]any, error) {
+				return f.DescribeLoadBalancerByName(id)
+			},
+			field: "Scheme",
+			want:  "internal",
+			remove: func(f *Fake, id string) error {
+				lb, err := f.DescribeLoadBalancerByName(id)
+				if err != nil {
+					return err
+				}
+				_, err = f.DeleteLoadBalancer(lb["LoadBalancerArn"].(string))
+				return err
+			},
+			gone: func(f *Fake, id string) error {
+				_, err := f.DescribeLoadBalancersWithNames([]string{id})
+				return err
+			},
+		},
+		{
+			name: "listener",
+			create: func(t *testing.T, f *Fake) string {
+				listener, _ := fakeListener(t, f)
+				return listener.ListenerArn
+			},
+			describe: func(f *Fake, id string) (map[string]any, error) {
+				return f.DescribeListenerByArn(id)
+			},
+			modify: func(t *testing.T, f *Fake, id string) error {
+				_, err := f.ModifyListener(id, writeInput(t, "listener.json", map[string]any{
+					"Protocol": "HTTP", "Port": 8080,
+				}), "")
+				return err
+			},
+			field: "Port",
+			want:  float64(8080),
+			remove: func(f *Fake, id string) error {
+				_, err := f.DeleteListener(id)
+				return err
+			},
+			gone: func(f *Fake, id string) error {
+				_, err := f.DescribeListenerByArn(id)
+				return err
+			},
+		},
+		{
+			name: "rule",
+			create: func(t *testing.T, f *Fake) string {
+				listener, tg := fakeListener(t, f)
+				rule, err := f.CreateRule2(writeInput(t, "rule.json", map[string]any{
+					"Conditions": []any{
+						map[string]any{"Field": "path-pattern", "Values": []string{"/api/*"}},
+					},
+				}), tg.TargetGroupArn, 10, listener.ListenerArn)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return rule.RuleArn
+			},
+			describe: func(f *Fake, id string) (map[string]any, error) {
+				return f.DescribeRuleByArn(id)
+			},
+			modify: func(t *testing.T, f *Fake, id string) error {
+				_, err := f.SetRulePriority(id, 20)
+				return err
+			},
+			field: "Priority",
+			want:  "20",
+			remove: func(f *Fake, id string) error {
+				_, err := f.DeleteRule(id)
+				return err
+			},
+			gone: func(f *Fake, id string) error {
+				_, err := f.DescribeRuleByArn(id)
+				return err
+			},
+		},
+		{
+			name: "log group",
+			create: func(t *testing.T, f *Fake) string {
+				if _, err := f.CreateLogGroup("/ecs/web"); err != nil {
+					t.Fatal(err)
+				}
+				return "/ecs/web"
+			},
+			describe: func(f *Fake, id string) (map[string]any, error) {
+				logGroups, err := f.DescribeLogGroups(id)
+				if err != nil || len(logGroups) != 1 {
+					return nil, err
+				}
+				var result map[string]any
+				err = decode(logGroups[0], &result)
+				return result, err
+			},
+			modify: func(t *testing.T, f *Fake, id string) error {
+				_, err := f.PutRetentionPolicy(id, 7)
+				return err
+			},
+			field: "retentionInDays",
+			want:  float64(7),
+			remove: func(f *Fake, id string) error {
+				_, err := f.DeleteLogGroup(id)
+				return err
+			},
+			gone: func(f *Fake, id string) error {
+				_, err := f.PutRetentionPolicy(id, 7)
+				return err
+			},
+		},
+		{
+			name: "task definition",
+			create: func(t *testing.T, f *Fake) string {
+				return fakeTaskDefinition(t, f, "256").Family
+			},
+			describe: func(f *Fake, id string) (map[string]any, error) {
+				return f.DescribeTaskDefinitionByArn(id)
+			},
+			// a new revision
+			modify: func(t *testing.T, f *Fake, id string) error {
+				fakeTaskDefinition(t, f, "512")
+				return nil
+			},
+			field: "revision",
+			want:  float64(2),
+		},
+		{
+			name: "service",
+			create: func(t *testing.T, f *Fake) string {
+				fakeTaskDefinition(t, f, "256")
+				service, err := f.CreateService(writeInput(t, "service.json", map[string]any{
+					"cluster": "c1", "serviceName": "web", "taskDefinition": "web",
+				}), ServiceLoadBalancer{}, 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return service.ServiceArn
+			},
+			describe: func(f *Fake, id string) (map[string]any, error) {
+				return f.DescribeServiceByArn("c1", id)
+			},
+			modify: func(t *testing.T, f *Fake, id string) error {
+				_, err := f.UpdateServiceWithFile("c1", id, writeInput(t, "service.json", map[string]any{
+					"serviceName": "web", "taskDefinition": "web", "desiredCount": 2,
+				}), ServiceLoadBalancer{}, 60)
+				return err
+			},
+			field: "desiredCount",
+			want:  float64(2),
+			remove: func(f *Fake, id string) error {
+				if _, err := f.UpdateServiceDesiredCount("c1", id, 0); err != nil {
+					return err
+				}
+				if _, err := f.DeleteService("c1", id); err != nil {
+					return err
+				}
+				return f.WaitServicesInactive("c1", id)
+			},
+			gone: func(f *Fake, id string) error {
+				_, err := f.DeleteService("c1", id)
+				return err
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewFake()
+			id := tt.create(t, f)
+			if id == "" {
+				t.Fatal("created without an arn or a name")
+			}
+
+			described, err := tt.describe(f, id)
+			if err != nil {
+				t.Fatalf("describe: %v", err)
+			}
+			if described == nil {
+				t.Fatal("describe: not found after create")
+			}
+
+			if tt.modify != nil {
+				if err = tt.modify(t, f, id); err != nil {
+					t.Fatalf("modify: %v", err)
+				}
+				if described, err = tt.describe(f, id); err != nil {
+					t.Fatalf("describe: %v", err)
+				}
+			}
+			if got := described[tt.field]; got != tt.want {
+				t.Errorf("%s = %v (%T), want %v (%T)", tt.field, got, got, tt.want, tt.want)
+			}
+
+			if tt.remove == nil {
+				return
+			}
+			if err = tt.remove(f, id); err != nil {
+				t.Fatalf("remove: %v", err)
+			}
+			err = tt.gone(f, id)
+			if !errors.Is(err, ErrNotFound) {
+				t.Errorf("after remove: got %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestFakeErrors(t *testing.T) {
+	const (
+		unknownTargetGroup = "arn:aws:elasticloadbalancing:us-west-2:123456789012:targetgroup/unknown/0123456789abcdef"
+		unknownListener    = "arn:aws:elasticloadbalancing:us-west-2:123456789012:listener/app/alb/0123456789abcdef/0123456789abcdef"
+		unknownRule        = "arn:aws:elasticloadbalancing:us-west-2:123456789012:listener-rule/app/alb/0123456789abcdef/0123456789abcdef/0123456789abcdef"
+	)
+	tests := []struct {
+		name string
+		call func(t *testing.T, f *Fake) error
+		kind error
+		code string
+	}{
+		{
+			name: "describe unknown target group",
+			call: func(t *testing.T, f *Fake) error {
+				_, err := f.DescribeTargetGroupsWithNames([]string{"unknown"})
+				return err
+			},
+			kind: ErrNotFound,
+			code: "TargetGroupNotFound",
+		},
+		{
+			name: "modify unknown target group",
+			call: func(t *testing.T, f *Fake) error {
+				_, err := f.ModifyTargetGroup(unknownTargetGroup, writeInput(t, "targetgroup.json", map[string]any{}))
+				return err
+			},
+			kind: ErrNotFound,
+			code: "TargetGroupNotFound",
+		},
+		{
+			name: "describe unknown load balancer",
+			call: func(t *testing.T, f *Fake) error {
+				_, err := f.DescribeLoadBalancerByName("unknown")
+				return err
+			},
+			kind: ErrNotFound,
+			code: "LoadBalancerNotFound",
+		},
+		{
+			name: "listener of an unknown load balancer",
+			call: func(t *testing.T, f *Fake) error {
+				_, err := f.CreateListener(writeInput(t, "listener.json", map[string]any{"Port": 80}), "arn:unknown", "")
+				return err
+			},
+			kind: ErrNotFound,
+			code: "LoadBalancerNotFound",
+		},
+		{
+			name: "modify unknown listener",
+			call: func(t *testing.T, f *Fake) error {
+				_, err := f.ModifyListener(unknownListener, writeInput(t, "listener.json", map[string]any{}), "")
+				return err
+			},
+			kind: ErrNotFound,
+			code: "ListenerNotFound",
+		},
+		{
+			name: "rules of an unknown listener",
+			call: func(t *testing.T, f *Fake) error {
+				_, err := f.DescribeRules(unknownListener)
+				return err
+			},
+			kind: ErrNotFound,
+			code: "ListenerNotFound",
+		},
+		{
+			name: "modify unknown rule",
+			call: func(t *testing.T, f *Fake) error {
+				_, err := f.ModifyRule(unknownRule, writeInput(t, "rule.json", map[string]any{}), "")
+				return err
+			},
+			kind: ErrNotFound,
+			code: "RuleNotFound",
+		},
+		{
+			name: "delete unknown log group",
+			call: func(t *testing.T, f *Fake) error {
+				_, err := f.DeleteLogGroup("/ecs/unknown")
+				return err
+			},
+			kind: ErrNotFound,
+			code: "ResourceNotFoundException",
+		},
+		{
+			name: "describe unknown task definition",
+			call: func(t *testing.T, f *Fake) error {
+				_, err := f.DescribeTaskDefinition("unknown")
+				return err
+			},
+			kind: ErrNotFound,
+			code: "ClientException",
+		},
+		{
+			name: "update unknown service",
+			call: func(t *testing.T, f *Fake) error {
+				_, err := f.UpdateServiceDesiredCount("c1", "unknown", 1)
+				return err
+			},
+			kind: ErrNotFound,
+			code: "ServiceNotFoundException",
+		},
+		{
+			name: "duplicate target group",
+			call: func(t *testing.T, f *Fake) error {
+				fakeTargetGroup(t, f, "web")
+				_, err := f.CreateTargetGroup(writeInput(t, "targetgroup.json", map[string]any{"Name": "web"}))
+				return err
+			},
+			kind: ErrAlreadyExists,
+			code: "DuplicateTargetGroupName",
+		},
+		{
+			name: "priority in use",
+			call: func(t *testing.T, f *Fake) error {
+				listener, tg := fakeListener(t, f)
+				rule := writeInput(t, "rule.json", map[string]any{"Conditions": []any{}})
+				if _, err := f.CreateRule2(rule, tg.TargetGroupArn, 10, listener.ListenerArn); err != nil {
+					t.Fatal(err)
+				}
+				_, err := f.CreateRule2(rule, tg.TargetGroupArn, 10, listener.ListenerArn)
+				return err
+			},
+			kind: ErrAlreadyExists,
+			code: "PriorityInUse",
+		},
+		{
+			name: "duplicate log group",
+			call: func(t *testing.T, f *Fake) error {
+				if _, err := f.CreateLogGroup("/ecs/web"); err != nil {
+					t.Fatal(err)
+				}
+				_, err := f.CreateLogGroup("/ecs/web")
+				return err
+			},
+			kind: ErrAlreadyExists,
+			code: "ResourceAlreadyExistsException",
+		},
+		{
+			name: "lock already held",
+			call: func(t *testing.T, f *Fake) error {
+				table := LockTable{Name: "locks"}
+				if err := f.PutLockItem(table, "project", "1", "{}"); err != nil {
+					t.Fatal(err)
+				}
+				return f.PutLockItem(table, "project", "2", "{}")
+			},
+			kind: ErrConditionalCheckFailed,
+			code: "ConditionalCheckFailedException",
+		},
+		{
+			name: "lock of another id",
+			call: func(t *testing.T, f *Fake) error {
+				table := LockTable{Name: "locks"}
+				if err := f.PutLockItem(table, "project", "1", "{}"); err != nil {
+					t.Fatal(err)
+				}
+				return f.DeleteLockItem(table, "project", "2")
+			},
+			kind: ErrConditionalCheckFailed,
+			code: "ConditionalCheckFailedException",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call(t, NewFake())
+			if !errors.Is(err, tt.kind) {
+				t.Fatalf("got %v, want %v", err, tt.kind)
+			}
+			var awsErr *Error
+			if !errors.As(err, &awsErr) || awsErr.Code != tt.code {
+				t.Errorf("got %#v, want the code %s", err, tt.code)
+			}
+		})
+	}
+}
+
+func TestFakeLock(t *testing.T) {
+	f := NewFake()
+	table := LockTable{Name: "locks"}
+	if err := f.PutLockItem(table, "project", "1", `{"ID":"1"}`); err != nil {
+		t.Fatal(err)
+	}
+	info, err := f.GetLockItem(table, "project")
+	if err != nil || info != `{"ID":"1"}` {
+		t.Fatalf("got %q, %v", info, err)
+	}
+	if err = f.DeleteLockItem(table, "project", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if info, err = f.GetLockItem(table, "project"); err != nil || info != "" {
+		t.Fatalf("got %q, %v after delete", info, err)
+	}
+	// unlocked: it can be locked again
+	if err = f.PutLockItem(table, "project", "2", "{}"); err != nil {
+		t.Fatal(err)
+	}
+}
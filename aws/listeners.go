@@ -4,14 +4,38 @@ import (
 	"fmt"
 
 	"github.com/charmbracelet/log"
-	"github.com/spf13/viper"
 )
 
 type Listener struct {
-	ListenerArn string `json:"ListenerArn"`
+	ListenerArn     string `json:"ListenerArn"`
+	LoadBalancerArn string `json:"LoadBalancerArn,omitempty"`
+	Port            int    `json:"Port,omitempty"`
+	Protocol        string `json:"Protocol,omitempty"`
+	DefaultActions  []any  `json:"DefaultActions,omitempty"`
 }
 
-func CreateListener(filepath string, loadBalancerArn string, targetGroupArn string) (Listener, error) {
+type describeListenersOutput struct {
+	Listeners []Listener
+}
+
+func (c CLI) DescribeListeners(loadBalancerArn string) ([]Listener, error) {
+	result := []Listener{}
+	var args []string
+	args = append(args, "elbv2", "describe-listeners", "--output", "json", "--no-paginate", "--load-balancer-arn", loadBalancerArn)
+	log.Debug(args)
+
+	var resp describeListenersOutput
+	_, err := execAWS(args, &resp)
+	if err != nil {
+		return result, err
+	}
+
+	result = resp.Listeners
+
+	return result, nil
+}
+
+func (c CLI) CreateListener(filepath string, loadBalancerArn string, targetGroupArn string) (Listener, error) {
 	var args []string
 	args = append(args, "elbv2", "create-listener", "--cli-input-json", fmt.Sprintf("file://%s", filepath), "--output", "json")
 	args = append(args, "--query", "Listeners[0].{ListenerArn: ListenerArn}")
@@ -22,15 +46,70 @@ func CreateListener(filepath string, loadBalancerArn string, targetGroupArn stri
 		args = append(args, "--default-actions", fmt.Sprintf("Type=forward,TargetGroupArn=%s", targetGroupArn))
 	}
 	log.Debug(args)
-	if viper.GetBool("dummy") {
-		sleep(1)
-		return Listener{
-			ListenerArn: "arn:aws:elasticloadbalancing:us-east-1:850631746142:listener/gwy/my-agw-lb-example2/e0f9b3d5c7f7d3d6/afc127db15f925de",
-		}, nil
-	}
 
 	var resp Listener
 	_, err := execAWS(args, &resp)
 
 	return resp, err
 }
+
+// modifyListenerKeys are the keys of a create-listener
+// file accepted by modify-listener.
+var modifyListenerKeys = []string{
+	"Port", "Protocol", "SslPolicy", "Certificates", "DefaultActions", "AlpnPolicy", "MutualAuthentication",
+}
+
+func (c CLI) ModifyListener(listenerArn string, filepath string, targetGroupArn string) (string, error) {
+	inputJson, err := inputJsonWithKeys(filepath, modifyListenerKeys)
+	if err != nil {
+		return "", err
+	}
+	var args []string
+	args = append(args, "elbv2", "modify-listener", "--cli-input-json", inputJson, "--listener-arn", listenerArn)
+	if targetGroupArn != "" {
+		args = append(args, "--default-actions", fmt.Sprintf("Type=forward,TargetGroupArn=%s", targetGroupArn))
+	}
+	log.Debug(args)
+
+	var resp any
+	stdout, err := execAWS(args, &resp)
+
+	return string(stdout), err
+}
+
+func (c CLI) DeleteListener(listenerArn string) (string, error) {
+	var args []string
+	args = append(args, "elbv2", "delete-listener", "--listener-arn", listenerArn)
+	log.Debug(args)
+
+	var resp any
+	stdout, err := execAWS(args, &resp)
+
+	return string(stdout), err
+}
+
+// DescribeListenerByArn returns every attribute
+// of a listener (drift detection).
+func (c CLI) DescribeListenerByArn(listenerArn string) (map[string]any, error) {
+	var result map[string]any
+	var args []string
+	args = append(args, "elbv2", "describe-listeners", "--output", "json", "--listener-arns", listenerArn, "--query", "Listeners[0]")
+	log.Debug(args)
+
+	_, err := execAWS(args, &result)
+
+	return result, err
+}
+
+// DescribeListenersFull returns every attribute
+// of the listeners of a load balancer (import).
+func (c CLI) DescribeListenersFull(loadBalancerArn string) ([]map[string]any, error) {
+	var result []map[string]any
+	var args []string
+	args = append(args, "elbv2", "describe-listeners", "--output", "json", "--no-paginate", "--load-balancer-arn", loadBalancerArn, "--query", "Listeners")
+	log.Debug(args)
+
+	_, err := execAWS(args, &result)
+
+	return result, err
+}
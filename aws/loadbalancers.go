@@ -4,56 +4,78 @@ import (
 	"fmt"
 
 	"github.com/charmbracelet/log"
-	"github.com/spf13/viper"
 )
 
 type LoadBalancer struct {
 	LoadBalancerName string `json:"LoadBalancerName"`
 	Type             string `json:"Type"`
 	LoadBalancerArn  string `json:"LoadBalancerArn"`
+	DNSName          string `json:"DNSName,omitempty"`
+	VpcId            string `json:"VpcId,omitempty"`
+	Scheme           string `json:"Scheme,omitempty"`
 }
 
-func DescribeLoadBalancersWithNames(names []string) ([]LoadBalancer, error) {
+func (c CLI) DescribeLoadBalancersWithNames(names []string) ([]LoadBalancer, error) {
 	result := []LoadBalancer{}
 	var args []string
 	args = append(args, "elbv2", "describe-load-balancers", "--output", "json", "--no-paginate")
-	args = append(args, "--query", "LoadBalancers[*].{LoadBalancerName:LoadBalancerName,Type:Type,LoadBalancerArn:LoadBalancerArn}")
+	args = append(args, "--query", "LoadBalancers[*].{LoadBalancerName:LoadBalancerName,Type:Type,LoadBalancerArn:LoadBalancerArn,DNSName:DNSName,VpcId:VpcId,Scheme:Scheme}")
 	if len(names) > 0 {
 		args = append(args, "--names")
 		args = append(args, names...)
 	}
 	log.Debug(args)
-	if viper.GetBool("dummy") {
-		sleep(2)
-		if len(names) > 0 {
-			name := names[0]
-			arn := "arn:aws:elasticloadbalancing:us-west-2:123456789012:loadbalancer/app/" + name + "/50dc6c495c0c9188"
-			result = append(result, LoadBalancer{LoadBalancerArn: arn, LoadBalancerName: name, Type: "application"})
-		}
-		return result, nil
-	}
 
 	_, err := execAWS(args, &result)
 
 	return result, err
 }
 
-func CreateLoadBalancer(filepath string) (LoadBalancer, error) {
+func (c CLI) CreateLoadBalancer(filepath string) (LoadBalancer, error) {
 	var args []string
 	args = append(args, "elbv2", "create-load-balancer", "--cli-input-json", fmt.Sprintf("file://%s", filepath), "--output", "json")
-	args = append(args, "--query", "LoadBalancers[0].{LoadBalancerName:LoadBalancerName,Type:Type,LoadBalancerArn:LoadBalancerArn}")
+	args = append(args, "--query", "LoadBalancers[0].{LoadBalancerName:LoadBalancerName,Type:Type,LoadBalancerArn:LoadBalancerArn,DNSName:DNSName,VpcId:VpcId,Scheme:Scheme}")
 	log.Debug(args)
-	if viper.GetBool("dummy") {
-		sleep(1)
-		return LoadBalancer{
-			Type:             "application",
-			LoadBalancerArn:  "arn:aws:elasticloadbalancing:us-west-2:123456789012:loadbalancer/app/dummy-load-balancer/50dc6c495c0c9188",
-			LoadBalancerName: "my-load-balancer",
-		}, nil
-	}
 
 	var resp LoadBalancer
 	_, err := execAWS(args, &resp)
 
 	return resp, err
 }
+
+func (c CLI) DeleteLoadBalancer(loadBalancerArn string) (string, error) {
+	var args []string
+	args = append(args, "elbv2", "delete-load-balancer", "--load-balancer-arn", loadBalancerArn)
+	log.Debug(args)
+
+	var resp any
+	stdout, err := execAWS(args, &resp)
+
+	return string(stdout), err
+}
+
+// WaitLoadBalancersDeleted waits until the load balancer is deleted
+// (its target groups can't be deleted before).
+func (c CLI) WaitLoadBalancersDeleted(loadBalancerArn string) error {
+	var args []string
+	args = append(args, "elbv2", "wait", "load-balancers-deleted", "--load-balancer-arns", loadBalancerArn)
+	log.Debug(args)
+
+	var resp any
+	_, err := execAWS(args, &resp)
+
+	return err
+}
+
+// DescribeLoadBalancerByName returns every attribute
+// of a load balancer (import).
+func (c CLI) DescribeLoadBalancerByName(name string) (map[string]any, error) {
+	var result map[string]any
+	var args []string
+	args = append(args, "elbv2", "describe-load-balancers", "--output", "json", "--names", name, "--query", "LoadBalancers[0]")
+	log.Debug(args)
+
+	_, err := execAWS(args, &result)
+
+	return result, err
+}
@@ -2,35 +2,63 @@ package aws
 
 import (
 	"strconv"
-	"strings"
 
 	"github.com/charmbracelet/log"
-	"github.com/spf13/viper"
 )
 
-func CreateLogGroup(logGroupName string) (string, error) {
+type LogGroup struct {
+	LogGroupName    string `json:"logGroupName"`
+	Arn             string `json:"arn"`
+	RetentionInDays int    `json:"retentionInDays,omitempty"`
+}
+
+type describeLogGroupsOutput struct {
+	LogGroups []LogGroup `json:"logGroups"`
+}
+
+func (c CLI) DescribeLogGroups(logGroupNamePrefix string) ([]LogGroup, error) {
+	result := []LogGroup{}
 	var args []string
-	args = append(args, "logs", "create-log-group", "--log-group-name", logGroupName)
+	args = append(args, "logs", "describe-log-groups", "--output", "json", "--no-paginate", "--log-group-name-prefix", logGroupNamePrefix)
 	log.Debug(args)
-	if viper.GetBool("dummy") {
-		sleep(1)
-		return strings.Join(args, " "), nil
+
+	var resp describeLogGroupsOutput
+	_, err := execAWS(args, &resp)
+	if err != nil {
+		return result, err
 	}
 
+	result = resp.LogGroups
+
+	return result, nil
+}
+
+func (c CLI) CreateLogGroup(logGroupName string) (string, error) {
+	var args []string
+	args = append(args, "logs", "create-log-group", "--log-group-name", logGroupName)
+	log.Debug(args)
+
 	var resp any
 	stdout, err := execAWS(args, &resp)
 
 	return string(stdout), err
 }
 
-func PutRetentionPolicy(logGroupName string, retentionInDays int) (string, error) {
+func (c CLI) PutRetentionPolicy(logGroupName string, retentionInDays int) (string, error) {
 	var args []string
 	args = append(args, "logs", "put-retention-policy", "--log-group-name", logGroupName, "--retention-in-days", strconv.Itoa(retentionInDays))
 	log.Debug(args)
-	if viper.GetBool("dummy") {
-		sleep(1)
-		return strings.Join(args, " "), nil
-	}
+
+	var resp any
+	stdout, err := execAWS(args, &resp)
+
+	return string(stdout), err
+}
+
+func (c CLI) DeleteLogGroup(logGroupName string) (string, error) {
+	var args []string
+	args = append(args, "logs", "delete-log-group", "--log-group-name", logGroupName)
+	log.Debug(args)
 
 	var resp any
 	stdout, err := execAWS(args, &resp)
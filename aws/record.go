@@ -0,0 +1,402 @@
+package aws
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/charmbracelet/log"
+)
+
+// Recorder is a Client saving every call of another one in a
+// cassette (--record <dir>): a json file per interaction,
+// numbered in the order the calls end.
+type Recorder struct {
+	mu     sync.Mutex
+	client Client
+	dir    string
+	n      int
+}
+
+// NewRecorder returns a Recorder of client saving
+// into dir (that must not have a cassette yet).
+func NewRecorder(dir string, client Client) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	files, err := interactionFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) > 0 {
+		return nil, fmt.Errorf("%s already has a cassette (%d interactions)", dir, len(files))
+	}
+	return &Recorder{client: client, dir: dir}, nil
+}
+
+// record saves an interaction. A cassette that can't be
+// written is logged, it does not fail the operation.
+func (r *Recorder) record(operation string, in input, output any, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	interaction := Interaction{Operation: operation, Input: in}
+	if output != nil {
+		interaction.Output, _ = json.Marshal(output)
+	}
+	interaction.setErr(err)
+	r.n++
+	file := interactionFile(r.dir, r.n, operation)
+	content, err := json.MarshalIndent(interaction, "", "  ")
+	if err == nil {
+		err = os.WriteFile(file, content, 0644)
+	}
+	if err != nil {
+		log.Warn("record", "interaction", file, "err", err)
+		return
+	}
+	log.Debug("record", "interaction", file)
+}
+
+func (r *Recorder) DescribeTargetGroups() ([]TargetGroup, error) {
+	in := input{}
+	result, err := r.client.DescribeTargetGroups()
+	r.record("DescribeTargetGroups", in, result, err)
+	return result, err
+}
+
+func (r *Recorder) DescribeTargetGroupsWithNames(names []string) ([]TargetGroup, error) {
+	in := input{"names": names}
+	result, err := r.client.DescribeTargetGroupsWithNames(names)
+	r.record("DescribeTargetGroupsWithNames", in, result, err)
+	return result, err
+}
+
+func (r *Recorder) CreateTargetGroup(filepath string) (TargetGroup, error) {
+	in := input{"file": fileInput(filepath)}
+	result, err := r.client.CreateTargetGroup(filepath)
+	r.record("CreateTargetGroup", in, result, err)
+	return result, err
+}
+
+func (r *Recorder) ModifyTargetGroup(targetGroupArn string, filepath string) (string, error) {
+	in := input{"targetGroupArn": targetGroupArn, "file": fileInput(filepath)}
+	result, err := r.client.ModifyTargetGroup(targetGroupArn, filepath)
+	r.record("ModifyTargetGroup", in, result, err)
+	return result, err
+}
+
+func (r *Recorder) DeleteTargetGroup(targetGroupArn string) (string, error) {
+	in := input{"targetGroupArn": targetGroupArn}
+	result, err := r.client.DeleteTargetGroup(targetGroupArn)
+	r.record("DeleteTargetGroup", in, result, err)
+	return result, err
+}
+
+func (r *Recorder) DescribeTargetGroupByArn(targetGroupArn string) (map[string]any, error) {
+	in := input{"targetGroupArn": targetGroupArn}
+	result, err := r.client.DescribeTargetGroupByArn(targetGroupArn)
+	r.record("DescribeTargetGroupByArn", in, result, err)
+	return result, err
+}
+
+func (r *Recorder) DescribeLoadBalancersWithNames(names []string) ([]LoadBalancer, error) {
+	in := input{"names": names}
+	result, err := r.client.DescribeLoadBalancersWithNames(names)
+	r.record("DescribeLoadBalancersWithNames", in, result, err)
+	return result, err
+}
+
+func (r *Recorder) CreateLoadBalancer(filepath string) (LoadBalancer, error) {
+	in := input{"file": fileInput(filepath)}
+	result, err := r.client.CreateLoadBalancer(filepath)
+	r.record("CreateLoadBalancer", in, result, err)
+	return result, err
+}
+
+func (r *Recorder) DeleteLoadBalancer(loadBalancerArn string) (string, error) {
+	in := input{"loadBalancerArn": loadBalancerArn}
+	result, err := r.client.DeleteLoadBalancer(loadBalancerArn)
+	r.record("DeleteLoadBalancer", in, result, err)
+	return result, err
+}
+
+func (r *Recorder) WaitLoadBalancersDeleted(loadBalancerArn string) error {
+	in := input{"loadBalancerArn": loadBalancerArn}
+	err := r.client.WaitLoadBalancersDeleted(loadBalancerArn)
+	r.record("WaitLoadBalancersDeleted", in, nil, err)
+	return err
+}
+
+func (r *Recorder) DescribeLoadBalancerByName(name string) (map[string]any, error) {
+	in := input{"name": name}
+	result, err := r.client.DescribeLoadBalancerByName(name)
+	r.record("DescribeLoadBalancerByName", in, result, err)
+	return result, err
+}
+
+func (r *Recorder) DescribeListeners(loadBalancerArn string) ([]Listener, error) {
+	in := input{"loadBalancerArn": loadBalancerArn}
+	result, err := r.client.DescribeListeners(loadBalancerArn)
+	r.record("DescribeListeners", in, result, err)
+	return result, err
+}
+
+func (r *Recorder) CreateListener(filepath string, loadBalancerArn string, targetGroupArn string) (Listener, error) {
+	in := input{"file": fileInput(filepath), "loadBalancerArn": loadBalancerArn, "targetGroupArn": targetGroupArn}
+	result, err := r.client.CreateListener(filepath, loadBalancerArn, targetGroupArn)
+	r.record("CreateListener", in, result, err)
+	return result, err
+}
+
+func (r *Recorder) ModifyListener(listenerArn string, filepath string, targetGroupArn string) (string, error) {
+	in := input{"listenerArn": listenerArn, "file": fileInput(filepath), "targetGroupArn": targetGroupArn}
+	result, err := r.client.ModifyListener(listenerArn, filepath, targetGroupArn)
+	r.record("ModifyListener", in, result, err)
+	return result, err
+}
+
+func (r *Recorder) DeleteListener(listenerArn string) (string, error) {
+	in := input{"listenerArn": listenerArn}
+	result, err := r.client.DeleteListener(listenerArn)
+	r.record("DeleteListener", in, result, err)
+	return result, err
+}
+
+func (r *Recorder) DescribeListenerByArn(listenerArn string) (map[string]any, error) {
+	in := input{"listenerArn": listenerArn}
+	result, err := r.client.DescribeListenerByArn(listenerArn)
+	r.record("DescribeListenerByArn", in, result, err)
+	return result, err
+}
+
+func (r *Recorder) DescribeListenersFull(loadBalancerArn string) ([]map[string]any, error) {
+	in := input{"loadBalancerArn": loadBalancerArn}
+	result, err := r.client.DescribeListenersFull(loadBalancerArn)
+	r.record("DescribeListenersFull", in, result, err)
+	return result, err
+}
+
+func (r *Recorder) DescribeRules(listenerArn string) ([]Rule, error) {
+	in := input{"listenerArn": listenerArn}
+	result, err := r.client.DescribeRules(listenerArn)
+	r.record("DescribeRules", in, result, err)
+	return result, err
+}
+
+func (r *Recorder) CreateRule(filepath string, targetGroupArn string) (string, error) {
+	in := input{"file": fileInput(filepath), "targetGroupArn": targetGroupArn}
+	result, err := r.client.CreateRule(filepath, targetGroupArn)
+	r.record("CreateRule", in, result, err)
+	return result, err
+}
+
+func (r *Recorder) CreateRule2(filepath string, targetGroupArn string, priority int, listenerArn string) (Rule, error) {
+	in := input{"file": fileInput(filepath), "targetGroupArn": targetGroupArn, "priority": priority, "listenerArn": listenerArn}
+	result, err := r.client.CreateRule2(filepath, targetGroupArn, priority, listenerArn)
+	r.record("CreateRule2", in, result, err)
+	return result, err
+}
+
+func (r *Recorder) ModifyRule(ruleArn string, filepath string, targetGroupArn string) (string, error) {
+	in := input{"ruleArn": ruleArn, "file": fileInput(filepath), "targetGroupArn": targetGroupArn}
+	result, err := r.client.ModifyRule(ruleArn, filepath, targetGroupArn)
+	r.record("ModifyRule", in, result, err)
+	return result, err
+}
+
+func (r *Recorder) SetRulePriority(ruleArn string, priority int) (string, error) {
+	in := input{"ruleArn": ruleArn, "priority": priority}
+	result, err := r.client.SetRulePriority(ruleArn, priority)
+	r.record("SetRulePriority", in, result, err)
+	return result, err
+}
+
+func (r *Recorder) DeleteRule(ruleArn string) (string, error) {
+	in := input{"ruleArn": ruleArn}
+	result, err := r.client.DeleteRule(ruleArn)
+	r.record("DeleteRule", in, result, err)
+	return result, err
+}
+
+func (r *Recorder) DescribeRuleByArn(ruleArn string) (map[string]any, error) {
+	in := input{"ruleArn": ruleArn}
+	result, err := r.client.DescribeRuleByArn(ruleArn)
+	r.record("DescribeRuleByArn", in, result, err)
+	return result, err
+}
+
+func (r *Recorder) DescribeRulesFull(listenerArn string) ([]map[string]any, error) {
+	in := input{"listenerArn": listenerArn}
+	result, err := r.client.DescribeRulesFull(listenerArn)
+	r.record("DescribeRulesFull", in, result, err)
+	return result, err
+}
+
+func (r *Recorder) DescribeLogGroups(logGroupNamePrefix string) ([]LogGroup, error) {
+	in := input{"logGroupNamePrefix": logGroupNamePrefix}
+	result, err := r.client.DescribeLogGroups(logGroupNamePrefix)
+	r.record("DescribeLogGroups", in, result, err)
+	return result, err
+}
+
+func (r *Recorder) CreateLogGroup(logGroupName string) (string, error) {
+	in := input{"logGroupName": logGroupName}
+	result, err := r.client.CreateLogGroup(logGroupName)
+	r.record("CreateLogGroup", in, result, err)
+	return result, err
+}
+
+func (r *Recorder) PutRetentionPolicy(logGroupName string, retentionInDays int) (string, error) {
+	in := input{"logGroupName": logGroupName, "retentionInDays": retentionInDays}
+	result, err := r.client.PutRetentionPolicy(logGroupName, retentionInDays)
+	r.record("PutRetentionPolicy", in, result, err)
+	return result, err
+}
+
+func (r *Recorder) DeleteLogGroup(logGroupName string) (string, error) {
+	in := input{"logGroupName": logGroupName}
+	result, err := r.client.DeleteLogGroup(logGroupName)
+	r.record("DeleteLogGroup", in, result, err)
+	return result, err
+}
+
+func (r *Recorder) ListImages(ecrRepositoryName string) ([]Image, error) {
+	in := input{"ecrRepositoryName": ecrRepositoryName}
+	result, err := r.client.ListImages(ecrRepositoryName)
+	r.record("ListImages", in, result, err)
+	return result, err
+}
+
+func (r *Recorder) CreateService(filepath string, loadBalancer ServiceLoadBalancer, healthCheckGracePeriodSeconds int) (Service, error) {
+	in := input{"file": fileInput(filepath), "loadBalancer": loadBalancer, "healthCheckGracePeriodSeconds": healthCheckGracePeriodSeconds}
+	result, err := r.client.CreateService(filepath, loadBalancer, healthCheckGracePeriodSeconds)
+	r.record("CreateService", in, result, err)
+	return result, err
+}
+
+func (r *Recorder) UpdateServiceWithFile(cluster string, serviceArn string, filepath string, loadBalancer ServiceLoadBalancer, healthCheckGracePeriodSeconds int) (string, error) {
+	in := input{"cluster": cluster, "serviceArn": serviceArn, "file": fileInput(filepath), "loadBalancer": loadBalancer, "healthCheckGracePeriodSeconds": healthCheckGracePeriodSeconds}
+	result, err := r.client.UpdateServiceWithFile(cluster, serviceArn, filepath, loadBalancer, healthCheckGracePeriodSeconds)
+	r.record("UpdateServiceWithFile", in, result, err)
+	return result, err
+}
+
+func (r *Recorder) DescribeService(cluster string, serviceArn string) (Service, error) {
+	in := input{"cluster": cluster, "serviceArn": serviceArn}
+	result, err := r.client.DescribeService(cluster, serviceArn)
+	r.record("DescribeService", in, result, err)
+	return result, err
+}
+
+func (r *Recorder) DescribeServiceStability(cluster string, serviceArn string) (ServiceStability, error) {
+	in := input{"cluster": cluster, "serviceArn": serviceArn}
+	result, err := r.client.DescribeServiceStability(cluster, serviceArn)
+	r.record("DescribeServiceStability", in, result, err)
+	return result, err
+}
+
+func (r *Recorder) DescribeServiceByArn(cluster string, serviceArn string) (map[string]any, error) {
+	in := input{"cluster": cluster, "serviceArn": serviceArn}
+	result, err := r.client.DescribeServiceByArn(cluster, serviceArn)
+	r.record("DescribeServiceByArn", in, result, err)
+	return result, err
+}
+
+func (r *Recorder) DescribeServices(cluster string, serviceArns ...string) ([]Service, error) {
+	in := input{"cluster": cluster, "serviceArns": serviceArns}
+	result, err := r.client.DescribeServices(cluster, serviceArns...)
+	r.record("DescribeServices", in, result, err)
+	return result, err
+}
+
+func (r *Recorder) ListServices(cluster string) ([]string, error) {
+	in := input{"cluster": cluster}
+	result, err := r.client.ListServices(cluster)
+	r.record("ListServices", in, result, err)
+	return result, err
+}
+
+func (r *Recorder) UpdateService(cluster string, serviceArn string, inputJson string) (string, error) {
+	in := input{"cluster": cluster, "serviceArn": serviceArn, "input": jsonInput(inputJson)}
+	result, err := r.client.UpdateService(cluster, serviceArn, inputJson)
+	r.record("UpdateService", in, result, err)
+	return result, err
+}
+
+func (r *Recorder) UpdateServiceDesiredCount(cluster string, serviceArn string, desiredCount int) (string, error) {
+	in := input{"cluster": cluster, "serviceArn": serviceArn, "desiredCount": desiredCount}
+	result, err := r.client.UpdateServiceDesiredCount(cluster, serviceArn, desiredCount)
+	r.record("UpdateServiceDesiredCount", in, result, err)
+	return result, err
+}
+
+func (r *Recorder) DeleteService(cluster string, serviceArn string) (string, error) {
+	in := input{"cluster": cluster, "serviceArn": serviceArn}
+	result, err := r.client.DeleteService(cluster, serviceArn)
+	r.record("DeleteService", in, result, err)
+	return result, err
+}
+
+func (r *Recorder) WaitServicesInactive(cluster string, serviceArn string) error {
+	in := input{"cluster": cluster, "serviceArn": serviceArn}
+	err := r.client.WaitServicesInactive(cluster, serviceArn)
+	r.record("WaitServicesInactive", in, nil, err)
+	return err
+}
+
+func (r *Recorder) DescribeTaskDefinition(taskDefinition string) (TaskDefinition, error) {
+	in := input{"taskDefinition": taskDefinition}
+	result, err := r.client.DescribeTaskDefinition(taskDefinition)
+	r.record("DescribeTaskDefinition", in, result, err)
+	return result, err
+}
+
+func (r *Recorder) RegisterTaskDefinition(inputJson string) (TaskDefinition, error) {
+	in := input{"input": jsonInput(inputJson)}
+	result, err := r.client.RegisterTaskDefinition(inputJson)
+	r.record("RegisterTaskDefinition", in, result, err)
+	return result, err
+}
+
+func (r *Recorder) DescribeTaskDefinitionByArn(taskDefinition string) (map[string]any, error) {
+	in := input{"taskDefinition": taskDefinition}
+	result, err := r.client.DescribeTaskDefinitionByArn(taskDefinition)
+	r.record("DescribeTaskDefinitionByArn", in, result, err)
+	return result, err
+}
+
+func (r *Recorder) ListStoppedTasks(cluster string, serviceName string) ([]string, error) {
+	in := input{"cluster": cluster, "serviceName": serviceName}
+	result, err := r.client.ListStoppedTasks(cluster, serviceName)
+	r.record("ListStoppedTasks", in, result, err)
+	return result, err
+}
+
+func (r *Recorder) DescribeTasks(cluster string, taskArns []string) ([]Task, error) {
+	in := input{"cluster": cluster, "taskArns": taskArns}
+	result, err := r.client.DescribeTasks(cluster, taskArns)
+	r.record("DescribeTasks", in, result, err)
+	return result, err
+}
+
+func (r *Recorder) PutLockItem(table LockTable, key string, id string, info string) error {
+	in := input{"table": table, "key": key, "id": id, "info": info}
+	err := r.client.PutLockItem(table, key, id, info)
+	r.record("PutLockItem", in, nil, err)
+	return err
+}
+
+func (r *Recorder) GetLockItem(table LockTable, key string) (string, error) {
+	in := input{"table": table, "key": key}
+	result, err := r.client.GetLockItem(table, key)
+	r.record("GetLockItem", in, result, err)
+	return result, err
+}
+
+func (r *Recorder) DeleteLockItem(table LockTable, key string, id string) error {
+	in := input{"table": table, "key": key, "id": id}
+	err := r.client.DeleteLockItem(table, key, id)
+	r.record("DeleteLockItem", in, nil, err)
+	return err
+}
@@ -0,0 +1,396 @@
+package aws
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+
+	"github.com/charmbracelet/log"
+)
+
+// Replayer is a Client serving the interactions of a cassette
+// (--replay <dir>) without calling aws.
+//
+// An operation is served the first unused interaction of that
+// operation with the same input or, if there is none, the first
+// unused one of that operation (with a warning): calls made
+// concurrently can end in another order than when recorded.
+type Replayer struct {
+	mu           sync.Mutex
+	dir          string
+	files        []string
+	interactions []Interaction
+	used         []bool
+	// interactions served to another input
+	differs []string
+}
+
+// NewReplayer returns the Replayer of the cassette in dir.
+func NewReplayer(dir string) (*Replayer, error) {
+	files, err := interactionFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%s has no cassette", dir)
+	}
+	r := &Replayer{dir: dir, files: files, used: make([]bool, len(files))}
+	for _, file := range files {
+		var interaction Interaction
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(content, &interaction); err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		interaction.Input = normalize(interaction.Input)
+		r.interactions = append(r.interactions, interaction)
+	}
+	return r, nil
+}
+
+// Check returns an error if the calls were not the ones of the
+// cassette: interactions served to another input or never served.
+func (r *Replayer) Check() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var errs []error
+	for _, file := range r.differs {
+		errs = append(errs, fmt.Errorf("%s: served to another input", file))
+	}
+	for i, used := range r.used {
+		if !used {
+			errs = append(errs, fmt.Errorf("%s: not replayed", filepath.Base(r.files[i])))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// replay serves the interaction of an operation: its output
+// is decoded into output and its error is returned.
+func (r *Replayer) replay(operation string, in input, output any) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	want := normalize(in)
+	index, same := -1, false
+	for i, interaction := range r.interactions {
+		if r.used[i] || interaction.Operation != operation {
+			continue
+		}
+		if reflect.DeepEqual(interaction.Input, want) {
+			index, same = i, true
+			break
+		}
+		if index == -1 {
+			index = i
+		}
+	}
+	if index == -1 {
+		return fmt.Errorf("replay: no %s left in the cassette %s", operation, r.dir)
+	}
+	r.used[index] = true
+	interaction := r.interactions[index]
+	file := filepath.Base(r.files[index])
+	if !same {
+		log.Warn("replay: the input differs from the cassette", "interaction", file, "input", want)
+		r.differs = append(r.differs, file)
+	}
+	log.Debug("replay", "interaction", file)
+	if output != nil && len(interaction.Output) > 0 {
+		if err := json.Unmarshal(interaction.Output, output); err != nil {
+			return fmt.Errorf("replay %s: %v", file, err)
+		}
+	}
+	return interaction.err()
+}
+
+func (r *Replayer) DescribeTargetGroups() ([]TargetGroup, error) {
+	var result []TargetGroup
+	err := r.replay("DescribeTargetGroups", input{}, &result)
+	return result, err
+}
+
+func (r *Replayer) DescribeTargetGroupsWithNames(names []string) ([]TargetGroup, error) {
+	var result []TargetGroup
+	err := r.replay("DescribeTargetGroupsWithNames", input{"names": names}, &result)
+	return result, err
+}
+
+func (r *Replayer) CreateTargetGroup(filepath string) (TargetGroup, error) {
+	var result TargetGroup
+	err := r.replay("CreateTargetGroup", input{"file": fileInput(filepath)}, &result)
+	return result, err
+}
+
+func (r *Replayer) ModifyTargetGroup(targetGroupArn string, filepath string) (string, error) {
+	var result string
+	err := r.replay("ModifyTargetGroup", input{"targetGroupArn": targetGroupArn, "file": fileInput(filepath)}, &result)
+	return result, err
+}
+
+func (r *Replayer) DeleteTargetGroup(targetGroupArn string) (string, error) {
+	var result string
+	err := r.replay("DeleteTargetGroup", input{"targetGroupArn": targetGroupArn}, &result)
+	return result, err
+}
+
+func (r *Replayer) DescribeTargetGroupByArn(targetGroupArn string) (map[string]any, error) {
+	var result map[string]any
+	err := r.replay("DescribeTargetGroupByArn", input{"targetGroupArn": targetGroupArn}, &result)
+	return result, err
+}
+
+func (r *Replayer) DescribeLoadBalancersWithNames(names []string) ([]LoadBalancer, error) {
+	var result []LoadBalancer
+	err := r.replay("DescribeLoadBalancersWithNames", input{"names": names}, &result)
+	return result, err
+}
+
+func (r *Replayer) CreateLoadBalancer(filepath string) (LoadBalancer, error) {
+	var result LoadBalancer
+	err := r.replay("CreateLoadBalancer", input{"file": fileInput(filepath)}, &result)
+	return result, err
+}
+
+func (r *Replayer) DeleteLoadBalancer(loadBalancerArn string) (string, error) {
+	var result string
+	err := r.replay("DeleteLoadBalancer", input{"loadBalancerArn": loadBalancerArn}, &result)
+	return result, err
+}
+
+func (r *Replayer) WaitLoadBalancersDeleted(loadBalancerArn string) error {
+	return r.replay("WaitLoadBalancersDeleted", input{"loadBalancerArn": loadBalancerArn}, nil)
+}
+
+func (r *Replayer) DescribeLoadBalancerByName(name string) (map[string]any, error) {
+	var result map[string]any
+	err := r.replay("DescribeLoadBalancerByName", input{"name": name}, &result)
+	return result, err
+}
+
+func (r *Replayer) DescribeListeners(loadBalancerArn string) ([]Listener, error) {
+	var result []Listener
+	err := r.replay("DescribeListeners", input{"loadBalancerArn": loadBalancerArn}, &result)
+	return result, err
+}
+
+func (r *Replayer) CreateListener(filepath string, loadBalancerArn string, targetGroupArn string) (Listener, error) {
+	var result Listener
+	err := r.replay("CreateListener", input{"file": fileInput(filepath), "loadBalancerArn": loadBalancerArn, "targetGroupArn": targetGroupArn}, &result)
+	return result, err
+}
+
+func (r *Replayer) ModifyListener(listenerArn string, filepath string, targetGroupArn string) (string, error) {
+	var result string
+	err := r.replay("ModifyListener", input{"listenerArn": listenerArn, "file": fileInput(filepath), "targetGroupArn": targetGroupArn}, &result)
+	return result, err
+}
+
+func (r *Replayer) DeleteListener(listenerArn string) (string, error) {
+	var result string
+	err := r.replay("DeleteListener", input{"listenerArn": listenerArn}, &result)
+	return result, err
+}
+
+func (r *Replayer) DescribeListenerByArn(listenerArn string) (map[string]any, error) {
+	var result map[string]any
+	err := r.replay("DescribeListenerByArn", input{"listenerArn": listenerArn}, &result)
+	return result, err
+}
+
+func (r *Replayer) DescribeListenersFull(loadBalancerArn string) ([]map[string]any, error) {
+	var result []map[string]any
+	err := r.replay("DescribeListenersFull", input{"loadBalancerArn": loadBalancerArn}, &result)
+	return result, err
+}
+
+func (r *Replayer) DescribeRules(listenerArn string) ([]Rule, error) {
+	var result []Rule
+	err := r.replay("DescribeRules", input{"listenerArn": listenerArn}, &result)
+	return result, err
+}
+
+func (r *Replayer) CreateRule(filepath string, targetGroupArn string) (string, error) {
+	var result string
+	err := r.replay("CreateRule", input{"file": fileInput(filepath), "targetGroupArn": targetGroupArn}, &result)
+	return result, err
+}
+
+func (r *Replayer) CreateRule2(filepath string, targetGroupArn string, priority int, listenerArn string) (Rule, error) {
+	var result Rule
+	err := r.replay("CreateRule2", input{"file": fileInput(filepath), "targetGroupArn": targetGroupArn, "priority": priority, "listenerArn": listenerArn}, &result)
+	return result, err
+}
+
+func (r *Replayer) ModifyRule(ruleArn string, filepath string, targetGroupArn string) (string, error) {
+	var result string
+	err := r.replay("ModifyRule", input{"ruleArn": ruleArn, "file": fileInput(filepath), "targetGroupArn": targetGroupArn}, &result)
+	return result, err
+}
+
+func (r *Replayer) SetRulePriority(ruleArn string, priority int) (string, error) {
+	var result string
+	err := r.replay("SetRulePriority", input{"ruleArn": ruleArn, "priority": priority}, &result)
+	return result, err
+}
+
+func (r *Replayer) DeleteRule(ruleArn string) (string, error) {
+	var result string
+	err := r.replay("DeleteRule", input{"ruleArn": ruleArn}, &result)
+	return result, err
+}
+
+func (r *Replayer) DescribeRuleByArn(ruleArn string) (map[string]any, error) {
+	var result map[string]any
+	err := r.replay("DescribeRuleByArn", input{"ruleArn": ruleArn}, &result)
+	return result, err
+}
+
+func (r *Replayer) DescribeRulesFull(listenerArn string) ([]map[string]any, error) {
+	var result []map[string]any
+	err := r.replay("DescribeRulesFull", input{"listenerArn": listenerArn}, &result)
+	return result, err
+}
+
+func (r *Replayer) DescribeLogGroups(logGroupNamePrefix string) ([]LogGroup, error) {
+	var result []LogGroup
+	err := r.replay("DescribeLogGroups", input{"logGroupNamePrefix": logGroupNamePrefix}, &result)
+	return result, err
+}
+
+func (r *Replayer) CreateLogGroup(logGroupName string) (string, error) {
+	var result string
+	err := r.replay("CreateLogGroup", input{"logGroupName": logGroupName}, &result)
+	return result, err
+}
+
+func (r *Replayer) PutRetentionPolicy(logGroupName string, retentionInDays int) (string, error) {
+	var result string
+	err := r.replay("PutRetentionPolicy", input{"logGroupName": logGroupName, "retentionInDays": retentionInDays}, &result)
+	return result, err
+}
+
+func (r *Replayer) DeleteLogGroup(logGroupName string) (string, error) {
+	var result string
+	err := r.replay("DeleteLogGroup", input{"logGroupName": logGroupName}, &result)
+	return result, err
+}
+
+func (r *Replayer) ListImages(ecrRepositoryName string) ([]Image, error) {
+	var result []Image
+	err := r.replay("ListImages", input{"ecrRepositoryName": ecrRepositoryName}, &result)
+	return result, err
+}
+
+func (r *Replayer) CreateService(filepath string, loadBalancer ServiceLoadBalancer, healthCheckGracePeriodSeconds int) (Service, error) {
+	var result Service
+	err := r.replay("CreateService", input{"file": fileInput(filepath), "loadBalancer": loadBalancer, "healthCheckGracePeriodSeconds": healthCheckGracePeriodSeconds}, &result)
+	return result, err
+}
+
+func (r *Replayer) UpdateServiceWithFile(cluster string, serviceArn string, filepath string, loadBalancer ServiceLoadBalancer, healthCheckGracePeriodSeconds int) (string, error) {
+	var result string
+	err := r.replay("UpdateServiceWithFile", input{"cluster": cluster, "serviceArn": serviceArn, "file": fileInput(filepath), "loadBalancer": loadBalancer, "healthCheckGracePeriodSeconds": healthCheckGracePeriodSeconds}, &result)
+	return result, err
+}
+
+func (r *Replayer) DescribeService(cluster string, serviceArn string) (Service, error) {
+	var result Service
+	err := r.replay("DescribeService", input{"cluster": cluster, "serviceArn": serviceArn}, &result)
+	return result, err
+}
+
+func (r *Replayer) DescribeServiceStability(cluster string, serviceArn string) (ServiceStability, error) {
+	var result ServiceStability
+	err := r.replay("DescribeServiceStability", input{"cluster": cluster, "serviceArn": serviceArn}, &result)
+	return result, err
+}
+
+func (r *Replayer) DescribeServiceByArn(cluster string, serviceArn string) (map[string]any, error) {
+	var result map[string]any
+	err := r.replay("DescribeServiceByArn", input{"cluster": cluster, "serviceArn": serviceArn}, &result)
+	return result, err
+}
+
+func (r *Replayer) DescribeServices(cluster string, serviceArns ...string) ([]Service, error) {
+	var result []Service
+	err := r.replay("DescribeServices", input{"cluster": cluster, "serviceArns": serviceArns}, &result)
+	return result, err
+}
+
+func (r *Replayer) ListServices(cluster string) ([]string, error) {
+	var result []string
+	err := r.replay("ListServices", input{"cluster": cluster}, &result)
+	return result, err
+}
+
+func (r *Replayer) UpdateService(cluster string, serviceArn string, inputJson string) (string, error) {
+	var result string
+	err := r.replay("UpdateService", input{"cluster": cluster, "serviceArn": serviceArn, "input": jsonInput(inputJson)}, &result)
+	return result, err
+}
+
+func (r *Replayer) UpdateServiceDesiredCount(cluster string, serviceArn string, desiredCount int) (string, error) {
+	var result string
+	err := r.replay("UpdateServiceDesiredCount", input{"cluster": cluster, "serviceArn": serviceArn, "desiredCount": desiredCount}, &result)
+	return result, err
+}
+
+func (r *Replayer) DeleteService(cluster string, serviceArn string) (string, error) {
+	var result string
+	err := r.replay("DeleteService", input{"cluster": cluster, "serviceArn": serviceArn}, &result)
+	return result, err
+}
+
+func (r *Replayer) WaitServicesInactive(cluster string, serviceArn string) error {
+	return r.replay("WaitServicesInactive", input{"cluster": cluster, "serviceArn": serviceArn}, nil)
+}
+
+func (r *Replayer) DescribeTaskDefinition(taskDefinition string) (TaskDefinition, error) {
+	var result TaskDefinition
+	err := r.replay("DescribeTaskDefinition", input{"taskDefinition": taskDefinition}, &result)
+	return result, err
+}
+
+func (r *Replayer) RegisterTaskDefinition(inputJson string) (TaskDefinition, error) {
+	var result TaskDefinition
+	err := r.replay("RegisterTaskDefinition", input{"input": jsonInput(inputJson)}, &result)
+	return result, err
+}
+
+func (r *Replayer) DescribeTaskDefinitionByArn(taskDefinition string) (map[string]any, error) {
+	var result map[string]any
+	err := r.replay("DescribeTaskDefinitionByArn", input{"taskDefinition": taskDefinition}, &result)
+	return result, err
+}
+
+func (r *Replayer) ListStoppedTasks(cluster string, serviceName string) ([]string, error) {
+	var result []string
+	err := r.replay("ListStoppedTasks", input{"cluster": cluster, "serviceName": serviceName}, &result)
+	return result, err
+}
+
+func (r *Replayer) DescribeTasks(cluster string, taskArns []string) ([]Task, error) {
+	var result []Task
+	err := r.replay("DescribeTasks", input{"cluster": cluster, "taskArns": taskArns}, &result)
+	return result, err
+}
+
+func (r *Replayer) PutLockItem(table LockTable, key string, id string, info string) error {
+	return r.replay("PutLockItem", input{"table": table, "key": key, "id": id, "info": info}, nil)
+}
+
+func (r *Replayer) GetLockItem(table LockTable, key string) (string, error) {
+	var result string
+	err := r.replay("GetLockItem", input{"table": table, "key": key}, &result)
+	return result, err
+}
+
+func (r *Replayer) DeleteLockItem(table LockTable, key string, id string) error {
+	return r.replay("DeleteLockItem", input{"table": table, "key": key, "id": id}, nil)
+}
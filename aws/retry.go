@@ -0,0 +1,512 @@
+package aws
+
+import (
+	"errors"
+	"math/rand/v2"
+	"strconv"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/spf13/viper"
+)
+
+// retryBaseDelay is the delay before the first retry
+// (it doubles at every attempt, up to the max delay).
+const retryBaseDelay = 500 * time.Millisecond
+
+// RetryPolicy is how the aws calls failing with a retryable error
+// (ErrThrottling, ErrUnavailable) are retried: MaxAttempts calls at most,
+// with an exponential backoff and jitter capped at MaxDelay between them.
+type RetryPolicy struct {
+	MaxAttempts int
+	MaxDelay    time.Duration
+}
+
+// retryPolicy returns the policy of --max-attempts and --max-retry-delay
+// (or of the retry section of the project).
+func retryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: viper.GetInt("max-attempts"),
+		MaxDelay:    viper.GetDuration("max-retry-delay"),
+	}
+}
+
+// delay returns how long to wait after a failed attempt:
+// half of the backoff plus a random part of the other half.
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	backoff := retryBaseDelay << min(attempt-1, 16)
+	if p.MaxDelay > 0 && backoff > p.MaxDelay {
+		backoff = p.MaxDelay
+	}
+	if backoff < 2 {
+		return backoff
+	}
+	return backoff/2 + rand.N(backoff/2)
+}
+
+// Retrier is a Client retrying the calls of another one with
+// the RetryPolicy of the config. Throttled calls are always retried
+// (aws rejected them). Calls failing with ErrUnavailable may have been
+// done anyway: creates are only retried when a check shows they were
+// not (and the ones that can't be checked are not retried).
+type Retrier struct {
+	client Client
+	// no wait between the attempts (replayed calls)
+	noDelay bool
+}
+
+func NewRetrier(client Client) *Retrier {
+	return &Retrier{client: client}
+}
+
+// safeToRetry is the check of the operations that can be called
+// again whatever the failed call did (reads, modifies, deletes, ...):
+// it never reports the call as done.
+func safeToRetry() (bool, error) {
+	return false, nil
+}
+
+// do calls an operation until it succeeds, fails with an error that is
+// not retryable or max attempts is reached. Before retrying a call that
+// failed with ErrUnavailable, done tells if it had its effect anyway
+// (nil if it can't tell: the error is returned).
+func (r *Retrier) do(operation string, call func() error, done func() (bool, error)) error {
+	policy := retryPolicy()
+	for attempt := 1; ; attempt++ {
+		err := call()
+		if err == nil || attempt >= policy.MaxAttempts || !(errors.Is(err, ErrThrottling) || errors.Is(err, ErrUnavailable)) {
+			return err
+		}
+		if errors.Is(err, ErrUnavailable) {
+			if done == nil {
+				return err
+			}
+			ok, checkErr := done()
+			if checkErr != nil {
+				return err
+			}
+			if ok {
+				log.Debug("retry: done despite the error", "operation", operation, "attempt", attempt, "err", err)
+				return nil
+			}
+		}
+		delay := policy.delay(attempt)
+		log.Debug("retry", "operation", operation, "attempt", attempt, "maxAttempts", policy.MaxAttempts, "delay", delay, "err", err)
+		if !r.noDelay {
+			time.Sleep(delay)
+		}
+	}
+}
+
+// retry is do for an operation that is safe to retry, returning a result.
+func retry[T any](r *Retrier, operation string, call func() (T, error)) (T, error) {
+	var result T
+	err := r.do(operation, func() (err error) {
+		result, err = call()
+		return err
+	}, safeToRetry)
+	return result, err
+}
+
+// retryCreate is do for an operation creating something:
+// find returns it if a failed call created it anyway.
+func retryCreate[T any](r *Retrier, operation string, call func() (T, error), find func() (T, bool, error)) (T, error) {
+	var result T
+	var done func() (bool, error)
+	if find != nil {
+		done = func() (found bool, err error) {
+			result, found, err = find()
+			return found, err
+		}
+	}
+	err := r.do(operation, func() (err error) {
+		result, err = call()
+		return err
+	}, done)
+	return result, err
+}
+
+// fileFields returns the fields of a --cli-input-json file.
+func fileFields(filepath string) map[string]any {
+	fields, _ := fileInput(filepath).(map[string]any)
+	return fields
+}
+
+// findRule returns the rule of a listener with a priority
+// (found is false if there is none).
+func (r *Retrier) findRule(listenerArn string, priority int) (Rule, bool, error) {
+	rules, err := r.client.DescribeRules(listenerArn)
+	if err != nil {
+		return Rule{}, false, err
+	}
+	for _, rule := range rules {
+		if rule.Priority == strconv.Itoa(priority) {
+			return rule, true, nil
+		}
+	}
+	return Rule{}, false, nil
+}
+
+func (r *Retrier) DescribeTargetGroups() ([]TargetGroup, error) {
+	return retry(r, "DescribeTargetGroups", func() ([]TargetGroup, error) {
+		return r.client.DescribeTargetGroups()
+	})
+}
+
+func (r *Retrier) DescribeTargetGroupsWithNames(names []string) ([]TargetGroup, error) {
+	return retry(r, "DescribeTargetGroupsWithNames", func() ([]TargetGroup, error) {
+		return r.client.DescribeTargetGroupsWithNames(names)
+	})
+}
+
+func (r *Retrier) CreateTargetGroup(filepath string) (TargetGroup, error) {
+	return retryCreate(r, "CreateTargetGroup", func() (TargetGroup, error) {
+		return r.client.CreateTargetGroup(filepath)
+	}, func() (TargetGroup, bool, error) {
+		results, err := r.client.DescribeTargetGroupsWithNames([]string{str(fileFields(filepath), "Name")})
+		if errors.Is(err, ErrNotFound) {
+			return TargetGroup{}, false, nil
+		}
+		if err != nil || len(results) == 0 {
+			return TargetGroup{}, false, err
+		}
+		return results[0], true, nil
+	})
+}
+
+func (r *Retrier) ModifyTargetGroup(targetGroupArn string, filepath string) (string, error) {
+	return retry(r, "ModifyTargetGroup", func() (string, error) {
+		return r.client.ModifyTargetGroup(targetGroupArn, filepath)
+	})
+}
+
+func (r *Retrier) DeleteTargetGroup(targetGroupArn string) (string, error) {
+	return retry(r, "DeleteTargetGroup", func() (string, error) {
+		return r.client.DeleteTargetGroup(targetGroupArn)
+	})
+}
+
+func (r *Retrier) DescribeTargetGroupByArn(targetGroupArn string) (map[string]any, error) {
+	return retry(r, "DescribeTargetGroupByArn", func() (map[string]any, error) {
+		return r.client.DescribeTargetGroupByArn(targetGroupArn)
+	})
+}
+
+func (r *Retrier) DescribeLoadBalancersWithNames(names []string) ([]LoadBalancer, error) {
+	return retry(r, "DescribeLoadBalancersWithNames", func() ([]LoadBalancer, error) {
+		return r.client.DescribeLoadBalancersWithNames(names)
+	})
+}
+
+func (r *Retrier) CreateLoadBalancer(filepath string) (LoadBalancer, error) {
+	return retryCreate(r, "CreateLoadBalancer", func() (LoadBalancer, error) {
+		return r.client.CreateLoadBalancer(filepath)
+	}, func() (LoadBalancer, bool, error) {
+		results, err := r.client.DescribeLoadBalancersWithNames([]string{str(fileFields(filepath), "Name")})
+		if errors.Is(err, ErrNotFound) {
+			return LoadBalancer{}, false, nil
+		}
+		if err != nil || len(results) == 0 {
+			return LoadBalancer{}, false, err
+		}
+		return results[0], true, nil
+	})
+}
+
+func (r *Retrier) DeleteLoadBalancer(loadBalancerArn string) (string, error) {
+	return retry(r, "DeleteLoadBalancer", func() (string, error) {
+		return r.client.DeleteLoadBalancer(loadBalancerArn)
+	})
+}
+
+func (r *Retrier) WaitLoadBalancersDeleted(loadBalancerArn string) error {
+	return r.do("WaitLoadBalancersDeleted", func() error {
+		return r.client.WaitLoadBalancersDeleted(loadBalancerArn)
+	}, safeToRetry)
+}
+
+func (r *Retrier) DescribeLoadBalancerByName(name string) (map[string]any, error) {
+	return retry(r, "DescribeLoadBalancerByName", func() (map[string]any, error) {
+		return r.client.DescribeLoadBalancerByName(name)
+	})
+}
+
+func (r *Retrier) DescribeListeners(loadBalancerArn string) ([]Listener, error) {
+	return retry(r, "DescribeListeners", func() ([]Listener, error) {
+		return r.client.DescribeListeners(loadBalancerArn)
+	})
+}
+
+func (r *Retrier) CreateListener(filepath string, loadBalancerArn string, targetGroupArn string) (Listener, error) {
+	return retryCreate(r, "CreateListener", func() (Listener, error) {
+		return r.client.CreateListener(filepath, loadBalancerArn, targetGroupArn)
+	}, func() (Listener, bool, error) {
+		listeners, err := r.client.DescribeListeners(loadBalancerArn)
+		if err != nil {
+			return Listener{}, false, err
+		}
+		port := num(fileFields(filepath), "Port")
+		for _, l := range listeners {
+			if l.Port == port {
+				return l, true, nil
+			}
+		}
+		return Listener{}, false, nil
+	})
+}
+
+func (r *Retrier) ModifyListener(listenerArn string, filepath string, targetGroupArn string) (string, error) {
+	return retry(r, "ModifyListener", func() (string, error) {
+		return r.client.ModifyListener(listenerArn, filepath, targetGroupArn)
+	})
+}
+
+func (r *Retrier) DeleteListener(listenerArn string) (string, error) {
+	return retry(r, "DeleteListener", func() (string, error) {
+		return r.client.DeleteListener(listenerArn)
+	})
+}
+
+func (r *Retrier) DescribeListenerByArn(listenerArn string) (map[string]any, error) {
+	return retry(r, "DescribeListenerByArn", func() (map[string]any, error) {
+		return r.client.DescribeListenerByArn(listenerArn)
+	})
+}
+
+func (r *Retrier) DescribeListenersFull(loadBalancerArn string) ([]map[string]any, error) {
+	return retry(r, "DescribeListenersFull", func() ([]map[string]any, error) {
+		return r.client.DescribeListenersFull(loadBalancerArn)
+	})
+}
+
+func (r *Retrier) DescribeRules(listenerArn string) ([]Rule, error) {
+	return retry(r, "DescribeRules", func() ([]Rule, error) {
+		return r.client.DescribeRules(listenerArn)
+	})
+}
+
+func (r *Retrier) CreateRule(filepath string, targetGroupArn string) (string, error) {
+	return retryCreate(r, "CreateRule", func() (string, error) {
+		return r.client.CreateRule(filepath, targetGroupArn)
+	}, func() (string, bool, error) {
+		fields := fileFields(filepath)
+		rule, found, err := r.findRule(str(fields, "ListenerArn"), num(fields, "Priority"))
+		return rule.RuleArn, found, err
+	})
+}
+
+func (r *Retrier) CreateRule2(filepath string, targetGroupArn string, priority int, listenerArn string) (Rule, error) {
+	return retryCreate(r, "CreateRule2", func() (Rule, error) {
+		return r.client.CreateRule2(filepath, targetGroupArn, priority, listenerArn)
+	}, func() (Rule, bool, error) {
+		fields := fileFields(filepath)
+		if priority <= 0 {
+			priority = num(fields, "Priority")
+		}
+		if listenerArn == "" {
+			listenerArn = str(fields, "ListenerArn")
+		}
+		return r.findRule(listenerArn, priority)
+	})
+}
+
+func (r *Retrier) ModifyRule(ruleArn string, filepath string, targetGroupArn string) (string, error) {
+	return retry(r, "ModifyRule", func() (string, error) {
+		return r.client.ModifyRule(ruleArn, filepath, targetGroupArn)
+	})
+}
+
+func (r *Retrier) SetRulePriority(ruleArn string, priority int) (string, error) {
+	return retry(r, "SetRulePriority", func() (string, error) {
+		return r.client.SetRulePriority(ruleArn, priority)
+	})
+}
+
+func (r *Retrier) DeleteRule(ruleArn string) (string, error) {
+	return retry(r, "DeleteRule", func() (string, error) {
+		return r.client.DeleteRule(ruleArn)
+	})
+}
+
+func (r *Retrier) DescribeRuleByArn(ruleArn string) (map[string]any, error) {
+	return retry(r, "DescribeRuleByArn", func() (map[string]any, error) {
+		return r.client.DescribeRuleByArn(ruleArn)
+	})
+}
+
+func (r *Retrier) DescribeRulesFull(listenerArn string) ([]map[string]any, error) {
+	return retry(r, "DescribeRulesFull", func() ([]map[string]any, error) {
+		return r.client.DescribeRulesFull(listenerArn)
+	})
+}
+
+func (r *Retrier) DescribeLogGroups(logGroupNamePrefix string) ([]LogGroup, error) {
+	return retry(r, "DescribeLogGroups", func() ([]LogGroup, error) {
+		return r.client.DescribeLogGroups(logGroupNamePrefix)
+	})
+}
+
+func (r *Retrier) CreateLogGroup(logGroupName string) (string, error) {
+	return retryCreate(r, "CreateLogGroup", func() (string, error) {
+		return r.client.CreateLogGroup(logGroupName)
+	}, func() (string, bool, error) {
+		results, err := r.client.DescribeLogGroups(logGroupName)
+		for _, lg := range results {
+			if lg.LogGroupName == logGroupName {
+				return "", true, nil
+			}
+		}
+		return "", false, err
+	})
+}
+
+func (r *Retrier) PutRetentionPolicy(logGroupName string, retentionInDays int) (string, error) {
+	return retry(r, "PutRetentionPolicy", func() (string, error) {
+		return r.client.PutRetentionPolicy(logGroupName, retentionInDays)
+	})
+}
+
+func (r *Retrier) DeleteLogGroup(logGroupName string) (string, error) {
+	return retry(r, "DeleteLogGroup", func() (string, error) {
+		return r.client.DeleteLogGroup(logGroupName)
+	})
+}
+
+func (r *Retrier) ListImages(ecrRepositoryName string) ([]Image, error) {
+	return retry(r, "ListImages", func() ([]Image, error) {
+		return r.client.ListImages(ecrRepositoryName)
+	})
+}
+
+func (r *Retrier) CreateService(filepath string, loadBalancer ServiceLoadBalancer, healthCheckGracePeriodSeconds int) (Service, error) {
+	return retryCreate(r, "CreateService", func() (Service, error) {
+		return r.client.CreateService(filepath, loadBalancer, healthCheckGracePeriodSeconds)
+	}, func() (Service, bool, error) {
+		fields := fileFields(filepath)
+		serviceName := str(fields, "serviceName")
+		services, err := r.client.DescribeServices(str(fields, "cluster"), serviceName)
+		for _, s := range services {
+			if s.ServiceName == serviceName && s.Status == "ACTIVE" {
+				return s, true, nil
+			}
+		}
+		return Service{}, false, err
+	})
+}
+
+func (r *Retrier) UpdateServiceWithFile(cluster string, serviceArn string, filepath string, loadBalancer ServiceLoadBalancer, healthCheckGracePeriodSeconds int) (string, error) {
+	return retry(r, "UpdateServiceWithFile", func() (string, error) {
+		return r.client.UpdateServiceWithFile(cluster, serviceArn, filepath, loadBalancer, healthCheckGracePeriodSeconds)
+	})
+}
+
+func (r *Retrier) DescribeService(cluster string, serviceArn string) (Service, error) {
+	return retry(r, "DescribeService", func() (Service, error) {
+		return r.client.DescribeService(cluster, serviceArn)
+	})
+}
+
+func (r *Retrier) DescribeServiceStability(cluster string, serviceArn string) (ServiceStability, error) {
+	return retry(r, "DescribeServiceStability", func() (ServiceStability, error) {
+		return r.client.DescribeServiceStability(cluster, serviceArn)
+	})
+}
+
+func (r *Retrier) DescribeServiceByArn(cluster string, serviceArn string) (map[string]any, error) {
+	return retry(r, "DescribeServiceByArn", func() (map[string]any, error) {
+		return r.client.DescribeServiceByArn(cluster, serviceArn)
+	})
+}
+
+func (r *Retrier) DescribeServices(cluster string, serviceArns ...string) ([]Service, error) {
+	return retry(r, "DescribeServices", func() ([]Service, error) {
+		return r.client.DescribeServices(cluster, serviceArns...)
+	})
+}
+
+func (r *Retrier) ListServices(cluster string) ([]string, error) {
+	return retry(r, "ListServices", func() ([]string, error) {
+		return r.client.ListServices(cluster)
+	})
+}
+
+func (r *Retrier) UpdateService(cluster string, serviceArn string, inputJson string) (string, error) {
+	return retry(r, "UpdateService", func() (string, error) {
+		return r.client.UpdateService(cluster, serviceArn, inputJson)
+	})
+}
+
+func (r *Retrier) UpdateServiceDesiredCount(cluster string, serviceArn string, desiredCount int) (string, error) {
+	return retry(r, "UpdateServiceDesiredCount", func() (string, error) {
+		return r.client.UpdateServiceDesiredCount(cluster, serviceArn, desiredCount)
+	})
+}
+
+func (r *Retrier) DeleteService(cluster string, serviceArn string) (string, error) {
+	return retry(r, "DeleteService", func() (string, error) {
+		return r.client.DeleteService(cluster, serviceArn)
+	})
+}
+
+func (r *Retrier) WaitServicesInactive(cluster string, serviceArn string) error {
+	return r.do("WaitServicesInactive", func() error {
+		return r.client.WaitServicesInactive(cluster, serviceArn)
+	}, safeToRetry)
+}
+
+func (r *Retrier) DescribeTaskDefinition(taskDefinition string) (TaskDefinition, error) {
+	return retry(r, "DescribeTaskDefinition", func() (TaskDefinition, error) {
+		return r.client.DescribeTaskDefinition(taskDefinition)
+	})
+}
+
+// RegisterTaskDefinition is only retried when it was throttled:
+// a call that failed may have registered a revision, there is
+// no telling it from one registered by another run.
+func (r *Retrier) RegisterTaskDefinition(inputJson string) (TaskDefinition, error) {
+	return retryCreate(r, "RegisterTaskDefinition", func() (TaskDefinition, error) {
+		return r.client.RegisterTaskDefinition(inputJson)
+	}, nil)
+}
+
+func (r *Retrier) DescribeTaskDefinitionByArn(taskDefinition string) (map[string]any, error) {
+	return retry(r, "DescribeTaskDefinitionByArn", func() (map[string]any, error) {
+		return r.client.DescribeTaskDefinitionByArn(taskDefinition)
+	})
+}
+
+func (r *Retrier) ListStoppedTasks(cluster string, serviceName string) ([]string, error) {
+	return retry(r, "ListStoppedTasks", func() ([]string, error) {
+		return r.client.ListStoppedTasks(cluster, serviceName)
+	})
+}
+
+func (r *Retrier) DescribeTasks(cluster string, taskArns []string) ([]Task, error) {
+	return retry(r, "DescribeTasks", func() ([]Task, error) {
+		return r.client.DescribeTasks(cluster, taskArns)
+	})
+}
+
+func (r *Retrier) PutLockItem(table LockTable, key string, id string, info string) error {
+	return r.do("PutLockItem", func() error {
+		return r.client.PutLockItem(table, key, id, info)
+	}, func() (bool, error) {
+		got, err := r.client.GetLockItem(table, key)
+		return err == nil && got == info, err
+	})
+}
+
+func (r *Retrier) GetLockItem(table LockTable, key string) (string, error) {
+	return retry(r, "GetLockItem", func() (string, error) {
+		return r.client.GetLockItem(table, key)
+	})
+}
+
+func (r *Retrier) DeleteLockItem(table LockTable, key string, id string) error {
+	return r.do("DeleteLockItem", func() error {
+		return r.client.DeleteLockItem(table, key, id)
+	}, func() (bool, error) {
+		got, err := r.client.GetLockItem(table, key)
+		return err == nil && got == "", err
+	})
+}
@@ -0,0 +1,164 @@
+package aws
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// flaky is a Fake whose calls fail with the errors of failures
+// (by operation, in order) before they succeed.
+type flaky struct {
+	*Fake
+	failures map[string][]error
+	calls    map[string]int
+}
+
+func newFlaky(failures map[string][]error) *flaky {
+	return &flaky{Fake: NewFake(), failures: failures, calls: map[string]int{}}
+}
+
+// fail returns the next error of operation (nil if there is none).
+func (f *flaky) fail(operation string) error {
+	f.calls[operation]++
+	if len(f.failures[operation]) == 0 {
+		return nil
+	}
+	err := f.failures[operation][0]
+	f.failures[operation] = f.failures[operation][1:]
+	return err
+}
+
+func (f *flaky) DescribeTargetGroups() ([]TargetGroup, error) {
+	if err := f.fail("DescribeTargetGroups"); err != nil {
+		return nil, err
+	}
+	return f.Fake.DescribeTargetGroups()
+}
+
+// CreateLogGroup creates the log group before failing:
+// the error of a call that was done anyway.
+func (f *flaky) CreateLogGroup(logGroupName string) (string, error) {
+	result, err := f.Fake.CreateLogGroup(logGroupName)
+	if failErr := f.fail("CreateLogGroup"); failErr != nil {
+		return "", failErr
+	}
+	return result, err
+}
+
+// RegisterTaskDefinition fails before registering the revision.
+func (f *flaky) RegisterTaskDefinition(inputJson string) (TaskDefinition, error) {
+	if err := f.fail("RegisterTaskDefinition"); err != nil {
+		return TaskDefinition{}, err
+	}
+	return f.Fake.RegisterTaskDefinition(inputJson)
+}
+
+func setRetryPolicy(t *testing.T, maxAttempts int) {
+	t.Helper()
+	viper.Set("max-attempts", maxAttempts)
+	viper.Set("max-retry-delay", time.Second)
+	t.Cleanup(viper.Reset)
+}
+
+func TestRetrierThrottling(t *testing.T) {
+	setRetryPolicy(t, 3)
+	throttled := fakeError("ThrottlingException", "DescribeTargetGroups", "Rate exceeded")
+
+	tests := []struct {
+		name      string
+		failures  int
+		wantCalls int
+		wantErr   error
+	}{
+		{name: "succeeds after a retry", failures: 1, wantCalls: 2},
+		{name: "succeeds at the last attempt", failures: 2, wantCalls: 3},
+		{name: "fails after max attempts", failures: 3, wantCalls: 3, wantErr: ErrThrottling},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var failures []error
+			for range tt.failures {
+				failures = append(failures, throttled)
+			}
+			client := newFlaky(map[string][]error{"DescribeTargetGroups": failures})
+			r := &Retrier{client: client, noDelay: true}
+
+			_, err := r.DescribeTargetGroups()
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Errorf("got %v, want %v", err, tt.wantErr)
+			}
+			if got := client.calls["DescribeTargetGroups"]; got != tt.wantCalls {
+				t.Errorf("%d calls, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetrierUnavailable(t *testing.T) {
+	setRetryPolicy(t, 3)
+	unavailable := fakeError("ServiceUnavailableException", "CreateLogGroup", "Service unavailable")
+
+	// the create was done anyway: found, not created again
+	client := newFlaky(map[string][]error{"CreateLogGroup": {unavailable}})
+	r := &Retrier{client: client, noDelay: true}
+	if _, err := r.CreateLogGroup("/ecs/web"); err != nil {
+		t.Fatalf("create log group: %v", err)
+	}
+	if got := client.calls["CreateLogGroup"]; got != 1 {
+		t.Errorf("%d calls of CreateLogGroup, want 1", got)
+	}
+	if logGroups, _ := client.DescribeLogGroups("/ecs/web"); len(logGroups) != 1 {
+		t.Errorf("log groups %v, want /ecs/web", logGroups)
+	}
+
+	// no way to tell if it was done: not retried
+	client = newFlaky(map[string][]error{"RegisterTaskDefinition": {unavailable}})
+	r = &Retrier{client: client, noDelay: true}
+	if _, err := r.RegisterTaskDefinition(`{"family":"web"}`); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("register task definition: got %v, want %v", err, ErrUnavailable)
+	}
+	if got := client.calls["RegisterTaskDefinition"]; got != 1 {
+		t.Errorf("%d calls of RegisterTaskDefinition, want 1", got)
+	}
+}
+
+func TestRetrierNotRetryable(t *testing.T) {
+	setRetryPolicy(t, 3)
+	for _, err := range []error{
+		fakeError("ValidationError", "DescribeTargetGroups", "invalid"),
+		fakeError("AccessDenied", "DescribeTargetGroups", "not authorized"),
+		fakeError("TargetGroupNotFound", "DescribeTargetGroups", "not found"),
+		errors.New("exit status 254"),
+	} {
+		t.Run(err.Error(), func(t *testing.T) {
+			client := newFlaky(map[string][]error{"DescribeTargetGroups": {err}})
+			r := &Retrier{client: client, noDelay: true}
+			if _, got := r.DescribeTargetGroups(); got != err {
+				t.Errorf("got %v, want %v", got, err)
+			}
+			if got := client.calls["DescribeTargetGroups"]; got != 1 {
+				t.Errorf("%d calls, want 1", got)
+			}
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 30, MaxDelay: 3 * time.Second}
+	for attempt := 1; attempt < policy.MaxAttempts; attempt++ {
+		backoff := min(retryBaseDelay<<min(attempt-1, 16), policy.MaxDelay)
+		delay := policy.delay(attempt)
+		if delay < backoff/2 || delay > backoff {
+			t.Errorf("attempt %d: delay %v, want between %v and %v", attempt, delay, backoff/2, backoff)
+		}
+	}
+
+	// no max delay: only the exponential backoff
+	policy.MaxDelay = 0
+	if delay := policy.delay(4); delay < 2*time.Second || delay > 4*time.Second {
+		t.Errorf("attempt 4: delay %v, want between 2s and 4s", delay)
+	}
+}
@@ -2,23 +2,46 @@ package aws
 
 import (
 	"fmt"
-	"strings"
 
 	"github.com/charmbracelet/log"
-	"github.com/spf13/viper"
 )
 
-func CreateRule(filepath string, targetGroupArn string) (string, error) {
+type Rule struct {
+	RuleArn    string `json:"RuleArn"`
+	Priority   string `json:"Priority"`
+	Conditions []any  `json:"Conditions,omitempty"`
+	Actions    []any  `json:"Actions,omitempty"`
+	IsDefault  bool   `json:"IsDefault"`
+}
+
+type describeRulesOutput struct {
+	Rules []Rule
+}
+
+func (c CLI) DescribeRules(listenerArn string) ([]Rule, error) {
+	result := []Rule{}
+	var args []string
+	args = append(args, "elbv2", "describe-rules", "--output", "json", "--no-paginate", "--listener-arn", listenerArn)
+	log.Debug(args)
+
+	var resp describeRulesOutput
+	_, err := execAWS(args, &resp)
+	if err != nil {
+		return result, err
+	}
+
+	result = resp.Rules
+
+	return result, nil
+}
+
+func (c CLI) CreateRule(filepath string, targetGroupArn string) (string, error) {
 	var args []string
 	args = append(args, "elbv2", "create-rule", "--cli-input-json", fmt.Sprintf("file://%s", filepath))
 	if targetGroupArn != "" {
 		args = append(args, "--action", fmt.Sprintf("Type=forward,TargetGroupArn=%s", targetGroupArn))
 	}
 	log.Debug(args)
-	if viper.GetBool("dummy") {
-		sleep(1)
-		return strings.Join(args, " "), nil
-	}
 
 	var resp any
 	stdout, err := execAWS(args, &resp)
@@ -26,7 +49,8 @@ func CreateRule(filepath string, targetGroupArn string) (string, error) {
 	return string(stdout), err
 }
 
-func CreateRule2(filepath string, targetGroupArn string, priority int, listenerArn string) (string, error) {
+func (c CLI) CreateRule2(filepath string, targetGroupArn string, priority int, listenerArn string) (Rule, error) {
+	var result Rule
 	var args []string
 	args = append(args, "elbv2", "create-rule", "--cli-input-json", fmt.Sprintf("file://%s", filepath))
 	if targetGroupArn != "" {
@@ -38,14 +62,88 @@ func CreateRule2(filepath string, targetGroupArn string, priority int, listenerA
 	if listenerArn != "" {
 		args = append(args, "--listener-arn", listenerArn)
 	}
+	args = append(args, "--output", "json")
 	log.Debug(args)
-	if viper.GetBool("dummy") {
-		sleep(1)
-		return strings.Join(args, " "), nil
+
+	var resp describeRulesOutput
+	_, err := execAWS(args, &resp)
+	if err != nil {
+		return result, err
 	}
 
+	if len(resp.Rules) > 0 {
+		result = resp.Rules[0]
+	}
+
+	return result, nil
+}
+
+// modifyRuleKeys are the keys of a create-rule
+// file accepted by modify-rule.
+var modifyRuleKeys = []string{"Conditions", "Actions"}
+
+func (c CLI) ModifyRule(ruleArn string, filepath string, targetGroupArn string) (string, error) {
+	inputJson, err := inputJsonWithKeys(filepath, modifyRuleKeys)
+	if err != nil {
+		return "", err
+	}
+	var args []string
+	args = append(args, "elbv2", "modify-rule", "--cli-input-json", inputJson, "--rule-arn", ruleArn)
+	if targetGroupArn != "" {
+		args = append(args, "--actions", fmt.Sprintf("Type=forward,TargetGroupArn=%s", targetGroupArn))
+	}
+	log.Debug(args)
+
 	var resp any
 	stdout, err := execAWS(args, &resp)
 
 	return string(stdout), err
 }
+
+func (c CLI) SetRulePriority(ruleArn string, priority int) (string, error) {
+	var args []string
+	args = append(args, "elbv2", "set-rule-priorities", "--rule-priorities", fmt.Sprintf("RuleArn=%s,Priority=%d", ruleArn, priority))
+	log.Debug(args)
+
+	var resp any
+	stdout, err := execAWS(args, &resp)
+
+	return string(stdout), err
+}
+
+func (c CLI) DeleteRule(ruleArn string) (string, error) {
+	var args []string
+	args = append(args, "elbv2", "delete-rule", "--rule-arn", ruleArn)
+	log.Debug(args)
+
+	var resp any
+	stdout, err := execAWS(args, &resp)
+
+	return string(stdout), err
+}
+
+// DescribeRuleByArn returns every attribute
+// of a rule (drift detection).
+func (c CLI) DescribeRuleByArn(ruleArn string) (map[string]any, error) {
+	var result map[string]any
+	var args []string
+	args = append(args, "elbv2", "describe-rules", "--output", "json", "--rule-arns", ruleArn, "--query", "Rules[0]")
+	log.Debug(args)
+
+	_, err := execAWS(args, &result)
+
+	return result, err
+}
+
+// DescribeRulesFull returns every attribute
+// of the rules of a listener (import).
+func (c CLI) DescribeRulesFull(listenerArn string) ([]map[string]any, error) {
+	var result []map[string]any
+	var args []string
+	args = append(args, "elbv2", "describe-rules", "--output", "json", "--no-paginate", "--listener-arn", listenerArn, "--query", "Rules")
+	log.Debug(args)
+
+	_, err := execAWS(args, &result)
+
+	return result, err
+}
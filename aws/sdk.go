@@ -0,0 +1,206 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/charmbracelet/log"
+)
+
+// waitTimeout is how long the waiters wait
+// (as long as the ones of the aws cli).
+const waitTimeout = 10 * time.Minute
+
+// timeLayout is how the aws cli prints times.
+const timeLayout = "2006-01-02T15:04:05.000000-07:00"
+
+// SDK is the Client calling the aws apis with aws-sdk-go-v2.
+// Credentials and region come from the same places as for
+// the aws cli (environment, ~/.aws/config, AWS_PROFILE, ...).
+type SDK struct {
+	ctx context.Context
+	cfg awssdk.Config
+
+	elbv2 *elasticloadbalancingv2.Client
+	ecs   *ecs.Client
+	logs  *cloudwatchlogs.Client
+	ecr   *ecr.Client
+}
+
+func NewSDK() (*SDK, error) {
+	ctx := context.Background()
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cfg.APIOptions = append(cfg.APIOptions, addErrorMiddleware)
+	// the calls are retried by the Retrier (--max-attempts)
+	cfg.Retryer = func() awssdk.Retryer {
+		return awssdk.NopRetryer{}
+	}
+	return &SDK{
+		ctx:   ctx,
+		cfg:   cfg,
+		elbv2: elasticloadbalancingv2.NewFromConfig(cfg),
+		ecs:   ecs.NewFromConfig(cfg),
+		logs:  cloudwatchlogs.NewFromConfig(cfg),
+		ecr:   ecr.NewFromConfig(cfg),
+	}, nil
+}
+
+// addErrorMiddleware makes the apis return an Error
+// (as the aws cli would print it) when they fail.
+func addErrorMiddleware(stack *middleware.Stack) error {
+	return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("ecxError", func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+		out, metadata, err := next.HandleInitialize(ctx, in)
+		var apiErr smithy.APIError
+		var sendErr *smithyhttp.RequestSendError
+		if err != nil && errors.As(err, &apiErr) {
+			err = newError(apiErr.ErrorCode(), awsmiddleware.GetOperationName(ctx), apiErr.ErrorMessage(), err)
+		} else if err != nil && errors.As(err, &sendErr) {
+			err = &Error{Operation: awsmiddleware.GetOperationName(ctx), Message: err.Error(), kind: ErrUnavailable, err: err}
+		}
+		return out, metadata, err
+	}), middleware.After)
+}
+
+// debug logs an operation and its input (as the args of the cli).
+func debug(operation string, input any) {
+	content, _ := json.Marshal(input)
+	log.Debug(operation, "input", string(content))
+}
+
+// readInput decodes a --cli-input-json file into the input
+// of an api (only the keys if some are given).
+func readInput(filepath string, keys []string, input any) error {
+	if keys != nil {
+		content, err := inputJsonWithKeys(filepath, keys)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal([]byte(content), input)
+	}
+	content, err := os.ReadFile(filepath)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(content, input)
+}
+
+// readInputJson decodes a --cli-input-json value
+// ("file://<path>" or json) into the input of an api.
+func readInputJson(inputJson string, input any) error {
+	if filepath, ok := strings.CutPrefix(inputJson, "file://"); ok {
+		return readInput(filepath, nil, input)
+	}
+	return json.Unmarshal([]byte(inputJson), input)
+}
+
+// convert converts an output of the sdk into a type of this package
+// through the json the aws cli would print. The fields of the sdk
+// are PascalCase, camel makes them camelCase (ecs, logs and ecr
+// outputs are camelCase, elbv2 ones are not).
+func convert(from any, to any, camel bool) error {
+	content, err := json.Marshal(generic(reflect.ValueOf(from), camel))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(content, to)
+}
+
+// toMap returns every attribute of an output of the sdk
+// as the aws cli would print it (nil if there is none).
+func toMap(from any, camel bool) map[string]any {
+	var result map[string]any
+	if err := convert(from, &result, camel); err != nil {
+		return nil
+	}
+	return result
+}
+
+// generic returns the json value of a value of the sdk,
+// without the unset (nil or empty enum) fields.
+func generic(v reflect.Value, camel bool) any {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return nil
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return generic(v.Elem(), camel)
+	case reflect.Struct:
+		if t, ok := v.Interface().(time.Time); ok {
+			return t.Format(timeLayout)
+		}
+		result := make(map[string]any)
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			value := generic(v.Field(i), camel)
+			if value == nil {
+				continue
+			}
+			name := field.Name
+			if camel {
+				r, size := utf8.DecodeRuneInString(name)
+				name = string(unicode.ToLower(r)) + name[size:]
+			}
+			result[name] = value
+		}
+		return result
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		result := make(map[string]any)
+		iter := v.MapRange()
+		for iter.Next() {
+			result[iter.Key().String()] = generic(iter.Value(), camel)
+		}
+		return result
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		result := make([]any, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			result = append(result, generic(v.Index(i), camel))
+		}
+		return result
+	case reflect.String:
+		// unset enums
+		if v.Len() == 0 {
+			return nil
+		}
+		return v.String()
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint()
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	}
+	return nil
+}
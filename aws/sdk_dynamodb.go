@@ -0,0 +1,73 @@
+package aws
+
+import (
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// dynamodb returns a client for the region and endpoint of a table.
+func (c *SDK) dynamodb(table LockTable) *dynamodb.Client {
+	return dynamodb.NewFromConfig(c.cfg, func(o *dynamodb.Options) {
+		if table.Region != "" {
+			o.Region = table.Region
+		}
+		if table.Endpoint != "" {
+			o.BaseEndpoint = awssdk.String(table.Endpoint)
+		}
+	})
+}
+
+func lockItemKeyAttributes(key string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"LockID": &types.AttributeValueMemberS{Value: key},
+	}
+}
+
+func (c *SDK) PutLockItem(table LockTable, key string, id string, info string) error {
+	input := &dynamodb.PutItemInput{
+		TableName: awssdk.String(table.Name),
+		Item: map[string]types.AttributeValue{
+			"LockID": &types.AttributeValueMemberS{Value: key},
+			"ID":     &types.AttributeValueMemberS{Value: id},
+			"Info":   &types.AttributeValueMemberS{Value: info},
+		},
+		ConditionExpression: awssdk.String("attribute_not_exists(LockID)"),
+	}
+	debug("dynamodb put-item", input)
+	_, err := c.dynamodb(table).PutItem(c.ctx, input)
+	return err
+}
+
+func (c *SDK) GetLockItem(table LockTable, key string) (string, error) {
+	input := &dynamodb.GetItemInput{
+		TableName:      awssdk.String(table.Name),
+		Key:            lockItemKeyAttributes(key),
+		ConsistentRead: awssdk.Bool(true),
+	}
+	debug("dynamodb get-item", input)
+	output, err := c.dynamodb(table).GetItem(c.ctx, input)
+	if err != nil {
+		return "", err
+	}
+	if info, ok := output.Item["Info"].(*types.AttributeValueMemberS); ok {
+		return info.Value, nil
+	}
+	return "", nil
+}
+
+func (c *SDK) DeleteLockItem(table LockTable, key string, id string) error {
+	input := &dynamodb.DeleteItemInput{
+		TableName: awssdk.String(table.Name),
+		Key:       lockItemKeyAttributes(key),
+	}
+	if id != "" {
+		input.ConditionExpression = awssdk.String("ID = :id")
+		input.ExpressionAttributeValues = map[string]types.AttributeValue{
+			":id": &types.AttributeValueMemberS{Value: id},
+		}
+	}
+	debug("dynamodb delete-item", input)
+	_, err := c.dynamodb(table).DeleteItem(c.ctx, input)
+	return err
+}
@@ -0,0 +1,35 @@
+package aws
+
+import (
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+)
+
+func (c *SDK) ListImages(ecrRepositoryName string) ([]Image, error) {
+	var result []Image
+	input := &ecr.ListImagesInput{
+		RepositoryName: awssdk.String(ecrRepositoryName),
+		Filter:         &types.ListImagesFilter{TagStatus: types.TagStatusTagged},
+	}
+	debug("ecr list-images", input)
+	paginator := ecr.NewListImagesPaginator(c.ecr, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(c.ctx)
+		if err != nil {
+			return result, err
+		}
+		var images []Image
+		if err = convert(page.ImageIds, &images, true); err != nil {
+			return result, err
+		}
+		result = append(result, images...)
+	}
+
+	// reverse array
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+
+	return result, nil
+}
@@ -0,0 +1,237 @@
+package aws
+
+import (
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// serviceLoadBalancers is
+// "targetGroupArn=<arn>,containerName=<name>,containerPort=<port>".
+func serviceLoadBalancers(loadBalancer ServiceLoadBalancer) []types.LoadBalancer {
+	return []types.LoadBalancer{
+		{
+			TargetGroupArn: awssdk.String(loadBalancer.TargetGroupArn),
+			ContainerName:  awssdk.String(loadBalancer.ContainerName),
+			ContainerPort:  awssdk.Int32(int32(loadBalancer.ContainerPort)),
+		},
+	}
+}
+
+func (c *SDK) CreateService(filepath string, loadBalancer ServiceLoadBalancer, healthCheckGracePeriodSeconds int) (Service, error) {
+	var result Service
+	var input ecs.CreateServiceInput
+	if err := readInput(filepath, nil, &input); err != nil {
+		return result, err
+	}
+	if loadBalancer.TargetGroupArn != "" && loadBalancer.ContainerName != "" {
+		input.LoadBalancers = serviceLoadBalancers(loadBalancer)
+	}
+	if healthCheckGracePeriodSeconds > 0 {
+		input.HealthCheckGracePeriodSeconds = awssdk.Int32(int32(healthCheckGracePeriodSeconds))
+	}
+	debug("ecs create-service", input)
+	output, err := c.ecs.CreateService(c.ctx, &input)
+	if err != nil {
+		return result, err
+	}
+	err = convert(output.Service, &result, true)
+	return result, err
+}
+
+func (c *SDK) UpdateServiceWithFile(cluster string, serviceArn string, filepath string, loadBalancer ServiceLoadBalancer, healthCheckGracePeriodSeconds int) (string, error) {
+	var input ecs.UpdateServiceInput
+	if err := readInput(filepath, updateServiceKeys, &input); err != nil {
+		return "", err
+	}
+	input.Cluster = awssdk.String(cluster)
+	input.Service = awssdk.String(serviceArn)
+	if loadBalancer.TargetGroupArn != "" && loadBalancer.ContainerName != "" {
+		input.LoadBalancers = serviceLoadBalancers(loadBalancer)
+	}
+	if healthCheckGracePeriodSeconds > 0 {
+		input.HealthCheckGracePeriodSeconds = awssdk.Int32(int32(healthCheckGracePeriodSeconds))
+	}
+	debug("ecs update-service", input)
+	_, err := c.ecs.UpdateService(c.ctx, &input)
+	return "", err
+}
+
+func (c *SDK) describeServices(cluster string, serviceArns []string) ([]types.Service, error) {
+	input := &ecs.DescribeServicesInput{Cluster: awssdk.String(cluster), Services: serviceArns}
+	debug("ecs describe-services", input)
+	output, err := c.ecs.DescribeServices(c.ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	return output.Services, nil
+}
+
+func (c *SDK) DescribeService(cluster string, serviceArn string) (Service, error) {
+	var result Service
+	services, err := c.describeServices(cluster, []string{serviceArn})
+	if err != nil || len(services) == 0 {
+		return result, err
+	}
+	err = convert(services[0], &result, true)
+	return result, err
+}
+
+func (c *SDK) DescribeServiceStability(cluster string, serviceArn string) (ServiceStability, error) {
+	var result ServiceStability
+	services, err := c.describeServices(cluster, []string{serviceArn})
+	if err != nil || len(services) == 0 {
+		return result, err
+	}
+	if len(services[0].Events) > 10 {
+		services[0].Events = services[0].Events[:10]
+	}
+	err = convert(services[0], &result, true)
+	return result, err
+}
+
+func (c *SDK) DescribeServiceByArn(cluster string, serviceArn string) (map[string]any, error) {
+	services, err := c.describeServices(cluster, []string{serviceArn})
+	if err != nil || len(services) == 0 {
+		return nil, err
+	}
+	return toMap(services[0], true), nil
+}
+
+func (c *SDK) DescribeServices(cluster string, serviceArns ...string) ([]Service, error) {
+	var result []Service
+	services, err := c.describeServices(cluster, serviceArns)
+	if err != nil {
+		return result, err
+	}
+	err = convert(services, &result, true)
+	return result, err
+}
+
+func (c *SDK) ListServices(cluster string) ([]string, error) {
+	var result []string
+	input := &ecs.ListServicesInput{Cluster: awssdk.String(cluster)}
+	debug("ecs list-services", input)
+	paginator := ecs.NewListServicesPaginator(c.ecs, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(c.ctx)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, page.ServiceArns...)
+	}
+	return result, nil
+}
+
+func (c *SDK) UpdateService(cluster string, serviceArn string, inputJson string) (string, error) {
+	var input ecs.UpdateServiceInput
+	if err := readInputJson(inputJson, &input); err != nil {
+		return "", err
+	}
+	input.Cluster = awssdk.String(cluster)
+	input.Service = awssdk.String(serviceArn)
+	debug("ecs update-service", input)
+	_, err := c.ecs.UpdateService(c.ctx, &input)
+	return "", err
+}
+
+func (c *SDK) UpdateServiceDesiredCount(cluster string, serviceArn string, desiredCount int) (string, error) {
+	input := &ecs.UpdateServiceInput{
+		Cluster:      awssdk.String(cluster),
+		Service:      awssdk.String(serviceArn),
+		DesiredCount: awssdk.Int32(int32(desiredCount)),
+	}
+	debug("ecs update-service", input)
+	output, err := c.ecs.UpdateService(c.ctx, input)
+	if err != nil || output.Service == nil {
+		return "", err
+	}
+	return awssdk.ToString(output.Service.ServiceArn), nil
+}
+
+func (c *SDK) DeleteService(cluster string, serviceArn string) (string, error) {
+	input := &ecs.DeleteServiceInput{Cluster: awssdk.String(cluster), Service: awssdk.String(serviceArn)}
+	debug("ecs delete-service", input)
+	output, err := c.ecs.DeleteService(c.ctx, input)
+	if err != nil || output.Service == nil {
+		return "", err
+	}
+	return awssdk.ToString(output.Service.ServiceArn), nil
+}
+
+func (c *SDK) WaitServicesInactive(cluster string, serviceArn string) error {
+	input := &ecs.DescribeServicesInput{Cluster: awssdk.String(cluster), Services: []string{serviceArn}}
+	debug("ecs wait services-inactive", input)
+	waiter := ecs.NewServicesInactiveWaiter(c.ecs)
+	return waiter.Wait(c.ctx, input, waitTimeout)
+}
+
+func (c *SDK) DescribeTaskDefinition(taskDefinition string) (TaskDefinition, error) {
+	result := TaskDefinition{}
+	input := &ecs.DescribeTaskDefinitionInput{
+		TaskDefinition: awssdk.String(taskDefinition),
+		Include:        []types.TaskDefinitionField{types.TaskDefinitionFieldTags},
+	}
+	debug("ecs describe-task-definition", input)
+	output, err := c.ecs.DescribeTaskDefinition(c.ctx, input)
+	if err != nil {
+		return result, err
+	}
+	if err = convert(output.TaskDefinition, &result, true); err != nil {
+		return result, err
+	}
+	err = convert(output.Tags, &result.Tags, true)
+	return result, err
+}
+
+func (c *SDK) RegisterTaskDefinition(inputJson string) (TaskDefinition, error) {
+	result := TaskDefinition{}
+	var input ecs.RegisterTaskDefinitionInput
+	if err := readInputJson(inputJson, &input); err != nil {
+		return result, err
+	}
+	debug("ecs register-task-definition", input)
+	output, err := c.ecs.RegisterTaskDefinition(c.ctx, &input)
+	if err != nil {
+		return result, err
+	}
+	err = convert(output.TaskDefinition, &result, true)
+	return result, err
+}
+
+func (c *SDK) DescribeTaskDefinitionByArn(taskDefinition string) (map[string]any, error) {
+	input := &ecs.DescribeTaskDefinitionInput{TaskDefinition: awssdk.String(taskDefinition)}
+	debug("ecs describe-task-definition", input)
+	output, err := c.ecs.DescribeTaskDefinition(c.ctx, input)
+	if err != nil || output.TaskDefinition == nil {
+		return nil, err
+	}
+	return toMap(output.TaskDefinition, true), nil
+}
+
+func (c *SDK) ListStoppedTasks(cluster string, serviceName string) ([]string, error) {
+	input := &ecs.ListTasksInput{
+		Cluster:       awssdk.String(cluster),
+		ServiceName:   awssdk.String(serviceName),
+		DesiredStatus: types.DesiredStatusStopped,
+		MaxResults:    awssdk.Int32(5),
+	}
+	debug("ecs list-tasks", input)
+	output, err := c.ecs.ListTasks(c.ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	return output.TaskArns, nil
+}
+
+func (c *SDK) DescribeTasks(cluster string, taskArns []string) ([]Task, error) {
+	var result []Task
+	input := &ecs.DescribeTasksInput{Cluster: awssdk.String(cluster), Tasks: taskArns}
+	debug("ecs describe-tasks", input)
+	output, err := c.ecs.DescribeTasks(c.ctx, input)
+	if err != nil {
+		return result, err
+	}
+	err = convert(output.Tasks, &result, true)
+	return result, err
+}
@@ -0,0 +1,336 @@
+package aws
+
+import (
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+)
+
+// forwardActions is "Type=forward,TargetGroupArn=<arn>".
+func forwardActions(targetGroupArn string) []types.Action {
+	return []types.Action{
+		{Type: types.ActionTypeEnumForward, TargetGroupArn: awssdk.String(targetGroupArn)},
+	}
+}
+
+func (c *SDK) describeTargetGroups(input *elasticloadbalancingv2.DescribeTargetGroupsInput) ([]TargetGroup, error) {
+	result := []TargetGroup{}
+	debug("elbv2 describe-target-groups", input)
+	paginator := elasticloadbalancingv2.NewDescribeTargetGroupsPaginator(c.elbv2, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(c.ctx)
+		if err != nil {
+			return result, err
+		}
+		var targetGroups []TargetGroup
+		if err = convert(page.TargetGroups, &targetGroups, false); err != nil {
+			return result, err
+		}
+		result = append(result, targetGroups...)
+	}
+	return result, nil
+}
+
+func (c *SDK) DescribeTargetGroups() ([]TargetGroup, error) {
+	return c.describeTargetGroups(&elasticloadbalancingv2.DescribeTargetGroupsInput{})
+}
+
+func (c *SDK) DescribeTargetGroupsWithNames(names []string) ([]TargetGroup, error) {
+	return c.describeTargetGroups(&elasticloadbalancingv2.DescribeTargetGroupsInput{Names: names})
+}
+
+func (c *SDK) CreateTargetGroup(filepath string) (TargetGroup, error) {
+	var result TargetGroup
+	var input elasticloadbalancingv2.CreateTargetGroupInput
+	if err := readInput(filepath, nil, &input); err != nil {
+		return result, err
+	}
+	debug("elbv2 create-target-group", input)
+	output, err := c.elbv2.CreateTargetGroup(c.ctx, &input)
+	if err != nil {
+		return result, err
+	}
+	if len(output.TargetGroups) > 0 {
+		err = convert(output.TargetGroups[0], &result, false)
+	}
+	return result, err
+}
+
+func (c *SDK) ModifyTargetGroup(targetGroupArn string, filepath string) (string, error) {
+	var input elasticloadbalancingv2.ModifyTargetGroupInput
+	if err := readInput(filepath, modifyTargetGroupKeys, &input); err != nil {
+		return "", err
+	}
+	input.TargetGroupArn = awssdk.String(targetGroupArn)
+	debug("elbv2 modify-target-group", input)
+	_, err := c.elbv2.ModifyTargetGroup(c.ctx, &input)
+	return "", err
+}
+
+func (c *SDK) DeleteTargetGroup(targetGroupArn string) (string, error) {
+	input := &elasticloadbalancingv2.DeleteTargetGroupInput{TargetGroupArn: awssdk.String(targetGroupArn)}
+	debug("elbv2 delete-target-group", input)
+	_, err := c.elbv2.DeleteTargetGroup(c.ctx, input)
+	return "", err
+}
+
+func (c *SDK) DescribeTargetGroupByArn(targetGroupArn string) (map[string]any, error) {
+	input := &elasticloadbalancingv2.DescribeTargetGroupsInput{TargetGroupArns: []string{targetGroupArn}}
+	debug("elbv2 describe-target-groups", input)
+	output, err := c.elbv2.DescribeTargetGroups(c.ctx, input)
+	if err != nil || len(output.TargetGroups) == 0 {
+		return nil, err
+	}
+	return toMap(output.TargetGroups[0], false), nil
+}
+
+func (c *SDK) DescribeLoadBalancersWithNames(names []string) ([]LoadBalancer, error) {
+	result := []LoadBalancer{}
+	input := &elasticloadbalancingv2.DescribeLoadBalancersInput{Names: names}
+	debug("elbv2 describe-load-balancers", input)
+	paginator := elasticloadbalancingv2.NewDescribeLoadBalancersPaginator(c.elbv2, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(c.ctx)
+		if err != nil {
+			return result, err
+		}
+		var loadBalancers []LoadBalancer
+		if err = convert(page.LoadBalancers, &loadBalancers, false); err != nil {
+			return result, err
+		}
+		result = append(result, loadBalancers...)
+	}
+	return result, nil
+}
+
+func (c *SDK) CreateLoadBalancer(filepath string) (LoadBalancer, error) {
+	var result LoadBalancer
+	var input elasticloadbalancingv2.CreateLoadBalancerInput
+	if err := readInput(filepath, nil, &input); err != nil {
+		return result, err
+	}
+	debug("elbv2 create-load-balancer", input)
+	output, err := c.elbv2.CreateLoadBalancer(c.ctx, &input)
+	if err != nil {
+		return result, err
+	}
+	if len(output.LoadBalancers) > 0 {
+		err = convert(output.LoadBalancers[0], &result, false)
+	}
+	return result, err
+}
+
+func (c *SDK) DeleteLoadBalancer(loadBalancerArn string) (string, error) {
+	input := &elasticloadbalancingv2.DeleteLoadBalancerInput{LoadBalancerArn: awssdk.String(loadBalancerArn)}
+	debug("elbv2 delete-load-balancer", input)
+	_, err := c.elbv2.DeleteLoadBalancer(c.ctx, input)
+	return "", err
+}
+
+func (c *SDK) WaitLoadBalancersDeleted(loadBalancerArn string) error {
+	input := &elasticloadbalancingv2.DescribeLoadBalancersInput{LoadBalancerArns: []string{loadBalancerArn}}
+	debug("elbv2 wait load-balancers-deleted", input)
+	waiter := elasticloadbalancingv2.NewLoadBalancersDeletedWaiter(c.elbv2)
+	return waiter.Wait(c.ctx, input, waitTimeout)
+}
+
+func (c *SDK) DescribeLoadBalancerByName(name string) (map[string]any, error) {
+	input := &elasticloadbalancingv2.DescribeLoadBalancersInput{Names: []string{name}}
+	debug("elbv2 describe-load-balancers", input)
+	output, err := c.elbv2.DescribeLoadBalancers(c.ctx, input)
+	if err != nil || len(output.LoadBalancers) == 0 {
+		return nil, err
+	}
+	return toMap(output.LoadBalancers[0], false), nil
+}
+
+func (c *SDK) describeListeners(input *elasticloadbalancingv2.DescribeListenersInput) ([]types.Listener, error) {
+	var result []types.Listener
+	debug("elbv2 describe-listeners", input)
+	paginator := elasticloadbalancingv2.NewDescribeListenersPaginator(c.elbv2, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(c.ctx)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, page.Listeners...)
+	}
+	return result, nil
+}
+
+func (c *SDK) DescribeListeners(loadBalancerArn string) ([]Listener, error) {
+	result := []Listener{}
+	listeners, err := c.describeListeners(&elasticloadbalancingv2.DescribeListenersInput{LoadBalancerArn: awssdk.String(loadBalancerArn)})
+	if err != nil {
+		return result, err
+	}
+	err = convert(listeners, &result, false)
+	return result, err
+}
+
+func (c *SDK) CreateListener(filepath string, loadBalancerArn string, targetGroupArn string) (Listener, error) {
+	var result Listener
+	var input elasticloadbalancingv2.CreateListenerInput
+	if err := readInput(filepath, nil, &input); err != nil {
+		return result, err
+	}
+	if loadBalancerArn != "" {
+		input.LoadBalancerArn = awssdk.String(loadBalancerArn)
+	}
+	if targetGroupArn != "" {
+		input.DefaultActions = forwardActions(targetGroupArn)
+	}
+	debug("elbv2 create-listener", input)
+	output, err := c.elbv2.CreateListener(c.ctx, &input)
+	if err != nil {
+		return result, err
+	}
+	if len(output.Listeners) > 0 {
+		err = convert(output.Listeners[0], &result, false)
+	}
+	return result, err
+}
+
+func (c *SDK) ModifyListener(listenerArn string, filepath string, targetGroupArn string) (string, error) {
+	var input elasticloadbalancingv2.ModifyListenerInput
+	if err := readInput(filepath, modifyListenerKeys, &input); err != nil {
+		return "", err
+	}
+	input.ListenerArn = awssdk.String(listenerArn)
+	if targetGroupArn != "" {
+		input.DefaultActions = forwardActions(targetGroupArn)
+	}
+	debug("elbv2 modify-listener", input)
+	_, err := c.elbv2.ModifyListener(c.ctx, &input)
+	return "", err
+}
+
+func (c *SDK) DeleteListener(listenerArn string) (string, error) {
+	input := &elasticloadbalancingv2.DeleteListenerInput{ListenerArn: awssdk.String(listenerArn)}
+	debug("elbv2 delete-listener", input)
+	_, err := c.elbv2.DeleteListener(c.ctx, input)
+	return "", err
+}
+
+func (c *SDK) DescribeListenerByArn(listenerArn string) (map[string]any, error) {
+	listeners, err := c.describeListeners(&elasticloadbalancingv2.DescribeListenersInput{ListenerArns: []string{listenerArn}})
+	if err != nil || len(listeners) == 0 {
+		return nil, err
+	}
+	return toMap(listeners[0], false), nil
+}
+
+func (c *SDK) DescribeListenersFull(loadBalancerArn string) ([]map[string]any, error) {
+	var result []map[string]any
+	listeners, err := c.describeListeners(&elasticloadbalancingv2.DescribeListenersInput{LoadBalancerArn: awssdk.String(loadBalancerArn)})
+	if err != nil {
+		return result, err
+	}
+	err = convert(listeners, &result, false)
+	return result, err
+}
+
+func (c *SDK) describeRules(input *elasticloadbalancingv2.DescribeRulesInput) ([]types.Rule, error) {
+	var result []types.Rule
+	debug("elbv2 describe-rules", input)
+	paginator := elasticloadbalancingv2.NewDescribeRulesPaginator(c.elbv2, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(c.ctx)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, page.Rules...)
+	}
+	return result, nil
+}
+
+func (c *SDK) DescribeRules(listenerArn string) ([]Rule, error) {
+	result := []Rule{}
+	rules, err := c.describeRules(&elasticloadbalancingv2.DescribeRulesInput{ListenerArn: awssdk.String(listenerArn)})
+	if err != nil {
+		return result, err
+	}
+	err = convert(rules, &result, false)
+	return result, err
+}
+
+func (c *SDK) CreateRule(filepath string, targetGroupArn string) (string, error) {
+	_, err := c.CreateRule2(filepath, targetGroupArn, 0, "")
+	return "", err
+}
+
+func (c *SDK) CreateRule2(filepath string, targetGroupArn string, priority int, listenerArn string) (Rule, error) {
+	var result Rule
+	var input elasticloadbalancingv2.CreateRuleInput
+	if err := readInput(filepath, nil, &input); err != nil {
+		return result, err
+	}
+	if targetGroupArn != "" {
+		input.Actions = forwardActions(targetGroupArn)
+	}
+	if priority > 0 {
+		input.Priority = awssdk.Int32(int32(priority))
+	}
+	if listenerArn != "" {
+		input.ListenerArn = awssdk.String(listenerArn)
+	}
+	debug("elbv2 create-rule", input)
+	output, err := c.elbv2.CreateRule(c.ctx, &input)
+	if err != nil {
+		return result, err
+	}
+	if len(output.Rules) > 0 {
+		err = convert(output.Rules[0], &result, false)
+	}
+	return result, err
+}
+
+func (c *SDK) ModifyRule(ruleArn string, filepath string, targetGroupArn string) (string, error) {
+	var input elasticloadbalancingv2.ModifyRuleInput
+	if err := readInput(filepath, modifyRuleKeys, &input); err != nil {
+		return "", err
+	}
+	input.RuleArn = awssdk.String(ruleArn)
+	if targetGroupArn != "" {
+		input.Actions = forwardActions(targetGroupArn)
+	}
+	debug("elbv2 modify-rule", input)
+	_, err := c.elbv2.ModifyRule(c.ctx, &input)
+	return "", err
+}
+
+func (c *SDK) SetRulePriority(ruleArn string, priority int) (string, error) {
+	input := &elasticloadbalancingv2.SetRulePrioritiesInput{
+		RulePriorities: []types.RulePriorityPair{
+			{RuleArn: awssdk.String(ruleArn), Priority: awssdk.Int32(int32(priority))},
+		},
+	}
+	debug("elbv2 set-rule-priorities", input)
+	_, err := c.elbv2.SetRulePriorities(c.ctx, input)
+	return "", err
+}
+
+func (c *SDK) DeleteRule(ruleArn string) (string, error) {
+	input := &elasticloadbalancingv2.DeleteRuleInput{RuleArn: awssdk.String(ruleArn)}
+	debug("elbv2 delete-rule", input)
+	_, err := c.elbv2.DeleteRule(c.ctx, input)
+	return "", err
+}
+
+func (c *SDK) DescribeRuleByArn(ruleArn string) (map[string]any, error) {
+	rules, err := c.describeRules(&elasticloadbalancingv2.DescribeRulesInput{RuleArns: []string{ruleArn}})
+	if err != nil || len(rules) == 0 {
+		return nil, err
+	}
+	return toMap(rules[0], false), nil
+}
+
+func (c *SDK) DescribeRulesFull(listenerArn string) ([]map[string]any, error) {
+	var result []map[string]any
+	rules, err := c.describeRules(&elasticloadbalancingv2.DescribeRulesInput{ListenerArn: awssdk.String(listenerArn)})
+	if err != nil {
+		return result, err
+	}
+	err = convert(rules, &result, false)
+	return result, err
+}
@@ -0,0 +1,49 @@
+package aws
+
+import (
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+)
+
+func (c *SDK) DescribeLogGroups(logGroupNamePrefix string) ([]LogGroup, error) {
+	result := []LogGroup{}
+	input := &cloudwatchlogs.DescribeLogGroupsInput{LogGroupNamePrefix: awssdk.String(logGroupNamePrefix)}
+	debug("logs describe-log-groups", input)
+	paginator := cloudwatchlogs.NewDescribeLogGroupsPaginator(c.logs, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(c.ctx)
+		if err != nil {
+			return result, err
+		}
+		var logGroups []LogGroup
+		if err = convert(page.LogGroups, &logGroups, true); err != nil {
+			return result, err
+		}
+		result = append(result, logGroups...)
+	}
+	return result, nil
+}
+
+func (c *SDK) CreateLogGroup(logGroupName string) (string, error) {
+	input := &cloudwatchlogs.CreateLogGroupInput{LogGroupName: awssdk.String(logGroupName)}
+	debug("logs create-log-group", input)
+	_, err := c.logs.CreateLogGroup(c.ctx, input)
+	return "", err
+}
+
+func (c *SDK) PutRetentionPolicy(logGroupName string, retentionInDays int) (string, error) {
+	input := &cloudwatchlogs.PutRetentionPolicyInput{
+		LogGroupName:    awssdk.String(logGroupName),
+		RetentionInDays: awssdk.Int32(int32(retentionInDays)),
+	}
+	debug("logs put-retention-policy", input)
+	_, err := c.logs.PutRetentionPolicy(c.ctx, input)
+	return "", err
+}
+
+func (c *SDK) DeleteLogGroup(logGroupName string) (string, error) {
+	input := &cloudwatchlogs.DeleteLogGroupInput{LogGroupName: awssdk.String(logGroupName)}
+	debug("logs delete-log-group", input)
+	_, err := c.logs.DeleteLogGroup(c.ctx, input)
+	return "", err
+}
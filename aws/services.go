@@ -2,10 +2,8 @@ package aws
 
 import (
 	"fmt"
-	"strings"
 
 	"github.com/charmbracelet/log"
-	"github.com/spf13/viper"
 )
 
 type ServiceLoadBalancer struct {
@@ -22,10 +20,16 @@ type Deployment struct {
 type Service struct {
 	ServiceArn  string       `json:"serviceArn"`
 	ServiceName string       `json:"serviceName"`
+	Status      string       `json:"status,omitempty"`
 	Deployments []Deployment `json:"deployments"`
 }
 
-func CreateService(filepath string, loadBalancer ServiceLoadBalancer, healthCheckGracePeriodSeconds int) (string, error) {
+type serviceOutput struct {
+	Service Service `json:"service"`
+}
+
+func (c CLI) CreateService(filepath string, loadBalancer ServiceLoadBalancer, healthCheckGracePeriodSeconds int) (Service, error) {
+	var result Service
 	var args []string
 	args = append(args, "ecs", "create-service", "--output", "json", "--cli-input-json", fmt.Sprintf("file://%s", filepath))
 	if loadBalancer.TargetGroupArn != "" && loadBalancer.ContainerName != "" {
@@ -40,38 +44,126 @@ func CreateService(filepath string, loadBalancer ServiceLoadBalancer, healthChec
 			healthCheckGracePeriodSeconds,
 		))
 	}
+	args = append(args, "--query", "{service: service.{serviceArn: serviceArn, serviceName: serviceName, status: status}}")
 	log.Debug(args)
-	if viper.GetBool("dummy") {
-		sleep(1)
-		return strings.Join(args, " "), nil
+
+	var resp serviceOutput
+	_, err := execAWS(args, &resp)
+	if err != nil {
+		return result, err
 	}
 
+	result = resp.Service
+
+	return result, nil
+}
+
+// updateServiceKeys are the keys of a create-service
+// file accepted by update-service.
+var updateServiceKeys = []string{
+	"desiredCount", "taskDefinition", "capacityProviderStrategy", "deploymentConfiguration",
+	"networkConfiguration", "placementConstraints", "placementStrategy", "platformVersion",
+	"healthCheckGracePeriodSeconds", "enableExecuteCommand", "enableECSManagedTags",
+	"loadBalancers", "propagateTags", "serviceRegistries", "serviceConnectConfiguration",
+}
+
+// UpdateServiceWithFile updates a service from its
+// creation file (create-service --cli-input-json).
+func (c CLI) UpdateServiceWithFile(cluster string, serviceArn string, filepath string, loadBalancer ServiceLoadBalancer, healthCheckGracePeriodSeconds int) (string, error) {
+	inputJson, err := inputJsonWithKeys(filepath, updateServiceKeys)
+	if err != nil {
+		return "", err
+	}
+	var args []string
+	args = append(args, "ecs", "update-service", "--output", "json", "--cluster", cluster, "--service", serviceArn, "--cli-input-json", inputJson)
+	if loadBalancer.TargetGroupArn != "" && loadBalancer.ContainerName != "" {
+		args = append(args, "--load-balancers", fmt.Sprintf(
+			"targetGroupArn=%s,containerName=%s,containerPort=%d",
+			loadBalancer.TargetGroupArn, loadBalancer.ContainerName, loadBalancer.ContainerPort,
+		))
+	}
+	if healthCheckGracePeriodSeconds > 0 {
+		args = append(args, "--health-check-grace-period-seconds", fmt.Sprintf(
+			"%d",
+			healthCheckGracePeriodSeconds,
+		))
+	}
+	log.Debug(args)
+
 	var resp any
 	stdout, err := execAWS(args, &resp)
 
 	return string(stdout), err
 }
 
-func DescribeService(cluster string, serviceArn string) (Service, error) {
+func (c CLI) DescribeService(cluster string, serviceArn string) (Service, error) {
 	var result Service
 	var args []string
 	args = append(args, "ecs", "describe-services", "--output", "json", "--cluster", cluster, "--no-paginate", "--services", serviceArn)
-	args = append(args, "--query", "services[0].{serviceArn: serviceArn, serviceName: serviceName, deployments: deployments[*].{id: id, taskDefinition: taskDefinition}}")
+	args = append(args, "--query", "services[0].{serviceArn: serviceArn, serviceName: serviceName, status: status, deployments: deployments[*].{id: id, taskDefinition: taskDefinition}}")
 
 	log.Debug(args)
-	if viper.GetBool("dummy") {
-		sleep(1)
-		return Service{
-			ServiceArn:  serviceArn,
-			ServiceName: "dummy-service",
-			Deployments: []Deployment{
-				{
-					Id:             "ecs-svc/1234567890123456789",
-					TaskDefinition: "arn:aws:ecs:us-east-1:053534965804:task-definition/dummy:5",
-				},
-			},
-		}, nil
-	}
+
+	_, err := execAWS(args, &result)
+
+	return result, err
+}
+
+// ServiceEvent is an event of a service
+// (e.g. "has reached a steady state").
+type ServiceEvent struct {
+	Id        string `json:"id"`
+	CreatedAt string `json:"createdAt"`
+	Message   string `json:"message"`
+}
+
+// ServiceDeployment is a deployment of a service
+// (status PRIMARY is the one being rolled out).
+type ServiceDeployment struct {
+	Id                 string `json:"id"`
+	Status             string `json:"status"`
+	TaskDefinition     string `json:"taskDefinition"`
+	RolloutState       string `json:"rolloutState,omitempty"`
+	RolloutStateReason string `json:"rolloutStateReason,omitempty"`
+	DesiredCount       int    `json:"desiredCount"`
+	RunningCount       int    `json:"runningCount"`
+	FailedTasks        int    `json:"failedTasks"`
+}
+
+// ServiceStability is what is needed to know
+// whether a service is stable.
+type ServiceStability struct {
+	ServiceArn   string              `json:"serviceArn"`
+	ServiceName  string              `json:"serviceName"`
+	Status       string              `json:"status"`
+	DesiredCount int                 `json:"desiredCount"`
+	RunningCount int                 `json:"runningCount"`
+	PendingCount int                 `json:"pendingCount"`
+	Deployments  []ServiceDeployment `json:"deployments"`
+	Events       []ServiceEvent      `json:"events"`
+}
+
+// DescribeServiceStability returns the counts, deployments
+// and latest events (most recent first) of a service.
+func (c CLI) DescribeServiceStability(cluster string, serviceArn string) (ServiceStability, error) {
+	var result ServiceStability
+	var args []string
+	args = append(args, "ecs", "describe-services", "--output", "json", "--cluster", cluster, "--services", serviceArn)
+	args = append(args, "--query", "services[0].{serviceArn: serviceArn, serviceName: serviceName, status: status, desiredCount: desiredCount, runningCount: runningCount, pendingCount: pendingCount, deployments: deployments[*].{id: id, status: status, taskDefinition: taskDefinition, rolloutState: rolloutState, rolloutStateReason: rolloutStateReason, desiredCount: desiredCount, runningCount: runningCount, failedTasks: failedTasks}, events: events[:10]}")
+	log.Debug(args)
+
+	_, err := execAWS(args, &result)
+
+	return result, err
+}
+
+// DescribeServiceByArn returns every attribute
+// of a service (drift detection).
+func (c CLI) DescribeServiceByArn(cluster string, serviceArn string) (map[string]any, error) {
+	var result map[string]any
+	var args []string
+	args = append(args, "ecs", "describe-services", "--output", "json", "--cluster", cluster, "--services", serviceArn, "--query", "services[0]")
+	log.Debug(args)
 
 	_, err := execAWS(args, &result)
 
@@ -80,82 +172,41 @@ func DescribeService(cluster string, serviceArn string) (Service, error) {
 
 // max 10 services
 // (https://docs.aws.amazon.com/cli/latest/reference/ecs/describe-services.html#options)
-func DescribeServices(cluster string, serviceArns ...string) ([]Service, error) {
+func (c CLI) DescribeServices(cluster string, serviceArns ...string) ([]Service, error) {
 	var result []Service
 	var args []string
 	args = append(args, "ecs", "describe-services", "--output", "json", "--cluster", cluster, "--no-paginate", "--services")
 	args = append(args, serviceArns...)
-	args = append(args, "--query", "services[*].{serviceArn: serviceArn, serviceName: serviceName, deployments: deployments[*].{id: id, taskDefinition: taskDefinition}}")
+	args = append(args, "--query", "services[*].{serviceArn: serviceArn, serviceName: serviceName, status: status, deployments: deployments[*].{id: id, taskDefinition: taskDefinition}}")
 
 	log.Debug(args)
-	if viper.GetBool("dummy") {
-		sleep(1)
-		return []Service{
-			{
-				ServiceArn:  "arn:aws:ecs:us-west-2:123456789012:service/dummy-service",
-				ServiceName: "dummy-service",
-				Deployments: []Deployment{
-					{
-						Id:             "ecs-svc/1234567890123456789",
-						TaskDefinition: "arn:aws:ecs:us-east-1:053534965804:task-definition/dummy:5",
-					},
-				},
-			},
-			{
-				ServiceArn:  "arn:aws:ecs:us-west-2:123456789012:service/dummy-service-2",
-				ServiceName: "dummy-service-2",
-				Deployments: []Deployment{
-					{
-						Id:             "ecs-svc/9876543210987654321",
-						TaskDefinition: "arn:aws:ecs:us-east-1:053534965804:task-definition/dummy2:18",
-					},
-				},
-			},
-		}, nil
-	}
 
 	_, err := execAWS(args, &result)
 
 	return result, err
 }
 
-func ListServices(cluster string) ([]string, error) {
+func (c CLI) ListServices(cluster string) ([]string, error) {
 	var result []string
 	var args []string
 	args = append(args, "ecs", "list-services", "--output", "json", "--cluster", cluster)
 	args = append(args, "--query", "serviceArns")
 	log.Debug(args)
-	if viper.GetBool("dummy") {
-		sleep(1)
-		return []string{
-			"arn:aws:ecs:us-west-2:123456789012:service/dummy-service",
-			"arn:aws:ecs:us-west-2:123456789012:service/dummy-service-2",
-		}, nil
-	}
 
 	_, err := execAWS(args, &result)
 
 	return result, err
 }
 
-func ListServices2(cluster string) ([]Service, error) {
+// ListServices2 describes every service of a cluster
+// (by chunks of 10).
+func ListServices2(client Client, cluster string) ([]Service, error) {
 	var result []Service
-	var args []string
-	args = append(args, "ecs", "list-services", "--output", "json", "--cluster", cluster)
-	args = append(args, "--query", "serviceArns")
-	log.Debug(args)
-	if viper.GetBool("dummy") {
-		sleep(1)
-		return DescribeServices(
-			cluster,
-			"arn:aws:ecs:us-west-2:123456789012:service/dummy-service",
-			"arn:aws:ecs:us-west-2:123456789012:service/dummy-service-2",
-		)
+	serviceArns, err := client.ListServices(cluster)
+	if err != nil {
+		return result, err
 	}
 
-	var serviceArns []string
-	_, err := execAWS(args, &serviceArns)
-
 	chunkSize := 10
 
 	for i := 0; i < len(serviceArns); i += chunkSize {
@@ -166,7 +217,7 @@ func ListServices2(cluster string) ([]Service, error) {
 			end = len(serviceArns)
 		}
 
-		resultChunk, err = DescribeServices(cluster, serviceArns[i:end]...)
+		resultChunk, err = client.DescribeServices(cluster, serviceArns[i:end]...)
 		if err != nil {
 			break
 		}
@@ -176,17 +227,50 @@ func ListServices2(cluster string) ([]Service, error) {
 	return result, err
 }
 
-func UpdateService(cluster string, serviceArn string, inputJson string) (string, error) {
+func (c CLI) UpdateService(cluster string, serviceArn string, inputJson string) (string, error) {
 	var args []string
 	args = append(args, "ecs", "update-service", "--cluster", cluster, "--service", serviceArn, "--cli-input-json", inputJson)
 	log.Debug(args)
-	if viper.GetBool("dummy") {
-		sleep(1)
-		return strings.Join(args, " "), nil
-	}
 
 	var resp any
 	stdout, err := execAWS(args, &resp)
 
 	return string(stdout), err
 }
+
+func (c CLI) UpdateServiceDesiredCount(cluster string, serviceArn string, desiredCount int) (string, error) {
+	var args []string
+	args = append(args, "ecs", "update-service", "--output", "json", "--cluster", cluster, "--service", serviceArn, "--desired-count", fmt.Sprintf("%d", desiredCount))
+	args = append(args, "--query", "service.serviceArn")
+	log.Debug(args)
+
+	var resp any
+	stdout, err := execAWS(args, &resp)
+
+	return string(stdout), err
+}
+
+func (c CLI) DeleteService(cluster string, serviceArn string) (string, error) {
+	var args []string
+	args = append(args, "ecs", "delete-service", "--output", "json", "--cluster", cluster, "--service", serviceArn)
+	args = append(args, "--query", "service.serviceArn")
+	log.Debug(args)
+
+	var resp any
+	stdout, err := execAWS(args, &resp)
+
+	return string(stdout), err
+}
+
+// WaitServicesInactive waits until the deleted service
+// is inactive (no more tasks registered in its target group).
+func (c CLI) WaitServicesInactive(cluster string, serviceArn string) error {
+	var args []string
+	args = append(args, "ecs", "wait", "services-inactive", "--cluster", cluster, "--services", serviceArn)
+	log.Debug(args)
+
+	var resp any
+	_, err := execAWS(args, &resp)
+
+	return err
+}
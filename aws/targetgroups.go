@@ -2,10 +2,8 @@ package aws
 
 import (
 	"fmt"
-	"strconv"
 
 	"github.com/charmbracelet/log"
-	"github.com/spf13/viper"
 )
 
 type TargetGroup struct {
@@ -17,20 +15,11 @@ type describeTargetGroupsOutput struct {
 	TargetGroups []TargetGroup
 }
 
-func DescribeTargetGroups() ([]TargetGroup, error) {
+func (c CLI) DescribeTargetGroups() ([]TargetGroup, error) {
 	result := []TargetGroup{}
 	var args []string
 	args = append(args, "elbv2", "describe-target-groups", "--output", "json", "--no-paginate")
 	log.Debug(args)
-	if viper.GetBool("dummy") {
-		sleep(2)
-		for i := 1; i <= 10; i += 1 {
-			arn := "arn:aws:elasticloadbalancing:us-west-2:123456789012:targetgroup/dummy-" + strconv.Itoa(i) + "/73e2d6bc24d8a067"
-			name := "dummy-" + strconv.Itoa(i)
-			result = append(result, TargetGroup{TargetGroupArn: arn, TargetGroupName: name})
-		}
-		return result, nil
-	}
 
 	var resp describeTargetGroupsOutput
 	_, err := execAWS(args, &resp)
@@ -43,7 +32,7 @@ func DescribeTargetGroups() ([]TargetGroup, error) {
 	return result, nil
 }
 
-func DescribeTargetGroupsWithNames(names []string) ([]TargetGroup, error) {
+func (c CLI) DescribeTargetGroupsWithNames(names []string) ([]TargetGroup, error) {
 	result := []TargetGroup{}
 	var args []string
 	args = append(args, "elbv2", "describe-target-groups", "--output", "json", "--no-paginate")
@@ -52,18 +41,6 @@ func DescribeTargetGroupsWithNames(names []string) ([]TargetGroup, error) {
 		args = append(args, names...)
 	}
 	log.Debug(args)
-	if viper.GetBool("dummy") {
-		sleep(2)
-		var err error
-		if len(names) == 1 && names[0] == "my-targets2" {
-			name := names[0]
-			arn := "arn:aws:elasticloadbalancing:us-west-2:123456789012:targetgroup/" + name + "/73e2d6bc24d8a067"
-			result = append(result, TargetGroup{TargetGroupArn: arn, TargetGroupName: name})
-		} else {
-			err = fmt.Errorf("target group not found")
-		}
-		return result, err
-	}
 
 	var resp describeTargetGroupsOutput
 	_, err := execAWS(args, &resp)
@@ -76,18 +53,11 @@ func DescribeTargetGroupsWithNames(names []string) ([]TargetGroup, error) {
 	return result, nil
 }
 
-func CreateTargetGroup(filepath string) (TargetGroup, error) {
+func (c CLI) CreateTargetGroup(filepath string) (TargetGroup, error) {
 	var result TargetGroup
 	var args []string
 	args = append(args, "elbv2", "create-target-group", "--output", "json", "--cli-input-json", fmt.Sprintf("file://%s", filepath))
 	log.Debug(args)
-	if viper.GetBool("dummy") {
-		sleep(1)
-		return TargetGroup{
-			TargetGroupArn:  "arn:aws:elasticloadbalancing:us-west-2:123456789012:targetgroup/dummy-created/73e2d6bc24d8a067",
-			TargetGroupName: "dummy-created",
-		}, nil
-	}
 
 	var resp describeTargetGroupsOutput
 	_, err := execAWS(args, &resp)
@@ -101,3 +71,52 @@ func CreateTargetGroup(filepath string) (TargetGroup, error) {
 
 	return result, nil
 }
+
+// modifyTargetGroupKeys are the keys of a create-target-group
+// file accepted by modify-target-group.
+var modifyTargetGroupKeys = []string{
+	"HealthCheckProtocol", "HealthCheckPort", "HealthCheckPath", "HealthCheckEnabled",
+	"HealthCheckIntervalSeconds", "HealthCheckTimeoutSeconds",
+	"HealthyThresholdCount", "UnhealthyThresholdCount", "Matcher",
+}
+
+// ModifyTargetGroup updates the health check settings
+// of a target group from its creation file.
+func (c CLI) ModifyTargetGroup(targetGroupArn string, filepath string) (string, error) {
+	inputJson, err := inputJsonWithKeys(filepath, modifyTargetGroupKeys)
+	if err != nil {
+		return "", err
+	}
+	var args []string
+	args = append(args, "elbv2", "modify-target-group", "--cli-input-json", inputJson, "--target-group-arn", targetGroupArn)
+	log.Debug(args)
+
+	var resp any
+	stdout, err := execAWS(args, &resp)
+
+	return string(stdout), err
+}
+
+func (c CLI) DeleteTargetGroup(targetGroupArn string) (string, error) {
+	var args []string
+	args = append(args, "elbv2", "delete-target-group", "--target-group-arn", targetGroupArn)
+	log.Debug(args)
+
+	var resp any
+	stdout, err := execAWS(args, &resp)
+
+	return string(stdout), err
+}
+
+// DescribeTargetGroupByArn returns every attribute
+// of a target group (drift detection).
+func (c CLI) DescribeTargetGroupByArn(targetGroupArn string) (map[string]any, error) {
+	var result map[string]any
+	var args []string
+	args = append(args, "elbv2", "describe-target-groups", "--output", "json", "--target-group-arns", targetGroupArn, "--query", "TargetGroups[0]")
+	log.Debug(args)
+
+	_, err := execAWS(args, &result)
+
+	return result, err
+}
@@ -4,7 +4,6 @@ import (
 	"strings"
 
 	"github.com/charmbracelet/log"
-	"github.com/spf13/viper"
 )
 
 type ContainerPortMapping struct {
@@ -101,32 +100,11 @@ type describeTaskDefinitionOutput struct {
 }
 
 // "taskDefinition" argument is the family, family:revision or full ARN
-func DescribeTaskDefinition(taskDefinition string) (TaskDefinition, error) {
+func (c CLI) DescribeTaskDefinition(taskDefinition string) (TaskDefinition, error) {
 	result := TaskDefinition{}
 	var args []string
 	args = append(args, "ecs", "describe-task-definition", "--output", "json", "--no-paginate", "--include", "TAGS", "--task-definition", taskDefinition)
 	log.Debug(args)
-	if viper.GetBool("dummy") {
-		sleep(2)
-		result = TaskDefinition{
-			TaskDefinitionArn: taskDefinition,
-			ContainerDefinitions: []ContainerDefinition{
-				{
-					Name:  "dmz-web",
-					Image: "xxx.dkr.ecr.us-west-2.amazonaws.com/repository-dummy:tag",
-					PortMappings: []PortMapping{
-						{
-							ContainerPort: 8080,
-							HostPort:      0,
-							Name:          "http",
-						},
-					},
-				},
-			},
-			Family: ExtractFamilyFromRevision(taskDefinition),
-		}
-		return result, nil
-	}
 
 	var output describeTaskDefinitionOutput
 	_, err := execAWS(args, &output)
@@ -140,9 +118,9 @@ func DescribeTaskDefinition(taskDefinition string) (TaskDefinition, error) {
 	return result, nil
 }
 
-func ListPortMapping(taskDefinitionArn string) ([]ContainerPortMapping, error) {
+func ListPortMapping(client Client, taskDefinitionArn string) ([]ContainerPortMapping, error) {
 	result := []ContainerPortMapping{}
-	td, err := DescribeTaskDefinition(taskDefinitionArn)
+	td, err := client.DescribeTaskDefinition(taskDefinitionArn)
 	if err != nil {
 		return result, err
 	}
@@ -172,17 +150,11 @@ func ExtractFamilyFromRevision(taskdefArn string) string {
 	return result
 }
 
-func RegisterTaskDefinition(inputJson string) (TaskDefinition, error) {
+func (c CLI) RegisterTaskDefinition(inputJson string) (TaskDefinition, error) {
 	result := TaskDefinition{}
 	var args []string
 	args = append(args, "ecs", "register-task-definition", "--cli-input-json", inputJson)
 	log.Debug(args)
-	if viper.GetBool("dummy") {
-		sleep(1)
-		return TaskDefinition{
-			TaskDefinitionArn: "arn:aws:ecs:us-east-1:053534965804:task-definition/dummy:99",
-		}, nil
-	}
 
 	var output describeTaskDefinitionOutput
 	_, err := execAWS(args, &output)
@@ -194,3 +166,16 @@ func RegisterTaskDefinition(inputJson string) (TaskDefinition, error) {
 
 	return result, err
 }
+
+// DescribeTaskDefinitionByArn returns every attribute
+// of a task definition (import).
+func (c CLI) DescribeTaskDefinitionByArn(taskDefinition string) (map[string]any, error) {
+	var result map[string]any
+	var args []string
+	args = append(args, "ecs", "describe-task-definition", "--output", "json", "--task-definition", taskDefinition, "--query", "taskDefinition")
+	log.Debug(args)
+
+	_, err := execAWS(args, &result)
+
+	return result, err
+}
@@ -0,0 +1,49 @@
+package aws
+
+import (
+	"github.com/charmbracelet/log"
+)
+
+type TaskContainer struct {
+	Name     string `json:"name"`
+	ExitCode *int   `json:"exitCode,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+type Task struct {
+	TaskArn        string          `json:"taskArn"`
+	TaskDefinition string          `json:"taskDefinitionArn"`
+	LastStatus     string          `json:"lastStatus"`
+	StopCode       string          `json:"stopCode,omitempty"`
+	StoppedReason  string          `json:"stoppedReason,omitempty"`
+	StoppedAt      string          `json:"stoppedAt,omitempty"`
+	Containers     []TaskContainer `json:"containers"`
+}
+
+// ListStoppedTasks returns the arns of the tasks
+// of a service that were stopped recently.
+func (c CLI) ListStoppedTasks(cluster string, serviceName string) ([]string, error) {
+	var result []string
+	var args []string
+	args = append(args, "ecs", "list-tasks", "--output", "json", "--cluster", cluster, "--service-name", serviceName, "--desired-status", "STOPPED", "--max-items", "5", "--query", "taskArns")
+	log.Debug(args)
+
+	_, err := execAWS(args, &result)
+
+	return result, err
+}
+
+// DescribeTasks returns the status and, for the stopped ones,
+// the reasons of tasks (max 100).
+func (c CLI) DescribeTasks(cluster string, taskArns []string) ([]Task, error) {
+	var result []Task
+	var args []string
+	args = append(args, "ecs", "describe-tasks", "--output", "json", "--cluster", cluster, "--tasks")
+	args = append(args, taskArns...)
+	args = append(args, "--query", "tasks[*].{taskArn: taskArn, taskDefinitionArn: taskDefinitionArn, lastStatus: lastStatus, stopCode: stopCode, stoppedReason: stoppedReason, stoppedAt: stoppedAt, containers: containers[*].{name: name, exitCode: exitCode, reason: reason}}")
+	log.Debug(args)
+
+	_, err := execAWS(args, &result)
+
+	return result, err
+}
@@ -2,15 +2,19 @@ package aws
 
 import (
 	"encoding/json"
+	"os"
 	"os/exec"
-	"time"
 )
 
+// execAWS runs the aws cli and decodes what it prints into resp.
+// It fails with an Error parsed from stderr when aws does.
 func execAWS[T any](args []string, resp *T) ([]byte, error) {
 	cmd := exec.Command("aws", args...)
+	// the calls are retried by the Retrier (--max-attempts)
+	cmd.Env = append(os.Environ(), "AWS_MAX_ATTEMPTS=1")
 	stdout, err := cmd.Output()
 	if err != nil {
-		return stdout, err
+		return stdout, cliError(err)
 	}
 	if len(stdout) > 0 {
 		err = json.Unmarshal(stdout, resp)
@@ -18,6 +22,24 @@ func execAWS[T any](args []string, resp *T) ([]byte, error) {
 	return stdout, err
 }
 
-func sleep(seconds time.Duration) {
-	time.Sleep(seconds * time.Second)
+// inputJsonWithKeys reads a --cli-input-json file and only
+// keeps the keys accepted by another operation
+// (e.g. a create-* file used for a modify-*).
+func inputJsonWithKeys(filepath string, keys []string) (string, error) {
+	content, err := os.ReadFile(filepath)
+	if err != nil {
+		return "", err
+	}
+	var input map[string]any
+	if err = json.Unmarshal(content, &input); err != nil {
+		return "", err
+	}
+	output := make(map[string]any)
+	for _, key := range keys {
+		if v, ok := input[key]; ok {
+			output[key] = v
+		}
+	}
+	result, err := json.Marshal(output)
+	return string(result), err
 }
@@ -0,0 +1,174 @@
+package progressmodel
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type Status int
+
+const (
+	StatusPending Status = iota
+	StatusRunning
+	StatusDone
+	StatusFailed
+	StatusCanceled
+)
+
+// Row is a task (e.g. a resource to apply).
+type Row struct {
+	Id      string
+	Title   string
+	Status  Status
+	Detail  string
+	Err     error
+	started time.Time
+	elapsed time.Duration
+}
+
+// StartMsg marks a row as running.
+type StartMsg struct {
+	Id string
+}
+
+// DoneMsg marks a row as done (or failed if Err is not nil).
+type DoneMsg struct {
+	Id     string
+	Detail string
+	Err    error
+}
+
+// FinishMsg stops the program. The rows that are still
+// pending are marked as canceled.
+type FinishMsg struct{}
+
+type Model struct {
+	rows    []Row
+	index   map[string]int
+	spinner spinner.Model
+	title   string
+	done    bool
+}
+
+var (
+	titleStyle    = lipgloss.NewStyle().Bold(true)
+	pendingStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+	doneStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("2"))
+	failedStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+	detailStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+	elapsedStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("6"))
+	canceledStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("3"))
+)
+
+func NewModel(title string, rows []Row) Model {
+	index := make(map[string]int)
+	for i, row := range rows {
+		index[row.Id] = i
+	}
+	s := spinner.New()
+	s.Spinner = spinner.MiniDot
+	return Model{
+		rows:    rows,
+		index:   index,
+		spinner: s,
+		title:   title,
+	}
+}
+
+// Rows returns the rows with their last status.
+func (m Model) Rows() []Row {
+	return m.rows
+}
+
+func (m Model) Init() tea.Cmd {
+	return m.spinner.Tick
+}
+
+func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		// no interruption while resources are being applied
+		// except with ctrl+c
+		if msg.String() == "ctrl+c" {
+			m.done = true
+			return m, tea.Quit
+		}
+	case StartMsg:
+		if i, ok := m.index[msg.Id]; ok {
+			m.rows[i].Status = StatusRunning
+			m.rows[i].started = time.Now()
+		}
+	case DoneMsg:
+		if i, ok := m.index[msg.Id]; ok {
+			m.rows[i].Status = StatusDone
+			m.rows[i].Detail = msg.Detail
+			m.rows[i].Err = msg.Err
+			m.rows[i].elapsed = time.Since(m.rows[i].started)
+			if msg.Err != nil {
+				m.rows[i].Status = StatusFailed
+			}
+		}
+	case FinishMsg:
+		for i, row := range m.rows {
+			if row.Status == StatusPending {
+				m.rows[i].Status = StatusCanceled
+			}
+		}
+		m.done = true
+		return m, tea.Quit
+	case spinner.TickMsg:
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
+	}
+	return m, nil
+}
+
+func formatElapsed(d time.Duration) string {
+	return fmt.Sprintf("%5.1fs", d.Seconds())
+}
+
+// RenderRow renders a row as a line of text.
+func (m Model) RenderRow(row Row) string {
+	var icon, elapsed, detail string
+	switch row.Status {
+	case StatusPending:
+		icon = pendingStyle.Render("·")
+		elapsed = pendingStyle.Render(formatElapsed(0))
+	case StatusRunning:
+		icon = m.spinner.View()
+		elapsed = elapsedStyle.Render(formatElapsed(time.Since(row.started)))
+	case StatusDone:
+		icon = doneStyle.Render("✔")
+		elapsed = elapsedStyle.Render(formatElapsed(row.elapsed))
+	case StatusFailed:
+		icon = failedStyle.Render("✘")
+		elapsed = elapsedStyle.Render(formatElapsed(row.elapsed))
+	case StatusCanceled:
+		icon = canceledStyle.Render("-")
+		elapsed = pendingStyle.Render(formatElapsed(0))
+		detail = canceledStyle.Render("canceled")
+	}
+	if row.Err != nil {
+		detail = failedStyle.Render(row.Err.Error())
+	} else if row.Detail != "" {
+		detail = detailStyle.Render(row.Detail)
+	}
+	return fmt.Sprintf("%s %s %s %s", icon, elapsed, row.Title, detail)
+}
+
+func (m Model) View() string {
+	doc := strings.Builder{}
+	if m.title != "" {
+		doc.WriteString(titleStyle.Render(m.title) + "\n")
+	}
+	for _, row := range m.rows {
+		doc.WriteString(m.RenderRow(row) + "\n")
+	}
+	return doc.String()
+}
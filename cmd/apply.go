@@ -4,6 +4,8 @@ Copyright © 2024 demingongo
 package cmd
 
 import (
+	"time"
+
 	"github.com/demingongo/ecx/apps/applyapp"
 	"github.com/demingongo/ecx/globals"
 	"github.com/spf13/cobra"
@@ -17,6 +19,55 @@ var applyCmd = &cobra.Command{
 	Long: `Apply ecx.yaml project file.
 
 The ecx.yaml project file should locate the resources to deploy.
+They are applied in the order of their dependencies ("ref:" values,
+service => task definition => log group), whatever the section
+they are declared in. Resources that do not depend on each other
+are applied concurrently (see --parallelism).
+
+Existing services (cluster and name), listeners (load balancer and port)
+and rules (listener and priority or conditions) that were not created
+by ecx are updated in place. They are not deleted by "ecx destroy".
+
+A flow that creates or updates a service waits for it to be stable:
+its primary deployment is COMPLETED and its running count equals
+its desired count. The apply fails if the deployment FAILED or
+after --wait-timeout, showing the latest events of the service and
+why its tasks stopped. --no-wait does not wait.
+
+"ref:<key>" is the arn of the resource with that key (target group,
+load balancer, listener, flow, task definition or log group) and
+"ref:<key>.<attribute>" one of its attributes, e.g. ref:alb.DNSName,
+ref:tg-app.Port or ref:td-web.TaskDefinitionArn. They resolve in
+ecx.yaml and in the json resource files.
+
+Resource files ending with .tmpl.json (or every file with --render-all)
+are rendered with text/template before being passed to the aws cli:
+	{{ .Var.name }}     variable of the project file
+	{{ .Env.NAME }}     environment variable
+	{{ .Environment }}  environment of the project file (--env)
+	{{ ref "key" }}     arn of a resource ({{ ref "key.attribute" }})
+The rendered files are removed after apply unless --keep-rendered is set.
+
+A part of the project can be applied with --target <kind>:<key>
+(e.g. flow:app-test-flow, listener:http-alb), --only <section>
+(e.g. taskDefinitions) and --exclude <section> (e.g. logGroups).
+The resources the selection depends on are looked up, not applied,
+so their "ref:" values still resolve.
+
+When apply fails, the resources created by the run can be deleted
+(in reverse order) so nothing is left half-deployed. You are asked
+unless --rollback-on-failure is set. Task definition revisions
+and resources that existed before the run are left in place.
+
+The project is locked during apply (see "ecx force-unlock"): another
+apply or destroy of the project fails instead of changing the same
+resources.
+
+Hooks (preApply, postApply, onFailure) of the project, flows and
+listeners run local commands with the arns of the resources and
+the "ref:" values as ECX_* environment variables. A command exiting
+with a non-zero status stops the apply.
+
 For example:
 
 ecx.yaml
@@ -55,9 +106,19 @@ ecx.yaml
 |       - value: rules/rule.json                    |
 +---------------------------------------------------+
 `,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		viper.BindPFlag("project", cmd.Flags().Lookup("project"))
+		bindVariableFlags(cmd)
+		bindRenderFlags(cmd)
+		bindSelectorFlags(cmd)
+		viper.BindPFlag("parallelism", cmd.Flags().Lookup("parallelism"))
+		viper.BindPFlag("rollback-on-failure", cmd.Flags().Lookup("rollback-on-failure"))
+		viper.BindPFlag("no-wait", cmd.Flags().Lookup("no-wait"))
+		viper.BindPFlag("wait-timeout", cmd.Flags().Lookup("wait-timeout"))
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		globals.LoadGlobals()
-		applyapp.Run()
+		applyapp.Run(awsClient())
 	},
 }
 
@@ -74,7 +135,12 @@ func init() {
 	// is called directly, e.g.:
 	// applyCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 	applyCmd.PersistentFlags().StringP("project", "p", "", "path to the directory with ecx.yaml")
+	applyCmd.PersistentFlags().Int("parallelism", 4, "max number of resources applied concurrently")
+	applyCmd.PersistentFlags().Bool("rollback-on-failure", false, "delete the resources created by the run if apply fails")
+	applyCmd.PersistentFlags().Bool("no-wait", false, "do not wait for the services of the flows to be stable")
+	applyCmd.PersistentFlags().Duration("wait-timeout", 10*time.Minute, "max time to wait for a service to be stable")
 	applyCmd.MarkPersistentFlagDirname("project")
-
-	viper.BindPFlag("project", applyCmd.PersistentFlags().Lookup("project"))
+	addVariableFlags(applyCmd)
+	addRenderFlags(applyCmd)
+	addSelectorFlags(applyCmd)
 }
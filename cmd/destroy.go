@@ -0,0 +1,48 @@
+/*
+Copyright © 2024 demingongo
+*/
+package cmd
+
+import (
+	"github.com/demingongo/ecx/apps/destroyapp"
+	"github.com/demingongo/ecx/globals"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// destroyCmd represents the destroy command
+var destroyCmd = &cobra.Command{
+	Use:   "destroy",
+	Short: "Delete the resources created by apply",
+	Long: `Delete the resources created by "ecx apply" for the ecx.yaml project.
+
+The resources are the ones recorded in ecx.state.json
+(ecx.<env>.state.json with --env) and they are deleted in reverse dependency order:
+	services (scaled to 0 then deleted),
+	rules,
+	listeners,
+	load balancers,
+	target groups,
+	log groups.
+
+Resources that already existed before apply (found by name)
+and task definition revisions are left in place.`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		viper.BindPFlag("project", cmd.Flags().Lookup("project"))
+		bindVariableFlags(cmd)
+		viper.BindPFlag("dry-run", cmd.Flags().Lookup("dry-run"))
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		globals.LoadGlobals()
+		destroyapp.Run(awsClient())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(destroyCmd)
+
+	destroyCmd.PersistentFlags().StringP("project", "p", "", "path to the directory with ecx.yaml")
+	destroyCmd.PersistentFlags().Bool("dry-run", false, "only list the resources to delete")
+	destroyCmd.MarkPersistentFlagDirname("project")
+	addVariableFlags(destroyCmd)
+}
@@ -0,0 +1,52 @@
+/*
+Copyright © 2024 demingongo
+*/
+package cmd
+
+import (
+	"github.com/demingongo/ecx/apps/driftapp"
+	"github.com/demingongo/ecx/globals"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// driftCmd represents the drift command
+var driftCmd = &cobra.Command{
+	Use:   "drift",
+	Short: "Detect live changes that differ from the project files",
+	Long: `Describe every resource the ecx.yaml project file references
+and compare the live attributes with the resource files:
+	target groups   port, protocol and health check
+	listeners       port, protocol and default actions
+	rules           priority, conditions and actions
+	services        desired count and task definition
+
+Only the fields set in the resource files are compared.
+A task definition given by family is expected to be at the
+revision last registered by "ecx apply".
+
+Exit codes:
+	0  every resource is in sync
+	1  error
+	2  a resource drifted or is missing
+
+Nothing is created or modified.`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		viper.BindPFlag("project", cmd.Flags().Lookup("project"))
+		bindVariableFlags(cmd)
+		bindRenderFlags(cmd)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		globals.LoadGlobals()
+		driftapp.Run(awsClient())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(driftCmd)
+
+	driftCmd.PersistentFlags().StringP("project", "p", "", "path to the directory with ecx.yaml")
+	driftCmd.MarkPersistentFlagDirname("project")
+	addVariableFlags(driftCmd)
+	addRenderFlags(driftCmd)
+}
@@ -0,0 +1,51 @@
+/*
+Copyright © 2024 demingongo
+*/
+package cmd
+
+import (
+	"github.com/demingongo/ecx/apps/unlockapp"
+	"github.com/demingongo/ecx/globals"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// forceUnlockCmd represents the force-unlock command
+var forceUnlockCmd = &cobra.Command{
+	Use:   "force-unlock <lock id>",
+	Short: "Release the lock of the project",
+	Long: `Release the lock of the project left by an apply or destroy
+that did not end (killed, lost connection, ...).
+
+"ecx apply" and "ecx destroy" take the lock of the project before
+changing anything, so two runs never change the same resources.
+The id of the lock is printed when a run finds the project locked.
+Only use force-unlock when that run is no longer running.
+
+The lock is a file (ecx.lock) in the project directory or,
+to share it, an item of a DynamoDB table:
+
+lock:
+  backend: dynamodb
+  table: ecx-locks               # partition key "LockID" (string)
+  key: my-project                # default: directory name (and /env)
+  region: us-east-1
+  endpoint: http://localhost:8000  # e.g. DynamoDB Local`,
+	Args: cobra.ExactArgs(1),
+	PreRun: func(cmd *cobra.Command, args []string) {
+		viper.BindPFlag("project", cmd.Flags().Lookup("project"))
+		bindVariableFlags(cmd)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		globals.LoadGlobals()
+		unlockapp.Run(awsClient(), args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(forceUnlockCmd)
+
+	forceUnlockCmd.PersistentFlags().StringP("project", "p", "", "path to the directory with ecx.yaml")
+	forceUnlockCmd.MarkPersistentFlagDirname("project")
+	addVariableFlags(forceUnlockCmd)
+}
@@ -0,0 +1,57 @@
+/*
+Copyright © 2024 demingongo
+*/
+package cmd
+
+import (
+	"github.com/demingongo/ecx/apps/importapp"
+	"github.com/demingongo/ecx/globals"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// importCmd represents the import command
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Generate an ecx.yaml project from an existing cluster",
+	Long: `Generate an ecx.yaml project from the services of an existing cluster
+and, with --load-balancer, the listeners and rules of a load balancer.
+
+It describes the services, their task definitions, log groups and
+target groups, the load balancer, its listeners and their rules.
+For each of them, it writes the --cli-input-json file of its create-*
+operation (read-only fields removed) and wires them together in
+ecx.yaml with "ref:" keys.
+
+The imported resources are recorded in ecx.state.json (or in
+ecx.<env>.state.json with --env) so a plan or an apply right after
+the import shows no changes. They are
+never deleted by "ecx destroy".
+
+For example:
+
+ecx import --cluster my-cluster --load-balancer my-alb -p ./my-project`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		viper.BindPFlag("project", cmd.Flags().Lookup("project"))
+		viper.BindPFlag("cluster", cmd.Flags().Lookup("cluster"))
+		viper.BindPFlag("load-balancer", cmd.Flags().Lookup("load-balancer"))
+		viper.BindPFlag("force", cmd.Flags().Lookup("force"))
+		viper.BindPFlag("env", cmd.Flags().Lookup("env"))
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		globals.LoadGlobals()
+		importapp.Run(awsClient())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+
+	importCmd.PersistentFlags().StringP("project", "p", "", "directory where ecx.yaml is written (default: current directory)")
+	importCmd.PersistentFlags().String("cluster", "", "cluster of the services to import")
+	importCmd.PersistentFlags().String("load-balancer", "", "name of the load balancer to import")
+	importCmd.PersistentFlags().Bool("force", false, "overwrite ecx.yaml and the state file")
+	importCmd.PersistentFlags().String("env", "", "environment the resources are recorded for")
+	importCmd.MarkPersistentFlagDirname("project")
+	importCmd.MarkPersistentFlagRequired("cluster")
+}
@@ -0,0 +1,57 @@
+/*
+Copyright © 2024 demingongo
+*/
+package cmd
+
+import (
+	"github.com/demingongo/ecx/apps/migrateapp"
+	"github.com/demingongo/ecx/globals"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// migrateCmd represents the migrate command
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Rewrite the project file in the latest apiVersion",
+	Long: `Rewrite the ecx.yaml project file from apiVersion 0.1 to 0.2.
+
+With apiVersion 0.2, target groups, load balancers, listeners, rules,
+services (flows) and task definitions can hold their body inline
+instead of the path to a json file:
+
+targetGroups:
+  - key: tg-app
+    value:
+      Name: my-targets
+      Protocol: HTTP
+      Port: 80
+      VpcId: vpc-0123456789abcdef0
+
+With --inline, the json files are inlined (references, templates and
+paths with variables are kept). The files themselves are not deleted.
+A resource identified by its file gets a key (or a name for a flow)
+with that file, and the keys of the state files (ecx.state.json and
+the ones of the environments) are renamed for the ones that can't
+(task definitions given as a file only).
+
+The previous ecx.yaml is kept in ecx.yaml.bak.`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		viper.BindPFlag("project", cmd.Flags().Lookup("project"))
+		viper.BindPFlag("inline", cmd.Flags().Lookup("inline"))
+		viper.BindPFlag("dry-run", cmd.Flags().Lookup("dry-run"))
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		globals.LoadGlobals()
+		migrateapp.Run()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+
+	migrateCmd.PersistentFlags().StringP("project", "p", "", "path to the directory with ecx.yaml")
+	migrateCmd.PersistentFlags().Bool("inline", false, "inline the resource files")
+	migrateCmd.PersistentFlags().Bool("dry-run", false, "print the migrated project file instead of writing it")
+	migrateCmd.MarkPersistentFlagDirname("project")
+}
@@ -0,0 +1,56 @@
+/*
+Copyright © 2024 demingongo
+*/
+package cmd
+
+import (
+	"github.com/demingongo/ecx/apps/outputapp"
+	"github.com/demingongo/ecx/globals"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// outputCmd represents the output command
+var outputCmd = &cobra.Command{
+	Use:   "output [name]",
+	Short: "Print the outputs of the last apply",
+	Long: `Print the values of the outputs section of ecx.yaml
+as evaluated by the last "ecx apply" (saved in ecx.state.json,
+or in ecx.<env>.state.json with --env).
+
+outputs:
+  albDns: ref:alb.DNSName
+  targetGroupArn: ref:tg-app.Arn
+  serviceArn: ref:app-test-flow.ServiceArn
+
+An output is a value or a "ref:<key>.<attribute>" of a target group,
+a load balancer (e.g. DNSName, VpcId, Scheme), a listener, a flow
+(ServiceArn, ServiceName, Cluster, TargetGroupArn), a task definition
+(TaskDefinitionArn, Family, Revision) or a log group.
+"ref:<key>" is the arn of the resource.
+
+With a name, only the value of that output is printed.`,
+	Args: cobra.MaximumNArgs(1),
+	PreRun: func(cmd *cobra.Command, args []string) {
+		viper.BindPFlag("project", cmd.Flags().Lookup("project"))
+		viper.BindPFlag("format", cmd.Flags().Lookup("format"))
+		viper.BindPFlag("env", cmd.Flags().Lookup("env"))
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		globals.LoadGlobals()
+		var name string
+		if len(args) > 0 {
+			name = args[0]
+		}
+		outputapp.Run(name)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(outputCmd)
+
+	outputCmd.PersistentFlags().StringP("project", "p", "", "path to the directory with ecx.yaml")
+	outputCmd.PersistentFlags().StringP("format", "f", "json", "output format (json, env or yaml)")
+	outputCmd.PersistentFlags().String("env", "", "environment of the last apply")
+	outputCmd.MarkPersistentFlagDirname("project")
+}
@@ -0,0 +1,43 @@
+/*
+Copyright © 2024 demingongo
+*/
+package cmd
+
+import (
+	"github.com/demingongo/ecx/apps/planapp"
+	"github.com/demingongo/ecx/globals"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// planCmd represents the plan command
+var planCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Show what apply would do",
+	Long: `Show what "ecx apply" would do with the ecx.yaml project file.
+
+It describes every resource the project file references
+and prints, for each of them, if it would be created, updated,
+left as is (no-op) or if it conflicts with the live resources
+(e.g. a rule priority already used on a listener).
+
+Nothing is created or modified.`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		viper.BindPFlag("project", cmd.Flags().Lookup("project"))
+		bindVariableFlags(cmd)
+		bindRenderFlags(cmd)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		globals.LoadGlobals()
+		planapp.Run(awsClient())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(planCmd)
+
+	planCmd.PersistentFlags().StringP("project", "p", "", "path to the directory with ecx.yaml")
+	planCmd.MarkPersistentFlagDirname("project")
+	addVariableFlags(planCmd)
+	addRenderFlags(planCmd)
+}
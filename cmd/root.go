@@ -5,8 +5,10 @@ package cmd
 
 import (
 	"os"
+	"time"
 
 	"github.com/demingongo/ecx/apps/starterapp"
+	"github.com/demingongo/ecx/aws"
 	"github.com/demingongo/ecx/globals"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -26,15 +28,39 @@ var rootCmd = &cobra.Command{
 Using AWS EC2/ECR/ECS?
 Tired of using the slow AWS console?
 
-Here's a helper that uses aws-cli under the hood.`,
+Here's a helper that uses aws-cli under the hood
+(or aws-sdk-go-v2 with --backend sdk).
+
+With --dummy, nothing is sent to aws: a fake account in memory
+(the one of --fixture, a yaml file, or a dummy one with the
+cluster "my-cluster" and the load balancer "my-alb") answers.
+The state of the project (ecx.state.json, or ecx.<env>.state.json
+with --env) is neither read nor written.
+
+With --record <dir>, every aws call (operation, input, output,
+error) is saved in dir, a cassette that --replay <dir> serves
+back without aws (e.g. to reproduce a run).
+
+Throttled and failed (5xx, network) aws calls are retried
+with an exponential backoff, up to --max-attempts calls
+(see the retry section of ecx.yaml).`,
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	Run: func(cmd *cobra.Command, args []string) {
 		globals.LoadGlobals()
-		starterapp.Run()
+		starterapp.Run(awsClient())
 	},
 }
 
+// awsClient returns the aws client of --backend.
+func awsClient() aws.Client {
+	client, err := aws.NewClient(viper.GetString("backend"))
+	if err != nil {
+		globals.Logger.Fatal(err)
+	}
+	return client
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
@@ -56,10 +82,22 @@ func init() {
 	rootCmd.PersistentFlags().BoolP("verbose", "v", false, "verbose output")
 	rootCmd.PersistentFlags().Bool("dummy", false, "dummy run (no aws call)")
 	rootCmd.PersistentFlags().BoolP("colors", "c", false, "colorful forms")
+	rootCmd.PersistentFlags().String("fixture", "", "yaml fixture of the fake account of --dummy")
+	rootCmd.PersistentFlags().String("record", "", "save the aws calls in a cassette (directory)")
+	rootCmd.PersistentFlags().String("replay", "", "serve the aws calls from a cassette (directory) recorded with --record")
+	rootCmd.PersistentFlags().String("backend", aws.BackendCLI, "how aws is called: cli (aws cli v2) or sdk (aws-sdk-go-v2)")
+	rootCmd.PersistentFlags().Int("max-attempts", 5, "max number of calls of a throttled or failed aws operation")
+	rootCmd.PersistentFlags().Duration("max-retry-delay", 20*time.Second, "max delay before an aws call is retried")
 
 	viper.BindPFlag("dummy", rootCmd.PersistentFlags().Lookup("dummy"))
 	viper.BindPFlag("colors", rootCmd.PersistentFlags().Lookup("colors"))
 	viper.BindPFlag("verbose", rootCmd.PersistentFlags().Lookup("verbose"))
+	viper.BindPFlag("fixture", rootCmd.PersistentFlags().Lookup("fixture"))
+	viper.BindPFlag("record", rootCmd.PersistentFlags().Lookup("record"))
+	viper.BindPFlag("replay", rootCmd.PersistentFlags().Lookup("replay"))
+	viper.BindPFlag("backend", rootCmd.PersistentFlags().Lookup("backend"))
+	viper.BindPFlag("max-attempts", rootCmd.PersistentFlags().Lookup("max-attempts"))
+	viper.BindPFlag("max-retry-delay", rootCmd.PersistentFlags().Lookup("max-retry-delay"))
 	viper.SetDefault("dummy", false)
 	viper.SetDefault("verbose", false)
 }
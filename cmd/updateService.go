@@ -22,7 +22,7 @@ It helps you:
 	updating the service with the new revisions.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		globals.LoadGlobals()
-		updateserviceapp.Run()
+		updateserviceapp.Run(awsClient())
 	},
 }
 
@@ -0,0 +1,47 @@
+/*
+Copyright © 2024 demingongo
+*/
+package cmd
+
+import (
+	"github.com/demingongo/ecx/apps/validateapp"
+	"github.com/demingongo/ecx/globals"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// validateCmd represents the validate command
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the project file without calling AWS",
+	Long: `Check the ecx.yaml project file and the resource files it references
+without calling AWS:
+	unknown keys (e.g. misspelled),
+	api and apiVersion,
+	resource files that do not exist or do not parse,
+	"ref:" values (and {{ ref "key" }} in templates) that do not resolve,
+	rule priorities used twice on the same listener,
+	dependency cycles,
+	the "http" port mapping of the services of flows with a target group.
+
+Errors are reported as file:line:column and the command
+exits with a non-zero status if there is any (for CI).`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		viper.BindPFlag("project", cmd.Flags().Lookup("project"))
+		bindVariableFlags(cmd)
+		bindRenderFlags(cmd)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		globals.LoadGlobals()
+		validateapp.Run()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+
+	validateCmd.PersistentFlags().StringP("project", "p", "", "path to the directory with ecx.yaml")
+	validateCmd.MarkPersistentFlagDirname("project")
+	addVariableFlags(validateCmd)
+	addRenderFlags(validateCmd)
+}
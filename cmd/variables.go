@@ -0,0 +1,53 @@
+/*
+Copyright © 2024 demingongo
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// addVariableFlags adds the flags setting the variables
+// of the ecx.yaml project file.
+func addVariableFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().String("env", "", "environment of the project file (environments)")
+	cmd.PersistentFlags().StringArray("var", []string{}, "variable of the project file (name=value)")
+	cmd.PersistentFlags().String("var-file", "", "yaml file with variables of the project file")
+	cmd.MarkPersistentFlagFilename("var-file", "yaml", "yml")
+}
+
+// bindVariableFlags binds the flags added by addVariableFlags.
+// It is called in PreRun as the flags are shared by several commands.
+func bindVariableFlags(cmd *cobra.Command) {
+	viper.BindPFlag("env", cmd.Flags().Lookup("env"))
+	viper.BindPFlag("var", cmd.Flags().Lookup("var"))
+	viper.BindPFlag("var-file", cmd.Flags().Lookup("var-file"))
+}
+
+// addRenderFlags adds the flags of the templated resource files.
+func addRenderFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().Bool("render-all", false, "render every resource file as a template (not only *.tmpl.json)")
+	cmd.PersistentFlags().Bool("keep-rendered", false, "keep the rendered resource files")
+}
+
+// bindRenderFlags binds the flags added by addRenderFlags.
+func bindRenderFlags(cmd *cobra.Command) {
+	viper.BindPFlag("render-all", cmd.Flags().Lookup("render-all"))
+	viper.BindPFlag("keep-rendered", cmd.Flags().Lookup("keep-rendered"))
+}
+
+// addSelectorFlags adds the flags selecting the resources
+// of the project file.
+func addSelectorFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringSlice("target", []string{}, "resource to apply (<kind>:<key>, e.g. flow:app-test-flow)")
+	cmd.PersistentFlags().StringSlice("only", []string{}, "section to apply (e.g. taskDefinitions)")
+	cmd.PersistentFlags().StringSlice("exclude", []string{}, "section not to apply (e.g. logGroups)")
+}
+
+// bindSelectorFlags binds the flags added by addSelectorFlags.
+func bindSelectorFlags(cmd *cobra.Command) {
+	viper.BindPFlag("target", cmd.Flags().Lookup("target"))
+	viper.BindPFlag("only", cmd.Flags().Lookup("only"))
+	viper.BindPFlag("exclude", cmd.Flags().Lookup("exclude"))
+}
@@ -4,9 +4,11 @@ import (
 	"os"
 
 	"github.com/charmbracelet/huh"
+	"github.com/charmbracelet/huh/spinner"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/log"
 	"github.com/spf13/viper"
+	"golang.org/x/term"
 )
 
 var (
@@ -48,3 +50,22 @@ func LoadGlobals() {
 	Logger.SetStyles(styles)
 	Logger.SetLevel(log.GetLevel())
 }
+
+// Spin runs action and returns when it is done, showing a spinner
+// while it runs if the output is a terminal (without one, the spinner
+// would return before the action is done).
+func Spin(t spinner.Type, title string, action func()) {
+	if !term.IsTerminal(int(os.Stdout.Fd())) || !term.IsTerminal(int(os.Stderr.Fd())) {
+		action()
+		return
+	}
+	done := make(chan struct{})
+	_ = spinner.New().Type(t).
+		Title(title).
+		Action(func() {
+			defer close(done)
+			action()
+		}).
+		Run()
+	<-done
+}
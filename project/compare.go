@@ -0,0 +1,48 @@
+package project
+
+import "fmt"
+
+// Contains reports whether every value of want is found in got
+// (both decoded from json). Fields only in got are ignored as AWS
+// returns more than what is needed to create a resource.
+// The order of the items of an array does not matter.
+func Contains(got any, want any) bool {
+	switch w := want.(type) {
+	case map[string]any:
+		g, ok := got.(map[string]any)
+		if !ok {
+			return false
+		}
+		for k, wv := range w {
+			gv, ok := g[k]
+			if !ok || !Contains(gv, wv) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		g, ok := got.([]any)
+		if !ok || len(g) != len(w) {
+			return false
+		}
+		used := make([]bool, len(g))
+		for _, wv := range w {
+			found := false
+			for i, gv := range g {
+				if !used[i] && Contains(gv, wv) {
+					used[i] = true
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	case nil:
+		return got == nil
+	}
+	// numbers can be strings in AWS responses (e.g. priorities)
+	return fmt.Sprint(got) == fmt.Sprint(want)
+}
@@ -0,0 +1,326 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+type LogGroup struct {
+	Key       string `yaml:"key"`
+	Group     string `yaml:"group"`
+	Retention int    `yaml:"retention"`
+}
+
+// Id returns the key of the log group or its name.
+func (lg LogGroup) Id() string {
+	if lg.Key != "" {
+		return lg.Key
+	}
+	return lg.Group
+}
+
+// TaskDefinition is a task definition file (or inline body),
+// given as is or with a key to be referenced:
+//
+//	taskDefinitions:
+//	  - taskdefinitions/app.json
+//	  - key: td-web
+//	    value: taskdefinitions/web.json
+type TaskDefinition struct {
+	Key   string `yaml:"key"`
+	Value string `yaml:"value"`
+}
+
+func (td *TaskDefinition) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		td.Value = n.Value
+		return nil
+	}
+	type plain TaskDefinition
+	return n.Decode((*plain)(td))
+}
+
+// Id returns the key of the task definition or its file.
+func (td TaskDefinition) Id() string {
+	if td.Key != "" {
+		return td.Key
+	}
+	return td.Value
+}
+
+type FlowRule struct {
+	Listener    string `yaml:"listener"`
+	Priority    int    `yaml:"priority"`
+	TargetGroup string `yaml:"targetGroup"`
+	Value       string `yaml:"value"`
+}
+
+type Flow struct {
+	Name                          string     `yaml:"name"`
+	Service                       string     `yaml:"service"`
+	TargetGroup                   string     `yaml:"targetGroup"`
+	HealthCheckGracePeriodSeconds int        `yaml:"healthCheckGracePeriodSeconds"`
+	Rules                         []FlowRule `yaml:"rules"`
+	Hooks                         Hooks      `yaml:"hooks"`
+}
+
+// Id returns the name of the flow or, if it has none,
+// the first file it uses.
+func (f Flow) Id() string {
+	if f.Name != "" {
+		return f.Name
+	}
+	if f.Service != "" {
+		return f.Service
+	}
+	return f.TargetGroup
+}
+
+type LoadBalancer struct {
+	Key   string `yaml:"key"`
+	Value string `yaml:"value"`
+}
+
+// Id returns the key of the load balancer or its file.
+func (lb LoadBalancer) Id() string {
+	if lb.Key != "" {
+		return lb.Key
+	}
+	return lb.Value
+}
+
+type Rule struct {
+	Priority    int    `yaml:"priority"`
+	TargetGroup string `yaml:"targetGroup"`
+	Value       string `yaml:"value"`
+}
+
+type Listener struct {
+	Key          string `yaml:"key"`
+	Value        string `yaml:"value"`
+	LoadBalancer string `yaml:"loadBalancer"`
+	TargetGroup  string `yaml:"targetGroup"`
+	Rules        []Rule `yaml:"rules"`
+	Hooks        Hooks  `yaml:"hooks"`
+}
+
+// Id returns the key of the listener or its file.
+func (l Listener) Id() string {
+	if l.Key != "" {
+		return l.Key
+	}
+	return l.Value
+}
+
+type TargetGroup struct {
+	Key   string `yaml:"key"`
+	Value string `yaml:"value"`
+}
+
+// Id returns the key of the target group or its file.
+func (tg TargetGroup) Id() string {
+	if tg.Key != "" {
+		return tg.Key
+	}
+	return tg.Value
+}
+
+type Config struct {
+	Api             string           `yaml:"api"`
+	ApiVersion      string           `yaml:"apiVersion"`
+	LogGroups       []LogGroup       `yaml:"logGroups"`
+	TaskDefinitions []TaskDefinition `yaml:"taskDefinitions"`
+	Flows           []Flow           `yaml:"flows"`
+	LoadBalancers   []LoadBalancer   `yaml:"loadBalancers"`
+	Listeners       []Listener       `yaml:"listeners"`
+	TargetGroups    []TargetGroup    `yaml:"targetGroups"`
+
+	Variables    map[string]string      `yaml:"variables"`
+	Environments map[string]Environment `yaml:"environments"`
+
+	// other project files merged into this one
+	// and instances of modules (see Module)
+	Include []string `yaml:"include"`
+	Modules []Module `yaml:"modules"`
+
+	// commands run before and after the apply of the project
+	Hooks Hooks `yaml:"hooks"`
+
+	// lock taken by apply and destroy
+	Lock LockConfig `yaml:"lock"`
+
+	// retries of the aws calls
+	// (--max-attempts and --max-retry-delay win)
+	Retry RetryConfig `yaml:"retry"`
+
+	// values exported after apply (ecx output),
+	// e.g. "ref:alb.DNSName"
+	Outputs map[string]string `yaml:"outputs"`
+
+	// Env is the selected environment and Vars the resolved
+	// variables (project, environment, --var-file and --var).
+	Env  string            `yaml:"-"`
+	Vars map[string]string `yaml:"-"`
+
+	// Inline are the inline resource bodies (json)
+	// by "inline:<path>" value.
+	Inline map[string][]byte `yaml:"-"`
+}
+
+// RetryConfig is how the aws calls of the project are retried
+// when they are throttled or fail (5xx, network).
+type RetryConfig struct {
+	MaxAttempts int           `yaml:"maxAttempts"`
+	MaxDelay    time.Duration `yaml:"maxDelay"`
+}
+
+// setDefaults makes the retry config of the project the one
+// of the aws calls, unless it is given on the command line.
+func (r RetryConfig) setDefaults() {
+	if r.MaxAttempts > 0 {
+		viper.SetDefault("max-attempts", r.MaxAttempts)
+	}
+	if r.MaxDelay > 0 {
+		viper.SetDefault("max-retry-delay", r.MaxDelay)
+	}
+}
+
+const (
+	FileName = "ecx.yaml"
+
+	ValidApi = "ecx"
+	// latest apiVersion (0.2 allows inline resource bodies)
+	ValidApiVersion = "0.2"
+)
+
+// ValidApiVersions are the supported values of apiVersion.
+var ValidApiVersions = []string{"0.1", "0.2"}
+
+// Open moves into the project directory (if any)
+// and loads its ecx.yaml file with the variables
+// given on the command line.
+func Open(dir string) (*Config, error) {
+	inputs, err := InputsFromFlags()
+	if err != nil {
+		return nil, err
+	}
+	if dir != "" {
+		if err := os.Chdir(dir); err != nil {
+			return nil, fmt.Errorf("project: %v", err)
+		}
+	}
+	c, err := Load(inputs)
+	if err != nil {
+		return nil, err
+	}
+	c.Retry.setDefaults()
+	return c, nil
+}
+
+// Load reads ecx.yaml from the current directory
+// and interpolates its ${var.name} references.
+func Load(inputs Inputs) (*Config, error) {
+	c, _, err := LoadDocument(inputs)
+	if err != nil {
+		var typeErr *yaml.TypeError
+		if errors.As(err, &typeErr) || c == nil {
+			return nil, fmt.Errorf("Unmarshal: %v", err)
+		}
+		return nil, fmt.Errorf("%s: %v", FileName, err)
+	}
+	return c, nil
+}
+
+// LoadDocument is Load that also returns the yaml document of ecx.yaml
+// (for the positions of its values). On error, the config is what
+// could be decoded and the document is nil if it could not be parsed.
+func LoadDocument(inputs Inputs) (*Config, *yaml.Node, error) {
+	var (
+		c   Config
+		doc yaml.Node
+	)
+
+	yamlFile, err := os.ReadFile(FileName)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = yaml.Unmarshal(yamlFile, &doc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// variables first as they are used everywhere else
+	var header struct {
+		Variables    map[string]string      `yaml:"variables"`
+		Environments map[string]Environment `yaml:"environments"`
+	}
+	if err = doc.Decode(&header); err != nil {
+		return &c, &doc, err
+	}
+	vars, err := resolveVariables(header.Variables, header.Environments, inputs)
+	if err != nil {
+		return &c, &doc, err
+	}
+	if err = interpolateDocument(&doc, vars); err != nil {
+		return &c, &doc, err
+	}
+	if err = expandDocument(&doc, vars); err != nil {
+		return &c, &doc, err
+	}
+
+	c.Env = inputs.Env
+	c.Vars = vars
+	var apiVersion string
+	if len(doc.Content) > 0 {
+		if n := MappingValue(doc.Content[0], "apiVersion"); n != nil {
+			apiVersion = n.Value
+		}
+	}
+	if c.Inline, err = extractInline(&doc, apiVersion); err != nil {
+		return &c, &doc, err
+	}
+	if err = doc.Decode(&c); err != nil {
+		return &c, &doc, err
+	}
+
+	return &c, &doc, nil
+}
+
+// Check validates the header of the project file.
+func (c Config) Check() error {
+	if c.Api != ValidApi {
+		return fmt.Errorf("Value for \"%s\" is not valid. Expected \"%s\".", "api", ValidApi)
+	}
+	if !slices.Contains(ValidApiVersions, c.ApiVersion) {
+		return fmt.Errorf("Value for \"%s\" is not valid. Expected \"%s\".", "apiVersion", strings.Join(ValidApiVersions, "\" or \""))
+	}
+	return nil
+}
+
+// IsRef reports whether the value is a "ref:<key>" reference
+// and returns the key.
+func IsRef(value string) (string, bool) {
+	return strings.CutPrefix(value, "ref:")
+}
+
+// ReadResourceFile reads a resource file (json)
+// to get values like its name.
+func ReadResourceFile(filepath string) (*viper.Viper, error) {
+	content := viper.New()
+	content.SetConfigFile(filepath)
+	err := content.ReadInConfig()
+	return content, err
+}
+
+// RuleId returns the id of the i-th rule
+// of a listener or a flow.
+func RuleId(parentId string, i int) string {
+	return fmt.Sprintf("%s/rules/%d", parentId, i)
+}
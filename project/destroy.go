@@ -0,0 +1,70 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/demingongo/ecx/aws"
+)
+
+// DestroyOrder is the order in which resource kinds
+// are deleted (reverse dependency order).
+var DestroyOrder = []string{
+	KindService,
+	KindRule,
+	KindListener,
+	KindLoadBalancer,
+	KindTargetGroup,
+	KindLogGroup,
+}
+
+// Destroyable returns the resources of the state that can be deleted,
+// sorted in DestroyOrder (task definition revisions and
+// adopted resources are kept).
+func (s *State) Destroyable() []StateResource {
+	var result []StateResource
+	for _, kind := range DestroyOrder {
+		for _, r := range s.Resources {
+			if r.Kind == kind && !r.Adopted {
+				result = append(result, r)
+			}
+		}
+	}
+	return result
+}
+
+// DeleteResource deletes a resource created by apply
+// (nothing to do if it does not exist anymore).
+func DeleteResource(client aws.Client, r StateResource) error {
+	var err error
+	switch r.Kind {
+	case KindService:
+		cluster := r.Attributes["cluster"]
+		// scale to 0 before deleting it
+		if _, err = client.UpdateServiceDesiredCount(cluster, r.Arn, 0); err == nil {
+			_, err = client.DeleteService(cluster, r.Arn)
+		}
+		if err == nil {
+			err = client.WaitServicesInactive(cluster, r.Arn)
+		}
+	case KindRule:
+		_, err = client.DeleteRule(r.Arn)
+	case KindListener:
+		_, err = client.DeleteListener(r.Arn)
+	case KindLoadBalancer:
+		if _, err = client.DeleteLoadBalancer(r.Arn); err == nil {
+			err = client.WaitLoadBalancersDeleted(r.Arn)
+		}
+	case KindTargetGroup:
+		_, err = client.DeleteTargetGroup(r.Arn)
+	case KindLogGroup:
+		_, err = client.DeleteLogGroup(r.Key)
+	default:
+		err = fmt.Errorf("cannot delete a resource of kind \"%s\"", r.Kind)
+	}
+	if errors.Is(err, aws.ErrNotFound) {
+		// already deleted (outside of ecx)
+		return nil
+	}
+	return err
+}
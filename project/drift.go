@@ -0,0 +1,87 @@
+package project
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// DriftFields are the fields of the resource files
+// compared with the live resources by "ecx drift".
+var DriftFields = map[string][]string{
+	KindTargetGroup: {
+		"Protocol", "Port", "HealthCheckProtocol", "HealthCheckPort", "HealthCheckPath",
+		"HealthCheckEnabled", "HealthCheckIntervalSeconds", "HealthCheckTimeoutSeconds",
+		"HealthyThresholdCount", "UnhealthyThresholdCount", "Matcher",
+	},
+	KindListener: {"Port", "Protocol", "SslPolicy", "DefaultActions"},
+	KindRule:     {"Priority", "Conditions", "Actions"},
+	KindService:  {"desiredCount", "taskDefinition"},
+}
+
+// FieldDiff is a field of a resource file
+// that differs from the live resource.
+type FieldDiff struct {
+	Field string
+	Want  any // value of the resource file
+	Got   any // live value (nil if not set)
+}
+
+// Drift compares the fields of a resource file (want) with
+// the live resource (got). Only the fields set by the file are
+// compared, nested objects field by field.
+func Drift(want map[string]any, got map[string]any, fields []string) []FieldDiff {
+	var result []FieldDiff
+	for _, field := range fields {
+		w, ok := want[field]
+		if !ok {
+			continue
+		}
+		result = append(result, drift(field, w, got[field])...)
+	}
+	return result
+}
+
+func drift(path string, want any, got any) []FieldDiff {
+	if w, ok := want.(map[string]any); ok {
+		g, ok := got.(map[string]any)
+		if !ok {
+			return []FieldDiff{{Field: path, Want: want, Got: got}}
+		}
+		keys := make([]string, 0, len(w))
+		for k := range w {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var result []FieldDiff
+		for _, k := range keys {
+			result = append(result, drift(path+"."+k, w[k], g[k])...)
+		}
+		return result
+	}
+	if Contains(got, want) {
+		return nil
+	}
+	return []FieldDiff{{Field: path, Want: want, Got: got}}
+}
+
+// ReadJSONMap reads a json resource file (rendered if needed)
+// as a map.
+func ReadJSONMap(filepath string) (map[string]any, error) {
+	var result map[string]any
+	if err := ReadJSON(filepath, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// FormatValue formats a value of a FieldDiff.
+func FormatValue(v any) string {
+	if v == nil {
+		return "(not set)"
+	}
+	content, err := json.Marshal(v)
+	if err != nil {
+		return "?"
+	}
+	return string(content)
+}
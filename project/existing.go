@@ -0,0 +1,119 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/demingongo/ecx/aws"
+)
+
+// Resources that already exist in AWS but were not created by ecx
+// are looked up so apply can update them in place instead of
+// failing on a duplicate.
+
+// FindListener returns the listener of the load balancer
+// that uses the port of the listener file (empty if none
+// or if the load balancer does not exist).
+func FindListener(client aws.Client, loadBalancerArn string, filepath string) (aws.Listener, error) {
+	var result aws.Listener
+	if loadBalancerArn == "" {
+		return result, nil
+	}
+	content, err := ReadResourceFile(filepath)
+	if err != nil {
+		return result, err
+	}
+	port := content.GetInt("Port")
+	if port <= 0 {
+		return result, nil
+	}
+	listeners, err := client.DescribeListeners(loadBalancerArn)
+	if errors.Is(err, aws.ErrNotFound) {
+		return result, nil
+	}
+	if err != nil {
+		return result, err
+	}
+	for _, l := range listeners {
+		if l.Port == port {
+			return l, nil
+		}
+	}
+	return result, nil
+}
+
+// RulePriority returns the priority of the rule:
+// the one from the project file or the one from the rule file.
+func RulePriority(filepath string, priority int) (int, error) {
+	if priority > 0 {
+		return priority, nil
+	}
+	content, err := ReadResourceFile(filepath)
+	if err != nil {
+		return 0, err
+	}
+	return content.GetInt("Priority"), nil
+}
+
+// FindRule returns the rule of the listener that has the same priority
+// or, if none, the same conditions as the rule file (empty if none
+// or if the listener does not exist).
+func FindRule(client aws.Client, listenerArn string, filepath string, priority int) (aws.Rule, error) {
+	var result aws.Rule
+	if listenerArn == "" {
+		return result, nil
+	}
+	priority, err := RulePriority(filepath, priority)
+	if err != nil {
+		return result, err
+	}
+	var content struct {
+		Conditions []any `json:"Conditions"`
+	}
+	if err = ReadJSON(filepath, &content); err != nil {
+		return result, err
+	}
+	rules, err := client.DescribeRules(listenerArn)
+	if errors.Is(err, aws.ErrNotFound) {
+		return result, nil
+	}
+	if err != nil {
+		return result, err
+	}
+	for _, r := range rules {
+		if !r.IsDefault && priority > 0 && r.Priority == fmt.Sprint(priority) {
+			return r, nil
+		}
+	}
+	if len(content.Conditions) == 0 {
+		return result, nil
+	}
+	for _, r := range rules {
+		if !r.IsDefault && Contains(r.Conditions, content.Conditions) {
+			return r, nil
+		}
+	}
+	return result, nil
+}
+
+// FindService returns the active service with that name
+// in the cluster (empty if none or if the cluster does not exist).
+func FindService(client aws.Client, cluster string, serviceName string) (aws.Service, error) {
+	var result aws.Service
+	if cluster == "" || serviceName == "" {
+		return result, nil
+	}
+	services, err := client.DescribeServices(cluster, serviceName)
+	if errors.Is(err, aws.ErrNotFound) {
+		return result, nil
+	}
+	if err != nil {
+		return result, err
+	}
+	for _, s := range services {
+		if s.ServiceName == serviceName && s.Status == "ACTIVE" {
+			return s, nil
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,351 @@
+package project
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/demingongo/ecx/aws"
+)
+
+// KindFlow is a flow of the project file (target group,
+// rules and/or service). It only exists in the graph.
+const KindFlow = "flow"
+
+// Node is a resource of the project file.
+type Node struct {
+	Kind  string
+	Key   string
+	Index int // index in its section of the project file
+	Deps  []string
+}
+
+// Id returns the unique id "<kind>:<key>" of the node.
+func (n *Node) Id() string {
+	return NodeId(n.Kind, n.Key)
+}
+
+func NodeId(kind string, key string) string {
+	return kind + ":" + key
+}
+
+// Graph is the resource graph of the project file.
+// The edges are taken from "ref:" values and implicit links
+// (service => task definition => log group).
+type Graph struct {
+	Nodes []*Node
+	nodes map[string]*Node
+}
+
+type taskDefinitionContent struct {
+	Family               string `json:"family"`
+	ContainerDefinitions []struct {
+		LogConfiguration struct {
+			LogDriver string            `json:"logDriver"`
+			Options   map[string]string `json:"options"`
+		} `json:"logConfiguration"`
+	} `json:"containerDefinitions"`
+}
+
+type serviceContent struct {
+	TaskDefinition string `json:"taskDefinition"`
+}
+
+// ReadJSON reads a json file into v.
+func ReadJSON(filepath string, v any) error {
+	content, err := os.ReadFile(filepath)
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(content, v); err != nil {
+		return fmt.Errorf("%s: %v", filepath, err)
+	}
+	return nil
+}
+
+// readTemplateJSON reads a resource file (or an inline body) into v.
+// A template is rendered first with "ref:<key>" placeholders
+// as the refs are not resolved yet.
+func readTemplateJSON(c *Config, filepath string, v any) error {
+	if IsInline(filepath) {
+		return json.Unmarshal(c.Inline[filepath], v)
+	}
+	if !IsTemplate(filepath) {
+		return ReadJSON(filepath, v)
+	}
+	content, err := Execute(filepath, c, func(key string) (string, error) {
+		return "ref:" + key, nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %v", filepath, err)
+	}
+	if err = json.Unmarshal(content, v); err != nil {
+		return fmt.Errorf("%s: %v", filepath, err)
+	}
+	return nil
+}
+
+// Node returns the node with that id.
+func (g *Graph) Node(id string) *Node {
+	return g.nodes[id]
+}
+
+func (g *Graph) add(kind string, key string, index int) error {
+	n := &Node{Kind: kind, Key: key, Index: index}
+	if _, ok := g.nodes[n.Id()]; ok {
+		return fmt.Errorf("duplicate %s \"%s\"", kind, key)
+	}
+	g.nodes[n.Id()] = n
+	g.Nodes = append(g.Nodes, n)
+	return nil
+}
+
+// exists reports whether a key is a node of one of the kinds
+// (any kind that can be referenced if none is given)
+// and returns the first one.
+func (g *Graph) exists(key string, kinds ...string) (string, bool) {
+	if len(kinds) == 0 {
+		kinds = RefKinds
+	}
+	for _, kind := range kinds {
+		if _, ok := g.nodes[NodeId(kind, key)]; ok {
+			return NodeId(kind, key), true
+		}
+	}
+	return "", false
+}
+
+// resourceRefs adds an edge for each "ref:" value of the resource
+// files: {{ ref "key" }} of the templates and "ref:" strings.
+func (g *Graph) resourceRefs(c *Config, from string, files ...string) error {
+	var errs []error
+	for _, file := range files {
+		for _, value := range ResourceRefs(c, file) {
+			key, _, _ := ParseRef(value, func(key string) bool {
+				_, ok := g.exists(key)
+				return ok
+			})
+			to, ok := g.exists(key)
+			if !ok {
+				errs = append(errs, fmt.Errorf("%s: %s references unknown key \"%s\"", from, file, key))
+				continue
+			}
+			if to != from {
+				g.link(from, to)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ref adds an edge for a "ref:" value (raw arns are ignored)
+// and returns an error if the reference is dangling.
+func (g *Graph) ref(from string, field string, value string, kind string) error {
+	key, _, ok := ParseRef(value, func(key string) bool {
+		_, ok := g.exists(key, kind)
+		return ok
+	})
+	if !ok {
+		return nil
+	}
+	to, ok := g.exists(key, kind)
+	if !ok {
+		return fmt.Errorf("%s: %s \"%s\" references unknown %s \"%s\"", from, field, value, kind, key)
+	}
+	g.link(from, to)
+	return nil
+}
+
+func (g *Graph) link(from string, to string) {
+	n := g.nodes[from]
+	for _, dep := range n.Deps {
+		if dep == to {
+			return
+		}
+	}
+	n.Deps = append(n.Deps, to)
+}
+
+// BuildGraph turns the project file into a resource graph.
+// Every dangling reference is reported in the returned error.
+func BuildGraph(c *Config) (*Graph, error) {
+	g := &Graph{nodes: make(map[string]*Node)}
+
+	var errs []error
+
+	// nodes
+	for i, targetGroup := range c.TargetGroups {
+		errs = append(errs, g.add(KindTargetGroup, targetGroup.Id(), i))
+	}
+	for i, loadBalancer := range c.LoadBalancers {
+		errs = append(errs, g.add(KindLoadBalancer, loadBalancer.Id(), i))
+	}
+	for i, listener := range c.Listeners {
+		errs = append(errs, g.add(KindListener, listener.Id(), i))
+	}
+	for i, logGroup := range c.LogGroups {
+		errs = append(errs, g.add(KindLogGroup, logGroup.Id(), i))
+	}
+	for i, taskDefinition := range c.TaskDefinitions {
+		errs = append(errs, g.add(KindTaskDefinition, taskDefinition.Id(), i))
+	}
+	for i, flow := range c.Flows {
+		errs = append(errs, g.add(KindFlow, flow.Id(), i))
+	}
+
+	// edges from "ref:" values
+	for _, listener := range c.Listeners {
+		from := NodeId(KindListener, listener.Id())
+		errs = append(errs,
+			g.ref(from, "loadBalancer", listener.LoadBalancer, KindLoadBalancer),
+			g.ref(from, "targetGroup", listener.TargetGroup, KindTargetGroup),
+		)
+		for _, rule := range listener.Rules {
+			errs = append(errs, g.ref(from, "rules.targetGroup", rule.TargetGroup, KindTargetGroup))
+		}
+	}
+	for _, flow := range c.Flows {
+		from := NodeId(KindFlow, flow.Id())
+		errs = append(errs, g.ref(from, "targetGroup", flow.TargetGroup, KindTargetGroup))
+		for _, rule := range flow.Rules {
+			errs = append(errs, g.ref(from, "rules.listener", rule.Listener, KindListener))
+		}
+	}
+
+	// edges from the "ref:" values of the resource files
+	// and {{ ref "key" }} in templates
+	for _, targetGroup := range c.TargetGroups {
+		errs = append(errs, g.resourceRefs(c, NodeId(KindTargetGroup, targetGroup.Id()), targetGroup.Value))
+	}
+	for _, loadBalancer := range c.LoadBalancers {
+		errs = append(errs, g.resourceRefs(c, NodeId(KindLoadBalancer, loadBalancer.Id()), loadBalancer.Value))
+	}
+	for _, listener := range c.Listeners {
+		files := []string{listener.Value}
+		for _, rule := range listener.Rules {
+			files = append(files, rule.Value)
+		}
+		errs = append(errs, g.resourceRefs(c, NodeId(KindListener, listener.Id()), files...))
+	}
+	for _, taskDefinition := range c.TaskDefinitions {
+		errs = append(errs, g.resourceRefs(c, NodeId(KindTaskDefinition, taskDefinition.Id()), taskDefinition.Value))
+	}
+	for _, flow := range c.Flows {
+		files := []string{flow.Service, flow.TargetGroup}
+		for _, rule := range flow.Rules {
+			files = append(files, rule.Value)
+		}
+		errs = append(errs, g.resourceRefs(c, NodeId(KindFlow, flow.Id()), files...))
+	}
+
+	// implicit edges: task definition => log group
+	logGroups := make(map[string]string)
+	for _, logGroup := range c.LogGroups {
+		logGroups[logGroup.Group] = NodeId(KindLogGroup, logGroup.Id())
+	}
+	families := make(map[string]string)
+	for _, taskDefinition := range c.TaskDefinitions {
+		var td taskDefinitionContent
+		if err := readTemplateJSON(c, taskDefinition.Value, &td); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		from := NodeId(KindTaskDefinition, taskDefinition.Id())
+		if td.Family != "" {
+			families[td.Family] = from
+		}
+		for _, cd := range td.ContainerDefinitions {
+			if to, ok := logGroups[cd.LogConfiguration.Options["awslogs-group"]]; ok {
+				g.link(from, to)
+			}
+		}
+	}
+
+	// implicit edges: service => task definition
+	for _, flow := range c.Flows {
+		if flow.Service == "" {
+			continue
+		}
+		var service serviceContent
+		if err := readTemplateJSON(c, flow.Service, &service); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		family := aws.ExtractFamilyFromRevision(service.TaskDefinition)
+		if to, ok := families[family]; ok {
+			g.link(NodeId(KindFlow, flow.Id()), to)
+		}
+	}
+
+	return g, errors.Join(errs...)
+}
+
+// Sort returns the nodes in topological order (dependencies first).
+// Independent nodes keep the order of the project file sections.
+func (g *Graph) Sort() ([]*Node, error) {
+	var (
+		result  []*Node
+		pending = make(map[string]int)
+		parents = make(map[string][]string)
+	)
+	for _, n := range g.Nodes {
+		pending[n.Id()] = len(n.Deps)
+		for _, dep := range n.Deps {
+			parents[dep] = append(parents[dep], n.Id())
+		}
+	}
+
+	done := make(map[string]bool)
+	for len(result) < len(g.Nodes) {
+		progress := false
+		for _, n := range g.Nodes {
+			if done[n.Id()] || pending[n.Id()] > 0 {
+				continue
+			}
+			done[n.Id()] = true
+			result = append(result, n)
+			for _, parent := range parents[n.Id()] {
+				pending[parent]--
+			}
+			progress = true
+			break
+		}
+		if !progress {
+			return result, fmt.Errorf("dependency cycle: %s", strings.Join(g.cycle(done), " => "))
+		}
+	}
+
+	return result, nil
+}
+
+// cycle returns a cycle of the nodes that are not done (each of them
+// depends on another one), e.g. [a b a]: the one reached by following
+// the dependencies from the first of them.
+func (g *Graph) cycle(done map[string]bool) []string {
+	var (
+		id   string
+		path []string
+		seen = make(map[string]int)
+	)
+	for _, n := range g.Nodes {
+		if !done[n.Id()] {
+			id = n.Id()
+			break
+		}
+	}
+	for {
+		if i, ok := seen[id]; ok {
+			return append(path[i:], id)
+		}
+		seen[id] = len(path)
+		path = append(path, id)
+		for _, dep := range g.nodes[id].Deps {
+			if !done[dep] {
+				id = dep
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,416 @@
+package project
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// projectFiles is a project with a resource of every kind:
+// the listener uses the load balancer and the target group,
+// the flow the target group, the listener and (by family)
+// the task definition which logs to the log group.
+var projectFiles = map[string]string{
+	FileName: `api: ecx
+apiVersion: 0.2
+
+targetGroups:
+  - key: tg-app
+    value: tg.json
+
+loadBalancers:
+  - key: alb
+    value: alb.json
+
+listeners:
+  - key: http
+    value: listener.json
+    loadBalancer: ref:alb
+    targetGroup: ref:tg-app
+
+logGroups:
+  - key: logs
+    group: /ecs/app
+
+taskDefinitions:
+  - key: td-web
+    value: td.json
+
+flows:
+  - name: app
+    service: service.json
+    targetGroup: ref:tg-app
+    rules:
+      - value: rule.json
+        priority: 1
+        listener: ref:http
+`,
+	"tg.json":       `{"Name": "app", "Protocol": "HTTP", "Port": 80}`,
+	"alb.json":      `{"Name": "alb"}`,
+	"listener.json": `{"Protocol": "HTTP", "Port": 80}`,
+	"rule.json":     `{"Conditions": []}`,
+	"td.json": `{
+  "family": "web",
+  "containerDefinitions": [{
+    "name": "web",
+    "portMappings": [{"name": "http", "containerPort": 80}],
+    "logConfiguration": {"logDriver": "awslogs", "options": {"awslogs-group": "/ecs/app"}}
+  }]
+}`,
+	"service.json": `{"serviceName": "app", "cluster": "app-cluster", "taskDefinition": "web"}`,
+}
+
+// writeProject writes the files of a project (projectFiles
+// with the given ones, removed if empty) into a temporary
+// directory and loads it from there.
+func writeProject(t *testing.T, files map[string]string) (*Config, *yaml.Node) {
+	t.Helper()
+	chdir(t, t.TempDir())
+	all := maps.Clone(projectFiles)
+	maps.Copy(all, files)
+	for name, content := range all {
+		if content == "" {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(".", name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c, doc, err := LoadDocument(Inputs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, doc
+}
+
+// projectWith returns the project file with replacements
+// (old, new, ...).
+func projectWith(replacements ...string) string {
+	return strings.NewReplacer(replacements...).Replace(projectFiles[FileName])
+}
+
+func nodeIds(nodes []*Node) []string {
+	var result []string
+	for _, n := range nodes {
+		result = append(result, n.Id())
+	}
+	return result
+}
+
+func sortedIds(ids map[string]bool) []string {
+	var result []string
+	for id := range ids {
+		result = append(result, id)
+	}
+	slices.Sort(result)
+	return result
+}
+
+func TestBuildGraph(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr []string
+	}{
+		{
+			name: "valid",
+		},
+		{
+			name: "unknown target group",
+			files: map[string]string{
+				FileName: projectWith("    targetGroup: ref:tg-app\n\nlogGroups", "    targetGroup: ref:tg-nope\n\nlogGroups"),
+			},
+			wantErr: []string{`listener:http: targetGroup "ref:tg-nope" references unknown targetGroup "tg-nope"`},
+		},
+		{
+			name: "unknown listener of a flow",
+			files: map[string]string{
+				FileName: projectWith("listener: ref:http", "listener: ref:https"),
+			},
+			wantErr: []string{`flow:app: rules.listener "ref:https" references unknown listener "https"`},
+		},
+		{
+			name: "ref of the wrong kind",
+			files: map[string]string{
+				FileName: projectWith("loadBalancer: ref:alb", "loadBalancer: ref:tg-app"),
+			},
+			wantErr: []string{`listener:http: loadBalancer "ref:tg-app" references unknown loadBalancer "tg-app"`},
+		},
+		{
+			name: "unknown key in a resource file",
+			files: map[string]string{
+				"service.json": `{"serviceName": "app", "taskDefinition": "ref:td-api.TaskDefinitionArn"}`,
+			},
+			wantErr: []string{`flow:app: service.json references unknown key "td-api"`},
+		},
+		{
+			name: "every dangling ref",
+			files: map[string]string{
+				FileName: projectWith("ref:alb", "ref:alb2", "listener: ref:http", "listener: ref:https"),
+			},
+			wantErr: []string{
+				`references unknown loadBalancer "alb2"`,
+				`references unknown listener "https"`,
+			},
+		},
+		{
+			name: "duplicate key",
+			files: map[string]string{
+				FileName: projectWith("loadBalancers:\n", "loadBalancers:\n  - key: alb\n    value: alb.json\n"),
+			},
+			wantErr: []string{`duplicate loadBalancer "alb"`},
+		},
+		{
+			name: "raw arn",
+			files: map[string]string{
+				FileName: projectWith("loadBalancer: ref:alb", "loadBalancer: arn:aws:elasticloadbalancing:us-west-2:123456789012:loadbalancer/app/alb/50dc6c495c0c9188"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := writeProject(t, tt.files)
+			_, err := BuildGraph(c)
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("no error, want %q", tt.wantErr)
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q, want %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSort(t *testing.T) {
+	// tg-late is tagged with the listener: it comes after it
+	lateTargetGroup := projectWith("loadBalancers:", "  - key: tg-late\n    value: tg-late.json\n\nloadBalancers:")
+	tests := []struct {
+		name    string
+		files   map[string]string
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "sections order",
+			want: []string{
+				"targetGroup:tg-app", "loadBalancer:alb", "listener:http",
+				"logGroup:logs", "taskDefinition:td-web", "flow:app",
+			},
+		},
+		{
+			name: "dependencies first",
+			files: map[string]string{
+				FileName:       lateTargetGroup,
+				"tg-late.json": `{"Name": "late", "Tags": [{"Key": "listener", "Value": "ref:http"}]}`,
+			},
+			want: []string{
+				"targetGroup:tg-app", "loadBalancer:alb", "listener:http", "targetGroup:tg-late",
+				"logGroup:logs", "taskDefinition:td-web", "flow:app",
+			},
+		},
+		{
+			name: "independent target groups",
+			files: map[string]string{
+				FileName:       lateTargetGroup,
+				"tg-late.json": `{"Name": "late"}`,
+			},
+			want: []string{
+				"targetGroup:tg-app", "targetGroup:tg-late", "loadBalancer:alb", "listener:http",
+				"logGroup:logs", "taskDefinition:td-web", "flow:app",
+			},
+		},
+		{
+			name: "cycle",
+			files: map[string]string{
+				FileName:       strings.Replace(lateTargetGroup, "targetGroup: ref:tg-app\n\nlogGroups", "targetGroup: ref:tg-late\n\nlogGroups", 1),
+				"tg-late.json": `{"Name": "late", "Tags": [{"Key": "listener", "Value": "ref:http"}]}`,
+			},
+			wantErr: "dependency cycle: targetGroup:tg-late => listener:http => targetGroup:tg-late",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := writeProject(t, tt.files)
+			g, err := BuildGraph(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			nodes, err := g.Sort()
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := nodeIds(nodes); !slices.Equal(got, tt.want) {
+				t.Errorf("order:\n got %v\nwant %v", got, tt.want)
+			}
+			// the same order every time
+			for range 5 {
+				again, _ := g.Sort()
+				if got := nodeIds(again); !slices.Equal(got, tt.want) {
+					t.Fatalf("order changed:\n got %v\nwant %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSelect(t *testing.T) {
+	all := []string{
+		"flow:app", "listener:http", "loadBalancer:alb",
+		"logGroup:logs", "targetGroup:tg-app", "taskDefinition:td-web",
+	}
+	tests := []struct {
+		name     string
+		selector Selector
+		want     []string
+		wantErr  string
+	}{
+		{name: "everything", want: all},
+		{
+			name:     "target",
+			selector: Selector{Targets: []string{"flow:app"}},
+			want:     []string{"flow:app"},
+		},
+		{
+			name:     "targets",
+			selector: Selector{Targets: []string{"flow:app", "logGroup:logs"}},
+			want:     []string{"flow:app", "logGroup:logs"},
+		},
+		{
+			name:     "only",
+			selector: Selector{Only: []string{"taskDefinitions", "logGroups"}},
+			want:     []string{"logGroup:logs", "taskDefinition:td-web"},
+		},
+		{
+			name:     "target and only add up",
+			selector: Selector{Targets: []string{"flow:app"}, Only: []string{"logGroups"}},
+			want:     []string{"flow:app", "logGroup:logs"},
+		},
+		{
+			name:     "exclude",
+			selector: Selector{Exclude: []string{"flows", "logGroups"}},
+			want:     []string{"listener:http", "loadBalancer:alb", "targetGroup:tg-app", "taskDefinition:td-web"},
+		},
+		{
+			name:     "exclude wins over target",
+			selector: Selector{Targets: []string{"flow:app", "listener:http"}, Exclude: []string{"flows"}},
+			want:     []string{"listener:http"},
+		},
+		{
+			name:     "exclude wins over only",
+			selector: Selector{Only: []string{"listeners", "flows"}, Exclude: []string{"listeners"}},
+			want:     []string{"flow:app"},
+		},
+		{
+			name:     "target without kind",
+			selector: Selector{Targets: []string{"app"}},
+			wantErr:  `target: "app" is not valid. Expected "<kind>:<key>".`,
+		},
+		{
+			name:     "target of an unknown kind",
+			selector: Selector{Targets: []string{"service:app"}},
+			wantErr:  `target: unknown kind "service"`,
+		},
+		{
+			name:     "unknown target",
+			selector: Selector{Targets: []string{"flow:api"}},
+			wantErr:  `target: no flow "api" in ecx.yaml`,
+		},
+		{
+			name:     "unknown section",
+			selector: Selector{Only: []string{"flow"}},
+			wantErr:  `only: unknown section "flow"`,
+		},
+		{
+			name:     "unknown excluded section",
+			selector: Selector{Exclude: []string{"services"}},
+			wantErr:  `exclude: unknown section "services"`,
+		},
+	}
+	c, _ := writeProject(t, nil)
+	g, err := BuildGraph(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selected, err := g.Select(tt.selector)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := sortedIds(selected); !slices.Equal(got, tt.want) {
+				t.Errorf("selected:\n got %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDependencies(t *testing.T) {
+	tests := []struct {
+		name string
+		ids  []string
+		want []string
+	}{
+		{
+			// the load balancer of the listener
+			// and the log group of the task definition
+			name: "transitive",
+			ids:  []string{"flow:app"},
+			want: []string{"listener:http", "loadBalancer:alb", "logGroup:logs", "targetGroup:tg-app", "taskDefinition:td-web"},
+		},
+		{
+			name: "selected ones are not dependencies",
+			ids:  []string{"flow:app", "listener:http", "logGroup:logs"},
+			want: []string{"loadBalancer:alb", "targetGroup:tg-app", "taskDefinition:td-web"},
+		},
+		{
+			name: "direct",
+			ids:  []string{"listener:http"},
+			want: []string{"loadBalancer:alb", "targetGroup:tg-app"},
+		},
+		{
+			name: "none",
+			ids:  []string{"logGroup:logs", "targetGroup:tg-app"},
+		},
+	}
+	c, _ := writeProject(t, nil)
+	g, err := BuildGraph(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids := make(map[string]bool)
+			for _, id := range tt.ids {
+				ids[id] = true
+			}
+			if got := sortedIds(g.Dependencies(ids)); !slices.Equal(got, tt.want) {
+				t.Errorf("dependencies:\n got %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package project
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Hooks are local commands run around the apply of a flow,
+// a listener or the whole project (hooks: in ecx.yaml).
+// Commands are run by "sh -c" from the project directory.
+type Hooks struct {
+	// before the resource is applied
+	PreApply []string `yaml:"preApply"`
+	// after the resource is applied
+	PostApply []string `yaml:"postApply"`
+	// when the resource (or one of its hooks) failed
+	OnFailure []string `yaml:"onFailure"`
+}
+
+// hook names (ECX_HOOK)
+const (
+	HookPreApply  = "preApply"
+	HookPostApply = "postApply"
+	HookOnFailure = "onFailure"
+)
+
+// Commands returns the commands of a hook.
+func (h Hooks) Commands(hook string) []string {
+	switch hook {
+	case HookPreApply:
+		return h.PreApply
+	case HookPostApply:
+		return h.PostApply
+	case HookOnFailure:
+		return h.OnFailure
+	}
+	return nil
+}
+
+// IsEmpty reports whether there are no hooks.
+func (h Hooks) IsEmpty() bool {
+	return len(h.PreApply) == 0 && len(h.PostApply) == 0 && len(h.OnFailure) == 0
+}
+
+var envNameInvalid = regexp.MustCompile(`[^A-Z0-9_]+`)
+
+// EnvName returns the name of an environment variable
+// for a key, e.g. ("ECX_REF", "orders/tg") => "ECX_REF_ORDERS_TG".
+func EnvName(prefix string, key string) string {
+	name := envNameInvalid.ReplaceAllString(strings.ToUpper(key), "_")
+	return prefix + "_" + strings.Trim(name, "_")
+}
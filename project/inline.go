@@ -0,0 +1,128 @@
+package project
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// InlinePrefix marks the values of ecx.yaml that held an inline
+// resource body (apiVersion 0.2), e.g. "inline:targetGroups[0].value".
+// The body is in Config.Inline and is written to a file when needed.
+const InlinePrefix = "inline:"
+
+// IsInline reports whether the value is an inline resource body.
+func IsInline(value string) bool {
+	return strings.HasPrefix(value, InlinePrefix)
+}
+
+// ResourceValue is a value of ecx.yaml that is a resource file
+// (or an inline body since apiVersion 0.2).
+type ResourceValue struct {
+	Path string
+	Node *yaml.Node
+}
+
+// MappingValue returns the value of a key of a yaml mapping
+// (nil if there is none or n is not a mapping).
+func MappingValue(n *yaml.Node, key string) *yaml.Node {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// Sequence returns the items of a yaml sequence
+// (nil if n is not a sequence).
+func Sequence(n *yaml.Node) []*yaml.Node {
+	if n == nil || n.Kind != yaml.SequenceNode {
+		return nil
+	}
+	return n.Content
+}
+
+// ResourceValues returns the values of the document
+// that are resource files or inline bodies.
+func ResourceValues(doc *yaml.Node) []ResourceValue {
+	var result []ResourceValue
+	root := doc
+	if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		root = root.Content[0]
+	}
+	add := func(path string, n *yaml.Node) {
+		if n != nil {
+			result = append(result, ResourceValue{Path: path, Node: n})
+		}
+	}
+	for _, section := range []string{"targetGroups", "loadBalancers"} {
+		for i, item := range Sequence(MappingValue(root, section)) {
+			add(fmt.Sprintf("%s[%d].value", section, i), MappingValue(item, "value"))
+		}
+	}
+	for i, listener := range Sequence(MappingValue(root, "listeners")) {
+		add(fmt.Sprintf("listeners[%d].value", i), MappingValue(listener, "value"))
+		for j, rule := range Sequence(MappingValue(listener, "rules")) {
+			add(fmt.Sprintf("listeners[%d].rules[%d].value", i, j), MappingValue(rule, "value"))
+		}
+	}
+	for i, taskDefinition := range Sequence(MappingValue(root, "taskDefinitions")) {
+		if value := MappingValue(taskDefinition, "value"); value != nil {
+			// key and value
+			add(fmt.Sprintf("taskDefinitions[%d].value", i), value)
+		} else {
+			add(fmt.Sprintf("taskDefinitions[%d]", i), taskDefinition)
+		}
+	}
+	for i, flow := range Sequence(MappingValue(root, "flows")) {
+		add(fmt.Sprintf("flows[%d].service", i), MappingValue(flow, "service"))
+		add(fmt.Sprintf("flows[%d].targetGroup", i), MappingValue(flow, "targetGroup"))
+		for j, rule := range Sequence(MappingValue(flow, "rules")) {
+			add(fmt.Sprintf("flows[%d].rules[%d].value", i, j), MappingValue(rule, "value"))
+		}
+	}
+	return result
+}
+
+// extractInline replaces the inline bodies of the document with
+// "inline:<path>" values and returns the bodies as json.
+func extractInline(doc *yaml.Node, apiVersion string) (map[string][]byte, error) {
+	result := make(map[string][]byte)
+	for _, rv := range ResourceValues(doc) {
+		n := rv.Node
+		if n.Kind != yaml.MappingNode {
+			continue
+		}
+		if apiVersion == "0.1" {
+			return result, &PosError{
+				Line:   n.Line,
+				Column: n.Column,
+				Err:    fmt.Errorf("%s: inline resource bodies need apiVersion 0.2", rv.Path),
+			}
+		}
+		var body map[string]any
+		if err := n.Decode(&body); err != nil {
+			return result, &PosError{Line: n.Line, Column: n.Column, Err: err}
+		}
+		content, err := json.Marshal(body)
+		if err != nil {
+			return result, &PosError{Line: n.Line, Column: n.Column, Err: err}
+		}
+		marker := InlinePrefix + rv.Path
+		result[marker] = content
+		*n = yaml.Node{
+			Kind:   yaml.ScalarNode,
+			Tag:    "!!str",
+			Value:  marker,
+			Line:   n.Line,
+			Column: n.Column,
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,9 @@
+package project
+
+// ListenerHash returns the hash recorded by apply for a listener.
+// loadBalancerArn is the arn of its "loadBalancer:" field, empty
+// when the listener file sets LoadBalancerArn itself (the file
+// is hashed already).
+func ListenerHash(listenerFile string, loadBalancerArn string, targetGroupArn string) (string, error) {
+	return Hash(listenerFile, loadBalancerArn, targetGroupArn)
+}
@@ -0,0 +1,206 @@
+package project
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+
+	"github.com/demingongo/ecx/aws"
+)
+
+const LockFileName = "ecx.lock"
+
+// lock backends (lock.backend in ecx.yaml)
+const (
+	LockBackendLocal    = "local"
+	LockBackendDynamoDB = "dynamodb"
+)
+
+// LockConfig is the lock: section of ecx.yaml.
+type LockConfig struct {
+	// local (default) or dynamodb
+	Backend string `yaml:"backend"`
+	// id of the project in a shared backend
+	// (default: name of the project directory and environment)
+	Key string `yaml:"key"`
+
+	// dynamodb: table with a "LockID" string partition key,
+	// region and endpoint (e.g. http://localhost:8000 for DynamoDB Local)
+	Table    string `yaml:"table"`
+	Region   string `yaml:"region"`
+	Endpoint string `yaml:"endpoint"`
+}
+
+// LockInfo is who holds the lock of a project.
+type LockInfo struct {
+	ID        string    `json:"id"`
+	Operation string    `json:"operation"`
+	Holder    string    `json:"holder"`
+	Host      string    `json:"host"`
+	Pid       int       `json:"pid"`
+	Created   time.Time `json:"created"`
+}
+
+func (info LockInfo) String() string {
+	return fmt.Sprintf("%s by %s@%s (pid %d) since %s, lock id %s",
+		info.Operation, info.Holder, info.Host, info.Pid, info.Created.Local().Format(time.RFC3339), info.ID)
+}
+
+// LockedError is returned when the lock is held by another run.
+type LockedError struct {
+	Info LockInfo
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("the project is locked: %s\n"+
+		"If that run is no longer running, use \"ecx force-unlock %s\".", e.Info, e.Info.ID)
+}
+
+// ErrNotLocked is returned by ForceUnlock when there is no lock.
+var ErrNotLocked = errors.New("the project is not locked")
+
+// LockBackend stores the lock of a project.
+type LockBackend interface {
+	// Lock takes the lock or returns a *LockedError
+	// with the info of the holder.
+	Lock(info LockInfo) error
+	// Unlock releases the lock if it is still the one with that id.
+	Unlock(id string) error
+	// Info returns the holder of the lock (nil if not locked).
+	Info() (*LockInfo, error)
+	// ForceUnlock releases the lock whoever holds it.
+	ForceUnlock() error
+}
+
+// NewLockInfo returns the info of a lock taken by this process.
+func NewLockInfo(operation string) LockInfo {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	info := LockInfo{
+		ID:        hex.EncodeToString(id),
+		Operation: operation,
+		Pid:       os.Getpid(),
+		Created:   time.Now().UTC(),
+	}
+	if u, err := user.Current(); err == nil {
+		info.Holder = u.Username
+	}
+	info.Host, _ = os.Hostname()
+	return info
+}
+
+// LockBackend returns the lock backend of the project
+// (the client is for the dynamodb backend).
+func (c Config) LockBackend(client aws.Client) (LockBackend, error) {
+	key := c.Lock.Key
+	if key == "" {
+		dir, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+		key = filepath.Base(dir)
+		if c.Env != "" {
+			key += "/" + c.Env
+		}
+	}
+	switch c.Lock.Backend {
+	case "", LockBackendLocal:
+		return &localLock{path: LockFileName}, nil
+	case LockBackendDynamoDB:
+		if c.Lock.Table == "" {
+			return nil, fmt.Errorf("lock: table is required with the %s backend", LockBackendDynamoDB)
+		}
+		return &dynamoDBLock{client: client, config: c.Lock, key: key}, nil
+	}
+	return nil, fmt.Errorf("lock: unknown backend \"%s\" (expected %s or %s)", c.Lock.Backend, LockBackendLocal, LockBackendDynamoDB)
+}
+
+// LockProject takes the lock of the project for an operation
+// (e.g. "apply") and returns the function releasing it.
+func LockProject(client aws.Client, c *Config, operation string) (func() error, error) {
+	backend, err := c.LockBackend(client)
+	if err != nil {
+		return nil, err
+	}
+	info := NewLockInfo(operation)
+	if err = backend.Lock(info); err != nil {
+		return nil, err
+	}
+	return func() error {
+		return backend.Unlock(info.ID)
+	}, nil
+}
+
+// localLock is a lock file in the project directory.
+type localLock struct {
+	path string
+}
+
+func (l *localLock) Lock(info LockInfo) error {
+	content, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if errors.Is(err, fs.ErrExist) {
+		holder, err := l.Info()
+		if err != nil {
+			return err
+		}
+		if holder == nil {
+			// released in the meantime
+			return l.Lock(info)
+		}
+		return &LockedError{Info: *holder}
+	}
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(content); err != nil {
+		f.Close()
+		os.Remove(l.path)
+		return err
+	}
+	return f.Close()
+}
+
+func (l *localLock) Unlock(id string) error {
+	holder, err := l.Info()
+	if err != nil || holder == nil {
+		return err
+	}
+	if holder.ID != id {
+		return fmt.Errorf("%s: the lock is held by another run (%s)", l.path, holder)
+	}
+	return os.Remove(l.path)
+}
+
+func (l *localLock) Info() (*LockInfo, error) {
+	content, err := os.ReadFile(l.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var info LockInfo
+	if err = json.Unmarshal(content, &info); err != nil {
+		return nil, fmt.Errorf("%s: %v", l.path, err)
+	}
+	return &info, nil
+}
+
+func (l *localLock) ForceUnlock() error {
+	err := os.Remove(l.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotLocked
+	}
+	return err
+}
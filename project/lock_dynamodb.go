@@ -0,0 +1,92 @@
+package project
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/demingongo/ecx/aws"
+)
+
+// dynamoDBLock is an item of a DynamoDB table
+// shared by the people applying the project.
+type dynamoDBLock struct {
+	client aws.Client
+	config LockConfig
+	key    string
+}
+
+func (l *dynamoDBLock) table() aws.LockTable {
+	return aws.LockTable{
+		Name:     l.config.Table,
+		Region:   l.config.Region,
+		Endpoint: l.config.Endpoint,
+	}
+}
+
+func (l *dynamoDBLock) Lock(info LockInfo) error {
+	content, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	err = l.client.PutLockItem(l.table(), l.key, info.ID, string(content))
+	if errors.Is(err, aws.ErrConditionalCheckFailed) {
+		holder, err := l.Info()
+		if err != nil {
+			return err
+		}
+		if holder == nil {
+			// released in the meantime
+			return l.Lock(info)
+		}
+		return &LockedError{Info: *holder}
+	}
+	if err != nil {
+		return fmt.Errorf("lock %s: %v", l.key, err)
+	}
+	return nil
+}
+
+func (l *dynamoDBLock) Unlock(id string) error {
+	err := l.client.DeleteLockItem(l.table(), l.key, id)
+	if errors.Is(err, aws.ErrConditionalCheckFailed) {
+		holder, _ := l.Info()
+		if holder == nil {
+			return nil
+		}
+		return fmt.Errorf("lock %s: the lock is held by another run (%s)", l.key, holder)
+	}
+	if err != nil {
+		return fmt.Errorf("unlock %s: %v", l.key, err)
+	}
+	return nil
+}
+
+func (l *dynamoDBLock) Info() (*LockInfo, error) {
+	content, err := l.client.GetLockItem(l.table(), l.key)
+	if err != nil {
+		return nil, fmt.Errorf("lock %s: %v", l.key, err)
+	}
+	if content == "" {
+		return nil, nil
+	}
+	var info LockInfo
+	if err = json.Unmarshal([]byte(content), &info); err != nil {
+		return nil, fmt.Errorf("lock %s: %v", l.key, err)
+	}
+	return &info, nil
+}
+
+func (l *dynamoDBLock) ForceUnlock() error {
+	holder, err := l.Info()
+	if err != nil {
+		return err
+	}
+	if holder == nil {
+		return ErrNotLocked
+	}
+	if err = l.client.DeleteLockItem(l.table(), l.key, ""); err != nil {
+		return fmt.Errorf("unlock %s: %v", l.key, err)
+	}
+	return nil
+}
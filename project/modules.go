@@ -0,0 +1,335 @@
+package project
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ModuleFileName is the project file of a module directory.
+const ModuleFileName = "module.yaml"
+
+// Module is an instance of a module (modules: in ecx.yaml).
+// The keys of the resources of the module are namespaced
+// with the name of the instance: "<name>/<key>".
+type Module struct {
+	Name   string            `yaml:"name"`
+	Source string            `yaml:"source"`
+	Inputs map[string]string `yaml:"inputs"`
+}
+
+// ModuleInput is an input declared by a module.
+// An input without default is required.
+type ModuleInput struct {
+	Description string  `yaml:"description"`
+	Default     *string `yaml:"default"`
+}
+
+// the sections of the included files and modules
+// merged into the project file
+var resourceSections = []string{"targetGroups", "loadBalancers", "listeners", "logGroups", "taskDefinitions", "flows"}
+
+// input reference: ${input.name}
+var inputPattern = regexp.MustCompile(`\$\{input\.([A-Za-z0-9_-]+)\}`)
+
+var moduleNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// readDocument reads a yaml file and returns its root mapping.
+func readDocument(path string) (*yaml.Node, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err = yaml.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("line %d: expected a mapping", root.Line)
+	}
+	return root, nil
+}
+
+// checkKeys returns an error for the keys that are not allowed.
+func checkKeys(root *yaml.Node, allowed []string, where string) error {
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key := root.Content[i]
+		if !slices.Contains(allowed, key.Value) {
+			return &PosError{
+				Line:   key.Line,
+				Column: key.Column,
+				Err:    fmt.Errorf("\"%s\" is not allowed in %s", key.Value, where),
+			}
+		}
+	}
+	return nil
+}
+
+// setPosition moves every node to a position of ecx.yaml
+// so the errors point at the include or the module.
+func setPosition(n *yaml.Node, line int, column int) {
+	n.Line = line
+	n.Column = column
+	for _, child := range n.Content {
+		setPosition(child, line, column)
+	}
+}
+
+// rebase makes the resource files of an included file
+// or a module relative to the project directory.
+func rebase(root *yaml.Node, dir string) {
+	for _, rv := range ResourceValues(root) {
+		n := rv.Node
+		if n.Kind != yaml.ScalarNode || n.Value == "" || filepath.IsAbs(n.Value) {
+			continue
+		}
+		if _, ok := IsRef(n.Value); ok {
+			continue
+		}
+		n.Value = filepath.Join(dir, n.Value)
+	}
+}
+
+// merge appends the sections of from to the sections of root.
+func merge(root *yaml.Node, from *yaml.Node) {
+	for _, section := range resourceSections {
+		src := MappingValue(from, section)
+		if src == nil || src.Kind != yaml.SequenceNode || len(src.Content) == 0 {
+			continue
+		}
+		dst := MappingValue(root, section)
+		if dst == nil {
+			root.Content = append(root.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: section},
+				&yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"},
+			)
+			dst = root.Content[len(root.Content)-1]
+		}
+		if dst.Kind != yaml.SequenceNode {
+			// "flows:" without items
+			*dst = yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Line: dst.Line, Column: dst.Column}
+		}
+		dst.Content = append(dst.Content, src.Content...)
+	}
+}
+
+// removeKey removes a key of a mapping.
+func removeKey(n *yaml.Node, key string) {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			n.Content = append(n.Content[:i], n.Content[i+2:]...)
+			return
+		}
+	}
+}
+
+// setKey sets the value of a key of a mapping.
+func setKey(n *yaml.Node, key string, value string) {
+	if v := MappingValue(n, key); v != nil {
+		v.Kind = yaml.ScalarNode
+		v.Tag = "!!str"
+		v.Value = value
+		return
+	}
+	n.Content = append(n.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value},
+	)
+}
+
+// namespace prefixes the keys (and flow names) of the resources
+// of a module with the name of the instance, and the "ref:" values
+// to them. Resources without a key get one.
+func namespace(root *yaml.Node, name string) {
+	renamed := make(map[string]string)
+	for _, section := range resourceSections {
+		field := "key"
+		if section == "flows" {
+			field = "name"
+		}
+		for i, item := range Sequence(MappingValue(root, section)) {
+			if item.Kind != yaml.MappingNode {
+				continue
+			}
+			key := ""
+			if n := MappingValue(item, field); n != nil {
+				key = n.Value
+			}
+			if key == "" && (section == "logGroups" || section == "taskDefinitions") {
+				// referenced by their group or file
+				continue
+			}
+			if key == "" {
+				setKey(item, field, fmt.Sprintf("%s/%s/%d", name, section, i))
+				continue
+			}
+			renamed[key] = name + "/" + key
+			setKey(item, field, renamed[key])
+		}
+	}
+
+	var walk func(n *yaml.Node)
+	walk = func(n *yaml.Node) {
+		if n.Kind == yaml.ScalarNode {
+			ref, ok := IsRef(n.Value)
+			if !ok {
+				return
+			}
+			if key, found := renamed[ref]; found {
+				n.Value = "ref:" + key
+			} else if key, attribute, _ := SplitRef(n.Value); renamed[key] != "" && attribute != "" {
+				n.Value = "ref:" + renamed[key] + "." + attribute
+			}
+			return
+		}
+		for _, child := range n.Content {
+			walk(child)
+		}
+	}
+	walk(root)
+}
+
+// moduleInputs returns the values of the inputs of an instance:
+// the ones given, else the defaults.
+func moduleInputs(declared map[string]ModuleInput, given map[string]string) (map[string]string, error) {
+	result := make(map[string]string)
+	var names []string
+	for name := range declared {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for name := range given {
+		if _, ok := declared[name]; !ok {
+			return nil, fmt.Errorf("unknown input \"%s\" (expected one of %s)", name, strings.Join(names, ", "))
+		}
+	}
+	var missing []string
+	for _, name := range names {
+		if v, ok := given[name]; ok {
+			result[name] = v
+		} else if declared[name].Default != nil {
+			result[name] = *declared[name].Default
+		} else {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing input(s) %s", strings.Join(missing, ", "))
+	}
+	return result, nil
+}
+
+// instantiate reads the module of an instance and returns
+// its resources, namespaced and relative to the project directory.
+func instantiate(entry *yaml.Node, base string, vars map[string]string) (*yaml.Node, error) {
+	var m Module
+	if err := entry.Decode(&m); err != nil {
+		return nil, err
+	}
+	if !moduleNamePattern.MatchString(m.Name) {
+		return nil, fmt.Errorf("module name \"%s\" is not valid (letters, digits, \"-\" and \"_\")", m.Name)
+	}
+	if m.Source == "" {
+		return nil, fmt.Errorf("module %s: source is required", m.Name)
+	}
+	dir := filepath.Join(base, m.Source)
+	path := filepath.Join(dir, ModuleFileName)
+	root, err := readDocument(path)
+	if err != nil {
+		return nil, fmt.Errorf("module %s: %v", m.Name, err)
+	}
+	if err = checkKeys(root, append([]string{"inputs"}, resourceSections...), "a module"); err != nil {
+		return nil, fmt.Errorf("module %s: %s: %v", m.Name, path, err)
+	}
+
+	declared := make(map[string]ModuleInput)
+	if n := MappingValue(root, "inputs"); n != nil {
+		if err = n.Decode(&declared); err != nil {
+			return nil, fmt.Errorf("module %s: %s: %v", m.Name, path, err)
+		}
+	}
+	removeKey(root, "inputs")
+	inputs, err := moduleInputs(declared, m.Inputs)
+	if err != nil {
+		return nil, fmt.Errorf("module %s: %v", m.Name, err)
+	}
+	if err = interpolateNodeWith(root, inputPattern, inputs, "input"); err != nil {
+		return nil, fmt.Errorf("module %s: %s: %v", m.Name, path, err)
+	}
+	if err = interpolateNode(root, vars); err != nil {
+		return nil, fmt.Errorf("module %s: %s: %v", m.Name, path, err)
+	}
+
+	namespace(root, m.Name)
+	rebase(root, dir)
+	return root, nil
+}
+
+// expand merges the included files and the instances
+// of the modules into root. Paths are relative to base.
+func expand(root *yaml.Node, base string, vars map[string]string, including map[string]bool) error {
+	for _, n := range Sequence(MappingValue(root, "include")) {
+		path := filepath.Join(base, n.Value)
+		fail := func(err error) error {
+			return &PosError{Line: n.Line, Column: n.Column, Err: fmt.Errorf("include %s: %v", n.Value, err)}
+		}
+		if including[path] {
+			return fail(fmt.Errorf("%s is already being included", path))
+		}
+		included, err := readDocument(path)
+		if err != nil {
+			return fail(err)
+		}
+		if err = checkKeys(included, append([]string{"include", "modules"}, resourceSections...), "an included file"); err != nil {
+			return fail(err)
+		}
+		if err = interpolateNode(included, vars); err != nil {
+			return fail(err)
+		}
+		// its own files first: what it includes is already rebased
+		rebase(included, filepath.Dir(path))
+		including[path] = true
+		err = expand(included, filepath.Dir(path), vars, including)
+		delete(including, path)
+		if err != nil {
+			return fail(err)
+		}
+		setPosition(included, n.Line, n.Column)
+		merge(root, included)
+	}
+
+	names := make(map[string]bool)
+	for _, entry := range Sequence(MappingValue(root, "modules")) {
+		resources, err := instantiate(entry, base, vars)
+		if err != nil {
+			return &PosError{Line: entry.Line, Column: entry.Column, Err: err}
+		}
+		name := MappingValue(entry, "name").Value
+		if names[name] {
+			return &PosError{Line: entry.Line, Column: entry.Column, Err: fmt.Errorf("duplicate module \"%s\"", name)}
+		}
+		names[name] = true
+		setPosition(resources, entry.Line, entry.Column)
+		merge(root, resources)
+	}
+	return nil
+}
+
+// expandDocument merges the included files and the modules
+// into the project file.
+func expandDocument(doc *yaml.Node, vars map[string]string) error {
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil
+	}
+	return expand(doc.Content[0], ".", vars, make(map[string]bool))
+}
@@ -0,0 +1,110 @@
+package project
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SplitRef splits a "ref:<key>.<attribute>" value. The attribute
+// is what follows the last dot (keys can have dots, e.g. file paths),
+// it is empty with "ref:<key>".
+func SplitRef(value string) (key string, attribute string, ok bool) {
+	ref, ok := IsRef(value)
+	if !ok {
+		return "", "", false
+	}
+	if i := strings.LastIndex(ref, "."); i > 0 {
+		return ref[:i], ref[i+1:], true
+	}
+	return ref, "", true
+}
+
+// attributeOf returns an attribute of a resource by the name
+// of its json field (case insensitive), else of its resource file.
+// "Arn" and "Name" are the arn and name of the resource whatever
+// its kind (e.g. LoadBalancerArn, serviceName).
+func attributeOf(resource map[string]any, file map[string]any, attribute string) (string, error) {
+	if attribute == "" {
+		attribute = "Arn"
+	}
+	names := sortedNames(resource)
+	for _, name := range names {
+		if strings.EqualFold(name, attribute) {
+			return attributeValue(resource[name]), nil
+		}
+	}
+	for _, name := range sortedNames(file) {
+		if strings.EqualFold(name, attribute) {
+			return attributeValue(file[name]), nil
+		}
+	}
+	// shorthands (not for the resource file,
+	// e.g. executionRoleArn of a task definition)
+	if strings.EqualFold(attribute, "arn") || strings.EqualFold(attribute, "name") {
+		for _, name := range names {
+			if strings.HasSuffix(strings.ToLower(name), strings.ToLower(attribute)) {
+				return attributeValue(resource[name]), nil
+			}
+		}
+	}
+	for _, name := range sortedNames(file) {
+		if _, ok := resource[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return "", fmt.Errorf("unknown attribute \"%s\" (expected one of %s)", attribute, strings.Join(names, ", "))
+}
+
+func sortedNames(fields map[string]any) []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// attributeValue formats an attribute
+// (numbers, objects and lists as json).
+func attributeValue(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	content, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(content)
+}
+
+// EvalOutputs evaluates the outputs of the project file
+// with the resources of the run.
+func EvalOutputs(outputs map[string]string, refs *Refs) (map[string]string, error) {
+	result := make(map[string]string)
+	var errs []string
+	for _, name := range OutputNames(outputs) {
+		v, err := refs.Resolve(outputs[name])
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		result[name] = v
+	}
+	if len(errs) > 0 {
+		return result, fmt.Errorf("outputs:\n%s", strings.Join(errs, "\n"))
+	}
+	return result, nil
+}
+
+// OutputNames returns the names of the outputs, sorted.
+func OutputNames(outputs map[string]string) []string {
+	names := make([]string, 0, len(outputs))
+	for name := range outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
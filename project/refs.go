@@ -0,0 +1,256 @@
+package project
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/demingongo/ecx/aws"
+)
+
+// RefKinds are the kinds of resources a "ref:<key>" can reference,
+// in the order they are looked up when a key is used by several kinds.
+var RefKinds = []string{KindTargetGroup, KindLoadBalancer, KindListener, KindFlow, KindTaskDefinition, KindLogGroup}
+
+var refKindLabels = map[string]string{
+	KindTargetGroup:    "target group",
+	KindLoadBalancer:   "load balancer",
+	KindListener:       "listener",
+	KindFlow:           "flow",
+	KindTaskDefinition: "task definition",
+	KindLogGroup:       "log group",
+}
+
+// ParseRef parses a "ref:<key>.<attribute>" value. As keys can have
+// dots (e.g. file paths), the whole value is the key when exists
+// reports that the key before the last dot does not exist.
+func ParseRef(value string, exists func(key string) bool) (key string, attribute string, ok bool) {
+	key, attribute, ok = SplitRef(value)
+	if !ok || attribute == "" || exists(key) {
+		return key, attribute, ok
+	}
+	if whole, _ := IsRef(value); exists(whole) {
+		return whole, "", true
+	}
+	return key, attribute, true
+}
+
+// FlowRef is what a flow exposes to the outputs
+// (ref:<flow name>.<attribute>).
+type FlowRef struct {
+	ServiceArn     string `json:"ServiceArn,omitempty"`
+	ServiceName    string `json:"ServiceName,omitempty"`
+	Cluster        string `json:"Cluster,omitempty"`
+	TargetGroupArn string `json:"TargetGroupArn,omitempty"`
+}
+
+// TaskDefinitionRef is what a task definition exposes
+// (ref:<key>.<attribute>), with the fields of its file.
+type TaskDefinitionRef struct {
+	TaskDefinitionArn string `json:"TaskDefinitionArn"`
+	Family            string `json:"Family"`
+	Revision          string `json:"Revision"`
+}
+
+// NewTaskDefinitionRef returns the ref of a task definition revision.
+func NewTaskDefinitionRef(arn string) TaskDefinitionRef {
+	ref := TaskDefinitionRef{
+		TaskDefinitionArn: arn,
+		Family:            aws.ExtractFamilyFromRevision(arn),
+	}
+	if i := strings.LastIndex(arn, ":"); i >= 0 {
+		ref.Revision = arn[i+1:]
+	}
+	return ref
+}
+
+// ref is a resource applied (or looked up) by the run:
+// the response of aws (e.g. aws.LoadBalancer) and the content
+// of its resource file for the attributes aws does not return.
+type ref struct {
+	resource any
+	file     map[string]any
+}
+
+// Refs resolves the "ref:" values of the project file
+// and of its resource files, and the {{ ref "key" }} of
+// the templates, with the resources applied so far.
+type Refs struct {
+	refs map[string]ref
+
+	// resources are applied concurrently
+	mu sync.Mutex
+}
+
+func NewRefs() *Refs {
+	return &Refs{refs: make(map[string]ref)}
+}
+
+// Set sets the resource of a key. filepath is its resource
+// file (rendered if needed), "" if it has none.
+func (r *Refs) Set(kind string, key string, resource any, filepath string) {
+	var file map[string]any
+	if filepath != "" {
+		file, _ = ReadJSONMap(filepath)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.refs[NodeId(kind, key)] = ref{resource: resource, file: file}
+}
+
+// Get returns the resource of a key.
+func (r *Refs) Get(kind string, key string) (any, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	v, ok := r.refs[NodeId(kind, key)]
+	return v.resource, ok
+}
+
+// find returns the kind and resource of a key
+// (the first of the kinds, or of RefKinds if none is given).
+func (r *Refs) find(key string, kinds []string) (string, ref, bool) {
+	if len(kinds) == 0 {
+		kinds = RefKinds
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, kind := range kinds {
+		if v, ok := r.refs[NodeId(kind, key)]; ok {
+			return kind, v, true
+		}
+	}
+	return "", ref{}, false
+}
+
+// Resolve returns the value of a "ref:<key>.<attribute>" among
+// the resources of the kinds (any kind if none is given).
+// "ref:<key>" is the arn of the resource. Other values are
+// returned as they are.
+func (r *Refs) Resolve(value string, kinds ...string) (string, error) {
+	if _, ok := IsRef(value); !ok {
+		return value, nil
+	}
+	key, attribute, _ := ParseRef(value, func(key string) bool {
+		_, _, ok := r.find(key, kinds)
+		return ok
+	})
+	_, v, ok := r.find(key, kinds)
+	if !ok {
+		var labels []string
+		if len(kinds) == 0 {
+			kinds = RefKinds
+		}
+		for _, kind := range kinds {
+			labels = append(labels, refKindLabels[kind])
+		}
+		return "", fmt.Errorf("ecx - could not find %s reference \"%s\"", strings.Join(labels, " or "), key)
+	}
+	result, err := attributeOf(fields(v.resource), v.file, attribute)
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", value, err)
+	}
+	return result, nil
+}
+
+// fields returns the json fields of a resource.
+func fields(resource any) map[string]any {
+	result := make(map[string]any)
+	if content, err := json.Marshal(resource); err == nil {
+		_ = json.Unmarshal(content, &result)
+	}
+	return result
+}
+
+// Arns returns the arn of every key (for the environment of the hooks).
+func (r *Refs) Arns() map[string]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	result := make(map[string]string)
+	for i := len(RefKinds) - 1; i >= 0; i-- {
+		prefix := NodeId(RefKinds[i], "")
+		for id, v := range r.refs {
+			key, ok := strings.CutPrefix(id, prefix)
+			if !ok {
+				continue
+			}
+			if arn, err := attributeOf(fields(v.resource), nil, "Arn"); err == nil && arn != "" {
+				result[key] = arn
+			}
+		}
+	}
+	return result
+}
+
+// ResourceRefs returns the "ref:" values of a resource file:
+// the {{ ref "key" }} of a template or the "ref:" strings
+// of a json file or inline body. Files that cannot be read
+// have none (their errors are reported when they are used).
+func ResourceRefs(c *Config, filepath string) []string {
+	if filepath == "" {
+		return nil
+	}
+	if _, ok := IsRef(filepath); ok {
+		return nil
+	}
+	if IsTemplate(filepath) {
+		keys, _ := TemplateRefs(filepath)
+		var result []string
+		for _, key := range keys {
+			result = append(result, "ref:"+key)
+		}
+		return result
+	}
+	var content any
+	if err := readTemplateJSON(c, filepath, &content); err != nil {
+		return nil
+	}
+	return jsonRefs(content)
+}
+
+// jsonRefs returns the "ref:" strings of a json value.
+func jsonRefs(v any) []string {
+	var result []string
+	switch v := v.(type) {
+	case string:
+		if _, ok := IsRef(v); ok {
+			result = append(result, v)
+		}
+	case []any:
+		for _, item := range v {
+			result = append(result, jsonRefs(item)...)
+		}
+	case map[string]any:
+		for _, item := range v {
+			result = append(result, jsonRefs(item)...)
+		}
+	}
+	return result
+}
+
+// resolveJSON replaces the "ref:" strings of a json value.
+func resolveJSON(v any, resolve func(value string) (string, error)) (any, error) {
+	switch v := v.(type) {
+	case string:
+		if _, ok := IsRef(v); ok {
+			return resolve(v)
+		}
+	case []any:
+		for i, item := range v {
+			resolved, err := resolveJSON(item, resolve)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = resolved
+		}
+	case map[string]any:
+		for name, item := range v {
+			resolved, err := resolveJSON(item, resolve)
+			if err != nil {
+				return nil, err
+			}
+			v[name] = resolved
+		}
+	}
+	return v, nil
+}
@@ -0,0 +1,214 @@
+package project
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/spf13/viper"
+)
+
+// TemplateExt is the extension of the resource files
+// rendered with text/template before being used.
+const TemplateExt = ".tmpl.json"
+
+// {{ ref "key" }} in a template
+var templateRefPattern = regexp.MustCompile(`\bref\s+"([^"]+)"`)
+
+// TemplateData is the data available in a templated resource file:
+//
+//	{{ .Var.name }}       variable of the project file
+//	{{ .Env.HOME }}       environment variable
+//	{{ .Environment }}    environment of the project file (--env)
+//	{{ ref "key" }}       arn of a resource of the project
+//	{{ ref "key.attr" }}  attribute of a resource (e.g. "alb.DNSName")
+type TemplateData struct {
+	Var         map[string]string
+	Env         map[string]string
+	Environment string
+}
+
+// IsTemplate reports whether the resource file must be rendered:
+// it ends with .tmpl.json or --render-all is set.
+func IsTemplate(path string) bool {
+	if _, ok := IsRef(path); ok || path == "" || IsInline(path) {
+		return false
+	}
+	return strings.HasSuffix(path, TemplateExt) || viper.GetBool("render-all")
+}
+
+// TemplateRefs returns the keys used with "ref" in a template.
+func TemplateRefs(path string) ([]string, error) {
+	if !IsTemplate(path) {
+		return nil, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, m := range templateRefPattern.FindAllStringSubmatch(string(content), -1) {
+		keys = append(keys, m[1])
+	}
+	return keys, nil
+}
+
+func environ() map[string]string {
+	result := make(map[string]string)
+	for _, kv := range os.Environ() {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			result[k] = v
+		}
+	}
+	return result
+}
+
+// Execute renders a templated resource file. ref resolves
+// the keys used with {{ ref "key" }}.
+func Execute(path string, c *Config, ref func(key string) (string, error)) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.New(filepath.Base(path)).
+		Option("missingkey=error").
+		Funcs(template.FuncMap{"ref": ref}).
+		Parse(string(content))
+	if err != nil {
+		return nil, err
+	}
+	data := TemplateData{
+		Var:         c.Vars,
+		Env:         environ(),
+		Environment: c.Env,
+	}
+	var b bytes.Buffer
+	if err = tmpl.Execute(&b, data); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// Renderer renders the templated resource files of a project
+// into a temporary directory (kept with --keep-rendered).
+type Renderer struct {
+	config *Config
+	ref    func(key string) (string, error)
+
+	dir string
+	mu  sync.Mutex
+}
+
+func NewRenderer(c *Config, ref func(key string) (string, error)) *Renderer {
+	return &Renderer{config: c, ref: ref}
+}
+
+// Render returns the path of the file to use in place of
+// the resource file: the rendered file if it is a template,
+// an inline body or has "ref:" values, the resource file
+// itself otherwise.
+func (r *Renderer) Render(path string) (string, error) {
+	if IsInline(path) {
+		content, ok := r.config.Inline[path]
+		if !ok {
+			return "", fmt.Errorf("unknown inline resource \"%s\"", path)
+		}
+		content, err := r.resolve(content)
+		if err != nil {
+			return "", fmt.Errorf("%s: %v", path, err)
+		}
+		name := strings.NewReplacer("[", "_", "]", "", ".", "_").Replace(strings.TrimPrefix(path, InlinePrefix))
+		return r.write(filepath.Join("inline", name+".json"), content)
+	}
+	var (
+		content []byte
+		err     error
+	)
+	if IsTemplate(path) {
+		if content, err = Execute(path, r.config, r.ref); err != nil {
+			return "", fmt.Errorf("render %s: %v", path, err)
+		}
+	} else {
+		content, err = os.ReadFile(path)
+		if err != nil || !hasRefs(content) {
+			// errors are reported by the caller
+			return path, nil
+		}
+	}
+	if content, err = r.resolve(content); err != nil {
+		return "", fmt.Errorf("render %s: %v", path, err)
+	}
+	// same tree as the project so two files never collide
+	name := strings.TrimSuffix(filepath.Clean(path), TemplateExt)
+	name = strings.TrimSuffix(name, ".json") + ".json"
+	return r.write(strings.ReplaceAll(name, "..", "__"), content)
+}
+
+// hasRefs reports whether json content has "ref:" strings.
+func hasRefs(content []byte) bool {
+	return bytes.Contains(content, []byte(`"ref:`))
+}
+
+// resolve replaces the "ref:" strings of json content.
+func (r *Renderer) resolve(content []byte) ([]byte, error) {
+	if !hasRefs(content) {
+		return content, nil
+	}
+	var v any
+	if err := json.Unmarshal(content, &v); err != nil {
+		return nil, err
+	}
+	v, err := resolveJSON(v, func(value string) (string, error) {
+		key, _ := IsRef(value)
+		return r.ref(key)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(v, "", "  ")
+}
+
+// write writes a rendered file into the directory
+// of the rendered files.
+func (r *Renderer) write(name string, content []byte) (string, error) {
+	var err error
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.dir == "" {
+		if r.dir, err = os.MkdirTemp("", "ecx-rendered-"); err != nil {
+			return "", err
+		}
+	}
+	output := filepath.Join(r.dir, name)
+	if err = os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+		return "", err
+	}
+	if err = os.WriteFile(output, content, 0644); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+// Dir returns the directory of the rendered files
+// (empty if nothing was rendered).
+func (r *Renderer) Dir() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.dir
+}
+
+// Close removes the rendered files unless --keep-rendered is set.
+func (r *Renderer) Close() error {
+	dir := r.Dir()
+	if dir == "" || viper.GetBool("keep-rendered") {
+		return nil
+	}
+	return os.RemoveAll(dir)
+}
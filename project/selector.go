@@ -0,0 +1,153 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Sections are the sections of the project file
+// and the kind of their resources.
+var Sections = map[string]string{
+	"targetGroups":    KindTargetGroup,
+	"loadBalancers":   KindLoadBalancer,
+	"listeners":       KindListener,
+	"logGroups":       KindLogGroup,
+	"taskDefinitions": KindTaskDefinition,
+	"flows":           KindFlow,
+}
+
+// Selector selects the resources of the project file to apply:
+//
+//	Targets  "<kind>:<key>", e.g. "flow:app-test-flow"
+//	Only     sections, e.g. "taskDefinitions"
+//	Exclude  sections, e.g. "logGroups"
+//
+// Every resource is selected by an empty selector.
+type Selector struct {
+	Targets []string
+	Only    []string
+	Exclude []string
+}
+
+// SelectorFromFlags reads --target, --only and --exclude.
+func SelectorFromFlags() Selector {
+	return Selector{
+		Targets: viper.GetStringSlice("target"),
+		Only:    viper.GetStringSlice("only"),
+		Exclude: viper.GetStringSlice("exclude"),
+	}
+}
+
+// IsEmpty reports whether the selector selects everything.
+func (s Selector) IsEmpty() bool {
+	return len(s.Targets) == 0 && len(s.Only) == 0 && len(s.Exclude) == 0
+}
+
+func sectionNames() string {
+	names := make([]string, 0, len(Sections))
+	for name := range Sections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func kindNames() string {
+	names := make([]string, 0, len(Sections))
+	for _, kind := range Sections {
+		names = append(names, kind)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func isKind(kind string) bool {
+	for _, k := range Sections {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// sectionKinds returns the kinds of the sections.
+func sectionKinds(flag string, sections []string) (map[string]bool, error) {
+	var errs []error
+	kinds := make(map[string]bool)
+	for _, section := range sections {
+		kind, ok := Sections[section]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: unknown section \"%s\" (expected one of %s)", flag, section, sectionNames()))
+			continue
+		}
+		kinds[kind] = true
+	}
+	return kinds, errors.Join(errs...)
+}
+
+// Select returns the ids of the selected nodes.
+func (g *Graph) Select(s Selector) (map[string]bool, error) {
+	var errs []error
+	selected := make(map[string]bool)
+
+	only, err := sectionKinds("only", s.Only)
+	errs = append(errs, err)
+	exclude, err := sectionKinds("exclude", s.Exclude)
+	errs = append(errs, err)
+
+	for _, target := range s.Targets {
+		kind, key, ok := strings.Cut(target, ":")
+		if !ok || key == "" {
+			errs = append(errs, fmt.Errorf("target: \"%s\" is not valid. Expected \"<kind>:<key>\".", target))
+			continue
+		}
+		if !isKind(kind) {
+			errs = append(errs, fmt.Errorf("target: unknown kind \"%s\" (expected one of %s)", kind, kindNames()))
+			continue
+		}
+		if _, ok := g.nodes[NodeId(kind, key)]; !ok {
+			errs = append(errs, fmt.Errorf("target: no %s \"%s\" in %s", kind, key, FileName))
+			continue
+		}
+		selected[NodeId(kind, key)] = true
+	}
+	for _, n := range g.Nodes {
+		// targets and sections add up
+		if (len(s.Targets) == 0 && len(only) == 0) || only[n.Kind] {
+			selected[n.Id()] = true
+		}
+		if exclude[n.Kind] {
+			delete(selected, n.Id())
+		}
+	}
+
+	return selected, errors.Join(errs...)
+}
+
+// Dependencies returns the ids of the nodes the given nodes
+// depend on (directly or not) that are not in ids.
+func (g *Graph) Dependencies(ids map[string]bool) map[string]bool {
+	result := make(map[string]bool)
+	var visit func(id string)
+	visit = func(id string) {
+		n, ok := g.nodes[id]
+		if !ok {
+			return
+		}
+		for _, dep := range n.Deps {
+			if ids[dep] || result[dep] {
+				continue
+			}
+			result[dep] = true
+			visit(dep)
+		}
+	}
+	for id := range ids {
+		visit(id)
+	}
+	return result
+}
@@ -0,0 +1,38 @@
+package project
+
+import (
+	"fmt"
+
+	"github.com/demingongo/ecx/aws"
+)
+
+// ServicePort returns the container and port a service registers
+// in its target group: the port mapping named "http" of the task
+// definition or, if there is none, the first port mapping.
+func ServicePort(client aws.Client, taskDefinition string) (string, int, error) {
+	containers, err := aws.ListPortMapping(client, taskDefinition)
+	if err != nil {
+		return "", 0, err
+	}
+	for _, container := range containers {
+		if container.PortMapping.Name == "http" {
+			return container.Name, container.PortMapping.ContainerPort, nil
+		}
+	}
+	if len(containers) > 0 {
+		return containers[0].Name, containers[0].PortMapping.ContainerPort, nil
+	}
+	return "", 0, nil
+}
+
+// ServiceHash returns the hash recorded by apply for a service
+// (file and the values it is created or updated with).
+func ServiceHash(serviceFile string, targetGroupArn string, containerName string, containerPort int, healthCheckGracePeriodSeconds int) (string, error) {
+	return Hash(
+		serviceFile,
+		targetGroupArn,
+		containerName,
+		fmt.Sprint(containerPort),
+		fmt.Sprint(healthCheckGracePeriodSeconds),
+	)
+}
@@ -0,0 +1,223 @@
+package project
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	StateFileName = "ecx.state.json"
+	StateVersion  = 1
+)
+
+// resource kinds
+const (
+	KindTargetGroup    = "targetGroup"
+	KindLoadBalancer   = "loadBalancer"
+	KindListener       = "listener"
+	KindRule           = "rule"
+	KindLogGroup       = "logGroup"
+	KindTaskDefinition = "taskDefinition"
+	KindService        = "service"
+)
+
+// StateResource is a resource created (or adopted) by apply.
+type StateResource struct {
+	Kind       string            `json:"kind"`
+	Key        string            `json:"key"`
+	File       string            `json:"file,omitempty"`
+	Arn        string            `json:"arn,omitempty"`
+	Hash       string            `json:"hash,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	// Adopted is true for a resource that already existed and was
+	// updated in place. It is managed by apply but never deleted.
+	Adopted bool `json:"adopted,omitempty"`
+}
+
+// State is the content of ecx.state.json (or of the state
+// file of an environment).
+// It maps the keys of the project to the resources
+// created in AWS so they are reused on the next runs.
+type State struct {
+	Version   int             `json:"version"`
+	Resources []StateResource `json:"resources"`
+	// outputs of the last apply
+	Outputs map[string]string `json:"outputs,omitempty"`
+
+	// the file of the environment (see StateFile)
+	file string
+
+	// resources can be applied concurrently
+	mu sync.Mutex
+}
+
+// StateFile returns the state file of an environment:
+// ecx.state.json or, with --env, ecx.<env>.state.json
+// (the environments don't share their resources).
+func StateFile(env string) string {
+	if env == "" {
+		return StateFileName
+	}
+	return "ecx." + env + ".state.json"
+}
+
+// NewState returns an empty state of an environment.
+func NewState(env string) *State {
+	return &State{Version: StateVersion, file: StateFile(env)}
+}
+
+// LoadState reads the state file of an environment (see StateFile)
+// from the current directory. An empty state is returned if the
+// file does not exist or with --dummy (the fake account starts
+// empty every time).
+func LoadState(env string) (*State, error) {
+	s := NewState(env)
+	if viper.GetBool("dummy") {
+		return s, nil
+	}
+
+	content, err := os.ReadFile(s.file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return s, err
+	}
+	if err = json.Unmarshal(content, s); err != nil {
+		return s, fmt.Errorf("%s: %v", s.file, err)
+	}
+
+	return s, nil
+}
+
+// File returns the state file the state is saved into.
+func (s *State) File() string {
+	if s.file == "" {
+		return StateFileName
+	}
+	return s.file
+}
+
+// Save writes the state into its file.
+// Nothing is written with --dummy: the arns of the
+// fake account must not be trusted by a real run.
+func (s *State) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if viper.GetBool("dummy") {
+		return nil
+	}
+	content, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.File(), append(content, '\n'), 0644)
+}
+
+func (s *State) find(kind string, key string) int {
+	for i, r := range s.Resources {
+		if r.Kind == kind && r.Key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// Get returns the resource of that kind with that key.
+func (s *State) Get(kind string, key string) (StateResource, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := s.find(kind, key); i > -1 {
+		return s.Resources[i], true
+	}
+	return StateResource{}, false
+}
+
+// Put adds or replaces a resource.
+func (s *State) Put(r StateResource) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := s.find(r.Kind, r.Key); i > -1 {
+		s.Resources[i] = r
+		return
+	}
+	s.Resources = append(s.Resources, r)
+}
+
+// Remove deletes a resource from the state.
+func (s *State) Remove(kind string, key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := s.find(kind, key); i > -1 {
+		s.Resources = append(s.Resources[:i], s.Resources[i+1:]...)
+	}
+}
+
+// Rename changes the key of the resources of these kinds that have
+// the key or are under it (e.g. "<flow>/rules/0"), after the id of
+// a resource of the project changed. It returns how many were renamed.
+func (s *State) Rename(key string, newKey string, kinds ...string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for i, r := range s.Resources {
+		if !slices.Contains(kinds, r.Kind) {
+			continue
+		}
+		if r.Key == key {
+			s.Resources[i].Key = newKey
+			n++
+		} else if rest, ok := strings.CutPrefix(r.Key, key+"/"); ok {
+			s.Resources[i].Key = newKey + "/" + rest
+			n++
+		}
+	}
+	return n
+}
+
+// Hash returns the content hash of a resource file
+// and of the values it is applied with (arns, priority, ...).
+// A json file is hashed as canonical json: a file and its body
+// inlined in ecx.yaml (written by the renderer) have the same hash.
+func Hash(filepath string, extra ...string) (string, error) {
+	h := sha256.New()
+	if filepath != "" {
+		content, err := os.ReadFile(filepath)
+		if err != nil {
+			return "", err
+		}
+		h.Write(canonicalJSON(content))
+	}
+	for _, v := range extra {
+		h.Write([]byte{0})
+		h.Write([]byte(v))
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// canonicalJSON returns json content without its formatting
+// (compact, sorted keys). Other content is returned as is.
+func canonicalJSON(content []byte) []byte {
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(content))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil || dec.More() {
+		return content
+	}
+	result, err := json.Marshal(v)
+	if err != nil {
+		return content
+	}
+	return result
+}
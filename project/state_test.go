@@ -0,0 +1,53 @@
+package project
+
+import (
+	"os"
+	"testing"
+)
+
+// chdir changes the current directory for the time of a test.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+}
+
+func TestStateOfEnv(t *testing.T) {
+	chdir(t, t.TempDir())
+
+	dev, err := LoadState("dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dev.Put(StateResource{Kind: KindTargetGroup, Key: "tg-app", Arn: "arn:dev"})
+	if err = dev.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat("ecx.dev.state.json"); err != nil {
+		t.Errorf("dev state: %v", err)
+	}
+
+	for _, env := range []string{"", "prod"} {
+		state, err := LoadState(env)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r, ok := state.Get(KindTargetGroup, "tg-app"); ok {
+			t.Errorf("%s: found %s of dev", state.File(), r.Arn)
+		}
+	}
+
+	dev, err = LoadState("dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, _ := dev.Get(KindTargetGroup, "tg-app"); r.Arn != "arn:dev" {
+		t.Errorf("dev: arn %q, want %q", r.Arn, "arn:dev")
+	}
+}
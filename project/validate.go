@@ -0,0 +1,567 @@
+package project
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/demingongo/ecx/aws"
+	"gopkg.in/yaml.v3"
+)
+
+// Diagnostic is an error (or a warning) found by Validate
+// at a position of a file.
+type Diagnostic struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+	Warning bool
+}
+
+// String returns "file:line:column: message".
+func (d Diagnostic) String() string {
+	level := "error"
+	if d.Warning {
+		level = "warning"
+	}
+	if d.Line <= 0 {
+		return fmt.Sprintf("%s: %s: %s", d.File, level, d.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s: %s", d.File, d.Line, d.Column, level, d.Message)
+}
+
+// "yaml: line 3: ..." or "line 3: ..." in yaml errors
+var yamlLinePattern = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// YAMLDiagnostics turns an error of LoadDocument into diagnostics.
+func YAMLDiagnostics(err error) []Diagnostic {
+	var (
+		result   []Diagnostic
+		posErr   *PosError
+		typeErr  *yaml.TypeError
+		messages []string
+	)
+	if errors.As(err, &posErr) {
+		return []Diagnostic{{File: FileName, Line: posErr.Line, Column: posErr.Column, Message: posErr.Err.Error()}}
+	}
+	if errors.As(err, &typeErr) {
+		messages = typeErr.Errors
+	} else {
+		messages = []string{err.Error()}
+	}
+	for _, m := range messages {
+		d := Diagnostic{File: FileName, Message: m}
+		if sub := yamlLinePattern.FindStringSubmatch(m); sub != nil {
+			fmt.Sscan(sub[1], &d.Line)
+			d.Column = 1
+			d.Message = sub[2]
+		}
+		result = append(result, d)
+	}
+	return result
+}
+
+// validator collects the diagnostics of a project.
+type validator struct {
+	config *Config
+	doc    *yaml.Node
+	diags  []Diagnostic
+
+	// files already checked
+	files map[string]bool
+
+	// keys by kind
+	keys map[string]map[string]bool
+}
+
+// nodeAt returns the node at the path (mapping keys and sequence
+// indexes) or the last node found on the way.
+func (v *validator) nodeAt(path ...any) *yaml.Node {
+	n := v.doc
+	if n == nil {
+		return nil
+	}
+	if n.Kind == yaml.DocumentNode && len(n.Content) > 0 {
+		n = n.Content[0]
+	}
+	for _, p := range path {
+		var next *yaml.Node
+		switch p := p.(type) {
+		case string:
+			if n.Kind == yaml.MappingNode {
+				for i := 0; i+1 < len(n.Content); i += 2 {
+					if n.Content[i].Value == p {
+						next = n.Content[i+1]
+						break
+					}
+				}
+			}
+		case int:
+			if n.Kind == yaml.SequenceNode && p < len(n.Content) {
+				next = n.Content[p]
+			}
+		}
+		if next == nil {
+			return n
+		}
+		n = next
+	}
+	return n
+}
+
+func (v *validator) errorf(path []any, format string, a ...any) {
+	d := Diagnostic{File: FileName, Message: fmt.Sprintf(format, a...)}
+	if n := v.nodeAt(path...); n != nil {
+		d.Line = n.Line
+		d.Column = n.Column
+	}
+	v.diags = append(v.diags, d)
+}
+
+func (v *validator) warnf(path []any, format string, a ...any) {
+	v.errorf(path, format, a...)
+	v.diags[len(v.diags)-1].Warning = true
+}
+
+func path(p ...any) []any {
+	return p
+}
+
+// sub returns a copy of the path with more keys.
+func sub(p []any, keys ...any) []any {
+	return append(append([]any{}, p...), keys...)
+}
+
+// yamlFields returns the yaml keys of a struct type.
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "-" || !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+// distance is the levenshtein distance (for suggestions).
+func distance(a string, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// knownFields reports the keys of the document
+// that are not in the schema (strict decoding).
+func (v *validator) knownFields(n *yaml.Node, t reflect.Type) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch n.Kind {
+	case yaml.DocumentNode:
+		for _, child := range n.Content {
+			v.knownFields(child, t)
+		}
+	case yaml.MappingNode:
+		switch t.Kind() {
+		case reflect.Struct:
+			fields := yamlFields(t)
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				key := n.Content[i]
+				ft, ok := fields[key.Value]
+				if !ok {
+					d := Diagnostic{
+						File:    FileName,
+						Line:    key.Line,
+						Column:  key.Column,
+						Message: fmt.Sprintf("unknown key \"%s\"", key.Value),
+					}
+					var names []string
+					for name := range fields {
+						names = append(names, name)
+					}
+					sort.Strings(names)
+					for _, name := range names {
+						if distance(strings.ToLower(name), strings.ToLower(key.Value)) <= 2 {
+							d.Message = fmt.Sprintf("%s, did you mean \"%s\"?", d.Message, name)
+							break
+						}
+					}
+					v.diags = append(v.diags, d)
+					continue
+				}
+				v.knownFields(n.Content[i+1], ft)
+			}
+		case reflect.Map:
+			for i := 1; i < len(n.Content); i += 2 {
+				v.knownFields(n.Content[i], t.Elem())
+			}
+		}
+	case yaml.SequenceNode:
+		if t.Kind() == reflect.Slice {
+			for _, child := range n.Content {
+				v.knownFields(child, t.Elem())
+			}
+		}
+	}
+}
+
+// jsonPosition returns the line and column of an offset.
+func jsonPosition(content []byte, offset int64) (int, int) {
+	line, column := 1, 1
+	for i, c := range content {
+		if int64(i) >= offset-1 {
+			break
+		}
+		if c == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+	return line, column
+}
+
+// readFile checks that a resource file exists and parses
+// and returns its content (nil if it does not).
+func (v *validator) readFile(p []any, file string) map[string]any {
+	if file == "" {
+		return nil
+	}
+	var (
+		content []byte
+		err     error
+	)
+	if IsInline(file) {
+		content = v.config.Inline[file]
+	} else if IsTemplate(file) {
+		if _, err = os.Stat(file); err == nil {
+			content, err = Execute(file, v.config, func(key string) (string, error) {
+				return "ref:" + key, nil
+			})
+		}
+	} else {
+		content, err = os.ReadFile(file)
+	}
+	if err != nil {
+		if !v.files[file] {
+			if errors.Is(err, os.ErrNotExist) {
+				v.errorf(p, "file \"%s\" does not exist", file)
+			} else {
+				v.diags = append(v.diags, Diagnostic{File: file, Message: err.Error()})
+			}
+		}
+		v.files[file] = true
+		return nil
+	}
+	var result map[string]any
+	if err = json.Unmarshal(content, &result); err != nil {
+		if !v.files[file] {
+			d := Diagnostic{File: file, Message: err.Error()}
+			var syntaxErr *json.SyntaxError
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &syntaxErr) {
+				d.Line, d.Column = jsonPosition(content, syntaxErr.Offset)
+			} else if errors.As(err, &typeErr) {
+				d.Line, d.Column = jsonPosition(content, typeErr.Offset)
+			}
+			v.diags = append(v.diags, d)
+		}
+		v.files[file] = true
+		return nil
+	}
+	v.files[file] = true
+	return result
+}
+
+// ref checks that a "ref:" value resolves to a key of the kind.
+func (v *validator) ref(p []any, value string, kind string) {
+	key, _, ok := ParseRef(value, func(key string) bool {
+		return v.keys[kind][key]
+	})
+	if ok && !v.keys[kind][key] {
+		v.errorf(p, "\"%s\" references unknown %s \"%s\"", value, refKindLabels[kind], key)
+	}
+}
+
+// exists reports whether a key can be referenced.
+func (v *validator) exists(key string) bool {
+	for _, kind := range RefKinds {
+		if v.keys[kind][key] {
+			return true
+		}
+	}
+	return false
+}
+
+// resourceRefs checks the "ref:" values of a resource file
+// and the {{ ref "key" }} of a template.
+func (v *validator) resourceRefs(p []any, file string) {
+	for _, value := range ResourceRefs(v.config, file) {
+		key, _, _ := ParseRef(value, v.exists)
+		if !v.exists(key) {
+			if IsTemplate(file) {
+				v.errorf(p, "template \"%s\" references unknown key \"%s\"", file, key)
+			} else {
+				v.errorf(p, "\"%s\" in \"%s\" references unknown key \"%s\"", value, file, key)
+			}
+		}
+	}
+}
+
+// priority is a rule priority claimed on a listener.
+type priority struct {
+	path []any
+	file string
+}
+
+// Validate checks the project without calling AWS: schema (unknown keys),
+// header, resource files, references, rule priorities and
+// the "http" port mapping of the services of flows with a target group.
+func Validate(c *Config, doc *yaml.Node) []Diagnostic {
+	v := &validator{config: c, doc: doc, files: make(map[string]bool)}
+
+	if doc != nil {
+		v.knownFields(doc, reflect.TypeOf(Config{}))
+	}
+
+	if c.Api != ValidApi {
+		v.errorf(path("api"), "value for \"api\" is not valid, expected \"%s\"", ValidApi)
+	}
+	if !slices.Contains(ValidApiVersions, c.ApiVersion) {
+		v.errorf(path("apiVersion"), "value for \"apiVersion\" is not valid, expected \"%s\"", strings.Join(ValidApiVersions, "\" or \""))
+	}
+
+	// keys and duplicates
+	v.keys = make(map[string]map[string]bool)
+	for _, kind := range RefKinds {
+		v.keys[kind] = make(map[string]bool)
+	}
+	duplicate := func(p []any, kind string, key string) {
+		if v.keys[kind][key] {
+			v.errorf(p, "duplicate %s \"%s\"", refKindLabels[kind], key)
+		}
+		v.keys[kind][key] = true
+	}
+	for i, targetGroup := range c.TargetGroups {
+		duplicate(path("targetGroups", i), KindTargetGroup, targetGroup.Id())
+	}
+	for i, loadBalancer := range c.LoadBalancers {
+		duplicate(path("loadBalancers", i), KindLoadBalancer, loadBalancer.Id())
+	}
+	for i, listener := range c.Listeners {
+		duplicate(path("listeners", i), KindListener, listener.Id())
+	}
+	for i, logGroup := range c.LogGroups {
+		duplicate(path("logGroups", i), KindLogGroup, logGroup.Id())
+	}
+	for i, taskDefinition := range c.TaskDefinitions {
+		duplicate(path("taskDefinitions", i), KindTaskDefinition, taskDefinition.Id())
+	}
+	for i, flow := range c.Flows {
+		duplicate(path("flows", i), KindFlow, flow.Id())
+	}
+
+	// rule priorities per listener (key or arn)
+	priorities := make(map[string]map[int]priority)
+	claim := func(p []any, listener string, value string, rulePriority int) {
+		if rulePriority <= 0 {
+			if content := v.readFile(p, value); content != nil {
+				if f, ok := content["Priority"].(float64); ok {
+					rulePriority = int(f)
+				}
+			}
+		}
+		if rulePriority <= 0 || listener == "" {
+			return
+		}
+		if priorities[listener] == nil {
+			priorities[listener] = make(map[int]priority)
+		}
+		if first, ok := priorities[listener][rulePriority]; ok {
+			by := first.file
+			if n := v.nodeAt(first.path...); n != nil {
+				by = fmt.Sprintf("%s (line %d)", by, n.Line)
+			}
+			v.errorf(p, "priority %d is already used on listener \"%s\" by %s", rulePriority, listener, by)
+			return
+		}
+		priorities[listener][rulePriority] = priority{path: p, file: value}
+	}
+
+	for i, targetGroup := range c.TargetGroups {
+		p := path("targetGroups", i, "value")
+		if targetGroup.Value == "" {
+			v.warnf(path("targetGroups", i), "target group \"%s\" has no value, it is skipped", targetGroup.Id())
+			continue
+		}
+		v.readFile(p, targetGroup.Value)
+		v.resourceRefs(p, targetGroup.Value)
+	}
+	for i, loadBalancer := range c.LoadBalancers {
+		p := path("loadBalancers", i, "value")
+		if loadBalancer.Value == "" {
+			v.warnf(path("loadBalancers", i), "load balancer \"%s\" has no value, it is skipped", loadBalancer.Id())
+			continue
+		}
+		v.readFile(p, loadBalancer.Value)
+		v.resourceRefs(p, loadBalancer.Value)
+	}
+	for i, listener := range c.Listeners {
+		if listener.Value == "" {
+			v.warnf(path("listeners", i), "listener \"%s\" has no value, it is skipped", listener.Id())
+			continue
+		}
+		p := path("listeners", i, "value")
+		v.readFile(p, listener.Value)
+		v.resourceRefs(p, listener.Value)
+		v.ref(path("listeners", i, "loadBalancer"), listener.LoadBalancer, KindLoadBalancer)
+		v.ref(path("listeners", i, "targetGroup"), listener.TargetGroup, KindTargetGroup)
+		for j, rule := range listener.Rules {
+			p := path("listeners", i, "rules", j)
+			v.readFile(sub(p, "value"), rule.Value)
+			v.resourceRefs(sub(p, "value"), rule.Value)
+			v.ref(sub(p, "targetGroup"), rule.TargetGroup, KindTargetGroup)
+			claim(sub(p, "priority"), listener.Id(), rule.Value, rule.Priority)
+		}
+	}
+
+	// task definitions by family (for the port mappings)
+	// (and by key for the "ref:" values)
+	families := make(map[string]map[string]any)
+	for i, taskDefinition := range c.TaskDefinitions {
+		p := path("taskDefinitions", i)
+		content := v.readFile(p, taskDefinition.Value)
+		v.resourceRefs(p, taskDefinition.Value)
+		if family, ok := content["family"].(string); ok {
+			families[family] = content
+		}
+		if content != nil {
+			families["ref:"+taskDefinition.Id()] = content
+		}
+	}
+
+	for i, flow := range c.Flows {
+		if flow.Service == "" && flow.TargetGroup == "" {
+			v.errorf(path("flows", i), "flow needs a service and/or a target group")
+		}
+		if _, ok := IsRef(flow.TargetGroup); ok {
+			v.ref(path("flows", i, "targetGroup"), flow.TargetGroup, KindTargetGroup)
+		} else {
+			v.readFile(path("flows", i, "targetGroup"), flow.TargetGroup)
+			v.resourceRefs(path("flows", i, "targetGroup"), flow.TargetGroup)
+		}
+		for j, rule := range flow.Rules {
+			p := path("flows", i, "rules", j)
+			if flow.TargetGroup == "" {
+				v.warnf(p, "rule of a flow without target group, it is skipped")
+			}
+			if rule.Listener == "" {
+				v.errorf(p, "rule of a flow needs a listener")
+			}
+			v.readFile(sub(p, "value"), rule.Value)
+			v.resourceRefs(sub(p, "value"), rule.Value)
+			v.ref(sub(p, "listener"), rule.Listener, KindListener)
+			listener := rule.Listener
+			if key, ok := IsRef(rule.Listener); ok {
+				listener = key
+			}
+			claim(sub(p, "priority"), listener, rule.Value, rule.Priority)
+		}
+		if flow.Service == "" {
+			continue
+		}
+		p := path("flows", i, "service")
+		service := v.readFile(p, flow.Service)
+		v.resourceRefs(p, flow.Service)
+		if service == nil || flow.TargetGroup == "" {
+			continue
+		}
+		// the container of the target group is the one
+		// with a port mapping named "http" (or the first one)
+		taskDefinition, _ := service["taskDefinition"].(string)
+		td, ok := families[aws.ExtractFamilyFromRevision(taskDefinition)]
+		isTaskDefinition := func(key string) bool { return v.keys[KindTaskDefinition][key] }
+		if key, _, isRef := ParseRef(taskDefinition, isTaskDefinition); isRef {
+			td, ok = families["ref:"+key]
+		}
+		if !ok {
+			continue
+		}
+		var (
+			mappings int
+			http     bool
+		)
+		containers, _ := td["containerDefinitions"].([]any)
+		for _, container := range containers {
+			cd, _ := container.(map[string]any)
+			portMappings, _ := cd["portMappings"].([]any)
+			for _, pm := range portMappings {
+				mappings++
+				if m, _ := pm.(map[string]any); m["name"] == "http" {
+					http = true
+				}
+			}
+		}
+		if mappings == 0 {
+			v.errorf(p, "task definition \"%s\" has no port mapping for the target group of the flow", taskDefinition)
+		} else if !http {
+			v.warnf(p, "task definition \"%s\" has no port mapping named \"http\", the first one is used", taskDefinition)
+		}
+	}
+
+	// outputs
+	for _, name := range OutputNames(c.Outputs) {
+		key, _, ok := ParseRef(c.Outputs[name], v.exists)
+		if ok && !v.exists(key) {
+			v.errorf(path("outputs", name), "\"%s\" references unknown key \"%s\"", c.Outputs[name], key)
+		}
+	}
+
+	// cycles
+	if graph, err := BuildGraph(c); err == nil {
+		if _, err = graph.Sort(); err != nil {
+			v.errorf(nil, "%v", err)
+		}
+	}
+
+	return v.diags
+}
+
+// HasErrors reports whether the diagnostics have errors
+// (not only warnings).
+func HasErrors(diags []Diagnostic) bool {
+	for _, d := range diags {
+		if !d.Warning {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package project
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []string
+	}{
+		{
+			name: "valid",
+		},
+		{
+			name: "bad apiVersion",
+			files: map[string]string{
+				FileName: projectWith("apiVersion: 0.2", "apiVersion: 0.3"),
+			},
+			want: []string{`ecx.yaml:2:13: error: value for "apiVersion" is not valid, expected "0.1" or "0.2"`},
+		},
+		{
+			name: "bad api",
+			files: map[string]string{
+				FileName: projectWith("api: ecx", "api: ecs"),
+			},
+			want: []string{`ecx.yaml:1:6: error: value for "api" is not valid, expected "ecx"`},
+		},
+		{
+			name: "unknown ref",
+			files: map[string]string{
+				FileName: projectWith("loadBalancer: ref:alb", "loadBalancer: ref:alb2"),
+			},
+			want: []string{`ecx.yaml:15:19: error: "ref:alb2" references unknown load balancer "alb2"`},
+		},
+		{
+			name: "ref of the wrong kind",
+			files: map[string]string{
+				FileName: projectWith("listener: ref:http", "listener: ref:tg-app"),
+			},
+			want: []string{`ecx.yaml:33:19: error: "ref:tg-app" references unknown listener "tg-app"`},
+		},
+		{
+			name: "unknown key in a resource file",
+			files: map[string]string{
+				"service.json": `{"serviceName": "app", "taskDefinition": "ref:td-api.TaskDefinitionArn"}`,
+			},
+			want: []string{`ecx.yaml:28:14: error: "ref:td-api.TaskDefinitionArn" in "service.json" references unknown key "td-api"`},
+		},
+		{
+			name: "duplicate key",
+			files: map[string]string{
+				FileName: projectWith("loadBalancers:\n", "loadBalancers:\n  - key: alb\n    value: alb.json\n"),
+			},
+			want: []string{`ecx.yaml:11:5: error: duplicate load balancer "alb"`},
+		},
+		{
+			name: "missing file",
+			files: map[string]string{
+				"tg.json": "",
+			},
+			want: []string{`ecx.yaml:6:12: error: file "tg.json" does not exist`},
+		},
+		{
+			name: "file reported once",
+			files: map[string]string{
+				FileName:  projectWith("service: service.json", "service: missing.json", "value: rule.json", "value: missing.json"),
+				"td.json": "",
+			},
+			want: []string{
+				`ecx.yaml:23:5: error: file "td.json" does not exist`,
+				`ecx.yaml:31:16: error: file "missing.json" does not exist`,
+			},
+		},
+		{
+			name: "bad json",
+			files: map[string]string{
+				"alb.json": "{\n  \"Name\": \n}",
+			},
+			want: []string{`alb.json:3:1: error: invalid character '}' looking for beginning of value`},
+		},
+		{
+			name: "unknown key",
+			files: map[string]string{
+				FileName: projectWith("    loadBalancer: ref:alb", "    loadBalancr: ref:alb"),
+			},
+			want: []string{`ecx.yaml:15:5: error: unknown key "loadBalancr", did you mean "loadBalancer"?`},
+		},
+		{
+			name: "no http port mapping",
+			files: map[string]string{
+				"td.json": `{"family": "web", "containerDefinitions": [{"name": "web", "portMappings": [{"containerPort": 80}]}]}`,
+			},
+			want: []string{`ecx.yaml:28:14: warning: task definition "web" has no port mapping named "http", the first one is used`},
+		},
+		{
+			name: "cycle",
+			files: map[string]string{
+				FileName:       projectWith("targetGroup: ref:tg-app\n\nlogGroups", "targetGroup: ref:tg-late\n\nlogGroups", "loadBalancers:", "  - key: tg-late\n    value: tg-late.json\n\nloadBalancers:"),
+				"tg-late.json": `{"Name": "late", "Tags": [{"Key": "listener", "Value": "ref:http"}]}`,
+			},
+			want: []string{`ecx.yaml:1:1: error: dependency cycle: targetGroup:tg-late => listener:http => targetGroup:tg-late`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, doc := writeProject(t, tt.files)
+			var got []string
+			for _, d := range Validate(c, doc) {
+				got = append(got, d.String())
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("diagnostics:\n got %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package project
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// Environment is an overlay of the project variables
+// selected with --env.
+type Environment struct {
+	Variables map[string]string `yaml:"variables"`
+}
+
+// Inputs are the variables given on the command line.
+type Inputs struct {
+	Env string
+	// values of --var-file then --var
+	Vars map[string]string
+}
+
+// variable reference: ${var.name}
+var varPattern = regexp.MustCompile(`\$\{var\.([A-Za-z0-9_-]+)\}`)
+
+// InputsFromFlags reads --env, --var-file and --var.
+// It must be called before moving into the project directory
+// as the path of --var-file is relative to the working directory.
+func InputsFromFlags() (Inputs, error) {
+	inputs := Inputs{
+		Env:  viper.GetString("env"),
+		Vars: make(map[string]string),
+	}
+	if varFile := viper.GetString("var-file"); varFile != "" {
+		content, err := os.ReadFile(varFile)
+		if err != nil {
+			return inputs, fmt.Errorf("var-file: %v", err)
+		}
+		if err = yaml.Unmarshal(content, &inputs.Vars); err != nil {
+			return inputs, fmt.Errorf("var-file %s: %v", varFile, err)
+		}
+	}
+	for _, v := range viper.GetStringSlice("var") {
+		name, value, ok := strings.Cut(v, "=")
+		if !ok || name == "" {
+			return inputs, fmt.Errorf("var: \"%s\" is not valid. Expected \"name=value\".", v)
+		}
+		inputs.Vars[name] = value
+	}
+	return inputs, nil
+}
+
+// PosError is an error at a position of ecx.yaml.
+type PosError struct {
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *PosError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *PosError) Unwrap() error {
+	return e.Err
+}
+
+// resolveVariables merges the variables by precedence:
+// project < environment < --var-file < --var.
+func resolveVariables(variables map[string]string, environments map[string]Environment, inputs Inputs) (map[string]string, error) {
+	result := make(map[string]string)
+	for k, v := range variables {
+		result[k] = v
+	}
+	if inputs.Env != "" {
+		env, ok := environments[inputs.Env]
+		if !ok {
+			return result, fmt.Errorf("unknown environment \"%s\"", inputs.Env)
+		}
+		for k, v := range env.Variables {
+			result[k] = v
+		}
+	}
+	for k, v := range inputs.Vars {
+		result[k] = v
+	}
+	return result, nil
+}
+
+// Interpolate replaces the ${var.name} references of the value.
+func Interpolate(value string, vars map[string]string) (string, error) {
+	return interpolate(value, varPattern, vars, "variable")
+}
+
+// interpolate replaces the references matching pattern
+// (e.g. ${var.name}, ${input.name}) with their values.
+func interpolate(value string, pattern *regexp.Regexp, values map[string]string, what string) (string, error) {
+	var err error
+	result := pattern.ReplaceAllStringFunc(value, func(m string) string {
+		name := pattern.FindStringSubmatch(m)[1]
+		v, ok := values[name]
+		if !ok {
+			err = fmt.Errorf("undefined %s \"%s\"", what, name)
+			return m
+		}
+		return v
+	})
+	return result, err
+}
+
+// interpolateNode replaces the ${var.name} references of every
+// scalar of the yaml document. A scalar that is only a reference
+// takes the type of the value (e.g. "priority: ${var.priority}").
+func interpolateNode(n *yaml.Node, vars map[string]string) error {
+	return interpolateNodeWith(n, varPattern, vars, "variable")
+}
+
+func interpolateNodeWith(n *yaml.Node, pattern *regexp.Regexp, values map[string]string, what string) error {
+	if n.Kind == yaml.ScalarNode {
+		if !pattern.MatchString(n.Value) {
+			return nil
+		}
+		value, err := interpolate(n.Value, pattern, values, what)
+		if err != nil {
+			return &PosError{Line: n.Line, Column: n.Column, Err: err}
+		}
+		if n.Style == 0 && pattern.FindString(n.Value) == n.Value {
+			// let yaml resolve the type of the value
+			n.Tag = ""
+		}
+		n.Value = value
+		return nil
+	}
+	for _, child := range n.Content {
+		if err := interpolateNodeWith(child, pattern, values, what); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// interpolateDocument interpolates the project file
+// except its variables and environments.
+func interpolateDocument(doc *yaml.Node, vars map[string]string) error {
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return interpolateNode(doc, vars)
+	}
+	root := doc.Content[0]
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		switch root.Content[i].Value {
+		case "variables", "environments":
+			continue
+		}
+		if err := interpolateNode(root.Content[i+1], vars); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VarNames returns the names of the resolved variables, sorted.
+func (c Config) VarNames() []string {
+	names := make([]string, 0, len(c.Vars))
+	for name := range c.Vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}